@@ -2,6 +2,7 @@ package config
 
 import (
 	"gorgonia.org/tensor"
+	"time"
 )
 
 type FaceDetectionOutput struct {
@@ -69,13 +70,158 @@ func ConvertMetadataToTensors(meta *FaceLandmark) *tensor.Dense {
 
 // FaceAntiSpoofingVerify defines the structure of the face anti-spoofing check.
 type FaceAntiSpoofingVerify struct {
-	IsFaceMask        bool    `json:"is_face_mask"`        // IsFaceMask determines if the face is obstructed.
-	IsLiveness        bool    `json:"is_liveness"`         // IsLiveness determines if the face is real.
-	IsSamePerson      bool    `json:"is_same_person"`      // IsSamePerson determines if the face images belong to the same person.
-	ScoreMN           float32 `json:"score_mn"`            // ScoreMN is the similarity score between mid- and near- face image.
-	ScoreFM           float32 `json:"score_fm"`            // ScoreFM is the similarity score between far- and mid- face image.
-	LivenessScoreFull float32 `json:"liveness_score_full"` // LivenessScoreFull is the liveness score using full face model.
-	LivenessScoreCrop float32 `json:"liveness_score_crop"` // LivenessScoreCrop is the liveness score using crop face model.
-	SimilarityScore   float32 `json:"similarity_score"`    // SimilarityScore is the cosine similarity score between far-face and id card image.
-	FaceMaskScore     float32 `json:"face_mask_score"`     // FaceMaskScore is the obstruction score from the model.
+	IsFaceMask        bool              `json:"is_face_mask"`        // IsFaceMask determines if the face is obstructed.
+	IsLiveness        bool              `json:"is_liveness"`         // IsLiveness determines if the face is real.
+	IsSamePerson      bool              `json:"is_same_person"`      // IsSamePerson determines if the face images belong to the same person.
+	ScoreMN           float32           `json:"score_mn"`            // ScoreMN is the similarity score between mid- and near- face image.
+	ScoreFM           float32           `json:"score_fm"`            // ScoreFM is the similarity score between far- and mid- face image.
+	LivenessScoreFull float32           `json:"liveness_score_full"` // LivenessScoreFull is the liveness score using full face model.
+	LivenessScoreCrop float32           `json:"liveness_score_crop"` // LivenessScoreCrop is the liveness score using crop face model.
+	SimilarityScore   float32           `json:"similarity_score"`    // SimilarityScore is the cosine similarity score between far-face and id card image.
+	FaceMaskScore     float32           `json:"face_mask_score"`     // FaceMaskScore is the obstruction score from the model.
+	IsGoodQuality     bool              `json:"is_good_quality"`     // IsGoodQuality determines if every capture passes the local image quality check.
+	ImageQualities    []ImageQuality    `json:"image_qualities"`     // ImageQualities is the local image quality of the far-, mid- and near-face images.
+	LandmarkChecks    []LandmarkCheck   `json:"landmark_checks"`     // LandmarkChecks is the validation of the far-, mid- and near-face landmarks.
+	ModelVersions     map[string]string `json:"model_versions"`      // ModelVersions is the version of each model that served the check, by model name.
+}
+
+// LandmarkCheck defines the validation of client-supplied face landmarks.
+type LandmarkCheck struct {
+	IsProvided   bool    `json:"is_provided"`   // IsProvided determines if the landmarks were supplied by the client.
+	IsValid      bool    `json:"is_valid"`      // IsValid determines if the client landmarks passed every check.
+	IsRedetected bool    `json:"is_redetected"` // IsRedetected determines if the server-side detection replaced the client landmarks.
+	Deviation    float32 `json:"deviation"`     // Deviation is the mean client to server landmark distance over the server eye distance, -1 if not compared.
+	Reason       string  `json:"reason"`        // Reason describes why the client landmarks failed a check.
+}
+
+// VideoFrameSelection defines the frames selected from a video for the face anti-spoofing check.
+type VideoFrameSelection struct {
+	FarIndex        int     `json:"far_index"`         // FarIndex is the index of the far-distance frame in the video.
+	MidIndex        int     `json:"mid_index"`         // MidIndex is the index of the mid-distance frame in the video.
+	NearIndex       int     `json:"near_index"`        // NearIndex is the index of the near-distance frame in the video.
+	FarEyeDistance  float32 `json:"far_eye_distance"`  // FarEyeDistance is the inter-eye pixel distance of the far-distance frame.
+	MidEyeDistance  float32 `json:"mid_eye_distance"`  // MidEyeDistance is the inter-eye pixel distance of the mid-distance frame.
+	NearEyeDistance float32 `json:"near_eye_distance"` // NearEyeDistance is the inter-eye pixel distance of the near-distance frame.
+	NumCandidates   int     `json:"num_candidates"`    // NumCandidates is the number of sampled frames usable for selection.
+}
+
+// LivenessChallenge defines an action the user is asked to perform during active liveness verification.
+// Directions are expressed from the user's point of view on a non-mirrored capture.
+type LivenessChallenge string
+
+const (
+	LivenessChallengeTurnLeft  LivenessChallenge = "turn_left"
+	LivenessChallengeTurnRight LivenessChallenge = "turn_right"
+	LivenessChallengeNod       LivenessChallenge = "nod"
+	LivenessChallengeBlink     LivenessChallenge = "blink"
+	LivenessChallengeSmile     LivenessChallenge = "smile"
+)
+
+// LivenessChallenges lists every supported liveness challenge.
+var LivenessChallenges = []LivenessChallenge{
+	LivenessChallengeTurnLeft,
+	LivenessChallengeTurnRight,
+	LivenessChallengeNod,
+	LivenessChallengeBlink,
+	LivenessChallengeSmile,
+}
+
+// LivenessChallengeIssue defines a challenge sequence issued to the user. The challenge ID must be passed back with the
+// captured frames to verify the sequence.
+type LivenessChallengeIssue struct {
+	ID         string              `json:"id"`         // ID is the signed challenge ID.
+	Challenges []LivenessChallenge `json:"challenges"` // Challenges is the challenge sequence to perform in order.
+	ExpiresAt  time.Time           `json:"expires_at"` // ExpiresAt is the time after which the challenge ID is rejected.
+}
+
+// LivenessChallengeResult defines the structure of a single liveness challenge check.
+type LivenessChallengeResult struct {
+	Challenge  LivenessChallenge `json:"challenge"`   // Challenge is the requested action.
+	IsPassed   bool              `json:"is_passed"`   // IsPassed determines if the action was performed.
+	StartFrame int               `json:"start_frame"` // StartFrame is the index of the frame where the action started, -1 if not found.
+	EndFrame   int               `json:"end_frame"`   // EndFrame is the index of the frame where the action completed, -1 if not found.
+	StartTime  time.Duration     `json:"start_time"`  // StartTime is the timestamp of StartFrame.
+	EndTime    time.Duration     `json:"end_time"`    // EndTime is the timestamp of EndFrame.
+}
+
+// FaceActiveLivenessVerify defines the structure of the challenge-response liveness check.
+type FaceActiveLivenessVerify struct {
+	IsLiveness    bool                      `json:"is_liveness"`    // IsLiveness determines if every challenge was performed in order.
+	Challenges    []LivenessChallengeResult `json:"challenges"`     // Challenges is the per-challenge result.
+	ModelVersions map[string]string         `json:"model_versions"` // ModelVersions is the version of each model that served the check, by model name.
+}
+
+// HeadPose defines the head orientation in degrees.
+type HeadPose struct {
+	Yaw   float32 `json:"yaw"`   // Yaw is positive when the face turns towards the right of the image.
+	Pitch float32 `json:"pitch"` // Pitch is positive when the face looks down.
+	Roll  float32 `json:"roll"`  // Roll is positive when the face tilts clockwise in the image.
+}
+
+// AlignmentQuality defines the fit quality of the similarity transform aligning face landmarks to a template.
+type AlignmentQuality struct {
+	ReprojectionError float32 `json:"reprojection_error"` // ReprojectionError is the RMS distance in template pixels between the aligned landmarks and the template.
+	NormalizedError   float32 `json:"normalized_error"`   // NormalizedError is ReprojectionError over the eye distance of the template.
+	Scale             float32 `json:"scale"`              // Scale is the number of template pixels per image pixel.
+	Rotation          float32 `json:"rotation"`           // Rotation is the clockwise in-plane rotation of the face in the image in degrees.
+	NumInliers        int     `json:"num_inliers"`        // NumInliers is the number of landmarks kept by the robust estimation.
+	NumPoints         int     `json:"num_points"`         // NumPoints is the number of landmarks.
+}
+
+// ImageQuality defines the structure of the local image quality check of a capture.
+type ImageQuality struct {
+	IsAcceptable       bool     `json:"is_acceptable"`       // IsAcceptable determines if every metric is within its threshold.
+	Failures           []string `json:"failures,omitempty"`  // Failures lists the metrics outside their threshold.
+	Sharpness          float32  `json:"sharpness"`           // Sharpness is the variance of the Laplacian of the face region.
+	Brightness         float32  `json:"brightness"`          // Brightness is the mean gray level of the face region.
+	Contrast           float32  `json:"contrast"`            // Contrast is the gray level standard deviation of the face region.
+	OverexposureRatio  float32  `json:"overexposure_ratio"`  // OverexposureRatio is the ratio of saturated (glare) pixels in the face region.
+	UnderexposureRatio float32  `json:"underexposure_ratio"` // UnderexposureRatio is the ratio of black pixels in the face region.
+	Blockiness         float32  `json:"blockiness"`          // Blockiness is the ratio of gradients on 8x8 block borders to gradients inside blocks.
+	EyeDistance        float32  `json:"eye_distance"`        // EyeDistance is the inter-eye pixel distance.
+	FaceFrameRatio     float32  `json:"face_frame_ratio"`    // FaceFrameRatio is the ratio of the face area to the image area.
+}
+
+// FaceImageQualityComponents defines the 0-100 score of each component of the unified face image quality.
+type FaceImageQualityComponents struct {
+	Sharpness   float32 `json:"sharpness"`   // Sharpness is the focus score of the face region.
+	Exposure    float32 `json:"exposure"`    // Exposure is the brightness, glare and shadow score of the face region.
+	Contrast    float32 `json:"contrast"`    // Contrast is the dynamic range score of the face region.
+	Compression float32 `json:"compression"` // Compression is the JPEG artifact score of the image.
+	Resolution  float32 `json:"resolution"`  // Resolution is the inter-eye distance score.
+	Pose        float32 `json:"pose"`        // Pose is the frontal head pose score.
+	Detection   float32 `json:"detection"`   // Detection is the face detection confidence score.
+	Occlusion   float32 `json:"occlusion"`   // Occlusion is the unobstructed face score from the face quality model.
+}
+
+// FaceImageQuality defines the structure of the unified face image quality of a capture.
+type FaceImageQuality struct {
+	Score          float32                    `json:"score"`           // Score is the weighted 0-100 quality score.
+	Components     FaceImageQualityComponents `json:"components"`      // Components is the per-component score breakdown.
+	DetectionScore float32                    `json:"detection_score"` // DetectionScore is the face detector confidence.
+	CoverScore     float32                    `json:"cover_score"`     // CoverScore is the obstruction score from the face quality model.
+	HeadPose       HeadPose                   `json:"head_pose"`       // HeadPose is the estimated head pose.
+	ImageQuality   ImageQuality               `json:"image_quality"`   // ImageQuality is the local image quality metrics.
+}
+
+const (
+	ShadowCheckSamePerson   = "same_person"
+	ShadowCheckLivenessCrop = "liveness_crop"
+	ShadowCheckLivenessFull = "liveness_full"
+)
+
+// ShadowComparison defines the paired results of a primary model and its candidate evaluated in shadow on the same inputs.
+type ShadowComparison struct {
+	Check             string        `json:"check"`              // Check is the pipeline check, one of the ShadowCheck constants.
+	PrimaryModel      string        `json:"primary_model"`      // PrimaryModel is the name of the model serving the verdict.
+	PrimaryVersion    string        `json:"primary_version"`    // PrimaryVersion is the version of the primary model.
+	CandidateModel    string        `json:"candidate_model"`    // CandidateModel is the name of the model evaluated in shadow.
+	CandidateVersion  string        `json:"candidate_version"`  // CandidateVersion is the version of the candidate model.
+	PrimaryScores     []float32     `json:"primary_scores"`     // PrimaryScores are the scores of the primary model.
+	CandidateScores   []float32     `json:"candidate_scores"`   // CandidateScores are the scores of the candidate model, nil if it failed.
+	PrimaryDecision   bool          `json:"primary_decision"`   // PrimaryDecision is the decision of the primary model.
+	CandidateDecision bool          `json:"candidate_decision"` // CandidateDecision is the decision of the candidate model with its own thresholds.
+	IsDisagreement    bool          `json:"is_disagreement"`    // IsDisagreement determines if the decisions differ.
+	Latency           time.Duration `json:"latency"`            // Latency is the time taken by the candidate model.
+	Error             string        `json:"error"`              // Error is the failure of the candidate model, empty on success.
 }
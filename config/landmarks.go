@@ -0,0 +1,233 @@
+package config
+
+import (
+	"fmt"
+	"gorgonia.org/tensor"
+	"math"
+)
+
+// LandmarkLayout defines the point layout of a facial landmark model.
+type LandmarkLayout string
+
+const (
+	LandmarkLayout5   LandmarkLayout = "5"   // LandmarkLayout5 is the eyes, nose and mouth corners layout of the face detectors.
+	LandmarkLayout68  LandmarkLayout = "68"  // LandmarkLayout68 is the iBUG 300-W layout.
+	LandmarkLayout106 LandmarkLayout = "106" // LandmarkLayout106 is the InsightFace 2d106det layout.
+)
+
+// LandmarkGroup defines a semantic group of facial landmarks.
+// Left and right are from the image point of view, as in FaceLandmark.
+type LandmarkGroup string
+
+const (
+	LandmarkGroupJaw          LandmarkGroup = "jaw"
+	LandmarkGroupLeftEyebrow  LandmarkGroup = "left_eyebrow"
+	LandmarkGroupRightEyebrow LandmarkGroup = "right_eyebrow"
+	LandmarkGroupNose         LandmarkGroup = "nose"
+	LandmarkGroupLeftEye      LandmarkGroup = "left_eye"
+	LandmarkGroupRightEye     LandmarkGroup = "right_eye"
+	LandmarkGroupMouth        LandmarkGroup = "mouth"
+)
+
+// landmarkLayoutSpec defines the point count, semantic groups and 5-point anchors of a layout.
+// Eye centers of the 5-point representation are the mean of the eye anchors.
+type landmarkLayoutSpec struct {
+	numPoints  int
+	groups     map[LandmarkGroup][]int
+	leftEye    []int
+	rightEye   []int
+	nose       int
+	leftMouth  int
+	rightMouth int
+}
+
+func indexRange(start, end int) []int {
+	indices := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+var landmarkLayouts = map[LandmarkLayout]landmarkLayoutSpec{
+	LandmarkLayout5: {
+		numPoints: 5,
+		groups: map[LandmarkGroup][]int{
+			LandmarkGroupLeftEye:  {0},
+			LandmarkGroupRightEye: {1},
+			LandmarkGroupNose:     {2},
+			LandmarkGroupMouth:    {3, 4},
+		},
+		leftEye:    []int{0},
+		rightEye:   []int{1},
+		nose:       2,
+		leftMouth:  3,
+		rightMouth: 4,
+	},
+	LandmarkLayout68: {
+		numPoints: 68,
+		groups: map[LandmarkGroup][]int{
+			LandmarkGroupJaw:          indexRange(0, 17),
+			LandmarkGroupLeftEyebrow:  indexRange(17, 22),
+			LandmarkGroupRightEyebrow: indexRange(22, 27),
+			LandmarkGroupNose:         indexRange(27, 36),
+			LandmarkGroupLeftEye:      indexRange(36, 42),
+			LandmarkGroupRightEye:     indexRange(42, 48),
+			LandmarkGroupMouth:        indexRange(48, 68),
+		},
+		leftEye:    indexRange(36, 42),
+		rightEye:   indexRange(42, 48),
+		nose:       30,
+		leftMouth:  48,
+		rightMouth: 54,
+	},
+	LandmarkLayout106: {
+		numPoints: 106,
+		groups: map[LandmarkGroup][]int{
+			LandmarkGroupJaw:          indexRange(0, 33),
+			LandmarkGroupLeftEye:      indexRange(33, 43),
+			LandmarkGroupLeftEyebrow:  indexRange(43, 52),
+			LandmarkGroupMouth:        indexRange(52, 72),
+			LandmarkGroupNose:         indexRange(72, 87),
+			LandmarkGroupRightEye:     indexRange(87, 97),
+			LandmarkGroupRightEyebrow: indexRange(97, 106),
+		},
+		leftEye:    []int{38},
+		rightEye:   []int{88},
+		nose:       86,
+		leftMouth:  52,
+		rightMouth: 61,
+	},
+}
+
+// NumPoints returns the number of points of the layout, 0 if it is not supported.
+func (l LandmarkLayout) NumPoints() int {
+	return landmarkLayouts[l].numPoints
+}
+
+// Landmarks defines a set of facial landmarks in image pixel coordinates.
+type Landmarks struct {
+	Layout LandmarkLayout `json:"layout"` // Layout is the point layout.
+	Points []Coordinate2D `json:"points"` // Points is the landmark coordinates in layout order.
+}
+
+// NewLandmarks creates landmarks from interleaved (x, y) coordinates.
+func NewLandmarks(layout LandmarkLayout, coordinates []float32) (*Landmarks, error) {
+	spec, ok := landmarkLayouts[layout]
+	if !ok {
+		return nil, fmt.Errorf("unsupported landmark layout %q", layout)
+	}
+	if len(coordinates) != 2*spec.numPoints {
+		return nil, fmt.Errorf("landmark layout %s expects %d coordinates, got %d", layout, 2*spec.numPoints, len(coordinates))
+	}
+
+	points := make([]Coordinate2D, spec.numPoints)
+	for i := range points {
+		points[i] = Coordinate2D{X: coordinates[2*i], Y: coordinates[2*i+1]}
+	}
+	return &Landmarks{Layout: layout, Points: points}, nil
+}
+
+func (l *Landmarks) spec() (landmarkLayoutSpec, error) {
+	spec, ok := landmarkLayouts[l.Layout]
+	if !ok {
+		return spec, fmt.Errorf("unsupported landmark layout %q", l.Layout)
+	}
+	if len(l.Points) != spec.numPoints {
+		return spec, fmt.Errorf("landmark layout %s expects %d points, got %d", l.Layout, spec.numPoints, len(l.Points))
+	}
+	return spec, nil
+}
+
+// Group returns the points of a semantic group, or nil if the layout does not define it.
+func (l *Landmarks) Group(group LandmarkGroup) []Coordinate2D {
+	spec, err := l.spec()
+	if err != nil {
+		return nil
+	}
+	indices, ok := spec.groups[group]
+	if !ok {
+		return nil
+	}
+	points := make([]Coordinate2D, 0, len(indices))
+	for _, idx := range indices {
+		points = append(points, l.Points[idx])
+	}
+	return points
+}
+
+func (l *Landmarks) meanPoint(indices []int) Coordinate2D {
+	var center Coordinate2D
+	for _, idx := range indices {
+		center.X += l.Points[idx].X
+		center.Y += l.Points[idx].Y
+	}
+	center.X /= float32(len(indices))
+	center.Y /= float32(len(indices))
+	return center
+}
+
+// FaceLandmark converts the landmarks to the 5-point representation used for alignment.
+func (l *Landmarks) FaceLandmark() (*FaceLandmark, error) {
+	spec, err := l.spec()
+	if err != nil {
+		return nil, err
+	}
+	return &FaceLandmark{
+		LeftEye:    l.meanPoint(spec.leftEye),
+		RightEye:   l.meanPoint(spec.rightEye),
+		Nose:       l.Points[spec.nose],
+		LeftMouth:  l.Points[spec.leftMouth],
+		RightMouth: l.Points[spec.rightMouth],
+	}, nil
+}
+
+// Tensor5 converts the landmarks to the (5, 2) landmark tensor used for alignment.
+func (l *Landmarks) Tensor5() (*tensor.Dense, error) {
+	lmk, err := l.FaceLandmark()
+	if err != nil {
+		return nil, err
+	}
+	return ConvertMetadataToTensors(lmk), nil
+}
+
+// eyeAspectRatio returns the eyelid opening over the eye width of an eye contour. The width is the distance between
+// the two farthest points (the eye corners) and the opening is the extent of the contour perpendicular to it,
+// which makes the ratio independent of the point order of the layout.
+func eyeAspectRatio(eye []Coordinate2D) float32 {
+	var a, b Coordinate2D
+	var width float64
+	for i := range eye {
+		for j := i + 1; j < len(eye); j++ {
+			d := math.Hypot(float64(eye[i].X-eye[j].X), float64(eye[i].Y-eye[j].Y))
+			if d > width {
+				width, a, b = d, eye[i], eye[j]
+			}
+		}
+	}
+	if width == 0 {
+		return 0
+	}
+
+	// Unit normal of the corner line.
+	nx, ny := -float64(b.Y-a.Y)/width, float64(b.X-a.X)/width
+	lower, upper := math.Inf(1), math.Inf(-1)
+	for _, p := range eye {
+		d := float64(p.X-a.X)*nx + float64(p.Y-a.Y)*ny
+		lower, upper = math.Min(lower, d), math.Max(upper, d)
+	}
+	return float32((upper - lower) / width)
+}
+
+// EyeAspectRatio returns the mean eye aspect ratio of both eyes, which drops towards zero when the eyes close.
+// It requires a layout with eye contours.
+func (l *Landmarks) EyeAspectRatio() (float32, error) {
+	if _, err := l.spec(); err != nil {
+		return 0, err
+	}
+	leftEye, rightEye := l.Group(LandmarkGroupLeftEye), l.Group(LandmarkGroupRightEye)
+	if len(leftEye) < 4 || len(rightEye) < 4 {
+		return 0, fmt.Errorf("landmark layout %s has no eye contours", l.Layout)
+	}
+	return (eyeAspectRatio(leftEye) + eyeAspectRatio(rightEye)) / 2, nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+// genTestLandmarks68 returns iBUG 68-point coordinates with eyes of the given aspect ratio.
+func genTestLandmarks68(eyeRatio float64) []float32 {
+	coordinates := make([]float32, 136)
+	set := func(idx int, x, y float64) {
+		coordinates[2*idx], coordinates[2*idx+1] = float32(x), float32(y)
+	}
+	for i := 0; i < 68; i++ {
+		set(i, 100, 150)
+	}
+	// Eye contours start at the outer corner and go clockwise: corner, 2 upper lid points, corner, 2 lower lid points.
+	for e, cx := range []float64{70, 130} {
+		for k := 0; k < 6; k++ {
+			angle := math.Pi - float64(k)*math.Pi/3
+			set(36+6*e+k, cx+15*math.Cos(angle), 100-15*eyeRatio*math.Sin(angle))
+		}
+	}
+	set(30, 100, 140)
+	set(48, 75, 180)
+	set(54, 125, 180)
+	return coordinates
+}
+
+func TestLandmarks_FaceLandmark(t *testing.T) {
+	landmarks, err := NewLandmarks(LandmarkLayout68, genTestLandmarks68(0.6))
+	assert.NoError(t, err)
+	assert.Len(t, landmarks.Group(LandmarkGroupJaw), 17)
+	assert.Len(t, landmarks.Group(LandmarkGroupMouth), 20)
+
+	lmk, err := landmarks.FaceLandmark()
+	assert.NoError(t, err)
+	assert.InDelta(t, 70, lmk.LeftEye.X, 1e-4)
+	assert.InDelta(t, 100, lmk.LeftEye.Y, 1e-4)
+	assert.InDelta(t, 130, lmk.RightEye.X, 1e-4)
+	assert.Equal(t, Coordinate2D{X: 100, Y: 140}, lmk.Nose)
+	assert.Equal(t, Coordinate2D{X: 75, Y: 180}, lmk.LeftMouth)
+	assert.Equal(t, Coordinate2D{X: 125, Y: 180}, lmk.RightMouth)
+
+	lmkTensor, err := landmarks.Tensor5()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5, 2}, []int(lmkTensor.Shape()))
+
+	coordinates := make([]float32, 212)
+	coordinates[2*38], coordinates[2*88], coordinates[2*86] = 60, 140, 100
+	landmarks, err = NewLandmarks(LandmarkLayout106, coordinates)
+	assert.NoError(t, err)
+	lmk, err = landmarks.FaceLandmark()
+	assert.NoError(t, err)
+	assert.Equal(t, float32(60), lmk.LeftEye.X)
+	assert.Equal(t, float32(140), lmk.RightEye.X)
+	assert.Equal(t, float32(100), lmk.Nose.X)
+
+	_, err = NewLandmarks(LandmarkLayout68, coordinates)
+	assert.Error(t, err)
+	assert.Equal(t, 106, LandmarkLayout106.NumPoints())
+	assert.Equal(t, 0, LandmarkLayout("98").NumPoints())
+	_, err = NewLandmarks("98", coordinates)
+	assert.Error(t, err)
+}
+
+func TestLandmarks_EyeAspectRatio(t *testing.T) {
+	open, err := NewLandmarks(LandmarkLayout68, genTestLandmarks68(0.6))
+	assert.NoError(t, err)
+	closed, err := NewLandmarks(LandmarkLayout68, genTestLandmarks68(0.05))
+	assert.NoError(t, err)
+
+	openRatio, err := open.EyeAspectRatio()
+	assert.NoError(t, err)
+	closedRatio, err := closed.EyeAspectRatio()
+	assert.NoError(t, err)
+	// The lid points at 60 degrees span 2 * 15 * ratio * sin(60) over a 30 pixel width.
+	assert.InDelta(t, 0.6*math.Sin(math.Pi/3), openRatio, 1e-4)
+	assert.Less(t, closedRatio, openRatio/5)
+
+	five, err := NewLandmarks(LandmarkLayout5, make([]float32, 10))
+	assert.NoError(t, err)
+	_, err = five.EyeAspectRatio()
+	assert.Error(t, err)
+}
@@ -1,34 +1,197 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"slices"
+	"time"
+)
 
+const (
+	FaceDetectorSCRFD      = "scrfd"
+	FaceDetectorRetinaFace = "retinaface"
+	FaceDetectorYuNet      = "yunet"
+	FaceDetectorYOLOv8Face = "yolov8_face"
+)
+
+// Channel orders of the params. The ColorOrder of the params is the channel order of the model input, or of the images
+// analyzed by the clients without a model, RGB if empty. Clients with a SwapRB param swap the red and blue channels of their input images while normalizing, they
+// expect images in the other order.
+const (
+	ColorOrderRGB = "rgb"
+	ColorOrderBGR = "bgr"
+)
+
+// FaceDetectionParams defines the face detection model. With TryRotation, the pipeline detects the faces not found in an
+// image on the image rotated by 90, 180 and 270 degrees, and checks the pose and alignment of faces relative to their
+// upright orientation.
 type FaceDetectionParams struct {
-	ModelName string        `json:"model_name"`
-	Mean      float64       `json:"mean"`
-	Scale     float64       `json:"scale"`
-	Timeout   time.Duration `json:"timeout"`
+	ModelName      string                     `json:"model_name"`
+	ModelVersion   string                     `json:"model_version"`
+	Architecture   string                     `json:"architecture"`
+	Mean           [3]float64                 `json:"mean"`
+	Scale          [3]float64                 `json:"scale"`
+	ColorOrder     string                     `json:"color_order"`
+	SwapRB         bool                       `json:"swap_rb"`
+	CenterPad      bool                       `json:"center_pad"`
+	ScoreThreshold float32                    `json:"score_threshold"`
+	NMSThreshold   float32                    `json:"nms_threshold"`
+	Tiling         *FaceDetectionTilingParams `json:"tiling"`
+	Timeout        time.Duration              `json:"timeout"`
+	TryRotation    bool                       `json:"try_rotation"`
 }
 
+// FaceDetectionOptions holds the params of NewFaceDetectionParamsWithOptions other than the model name. A zero score threshold,
+// NMS threshold or timeout selects the value of DefaultFaceDetectionParams.
+type FaceDetectionOptions struct {
+	ModelVersion   string
+	Architecture   string
+	Mean           [3]float64
+	Scale          [3]float64
+	ColorOrder     string
+	SwapRB         bool
+	CenterPad      bool
+	ScoreThreshold float32
+	NMSThreshold   float32
+	Tiling         *FaceDetectionTilingParams
+	Timeout        time.Duration
+	TryRotation    bool
+}
+
+// NewFaceDetectionParams returns the params of an SCRFD model normalized with the same mean and scale on every channel,
+// with the thresholds of DefaultFaceDetectionParams.
 func NewFaceDetectionParams(modelName string, mean, scale float64, timeout time.Duration) *FaceDetectionParams {
-	return &FaceDetectionParams{
-		ModelName: modelName,
-		Mean:      mean,
-		Scale:     scale,
-		Timeout:   timeout,
+	return NewFaceDetectionParamsWithOptions(modelName, FaceDetectionOptions{
+		Architecture: FaceDetectorSCRFD,
+		Mean:         [3]float64{mean, mean, mean},
+		Scale:        [3]float64{scale, scale, scale},
+		Timeout:      timeout,
+	})
+}
+
+func NewFaceDetectionParamsWithOptions(modelName string, opts FaceDetectionOptions) *FaceDetectionParams {
+	params := &FaceDetectionParams{
+		ModelName:      modelName,
+		ModelVersion:   opts.ModelVersion,
+		Architecture:   opts.Architecture,
+		Mean:           opts.Mean,
+		Scale:          opts.Scale,
+		ColorOrder:     opts.ColorOrder,
+		SwapRB:         opts.SwapRB,
+		CenterPad:      opts.CenterPad,
+		ScoreThreshold: opts.ScoreThreshold,
+		NMSThreshold:   opts.NMSThreshold,
+		Tiling:         opts.Tiling,
+		Timeout:        opts.Timeout,
+		TryRotation:    opts.TryRotation,
+	}
+	if params.ScoreThreshold == 0 {
+		params.ScoreThreshold = DefaultFaceDetectionParams.ScoreThreshold
+	}
+	if params.NMSThreshold == 0 {
+		params.NMSThreshold = DefaultFaceDetectionParams.NMSThreshold
+	}
+	if params.Timeout == 0 {
+		params.Timeout = DefaultFaceDetectionParams.Timeout
 	}
+	return params
+}
+
+// FaceDetectionTilingParams defines the multi-scale sliding-tile detection of high-resolution images.
+// Each scale of the image pyramid is split into overlapping tiles detected separately, in addition to the whole image,
+// and detections are merged with NMS.
+type FaceDetectionTilingParams struct {
+	Scales       []float32 `json:"scales"`
+	TileSize     int       `json:"tile_size"`
+	TileOverlap  float32   `json:"tile_overlap"`
+	MinImageSide int       `json:"min_image_side"`
+}
+
+func NewFaceDetectionTilingParams(scales []float32, tileSize int, tileOverlap float32, minImageSide int) *FaceDetectionTilingParams {
+	return &FaceDetectionTilingParams{
+		Scales:       scales,
+		TileSize:     tileSize,
+		TileOverlap:  tileOverlap,
+		MinImageSide: minImageSide,
+	}
+}
+
+var DefaultFaceDetectionTilingParams = &FaceDetectionTilingParams{
+	Scales:       []float32{1, 0.5},
+	TileSize:     640,
+	TileOverlap:  0.25,
+	MinImageSide: 1280,
 }
 
 var DefaultFaceDetectionParams = &FaceDetectionParams{
-	ModelName: "scrfd",
-	Mean:      127.5,
-	Scale:     0.00784313725490196,
-	Timeout:   10 * time.Second,
+	ModelName:      "scrfd",
+	Architecture:   FaceDetectorSCRFD,
+	Mean:           [3]float64{127.5, 127.5, 127.5},
+	Scale:          [3]float64{0.00784313725490196, 0.00784313725490196, 0.00784313725490196},
+	ScoreThreshold: 0.3,
+	NMSThreshold:   0.4,
+	Timeout:        10 * time.Second,
+}
+
+var DefaultRetinaFaceDetectionParams = &FaceDetectionParams{
+	ModelName:      "retinaface",
+	Architecture:   FaceDetectorRetinaFace,
+	Mean:           [3]float64{104, 117, 123},
+	Scale:          [3]float64{1, 1, 1},
+	ColorOrder:     ColorOrderBGR,
+	SwapRB:         true,
+	ScoreThreshold: 0.3,
+	NMSThreshold:   0.4,
+	Timeout:        10 * time.Second,
+}
+
+var DefaultYuNetDetectionParams = &FaceDetectionParams{
+	ModelName:      "yunet",
+	Architecture:   FaceDetectorYuNet,
+	Mean:           [3]float64{0, 0, 0},
+	Scale:          [3]float64{1, 1, 1},
+	ColorOrder:     ColorOrderBGR,
+	SwapRB:         true,
+	ScoreThreshold: 0.3,
+	NMSThreshold:   0.3,
+	Timeout:        10 * time.Second,
+}
+
+var DefaultYOLOv8FaceDetectionParams = &FaceDetectionParams{
+	ModelName:      "yolov8_face",
+	Architecture:   FaceDetectorYOLOv8Face,
+	Mean:           [3]float64{0, 0, 0},
+	Scale:          [3]float64{1 / 255.0, 1 / 255.0, 1 / 255.0},
+	CenterPad:      true,
+	ScoreThreshold: 0.3,
+	NMSThreshold:   0.45,
+	Timeout:        10 * time.Second,
+}
+
+// FaceDetectionParamsByArchitecture returns a copy of the default params of a face detector architecture.
+func FaceDetectionParamsByArchitecture(architecture string) (*FaceDetectionParams, error) {
+	var params *FaceDetectionParams
+	switch architecture {
+	case FaceDetectorSCRFD:
+		params = DefaultFaceDetectionParams
+	case FaceDetectorRetinaFace:
+		params = DefaultRetinaFaceDetectionParams
+	case FaceDetectorYuNet:
+		params = DefaultYuNetDetectionParams
+	case FaceDetectorYOLOv8Face:
+		params = DefaultYOLOv8FaceDetectionParams
+	default:
+		return nil, fmt.Errorf("unsupported face detector architecture %q", architecture)
+	}
+	paramsCopy := *params
+	return &paramsCopy, nil
 }
 
 type FaceIDParams struct {
 	ModelName           string        `json:"model_name"`
+	ModelVersion        string        `json:"model_version"`
 	Mean                float64       `json:"mean"`
 	Scale               float64       `json:"scale"`
+	ColorOrder          string        `json:"color_order"`
 	ThresholdSameEKYC   float32       `json:"threshold_same_ekyc"`
 	ThresholdSamePerson float32       `json:"threshold_same_person"`
 	ImgSize             int           `json:"img_size"`
@@ -88,8 +251,10 @@ func NewFaceAttributeParams(modelName string, mean, scale float64, thresholdFace
 
 type FaceQualityParams struct {
 	ModelName      string        `json:"model_name"`
+	ModelVersion   string        `json:"model_version"`
 	Mean           [3]float64    `json:"mean"`
 	Scale          [3]float64    `json:"scale"`
+	ColorOrder     string        `json:"color_order"`
 	ThresholdCover float64       `json:"threshold_cover"`
 	ThresholdAll   float64       `json:"threshold_all"`
 	ImgSize        int           `json:"img_size"`
@@ -118,13 +283,55 @@ func NewFaceQualityParams(modelName string, mean, scale [3]float64, thresholdCov
 	}
 }
 
+type FaceLandmarkParams struct {
+	ModelName        string         `json:"model_name"`
+	ModelVersion     string         `json:"model_version"`
+	Layout           LandmarkLayout `json:"layout"`
+	Mean             [3]float64     `json:"mean"`
+	Scale            [3]float64     `json:"scale"`
+	ColorOrder       string         `json:"color_order"`
+	SwapRB           bool           `json:"swap_rb"`
+	ImgSize          int            `json:"img_size"`
+	CropScale        float32        `json:"crop_scale"`
+	IsCenteredOutput bool           `json:"is_centered_output"`
+	Timeout          time.Duration  `json:"timeout"`
+}
+
+var DefaultFaceLandmarkParams = &FaceLandmarkParams{
+	ModelName:        "2d106det",
+	Layout:           LandmarkLayout106,
+	Mean:             [3]float64{0, 0, 0},
+	Scale:            [3]float64{1, 1, 1},
+	ImgSize:          192,
+	CropScale:        1.5,
+	IsCenteredOutput: true,
+	Timeout:          10 * time.Second,
+}
+
+func NewFaceLandmarkParams(modelName, modelVersion string, layout LandmarkLayout, mean, scale [3]float64, swapRB bool, imgSize int, cropScale float32, isCenteredOutput bool, timeout time.Duration) *FaceLandmarkParams {
+	return &FaceLandmarkParams{
+		ModelName:        modelName,
+		ModelVersion:     modelVersion,
+		Layout:           layout,
+		Mean:             mean,
+		Scale:            scale,
+		SwapRB:           swapRB,
+		ImgSize:          imgSize,
+		CropScale:        cropScale,
+		IsCenteredOutput: isCenteredOutput,
+		Timeout:          timeout,
+	}
+}
+
 type FaceAntiSpoofingParams struct {
-	ModelName string        `json:"model_name"`
-	Mean      [3]float64    `json:"mean"`
-	STD       [3]float64    `json:"std"`
-	Threshold float32       `json:"threshold"`
-	ImgSize   int           `json:"img_size"`
-	Timeout   time.Duration `json:"timeout"`
+	ModelName    string        `json:"model_name"`
+	ModelVersion string        `json:"model_version"`
+	Mean         [3]float64    `json:"mean"`
+	STD          [3]float64    `json:"std"`
+	ColorOrder   string        `json:"color_order"`
+	Threshold    float32       `json:"threshold"`
+	ImgSize      int           `json:"img_size"`
+	Timeout      time.Duration `json:"timeout"`
 }
 
 var DefaultCropFaceAntiSpoofingParams = &FaceAntiSpoofingParams{
@@ -155,3 +362,431 @@ func NewFaceAntiSpoofingParams(modelName string, mean, std [3]float64, threshold
 		Timeout:   timeout,
 	}
 }
+
+type VideoFrameSelectionParams struct {
+	SampleStep       int     `json:"sample_step"`
+	MaxFrames        int     `json:"max_frames"`
+	MinEyeDistance   float32 `json:"min_eye_distance"`
+	MinSharpness     float64 `json:"min_sharpness"`
+	MinDistanceRatio float32 `json:"min_distance_ratio"`
+}
+
+var DefaultVideoFrameSelectionParams = &VideoFrameSelectionParams{
+	SampleStep:       3,
+	MaxFrames:        60,
+	MinEyeDistance:   20,
+	MinSharpness:     10,
+	MinDistanceRatio: 1.2,
+}
+
+func NewVideoFrameSelectionParams(sampleStep, maxFrames int, minEyeDistance float32, minSharpness float64, minDistanceRatio float32) *VideoFrameSelectionParams {
+	return &VideoFrameSelectionParams{
+		SampleStep:       sampleStep,
+		MaxFrames:        maxFrames,
+		MinEyeDistance:   minEyeDistance,
+		MinSharpness:     minSharpness,
+		MinDistanceRatio: minDistanceRatio,
+	}
+}
+
+// LivenessChallengeParams defines the issue and the verification of active liveness challenges.
+// Issued challenges are identified by a challenge ID signed with SecretKey, valid for ChallengeTTL and verified once.
+// A random key is generated by each challenge store if SecretKey is empty, challenges are then only verified by the
+// clients sharing the store of the client that issued them.
+type LivenessChallengeParams struct {
+	NumChallenges  int           `json:"num_challenges"`
+	YawThreshold   float32       `json:"yaw_threshold"`
+	PitchThreshold float32       `json:"pitch_threshold"`
+	BlinkRatio     float32       `json:"blink_ratio"`
+	SmileRatio     float32       `json:"smile_ratio"`
+	BaselineFrames int           `json:"baseline_frames"`
+	MaxDuration    time.Duration `json:"max_duration"`
+	ChallengeTTL   time.Duration `json:"challenge_ttl"`
+	SecretKey      string        `json:"secret_key"`
+	ColorOrder     string        `json:"color_order"`
+}
+
+var DefaultLivenessChallengeParams = &LivenessChallengeParams{
+	NumChallenges:  3,
+	YawThreshold:   20,
+	PitchThreshold: 12,
+	BlinkRatio:     0.6,
+	SmileRatio:     1.15,
+	BaselineFrames: 5,
+	MaxDuration:    5 * time.Second,
+	ChallengeTTL:   2 * time.Minute,
+}
+
+func NewLivenessChallengeParams(numChallenges int, yawThreshold, pitchThreshold, blinkRatio, smileRatio float32, baselineFrames int, maxDuration, challengeTTL time.Duration, secretKey string) *LivenessChallengeParams {
+	return &LivenessChallengeParams{
+		NumChallenges:  numChallenges,
+		YawThreshold:   yawThreshold,
+		PitchThreshold: pitchThreshold,
+		BlinkRatio:     blinkRatio,
+		SmileRatio:     smileRatio,
+		BaselineFrames: baselineFrames,
+		MaxDuration:    maxDuration,
+		ChallengeTTL:   challengeTTL,
+		SecretKey:      secretKey,
+	}
+}
+
+type AlignmentParams struct {
+	MaxReprojectionError float32 `json:"max_reprojection_error"`
+	MinInlierRatio       float32 `json:"min_inlier_ratio"`
+	MinScale             float32 `json:"min_scale"`
+	MaxScale             float32 `json:"max_scale"`
+	MaxRotation          float32 `json:"max_rotation"`
+}
+
+var DefaultAlignmentParams = &AlignmentParams{
+	MaxReprojectionError: 0.25,
+	MinInlierRatio:       0.6,
+	MinScale:             0,
+	MaxScale:             4,
+	MaxRotation:          60,
+}
+
+func NewAlignmentParams(maxReprojectionError, minInlierRatio, minScale, maxScale, maxRotation float32) *AlignmentParams {
+	return &AlignmentParams{
+		MaxReprojectionError: maxReprojectionError,
+		MinInlierRatio:       minInlierRatio,
+		MinScale:             minScale,
+		MaxScale:             maxScale,
+		MaxRotation:          maxRotation,
+	}
+}
+
+// LandmarkValidationMode defines how client-supplied landmarks are checked.
+type LandmarkValidationMode string
+
+const (
+	LandmarkValidationGeometry LandmarkValidationMode = "geometry" // LandmarkValidationGeometry only checks that the landmarks lie inside the image and form a face.
+	LandmarkValidationVerify   LandmarkValidationMode = "verify"   // LandmarkValidationVerify also rejects landmarks deviating from a server-side detection.
+	LandmarkValidationRedetect LandmarkValidationMode = "redetect" // LandmarkValidationRedetect always uses the server-side detection and only reports the deviation.
+)
+
+type LandmarkValidationParams struct {
+	Mode           LandmarkValidationMode `json:"mode"`
+	MaxDeviation   float32                `json:"max_deviation"`
+	MinEyeDistance float32                `json:"min_eye_distance"`
+}
+
+var DefaultLandmarkValidationParams = &LandmarkValidationParams{
+	Mode:           LandmarkValidationVerify,
+	MaxDeviation:   0.25,
+	MinEyeDistance: 8,
+}
+
+func NewLandmarkValidationParams(mode LandmarkValidationMode, maxDeviation, minEyeDistance float32) *LandmarkValidationParams {
+	return &LandmarkValidationParams{
+		Mode:           mode,
+		MaxDeviation:   maxDeviation,
+		MinEyeDistance: minEyeDistance,
+	}
+}
+
+type HeadPoseParams struct {
+	MaxYaw   float32 `json:"max_yaw"`
+	MaxPitch float32 `json:"max_pitch"`
+	MaxRoll  float32 `json:"max_roll"`
+}
+
+var DefaultHeadPoseParams = &HeadPoseParams{
+	MaxYaw:   35,
+	MaxPitch: 30,
+	MaxRoll:  30,
+}
+
+func NewHeadPoseParams(maxYaw, maxPitch, maxRoll float32) *HeadPoseParams {
+	return &HeadPoseParams{
+		MaxYaw:   maxYaw,
+		MaxPitch: maxPitch,
+		MaxRoll:  maxRoll,
+	}
+}
+
+type ImageQualityParams struct {
+	MinSharpness      float32 `json:"min_sharpness"`
+	MinBrightness     float32 `json:"min_brightness"`
+	MaxBrightness     float32 `json:"max_brightness"`
+	MinContrast       float32 `json:"min_contrast"`
+	MaxOverexposure   float32 `json:"max_overexposure"`
+	MaxUnderexposure  float32 `json:"max_underexposure"`
+	MaxBlockiness     float32 `json:"max_blockiness"`
+	MinEyeDistance    float32 `json:"min_eye_distance"`
+	MinFaceFrameRatio float32 `json:"min_face_frame_ratio"`
+	ColorOrder        string  `json:"color_order"`
+}
+
+var DefaultImageQualityParams = &ImageQualityParams{
+	MinSharpness:      50,
+	MinBrightness:     60,
+	MaxBrightness:     200,
+	MinContrast:       25,
+	MaxOverexposure:   0.1,
+	MaxUnderexposure:  0.2,
+	MaxBlockiness:     1.6,
+	MinEyeDistance:    40,
+	MinFaceFrameRatio: 0.02,
+}
+
+func NewImageQualityParams(minSharpness, minBrightness, maxBrightness, minContrast, maxOverexposure, maxUnderexposure, maxBlockiness, minEyeDistance, minFaceFrameRatio float32) *ImageQualityParams {
+	return &ImageQualityParams{
+		MinSharpness:      minSharpness,
+		MinBrightness:     minBrightness,
+		MaxBrightness:     maxBrightness,
+		MinContrast:       minContrast,
+		MaxOverexposure:   maxOverexposure,
+		MaxUnderexposure:  maxUnderexposure,
+		MaxBlockiness:     maxBlockiness,
+		MinEyeDistance:    minEyeDistance,
+		MinFaceFrameRatio: minFaceFrameRatio,
+	}
+}
+
+type FaceImageQualityParams struct {
+	WeightSharpness   float32 `json:"weight_sharpness"`
+	WeightExposure    float32 `json:"weight_exposure"`
+	WeightContrast    float32 `json:"weight_contrast"`
+	WeightCompression float32 `json:"weight_compression"`
+	WeightResolution  float32 `json:"weight_resolution"`
+	WeightPose        float32 `json:"weight_pose"`
+	WeightDetection   float32 `json:"weight_detection"`
+	WeightOcclusion   float32 `json:"weight_occlusion"`
+	TargetSharpness   float32 `json:"target_sharpness"`
+	TargetContrast    float32 `json:"target_contrast"`
+	TargetEyeDistance float32 `json:"target_eye_distance"`
+	MaxBlockiness     float32 `json:"max_blockiness"`
+}
+
+var DefaultFaceImageQualityParams = &FaceImageQualityParams{
+	WeightSharpness:   0.15,
+	WeightExposure:    0.15,
+	WeightContrast:    0.05,
+	WeightCompression: 0.05,
+	WeightResolution:  0.1,
+	WeightPose:        0.2,
+	WeightDetection:   0.1,
+	WeightOcclusion:   0.2,
+	TargetSharpness:   300,
+	TargetContrast:    50,
+	TargetEyeDistance: 90,
+	MaxBlockiness:     2,
+}
+
+func NewFaceImageQualityParams(weightSharpness, weightExposure, weightContrast, weightCompression, weightResolution, weightPose, weightDetection, weightOcclusion, targetSharpness, targetContrast, targetEyeDistance, maxBlockiness float32) *FaceImageQualityParams {
+	return &FaceImageQualityParams{
+		WeightSharpness:   weightSharpness,
+		WeightExposure:    weightExposure,
+		WeightContrast:    weightContrast,
+		WeightCompression: weightCompression,
+		WeightResolution:  weightResolution,
+		WeightPose:        weightPose,
+		WeightDetection:   weightDetection,
+		WeightOcclusion:   weightOcclusion,
+		TargetSharpness:   targetSharpness,
+		TargetContrast:    targetContrast,
+		TargetEyeDistance: targetEyeDistance,
+		MaxBlockiness:     maxBlockiness,
+	}
+}
+
+const (
+	InferenceBalancingRoundRobin       = "round_robin"
+	InferenceBalancingLeastOutstanding = "least_outstanding"
+)
+
+// InferencePoolParams defines how inference calls are spread across Triton endpoints.
+// Endpoints failing a health check are taken out of rotation until they pass a health check or serve a call again.
+// Calls failing with an unavailability error take their endpoint out of rotation at once, other errors worth a
+// failover only after EvictionFailures consecutive failures, never if zero.
+// Models lists the models that must be ready on an endpoint for it to be healthy, only the server readiness is
+// checked if empty.
+type InferencePoolParams struct {
+	Balancing           string        `json:"balancing"`
+	HealthCheckInterval time.Duration `json:"health_check_interval"`
+	HealthCheckTimeout  time.Duration `json:"health_check_timeout"`
+	MaxAttempts         int           `json:"max_attempts"`
+	EvictionFailures    int           `json:"eviction_failures"`
+	Models              []string      `json:"models"`
+}
+
+var DefaultInferencePoolParams = &InferencePoolParams{
+	Balancing:           InferenceBalancingLeastOutstanding,
+	HealthCheckInterval: 5 * time.Second,
+	HealthCheckTimeout:  time.Second,
+	MaxAttempts:         3,
+	EvictionFailures:    3,
+}
+
+func NewInferencePoolParams(balancing string, healthCheckInterval, healthCheckTimeout time.Duration, maxAttempts, evictionFailures int, models []string) *InferencePoolParams {
+	return &InferencePoolParams{
+		Balancing:           balancing,
+		HealthCheckInterval: healthCheckInterval,
+		HealthCheckTimeout:  healthCheckTimeout,
+		MaxAttempts:         maxAttempts,
+		EvictionFailures:    evictionFailures,
+		Models:              models,
+	}
+}
+
+// InferenceResilienceParams defines the retries, circuit breakers and hedged requests of inference calls.
+// Retries back off exponentially with full jitter from InitialBackoff up to MaxBackoff. A circuit breaker opens
+// after BreakerFailures consecutive failures of a model, rejects its calls for BreakerCooldown, then lets a probe
+// through. Once HedgeMinSamples latencies of a model are known, a second request is sent if the first has not
+// returned after the HedgePercentile latency. Zero MaxRetries, BreakerFailures or HedgePercentile disable each.
+type InferenceResilienceParams struct {
+	MaxRetries        int           `json:"max_retries"`
+	InitialBackoff    time.Duration `json:"initial_backoff"`
+	MaxBackoff        time.Duration `json:"max_backoff"`
+	BackoffMultiplier float64       `json:"backoff_multiplier"`
+	BreakerFailures   int           `json:"breaker_failures"`
+	BreakerCooldown   time.Duration `json:"breaker_cooldown"`
+	HedgePercentile   float64       `json:"hedge_percentile"`
+	HedgeMinSamples   int           `json:"hedge_min_samples"`
+}
+
+var DefaultInferenceResilienceParams = &InferenceResilienceParams{
+	MaxRetries:        2,
+	InitialBackoff:    50 * time.Millisecond,
+	MaxBackoff:        time.Second,
+	BackoffMultiplier: 2,
+	BreakerFailures:   5,
+	BreakerCooldown:   10 * time.Second,
+	HedgePercentile:   0,
+	HedgeMinSamples:   50,
+}
+
+func NewInferenceResilienceParams(maxRetries int, initialBackoff, maxBackoff time.Duration, backoffMultiplier float64, breakerFailures int, breakerCooldown time.Duration, hedgePercentile float64, hedgeMinSamples int) *InferenceResilienceParams {
+	return &InferenceResilienceParams{
+		MaxRetries:        maxRetries,
+		InitialBackoff:    initialBackoff,
+		MaxBackoff:        maxBackoff,
+		BackoffMultiplier: backoffMultiplier,
+		BreakerFailures:   breakerFailures,
+		BreakerCooldown:   breakerCooldown,
+		HedgePercentile:   hedgePercentile,
+		HedgeMinSamples:   hedgeMinSamples,
+	}
+}
+
+// EKYCPipelineParams defines the models, versions and thresholds of every client of an EKYC pipeline. The optional
+// FaceLandmark params enable the dense landmark client if set, the landmarks are checked against
+// DefaultAlignmentParams if Alignment is nil. Empty model versions let Triton serve the latest version of each model.
+type EKYCPipelineParams struct {
+	FaceID             *FaceIDParams             `json:"face_id"`
+	FaceQuality        *FaceQualityParams        `json:"face_quality"`
+	FullFaceAS         *FaceAntiSpoofingParams   `json:"full_face_anti_spoofing"`
+	CropFaceAS         *FaceAntiSpoofingParams   `json:"crop_face_anti_spoofing"`
+	FaceDetection      *FaceDetectionParams      `json:"face_detection"`
+	FaceLandmark       *FaceLandmarkParams       `json:"face_landmark"`
+	LivenessChallenge  *LivenessChallengeParams  `json:"liveness_challenge"`
+	HeadPose           *HeadPoseParams           `json:"head_pose"`
+	ImageQuality       *ImageQualityParams       `json:"image_quality"`
+	FaceImageQuality   *FaceImageQualityParams   `json:"face_image_quality"`
+	LandmarkValidation *LandmarkValidationParams `json:"landmark_validation"`
+	Alignment          *AlignmentParams          `json:"alignment"`
+	Shadow             *ShadowParams             `json:"shadow"`
+}
+
+var DefaultEKYCPipelineParams = &EKYCPipelineParams{
+	FaceID:             DefaultFaceIDParams,
+	FaceQuality:        DefaultFaceQualityParams,
+	FullFaceAS:         DefaultFullFaceAntiSpoofingParams,
+	CropFaceAS:         DefaultCropFaceAntiSpoofingParams,
+	FaceDetection:      DefaultFaceDetectionParams,
+	LivenessChallenge:  DefaultLivenessChallengeParams,
+	HeadPose:           DefaultHeadPoseParams,
+	ImageQuality:       DefaultImageQualityParams,
+	FaceImageQuality:   DefaultFaceImageQualityParams,
+	LandmarkValidation: DefaultLandmarkValidationParams,
+}
+
+func NewEKYCPipelineParams(faceID *FaceIDParams, faceQuality *FaceQualityParams, fullFaceAS, cropFaceAS *FaceAntiSpoofingParams, faceDetection *FaceDetectionParams, faceLandmark *FaceLandmarkParams, livenessChallenge *LivenessChallengeParams, headPose *HeadPoseParams, imageQuality *ImageQualityParams, faceImageQuality *FaceImageQualityParams, landmarkValidation *LandmarkValidationParams, alignment *AlignmentParams, shadow *ShadowParams) *EKYCPipelineParams {
+	return &EKYCPipelineParams{
+		FaceID:             faceID,
+		FaceQuality:        faceQuality,
+		FullFaceAS:         fullFaceAS,
+		CropFaceAS:         cropFaceAS,
+		FaceDetection:      faceDetection,
+		FaceLandmark:       faceLandmark,
+		LivenessChallenge:  livenessChallenge,
+		HeadPose:           headPose,
+		ImageQuality:       imageQuality,
+		FaceImageQuality:   faceImageQuality,
+		LandmarkValidation: landmarkValidation,
+		Alignment:          alignment,
+		Shadow:             shadow,
+	}
+}
+
+// Clone returns a deep copy of the params, so that a copy can be changed and switched to while the original serves
+// requests. Nil params stay nil.
+func (p *EKYCPipelineParams) Clone() *EKYCPipelineParams {
+	clone := *p
+	clone.FaceID = clonePtr(p.FaceID)
+	clone.FaceQuality = clonePtr(p.FaceQuality)
+	clone.FullFaceAS = clonePtr(p.FullFaceAS)
+	clone.CropFaceAS = clonePtr(p.CropFaceAS)
+	clone.FaceDetection = clonePtr(p.FaceDetection)
+	if clone.FaceDetection != nil && clone.FaceDetection.Tiling != nil {
+		clone.FaceDetection.Tiling = clonePtr(clone.FaceDetection.Tiling)
+		clone.FaceDetection.Tiling.Scales = slices.Clone(clone.FaceDetection.Tiling.Scales)
+	}
+	clone.FaceLandmark = clonePtr(p.FaceLandmark)
+	clone.LivenessChallenge = clonePtr(p.LivenessChallenge)
+	clone.HeadPose = clonePtr(p.HeadPose)
+	clone.ImageQuality = clonePtr(p.ImageQuality)
+	clone.FaceImageQuality = clonePtr(p.FaceImageQuality)
+	clone.LandmarkValidation = clonePtr(p.LandmarkValidation)
+	clone.Alignment = clonePtr(p.Alignment)
+	if p.Shadow != nil {
+		clone.Shadow = p.Shadow.Clone()
+	}
+	return &clone
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	return &clone
+}
+
+// ShadowParams defines the candidate models evaluated in shadow on live traffic. Candidates run asynchronously on the
+// inputs of their primary model, in SampleRate of the checks, and never affect the verdicts. Checks are skipped rather
+// than queued once MaxPending evaluations are running. Nil candidate params leave their model out of the evaluation.
+type ShadowParams struct {
+	FaceID     *FaceIDParams           `json:"face_id"`
+	FullFaceAS *FaceAntiSpoofingParams `json:"full_face_anti_spoofing"`
+	CropFaceAS *FaceAntiSpoofingParams `json:"crop_face_anti_spoofing"`
+	SampleRate float64                 `json:"sample_rate"`
+	MaxPending int                     `json:"max_pending"`
+}
+
+// DefaultShadowParams evaluates no candidate, candidates are set on a copy.
+var DefaultShadowParams = &ShadowParams{
+	SampleRate: 1.0,
+	MaxPending: 16,
+}
+
+func NewShadowParams(faceID *FaceIDParams, fullFaceAS, cropFaceAS *FaceAntiSpoofingParams, sampleRate float64, maxPending int) *ShadowParams {
+	return &ShadowParams{
+		FaceID:     faceID,
+		FullFaceAS: fullFaceAS,
+		CropFaceAS: cropFaceAS,
+		SampleRate: sampleRate,
+		MaxPending: maxPending,
+	}
+}
+
+// Clone returns a deep copy of the params, the candidate params included.
+func (p *ShadowParams) Clone() *ShadowParams {
+	clone := *p
+	clone.FaceID = clonePtr(p.FaceID)
+	clone.FullFaceAS = clonePtr(p.FullFaceAS)
+	clone.CropFaceAS = clonePtr(p.CropFaceAS)
+	return &clone
+}
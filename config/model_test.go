@@ -0,0 +1,68 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFaceDetectionParamsByArchitecture(t *testing.T) {
+	for _, architecture := range []string{FaceDetectorSCRFD, FaceDetectorRetinaFace, FaceDetectorYuNet, FaceDetectorYOLOv8Face} {
+		params, err := FaceDetectionParamsByArchitecture(architecture)
+		assert.NoError(t, err)
+		assert.Equal(t, architecture, params.Architecture)
+	}
+
+	params, err := FaceDetectionParamsByArchitecture(FaceDetectorYuNet)
+	assert.NoError(t, err)
+	params.ScoreThreshold = 0.9
+	assert.NotEqual(t, params.ScoreThreshold, DefaultYuNetDetectionParams.ScoreThreshold)
+
+	_, err = FaceDetectionParamsByArchitecture("mtcnn")
+	assert.Error(t, err)
+}
+
+func TestNewFaceDetectionParams(t *testing.T) {
+	params := NewFaceDetectionParams("scrfd", 127.5, 0.00784313725490196, 10*time.Second)
+	assert.Equal(t, DefaultFaceDetectionParams, params)
+}
+
+func TestNewFaceDetectionParamsWithOptions(t *testing.T) {
+	params := NewFaceDetectionParamsWithOptions("yunet", FaceDetectionOptions{Architecture: FaceDetectorYuNet, ScoreThreshold: 0.6})
+	assert.Equal(t, "yunet", params.ModelName)
+	assert.Equal(t, FaceDetectorYuNet, params.Architecture)
+	assert.Equal(t, float32(0.6), params.ScoreThreshold)
+	assert.Equal(t, DefaultFaceDetectionParams.NMSThreshold, params.NMSThreshold)
+	assert.Equal(t, DefaultFaceDetectionParams.Timeout, params.Timeout)
+}
+
+func TestEKYCPipelineParams_Clone(t *testing.T) {
+	params := DefaultEKYCPipelineParams.Clone()
+	assert.Equal(t, DefaultEKYCPipelineParams, params)
+
+	params.FaceID.ModelVersion = "2"
+	params.FullFaceAS.Threshold = 0.9
+	assert.Empty(t, DefaultFaceIDParams.ModelVersion)
+	assert.NotEqual(t, params.FullFaceAS.Threshold, DefaultFullFaceAntiSpoofingParams.Threshold)
+	assert.Nil(t, params.FaceLandmark)
+	assert.Nil(t, params.Alignment)
+
+	params.Alignment = NewAlignmentParams(0.2, 0.6, 0.5, 2, 45)
+	clone := params.Clone()
+	clone.Alignment.MaxRotation = 30
+	assert.Equal(t, float32(45), params.Alignment.MaxRotation)
+
+	params.FaceDetection.Tiling = NewFaceDetectionTilingParams([]float32{1, 0.5}, 640, 0.25, 1280)
+	clone = params.Clone()
+	clone.FaceDetection.Tiling.Scales[0] = 2
+	assert.Equal(t, float32(1), params.FaceDetection.Tiling.Scales[0])
+
+	params.Shadow = DefaultShadowParams.Clone()
+	params.Shadow.FaceID = DefaultFaceIDParams
+	clone = params.Clone()
+	clone.Shadow.FaceID.ModelVersion = "3"
+	clone.Shadow.SampleRate = 0.1
+	assert.Empty(t, DefaultFaceIDParams.ModelVersion)
+	assert.Equal(t, 1.0, params.Shadow.SampleRate)
+	assert.Nil(t, clone.Shadow.CropFaceAS)
+}
@@ -0,0 +1,68 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"gocv.io/x/gocv"
+)
+
+// ColorOrderer is implemented by the clients reading image pixels. ColorOrder returns the color order of the images
+// the client expects, which is the order its preprocessing and normalization parameters assume.
+type ColorOrderer interface {
+	ColorOrder() utils.ColorOrder
+}
+
+// paramsColorOrder returns the color order of the images expected by a client from the color order of its params, RGB
+// if empty, and the other order if the client swaps the red and blue channels while normalizing.
+func paramsColorOrder(order string, swapRB bool) (utils.ColorOrder, error) {
+	var imageOrder utils.ColorOrder
+	switch order {
+	case "", config.ColorOrderRGB:
+		imageOrder = utils.ColorOrderRGB
+	case config.ColorOrderBGR:
+		imageOrder = utils.ColorOrderBGR
+	default:
+		return "", fmt.Errorf("unsupported color order %q", order)
+	}
+	if !swapRB {
+		return imageOrder, nil
+	}
+	if imageOrder == utils.ColorOrderRGB {
+		return utils.ColorOrderBGR, nil
+	}
+	return utils.ColorOrderRGB, nil
+}
+
+// grayConversion returns the OpenCV conversion code of RGB or BGR images to gray.
+func grayConversion(order utils.ColorOrder) gocv.ColorConversionCode {
+	if order == utils.ColorOrderBGR {
+		return gocv.ColorBGRToGray
+	}
+	return gocv.ColorRGBToGray
+}
+
+// PrepareImages returns the Mats of the images in the color order expected by the client. Images already in that
+// order are returned as is, converted Mats are owned by scope.
+func PrepareImages(client ColorOrderer, scope *utils.MatScope, images ...utils.Image) ([]gocv.Mat, error) {
+	order := client.ColorOrder()
+	mats := make([]gocv.Mat, len(images))
+	for idx := range images {
+		mat, err := images[idx].As(order, scope)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", idx, err)
+		}
+		mats[idx] = mat
+	}
+	return mats, nil
+}
+
+// CheckColorOrders checks that the clients sharing the same input images expect the same color order.
+func CheckColorOrders(order utils.ColorOrder, clients ...ColorOrderer) error {
+	for _, client := range clients {
+		if clientOrder := client.ColorOrder(); clientOrder != order {
+			return fmt.Errorf("%T expects %s images, got %s", client, clientOrder, order)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"testing"
+)
+
+type bgrClient struct{}
+
+func (bgrClient) ColorOrder() utils.ColorOrder {
+	return utils.ColorOrderBGR
+}
+
+func TestPrepareImages(t *testing.T) {
+	rgb := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(10, 20, 30, 0), 2, 2, gocv.MatTypeCV8UC3)
+	defer rgb.Close()
+	bgr := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(30, 20, 10, 0), 2, 2, gocv.MatTypeCV8UC3)
+	defer bgr.Close()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	// Only the RGB image is converted, the BGR image is passed through without a second swap.
+	mats, err := PrepareImages(bgrClient{}, scope, utils.Image{Mat: rgb, Order: utils.ColorOrderRGB}, utils.Image{Mat: bgr, Order: utils.ColorOrderBGR})
+	assert.NoError(t, err)
+	assert.Len(t, mats, 2)
+	assert.Equal(t, bgr.Ptr(), mats[1].Ptr())
+	for _, mat := range mats {
+		pixel := mat.GetVecbAt(0, 0)
+		assert.Equal(t, []uint8{30, 20, 10}, []uint8{pixel[0], pixel[1], pixel[2]})
+	}
+
+	_, err = PrepareImages(bgrClient{}, scope, utils.Image{Mat: rgb, Order: utils.ColorOrder("yuv")})
+	assert.Error(t, err)
+}
+
+func TestCheckColorOrders(t *testing.T) {
+	challenge, err := NewLivenessChallengeClient(config.DefaultLivenessChallengeParams)
+	assert.NoError(t, err)
+	quality, err := NewImageQualityClient(config.DefaultImageQualityParams)
+	assert.NoError(t, err)
+
+	assert.NoError(t, CheckColorOrders(utils.ColorOrderRGB, challenge, quality))
+	assert.Error(t, CheckColorOrders(utils.ColorOrderRGB, challenge, bgrClient{}))
+
+	// The default params of every client expect RGB images.
+	for _, params := range []*config.FaceDetectionParams{config.DefaultFaceDetectionParams, config.DefaultRetinaFaceDetectionParams, config.DefaultYuNetDetectionParams, config.DefaultYOLOv8FaceDetectionParams} {
+		assert.NoError(t, CheckColorOrders(utils.ColorOrderRGB, &tritonFaceDetector{ModelParams: params}), params.ModelName)
+	}
+	assert.NoError(t, CheckColorOrders(utils.ColorOrderRGB,
+		&FaceDetectionClient{ModelParams: config.DefaultFaceDetectionParams},
+		&FaceLandmarkClient{ModelParams: config.DefaultFaceLandmarkParams},
+		&FaceIDClient{ModelParams: config.DefaultFaceIDParams},
+		&FaceQualityClient{ModelParams: config.DefaultFaceQualityParams},
+		&FaceAntiSpoofingClient{ModelParams: config.DefaultCropFaceAntiSpoofingParams},
+	))
+
+	// A landmark model swapping the channels of BGR images expects RGB images, one without the swap does not.
+	landmarkParams := *config.DefaultFaceLandmarkParams
+	landmarkParams.ColorOrder, landmarkParams.SwapRB = config.ColorOrderBGR, true
+	assert.NoError(t, CheckColorOrders(utils.ColorOrderRGB, &FaceLandmarkClient{ModelParams: &landmarkParams}))
+	landmarkParams.SwapRB = false
+	assert.Error(t, CheckColorOrders(utils.ColorOrderRGB, &FaceLandmarkClient{ModelParams: &landmarkParams}))
+
+	challengeParams := *config.DefaultLivenessChallengeParams
+	challengeParams.ColorOrder = config.ColorOrderBGR
+	challenge, err = NewLivenessChallengeClient(&challengeParams)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.ColorOrderBGR, challenge.ColorOrder())
+	assert.Error(t, CheckColorOrders(utils.ColorOrderRGB, challenge, quality))
+
+	challengeParams.ColorOrder = "yuv"
+	_, err = NewLivenessChallengeClient(&challengeParams)
+	assert.Error(t, err)
+}
@@ -1,24 +1,29 @@
 package modules
 
 import (
+	"context"
+	"fmt"
 	"github.com/okieraised/go-ekyc-pipeline/config"
 	"github.com/okieraised/go-ekyc-pipeline/utils"
-	gotritonclient "github.com/okieraised/go-triton-client"
 	"github.com/okieraised/go-triton-client/triton_proto"
 	"gocv.io/x/gocv"
-	"gorgonia.org/tensor"
 	"image"
+	"time"
 )
 
 type FaceAntiSpoofingClient struct {
-	tritonClient *gotritonclient.TritonGRPCClient
+	tritonClient InferenceClient
 	ModelParams  *config.FaceAntiSpoofingParams
 	ModelConfig  *triton_proto.ModelConfigResponse
+	requests     *inferRequestPool
 }
 
-func NewFaceAntiSpoofingClient(triton *gotritonclient.TritonGRPCClient, cfg *config.FaceAntiSpoofingParams) (*FaceAntiSpoofingClient, error) {
+func NewFaceAntiSpoofingClient(triton InferenceClient, cfg *config.FaceAntiSpoofingParams) (*FaceAntiSpoofingClient, error) {
+	if _, err := paramsColorOrder(cfg.ColorOrder, false); err != nil {
+		return nil, err
+	}
 
-	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, "")
+	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, cfg.ModelVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -27,10 +32,52 @@ func NewFaceAntiSpoofingClient(triton *gotritonclient.TritonGRPCClient, cfg *con
 		tritonClient: triton,
 		ModelParams:  cfg,
 		ModelConfig:  inferenceConfig,
+		requests:     newInferRequestPool(cfg.ModelName, cfg.ModelVersion, inferenceConfig),
 	}, nil
 }
 
-func (c *FaceAntiSpoofingClient) preprocess(img gocv.Mat) (*tensor.Dense, error) {
+// Contract returns the model configuration expected by the client: the far, mid and near faces resized to the image
+// size of the params and FP32 scores as outputs, the second score of which is the anti-spoofing score.
+func (c *FaceAntiSpoofingClient) Contract() ModelContract {
+	face := imageInputContract(c.ModelParams.ImgSize)
+	return ModelContract{
+		ModelName:    c.ModelParams.ModelName,
+		Inputs:       []TensorContract{face, face, face},
+		Outputs:      []TensorContract{fp32Contract},
+		ExtraOutputs: &TensorContract{DataTypes: fp32Contract.DataTypes},
+		Check: func(modelConfig *triton_proto.ModelConfig) []string {
+			outputs := modelConfig.GetOutput()
+			if len(outputs) > 0 && staticSize(outputs[0].GetDims()) == 1 {
+				return []string{fmt.Sprintf("output 0 %q: dims %v, want at least 2 scores", outputs[0].GetName(), outputs[0].GetDims())}
+			}
+			return nil
+		},
+	}
+}
+
+// CheckContract validates the model configuration against the contract of the client.
+func (c *FaceAntiSpoofingClient) CheckContract() error {
+	return c.Contract().Validate(c.ModelConfig)
+}
+
+// ServedModel returns the name of the model and the version that served the last request of the client.
+func (c *FaceAntiSpoofingClient) ServedModel() (string, string) {
+	return c.requests.ServedModel()
+}
+
+// Instrument records the duration of the preprocess, network and postprocess stages of the calls of the client.
+func (c *FaceAntiSpoofingClient) Instrument(metrics *Metrics) {
+	c.requests.Instrument(metrics)
+}
+
+// ColorOrder returns the color order of the images expected by the client, set by the color order of its params.
+func (c *FaceAntiSpoofingClient) ColorOrder() utils.ColorOrder {
+	order, _ := paramsColorOrder(c.ModelParams.ColorOrder, false)
+	return order
+}
+
+// preprocess normalizes an image into a (3, H, W) buffer of the model input inputIdx from the request pool.
+func (c *FaceAntiSpoofingClient) preprocess(img gocv.Mat, inputIdx int) ([]float32, error) {
 	var err error
 	resizedImg := gocv.NewMat()
 	defer func(resizedImg *gocv.Mat) {
@@ -51,84 +98,42 @@ func (c *FaceAntiSpoofingClient) preprocess(img gocv.Mat) (*tensor.Dense, error)
 		gocv.InterpolationLinear,
 	)
 
-	imgTensors := tensor.New(
-		tensor.Of(tensor.Float32),
-		tensor.WithShape(
-			resizedImg.Cols(),
-			resizedImg.Rows(),
-			3,
-		),
-	)
-
-	for z := range 3 {
-		for y := range resizedImg.Cols() {
-			for x := range resizedImg.Rows() {
-				err := imgTensors.SetAt(
-					(float32(resizedImg.GetVecbAt(y, x)[z])/255.0-float32(c.ModelParams.Mean[z]))/float32(c.ModelParams.STD[z]),
-					y, x, z)
-				if err != nil {
-					return nil, err
-				}
-			}
-		}
-	}
-	err = imgTensors.T(2, 0, 1)
-	if err != nil {
-		return nil, err
-	}
-	newShape := []int{1}
-	newShape = append(newShape, imgTensors.Shape()...)
-	err = imgTensors.Reshape(newShape...)
-
-	return imgTensors, nil
-
+	norm := utils.NewMeanStdNormalization(c.ModelParams.Mean, c.ModelParams.STD, false)
+	imgH, imgW := resizedImg.Rows(), resizedImg.Cols()
+	return utils.MatToCHW(resizedImg, norm, c.requests.Buffer(inputIdx, 3*imgH*imgW))
 }
 
 func (c *FaceAntiSpoofingClient) InferSingle(imgFar, imgMid, imgNear gocv.Mat) (float32, error) {
-	var asScore float32
-
-	far, err := c.preprocess(imgFar)
-	if err != nil {
-		return asScore, err
-	}
-	mid, err := c.preprocess(imgMid)
-	if err != nil {
-		return asScore, err
-	}
-	near, err := c.preprocess(imgNear)
-	if err != nil {
-		return asScore, err
-	}
-
-	inputTensors := [][]float32{
-		far.Float32s(),
-		mid.Float32s(),
-		near.Float32s(),
-	}
+	return c.InferSingleContext(context.Background(), imgFar, imgMid, imgNear)
+}
 
-	modelInputs := make([]*triton_proto.ModelInferRequest_InferInputTensor, 3)
-	modelRequest := &triton_proto.ModelInferRequest{
-		ModelName: c.ModelParams.ModelName,
-	}
+func (c *FaceAntiSpoofingClient) InferSingleContext(ctx context.Context, imgFar, imgMid, imgNear gocv.Mat) (float32, error) {
+	var asScore float32
 
-	for idx, inputCfg := range c.ModelConfig.Config.Input {
-		modelInput := &triton_proto.ModelInferRequest_InferInputTensor{
-			Name:     inputCfg.Name,
-			Datatype: inputCfg.DataType.String()[5:],
-			Shape:    inputCfg.Dims,
-			Contents: &triton_proto.InferTensorContents{
-				Fp32Contents: inputTensors[idx],
-			},
+	start := time.Now()
+	modelRequest := c.requests.Get()
+	for idx, img := range []gocv.Mat{imgFar, imgMid, imgNear} {
+		input, err := c.preprocess(img, idx)
+		if err != nil {
+			c.requests.Observe(StagePreprocess, start, err)
+			c.requests.Put(modelRequest)
+			return asScore, err
 		}
-		modelInputs[idx] = modelInput
+		modelRequest.SetInput(idx, input, c.ModelConfig.Config.Input[idx].Dims...)
 	}
-	modelRequest.Inputs = modelInputs
+	c.requests.Observe(StagePreprocess, start, nil)
 
-	inferResp, err := c.tritonClient.ModelGRPCInfer(c.ModelParams.Timeout, modelRequest)
+	sent := time.Now()
+	inferResp, err := modelInfer(ctx, c.tritonClient, c.ModelParams.Timeout, modelRequest.Request)
+	c.requests.Observe(StageNetwork, sent, err)
+	c.requests.Put(modelRequest)
 	if err != nil {
 		return asScore, err
 	}
+	c.requests.Record(inferResp)
 
+	start = time.Now()
+	defer c.requests.Observe(StagePostprocess, start, nil)
 	for oIdx, output := range inferResp.GetOutputs() {
 		outputShape := make([]int, 0, len(output.Shape))
 		for _, shp := range output.Shape {
@@ -1,7 +1,29 @@
 package modules
 
-import "testing"
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"gocv.io/x/gocv"
+	"testing"
+)
 
 func TestNewFaceAntiSpoofingClient(t *testing.T) {
 
 }
+
+func BenchmarkFaceAntiSpoofingClient_preprocess(b *testing.B) {
+	modelConfig := genTestModelConfig(1, 3, 224, 224)
+	client := &FaceAntiSpoofingClient{ModelParams: config.DefaultCropFaceAntiSpoofingParams, ModelConfig: modelConfig, requests: newInferRequestPool("anti_spoofing", "", modelConfig)}
+	img := gocv.NewMatWithSize(224, 224, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	b.ReportAllocs()
+	for range b.N {
+		input, err := client.preprocess(img, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		request := client.requests.Get()
+		request.SetInput(0, input, 1, 3, 224, 224)
+		client.requests.Put(request)
+	}
+}
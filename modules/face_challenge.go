@@ -0,0 +1,459 @@
+package modules
+
+import (
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"image"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrChallengeInvalid is returned for challenge IDs not issued by the client or altered.
+	ErrChallengeInvalid = errors.New("invalid liveness challenge id")
+	// ErrChallengeExpired is returned for challenge IDs verified after their TTL.
+	ErrChallengeExpired = errors.New("liveness challenge expired")
+	// ErrChallengeUsed is returned for challenge IDs already verified.
+	ErrChallengeUsed = errors.New("liveness challenge already verified")
+)
+
+type LivenessChallengeClient struct {
+	ModelParams *config.LivenessChallengeParams
+	// Store holds the state of the issued challenges. It may be replaced by the store of another client before the
+	// first challenge is issued, for both clients to verify each challenge once.
+	Store *LivenessChallengeStore
+	now   func() time.Time
+}
+
+// LivenessChallengeStore holds the key signing the challenge IDs of the clients whose params set no secret key, and
+// the nonces of the verified challenge IDs until they expire. Clients sharing a store, e.g. the successive clients of
+// a HotSwapPipeline, verify the challenges issued by each other, once.
+type LivenessChallengeStore struct {
+	key  []byte
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewLivenessChallengeStore initializes a store with a random key.
+func NewLivenessChallengeStore() (*LivenessChallengeStore, error) {
+	key := make([]byte, 32)
+	if _, err := cryptorand.Read(key); err != nil {
+		return nil, err
+	}
+	return &LivenessChallengeStore{key: key, used: map[string]time.Time{}}, nil
+}
+
+// use marks the nonce of a challenge ID as verified until it expires, reporting false if it already was.
+func (s *LivenessChallengeStore) use(nonce string, expiresAt, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for used, expiry := range s.used {
+		if !now.Before(expiry) {
+			delete(s.used, used)
+		}
+	}
+	if _, ok := s.used[nonce]; ok {
+		return false
+	}
+	s.used[nonce] = expiresAt
+	return true
+}
+
+// challengePayload is the signed content of a challenge ID.
+type challengePayload struct {
+	Nonce      string                     `json:"n"`
+	Challenges []config.LivenessChallenge `json:"c"`
+	ExpiresAt  int64                      `json:"e"`
+}
+
+// faceMotion holds the per-frame measurements used to verify liveness challenges.
+type faceMotion struct {
+	valid       bool
+	yaw         float32
+	pitch       float32
+	roll        float32
+	eyeOpenness float32
+	mouthRatio  float32
+}
+
+// NewLivenessChallengeClient initializes a new LivenessChallengeClient.
+func NewLivenessChallengeClient(cfg *config.LivenessChallengeParams) (*LivenessChallengeClient, error) {
+	if cfg == nil {
+		return nil, errors.New("liveness challenge params must not be nil")
+	}
+	if cfg.NumChallenges <= 0 {
+		return nil, errors.New("number of liveness challenges must be positive")
+	}
+	if cfg.ChallengeTTL <= 0 {
+		return nil, errors.New("liveness challenge TTL must be positive")
+	}
+	if _, err := paramsColorOrder(cfg.ColorOrder, false); err != nil {
+		return nil, err
+	}
+
+	store, err := NewLivenessChallengeStore()
+	if err != nil {
+		return nil, err
+	}
+
+	return &LivenessChallengeClient{
+		ModelParams: cfg,
+		Store:       store,
+		now:         time.Now,
+	}, nil
+}
+
+// ColorOrder returns the color order of the images expected by the client, set by the color order of its params.
+func (c *LivenessChallengeClient) ColorOrder() utils.ColorOrder {
+	order, _ := paramsColorOrder(c.ModelParams.ColorOrder, false)
+	return order
+}
+
+// GenerateChallenges returns a random sequence of liveness challenges without consecutive repetitions.
+func (c *LivenessChallengeClient) GenerateChallenges() []config.LivenessChallenge {
+	challenges := make([]config.LivenessChallenge, 0, c.ModelParams.NumChallenges)
+	for len(challenges) < c.ModelParams.NumChallenges {
+		challenge := config.LivenessChallenges[rand.IntN(len(config.LivenessChallenges))]
+		if len(challenges) > 0 && challenges[len(challenges)-1] == challenge {
+			continue
+		}
+		challenges = append(challenges, challenge)
+	}
+	return challenges
+}
+
+// IssueChallenges returns a random challenge sequence with the signed challenge ID identifying it, valid for the
+// challenge TTL of the params.
+func (c *LivenessChallengeClient) IssueChallenges() (*config.LivenessChallengeIssue, error) {
+	return c.issue(c.GenerateChallenges())
+}
+
+func (c *LivenessChallengeClient) issue(challenges []config.LivenessChallenge) (*config.LivenessChallengeIssue, error) {
+	nonce := make([]byte, 16)
+	if _, err := cryptorand.Read(nonce); err != nil {
+		return nil, err
+	}
+	issue := &config.LivenessChallengeIssue{
+		Challenges: challenges,
+		ExpiresAt:  c.now().Add(c.ModelParams.ChallengeTTL),
+	}
+
+	payload, err := json.Marshal(challengePayload{
+		Nonce:      base64.RawURLEncoding.EncodeToString(nonce),
+		Challenges: issue.Challenges,
+		ExpiresAt:  issue.ExpiresAt.UnixNano(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	issue.ID = base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload))
+	return issue, nil
+}
+
+func (c *LivenessChallengeClient) sign(payload []byte) []byte {
+	key := []byte(c.ModelParams.SecretKey)
+	if len(key) == 0 {
+		key = c.Store.key
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// redeem returns the challenge sequence of a challenge ID, checking its signature and expiry, and marks it as used.
+func (c *LivenessChallengeClient) redeem(challengeID string) ([]config.LivenessChallenge, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(challengeID, ".")
+	if !ok {
+		return nil, ErrChallengeInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrChallengeInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, c.sign(payload)) {
+		return nil, ErrChallengeInvalid
+	}
+	var content challengePayload
+	if err := json.Unmarshal(payload, &content); err != nil || len(content.Challenges) == 0 {
+		return nil, ErrChallengeInvalid
+	}
+
+	now := c.now()
+	expiresAt := time.Unix(0, content.ExpiresAt)
+	if !now.Before(expiresAt) {
+		return nil, ErrChallengeExpired
+	}
+
+	if !c.Store.use(content.Nonce, expiresAt, now) {
+		return nil, ErrChallengeUsed
+	}
+	return content.Challenges, nil
+}
+
+// eyeOpenness returns the mean gray-level standard deviation of the two eye patches, which drops when the eyes close.
+func eyeOpenness(img gocv.Mat, order utils.ColorOrder, lmk []float32) float32 {
+	dims := img.Size()
+	h, w := dims[0], dims[1]
+	half := int(0.15 * utils.EuclideanDistance(lmk[0], lmk[1], lmk[2], lmk[3]))
+	if half < 1 {
+		return 0
+	}
+
+	var total float64
+	for i := 0; i < 2; i++ {
+		x, y := int(lmk[2*i]), int(lmk[2*i+1])
+		region := image.Rect(x-half, y-half, x+half, y+half).Intersect(image.Rect(0, 0, w, h))
+		if region.Empty() {
+			return 0
+		}
+		patch := img.Region(region)
+		gray := gocv.NewMat()
+		gocv.CvtColor(patch, &gray, grayConversion(order))
+		mean := gocv.NewMat()
+		stdDev := gocv.NewMat()
+		gocv.MeanStdDev(gray, &mean, &stdDev)
+		total += stdDev.GetDoubleAt(0, 0)
+		_ = stdDev.Close()
+		_ = mean.Close()
+		_ = gray.Close()
+		_ = patch.Close()
+	}
+	return float32(total / 2)
+}
+
+// computeFaceMotion measures head pose, eye openness and mouth width of a frame.
+// Eye openness is the eye aspect ratio of the dense landmarks if given, the eye patch contrast otherwise.
+func computeFaceMotion(img gocv.Mat, order utils.ColorOrder, landmark *tensor.Dense, dense *config.Landmarks) faceMotion {
+	if landmark == nil {
+		return faceMotion{}
+	}
+	lmk := landmark.Float32s()
+	eyeDist := utils.EuclideanDistance(lmk[0], lmk[1], lmk[2], lmk[3])
+	if eyeDist == 0 {
+		return faceMotion{}
+	}
+	dims := img.Size()
+	pose, err := EstimateHeadPose(config.Size{Width: dims[1], Height: dims[0]}, landmark)
+	if err != nil {
+		return faceMotion{}
+	}
+
+	openness := eyeOpenness(img, order, lmk)
+	if dense != nil {
+		openness, err = dense.EyeAspectRatio()
+		if err != nil {
+			return faceMotion{}
+		}
+	}
+
+	return faceMotion{
+		valid:       true,
+		yaw:         pose.Yaw,
+		pitch:       pose.Pitch,
+		roll:        pose.Roll,
+		eyeOpenness: openness,
+		mouthRatio:  utils.EuclideanDistance(lmk[6], lmk[7], lmk[8], lmk[9]) / eyeDist,
+	}
+}
+
+// baselineMotion returns the median measurements of the first valid frames, assumed to be a neutral face.
+func (c *LivenessChallengeClient) baselineMotion(motions []faceMotion) (faceMotion, error) {
+	yaws, pitches, openness, mouths := make([]float32, 0), make([]float32, 0), make([]float32, 0), make([]float32, 0)
+	for _, m := range motions {
+		if !m.valid {
+			continue
+		}
+		yaws = append(yaws, m.yaw)
+		pitches = append(pitches, m.pitch)
+		openness = append(openness, m.eyeOpenness)
+		mouths = append(mouths, m.mouthRatio)
+		if len(yaws) >= max(c.ModelParams.BaselineFrames, 1) {
+			break
+		}
+	}
+	if len(yaws) == 0 {
+		return faceMotion{}, errors.New("cannot detect any face in input frames")
+	}
+
+	median := func(values []float32) float32 {
+		slices.Sort(values)
+		return values[len(values)/2]
+	}
+
+	return faceMotion{
+		valid:       true,
+		yaw:         median(yaws),
+		pitch:       median(pitches),
+		eyeOpenness: median(openness),
+		mouthRatio:  median(mouths),
+	}, nil
+}
+
+// challengeSignal returns how far a frame is into the challenge action relative to the neutral baseline:
+// the action is starting at 0.5 and performed at 1. It also reports whether the face must return to rest afterwards.
+func (c *LivenessChallengeClient) challengeSignal(challenge config.LivenessChallenge, m, base faceMotion) (float32, bool) {
+	params := c.ModelParams
+	switch challenge {
+	case config.LivenessChallengeTurnLeft:
+		// The user's left is the image right on a non-mirrored capture.
+		return (m.yaw - base.yaw) / params.YawThreshold, false
+	case config.LivenessChallengeTurnRight:
+		return (base.yaw - m.yaw) / params.YawThreshold, false
+	case config.LivenessChallengeNod:
+		return (m.pitch - base.pitch) / params.PitchThreshold, true
+	case config.LivenessChallengeBlink:
+		if base.eyeOpenness <= 0 || params.BlinkRatio >= 1 {
+			return 0, true
+		}
+		return (1 - m.eyeOpenness/base.eyeOpenness) / (1 - params.BlinkRatio), true
+	case config.LivenessChallengeSmile:
+		if base.mouthRatio <= 0 || params.SmileRatio <= 1 {
+			return 0, false
+		}
+		return (m.mouthRatio/base.mouthRatio - 1) / (params.SmileRatio - 1), false
+	}
+	return 0, false
+}
+
+// Verify checks that the challenges of the challenge ID were performed in order in the input frame sequence. The
+// challenge ID must have been issued by IssueChallenges, within its TTL, and is rejected once verified.
+//
+// Inputs:
+//
+//   - frames ([]gocv.Mat): RGB frame sequence.
+//   - landmarks ([]*tensor.Dense): (5, 2) facial landmark of each frame, nil when no face is found.
+//   - timestamps ([]time.Duration): timestamp of each frame, challenge durations are not checked if nil.
+//   - challengeID (string): ID of the challenge sequence issued to the user.
+//
+// Outputs:
+//
+//   - result (*config.FaceActiveLivenessVerify): per-challenge result.
+func (c *LivenessChallengeClient) Verify(frames []gocv.Mat, landmarks []*tensor.Dense, timestamps []time.Duration, challengeID string) (*config.FaceActiveLivenessVerify, error) {
+	return c.verify(frames, landmarks, nil, timestamps, challengeID)
+}
+
+// VerifyDense checks that the challenges of the challenge ID were performed in order in the input frame sequence, using
+// the eye aspect ratio of dense landmarks for blinks. See Verify for the checks of the challenge ID.
+//
+// Inputs:
+//
+//   - frames ([]gocv.Mat): RGB frame sequence.
+//   - landmarks ([]*config.Landmarks): dense facial landmarks of each frame, nil when no face is found.
+//   - timestamps ([]time.Duration): timestamp of each frame, challenge durations are not checked if nil.
+//   - challengeID (string): ID of the challenge sequence issued to the user.
+//
+// Outputs:
+//
+//   - result (*config.FaceActiveLivenessVerify): per-challenge result.
+func (c *LivenessChallengeClient) VerifyDense(frames []gocv.Mat, landmarks []*config.Landmarks, timestamps []time.Duration, challengeID string) (*config.FaceActiveLivenessVerify, error) {
+	landmarks5 := make([]*tensor.Dense, len(landmarks))
+	for idx, dense := range landmarks {
+		if dense == nil {
+			continue
+		}
+		lmk, err := dense.Tensor5()
+		if err != nil {
+			return nil, err
+		}
+		landmarks5[idx] = lmk
+	}
+	return c.verify(frames, landmarks5, landmarks, timestamps, challengeID)
+}
+
+func (c *LivenessChallengeClient) verify(frames []gocv.Mat, landmarks []*tensor.Dense, dense []*config.Landmarks, timestamps []time.Duration, challengeID string) (*config.FaceActiveLivenessVerify, error) {
+	if len(frames) != len(landmarks) {
+		return nil, errors.New("number of input frames and landmarks must be equal")
+	}
+	if timestamps != nil && len(timestamps) != len(frames) {
+		return nil, errors.New("number of input frames and timestamps must be equal")
+	}
+	challenges, err := c.redeem(challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	motions := make([]faceMotion, len(frames))
+	for idx := range frames {
+		var denseLandmark *config.Landmarks
+		if dense != nil {
+			denseLandmark = dense[idx]
+		}
+		motions[idx] = computeFaceMotion(frames[idx], c.ColorOrder(), landmarks[idx], denseLandmark)
+	}
+	base, err := c.baselineMotion(motions)
+	if err != nil {
+		return nil, err
+	}
+
+	timestampAt := func(idx int) time.Duration {
+		if timestamps == nil || idx < 0 {
+			return 0
+		}
+		return timestamps[idx]
+	}
+
+	resp := &config.FaceActiveLivenessVerify{
+		IsLiveness: true,
+		Challenges: make([]config.LivenessChallengeResult, 0, len(challenges)),
+	}
+
+	start := 0
+	for _, challenge := range challenges {
+		onset, end := -1, -1
+		active := false
+		for idx := start; idx < len(motions); idx++ {
+			if !motions[idx].valid {
+				continue
+			}
+			signal, needReturn := c.challengeSignal(challenge, motions[idx], base)
+			if signal >= 0.5 && onset < 0 {
+				onset = idx
+			}
+			if signal < 0.5 && !active {
+				onset = -1
+			}
+			if signal >= 1 {
+				active = true
+				if !needReturn {
+					end = idx
+					break
+				}
+			}
+			if active && signal < 0.5 {
+				end = idx
+				break
+			}
+		}
+
+		result := config.LivenessChallengeResult{
+			Challenge:  challenge,
+			StartFrame: onset,
+			EndFrame:   end,
+			StartTime:  timestampAt(onset),
+			EndTime:    timestampAt(end),
+		}
+		result.IsPassed = end >= 0
+		if result.IsPassed && timestamps != nil && c.ModelParams.MaxDuration > 0 {
+			result.IsPassed = result.EndTime-result.StartTime <= c.ModelParams.MaxDuration
+		}
+		if end >= 0 {
+			start = end + 1
+		}
+		resp.IsLiveness = resp.IsLiveness && result.IsPassed
+		resp.Challenges = append(resp.Challenges, result)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,270 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	gotritonclient "github.com/okieraised/go-triton-client"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"gorgonia.org/tensor"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func genTestChallengeLandmark(noseShift, mouthWidth float32) *tensor.Dense {
+	return tensor.New(
+		tensor.Of(tensor.Float32),
+		tensor.WithShape(5, 2),
+		tensor.WithBacking([]float32{
+			200, 200,
+			300, 200,
+			250 + noseShift, 250,
+			250 - mouthWidth/2, 300,
+			250 + mouthWidth/2, 300,
+		}),
+	)
+}
+
+// genTestChallengeDenseLandmarks returns iBUG 68-point landmarks matching genTestChallengeLandmark(0, 80) with eyes
+// of the given aspect ratio.
+func genTestChallengeDenseLandmarks(eyeRatio float64) *config.Landmarks {
+	coordinates := make([]float32, 136)
+	set := func(idx int, x, y float64) {
+		coordinates[2*idx], coordinates[2*idx+1] = float32(x), float32(y)
+	}
+	for e, cx := range []float64{200, 300} {
+		for k := 0; k < 6; k++ {
+			angle := math.Pi - float64(k)*math.Pi/3
+			set(36+6*e+k, cx+15*math.Cos(angle), 200-15*eyeRatio*math.Sin(angle))
+		}
+	}
+	set(30, 250, 250)
+	set(48, 210, 300)
+	set(54, 290, 300)
+	landmarks, _ := config.NewLandmarks(config.LandmarkLayout68, coordinates)
+	return landmarks
+}
+
+func TestLivenessChallengeClient_GenerateChallenges(t *testing.T) {
+	client, err := NewLivenessChallengeClient(config.DefaultLivenessChallengeParams)
+	assert.NoError(t, err)
+
+	challenges := client.GenerateChallenges()
+	assert.Len(t, challenges, config.DefaultLivenessChallengeParams.NumChallenges)
+	for idx := 1; idx < len(challenges); idx++ {
+		assert.NotEqual(t, challenges[idx-1], challenges[idx])
+	}
+}
+
+func TestLivenessChallengeClient_IssueChallenges(t *testing.T) {
+	client, err := NewLivenessChallengeClient(config.DefaultLivenessChallengeParams)
+	assert.NoError(t, err)
+
+	issue, err := client.IssueChallenges()
+	assert.NoError(t, err)
+	assert.Len(t, issue.Challenges, config.DefaultLivenessChallengeParams.NumChallenges)
+
+	// The challenges are carried by the challenge ID, which is verified once.
+	challenges, err := client.redeem(issue.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, issue.Challenges, challenges)
+	_, err = client.redeem(issue.ID)
+	assert.ErrorIs(t, err, ErrChallengeUsed)
+
+	// Altered IDs and IDs signed by another key are rejected.
+	issue, err = client.IssueChallenges()
+	assert.NoError(t, err)
+	_, err = client.redeem(issue.ID[1:])
+	assert.ErrorIs(t, err, ErrChallengeInvalid)
+	_, err = client.redeem("")
+	assert.ErrorIs(t, err, ErrChallengeInvalid)
+	other, err := NewLivenessChallengeClient(config.DefaultLivenessChallengeParams)
+	assert.NoError(t, err)
+	_, err = other.redeem(issue.ID)
+	assert.ErrorIs(t, err, ErrChallengeInvalid)
+
+	// IDs are rejected after their TTL.
+	now := time.Now()
+	client.now = func() time.Time { return now.Add(config.DefaultLivenessChallengeParams.ChallengeTTL) }
+	_, err = client.redeem(issue.ID)
+	assert.ErrorIs(t, err, ErrChallengeExpired)
+
+	// Clients sharing a secret key verify the challenges issued by each other.
+	params := *config.DefaultLivenessChallengeParams
+	params.SecretKey = "secret"
+	issuer, err := NewLivenessChallengeClient(&params)
+	assert.NoError(t, err)
+	verifier, err := NewLivenessChallengeClient(&params)
+	assert.NoError(t, err)
+	issue, err = issuer.IssueChallenges()
+	assert.NoError(t, err)
+	_, err = verifier.redeem(issue.ID)
+	assert.NoError(t, err)
+
+	// Clients sharing a store verify the challenges issued by each other, once.
+	verifier, err = NewLivenessChallengeClient(config.DefaultLivenessChallengeParams)
+	assert.NoError(t, err)
+	verifier.Store = other.Store
+	issue, err = other.IssueChallenges()
+	assert.NoError(t, err)
+	_, err = verifier.redeem(issue.ID)
+	assert.NoError(t, err)
+	_, err = other.redeem(issue.ID)
+	assert.ErrorIs(t, err, ErrChallengeUsed)
+
+	params.ChallengeTTL = 0
+	_, err = NewLivenessChallengeClient(&params)
+	assert.Error(t, err)
+}
+
+func TestLivenessChallengeClient_Verify(t *testing.T) {
+	client, err := NewLivenessChallengeClient(config.DefaultLivenessChallengeParams)
+	assert.NoError(t, err)
+
+	landmarks := []*tensor.Dense{
+		genTestChallengeLandmark(0, 80),
+		genTestChallengeLandmark(0, 80),
+		genTestChallengeLandmark(10, 80),
+		genTestChallengeLandmark(40, 80),
+		genTestChallengeLandmark(0, 80),
+		genTestChallengeLandmark(-40, 80),
+		genTestChallengeLandmark(0, 80),
+		genTestChallengeLandmark(0, 110),
+	}
+	frames := make([]gocv.Mat, 0, len(landmarks))
+	timestamps := make([]time.Duration, 0, len(landmarks))
+	for idx := range landmarks {
+		frame := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+		defer frame.Close()
+		frames = append(frames, frame)
+		timestamps = append(timestamps, time.Duration(idx)*100*time.Millisecond)
+	}
+
+	issue, err := client.issue([]config.LivenessChallenge{
+		config.LivenessChallengeTurnLeft,
+		config.LivenessChallengeTurnRight,
+		config.LivenessChallengeSmile,
+	})
+	assert.NoError(t, err)
+	res, err := client.Verify(frames, landmarks, timestamps, issue.ID)
+	assert.NoError(t, err)
+	assert.True(t, res.IsLiveness)
+	assert.Equal(t, 2, res.Challenges[0].StartFrame)
+	assert.Equal(t, 3, res.Challenges[0].EndFrame)
+	assert.Equal(t, 5, res.Challenges[1].EndFrame)
+	assert.Equal(t, 7, res.Challenges[2].EndFrame)
+
+	issue, err = client.issue([]config.LivenessChallenge{
+		config.LivenessChallengeSmile,
+		config.LivenessChallengeTurnLeft,
+	})
+	assert.NoError(t, err)
+	res, err = client.Verify(frames, landmarks, timestamps, issue.ID)
+	assert.NoError(t, err)
+	assert.False(t, res.IsLiveness)
+	assert.True(t, res.Challenges[0].IsPassed)
+	assert.False(t, res.Challenges[1].IsPassed)
+}
+
+func TestLivenessChallengeClient_VerifyDense(t *testing.T) {
+	client, err := NewLivenessChallengeClient(config.DefaultLivenessChallengeParams)
+	assert.NoError(t, err)
+
+	landmarks := []*config.Landmarks{
+		genTestChallengeDenseLandmarks(0.6),
+		genTestChallengeDenseLandmarks(0.6),
+		nil,
+		genTestChallengeDenseLandmarks(0.6),
+		genTestChallengeDenseLandmarks(0.1),
+		genTestChallengeDenseLandmarks(0.6),
+	}
+	landmarks5 := make([]*tensor.Dense, len(landmarks))
+	frames := make([]gocv.Mat, 0, len(landmarks))
+	for idx := range landmarks {
+		if landmarks[idx] != nil {
+			landmarks5[idx] = genTestChallengeLandmark(0, 80)
+		}
+		// Blank frames carry no eye contrast, only the eye aspect ratio can see the blink.
+		frame := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+		defer frame.Close()
+		frames = append(frames, frame)
+	}
+	challenges := []config.LivenessChallenge{config.LivenessChallengeBlink}
+
+	issue, err := client.issue(challenges)
+	assert.NoError(t, err)
+	res, err := client.VerifyDense(frames, landmarks, nil, issue.ID)
+	assert.NoError(t, err)
+	assert.True(t, res.IsLiveness)
+	assert.Equal(t, 4, res.Challenges[0].StartFrame)
+	assert.Equal(t, 5, res.Challenges[0].EndFrame)
+
+	issue, err = client.issue(challenges)
+	assert.NoError(t, err)
+	res, err = client.Verify(frames, landmarks5, nil, issue.ID)
+	assert.NoError(t, err)
+	assert.False(t, res.IsLiveness)
+}
+
+func genTestFrameData(name string) (*gocv.Mat, error) {
+	content, err := os.ReadFile("../test_data/" + name)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ConvertImageToMat(content)
+}
+
+// TestEyeOpenness checks the eye patch contrast heuristic on frames of the same face with open and closed eyes.
+func TestEyeOpenness(t *testing.T) {
+	triton, err := gotritonclient.NewTritonGRPCClient(
+		tritonTestURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	)
+	assert.NoError(t, err)
+
+	open, err := genTestFrameData("eyes_open")
+	assert.NoError(t, err)
+	defer open.Close()
+	closed, err := genTestFrameData("eyes_closed")
+	assert.NoError(t, err)
+	defer closed.Close()
+
+	faceHelper, err := NewFaceHelperClient(triton, 112, 224, 128, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	_, landmarks, err := faceHelper.GetFaceLandmarks5([]gocv.Mat{*open, *closed}, utils.RefPointer(true), nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, landmarks[0])
+	assert.NotNil(t, landmarks[1])
+
+	openness := eyeOpenness(*open, faceHelper.ColorOrder(), landmarks[0].Float32s())
+	closedness := eyeOpenness(*closed, faceHelper.ColorOrder(), landmarks[1].Float32s())
+	assert.Greater(t, openness, float32(0))
+	assert.Less(t, closedness, openness*config.DefaultLivenessChallengeParams.BlinkRatio)
+
+	// A blink is seen in the open, closed, open sequence and not in a sequence of open eyes.
+	client, err := NewLivenessChallengeClient(config.DefaultLivenessChallengeParams)
+	assert.NoError(t, err)
+	blink := []config.LivenessChallenge{config.LivenessChallengeBlink}
+
+	frames := []gocv.Mat{*open, *open, *open, *closed, *open}
+	lmks := []*tensor.Dense{landmarks[0], landmarks[0], landmarks[0], landmarks[1], landmarks[0]}
+	issue, err := client.issue(blink)
+	assert.NoError(t, err)
+	res, err := client.Verify(frames, lmks, nil, issue.ID)
+	assert.NoError(t, err)
+	assert.True(t, res.IsLiveness)
+
+	frames = []gocv.Mat{*open, *open, *open, *open, *open}
+	lmks = []*tensor.Dense{landmarks[0], landmarks[0], landmarks[0], landmarks[0], landmarks[0]}
+	issue, err = client.issue(blink)
+	assert.NoError(t, err)
+	res, err = client.Verify(frames, lmks, nil, issue.ID)
+	assert.NoError(t, err)
+	assert.False(t, res.IsLiveness)
+}
@@ -1,25 +1,34 @@
 package modules
 
 import (
+	"context"
 	"github.com/okieraised/go-ekyc-pipeline/config"
 	"github.com/okieraised/go-ekyc-pipeline/utils"
-	gotritonclient "github.com/okieraised/go-triton-client"
 	"github.com/okieraised/go-triton-client/triton_proto"
 	"gocv.io/x/gocv"
 	"gorgonia.org/tensor"
-	"image"
 	"slices"
+	"time"
 )
 
 type FaceDetectionClient struct {
-	tritonClient *gotritonclient.TritonGRPCClient
+	tritonClient InferenceClient
 	ModelConfig  *triton_proto.ModelConfigResponse
 	ModelParams  *config.FaceDetectionParams
+	outputFormat detectorOutputFormat
+	requests     *inferRequestPool
 }
 
-func NewFaceDetectionClient(triton *gotritonclient.TritonGRPCClient, cfg *config.FaceDetectionParams) (*FaceDetectionClient, error) {
+func NewFaceDetectionClient(triton InferenceClient, cfg *config.FaceDetectionParams) (*FaceDetectionClient, error) {
+	if _, err := paramsColorOrder(cfg.ColorOrder, false); err != nil {
+		return nil, err
+	}
 
-	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, "")
+	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, cfg.ModelVersion)
+	if err != nil {
+		return nil, err
+	}
+	outputFormat, err := detectOutputFormat(inferenceConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -28,144 +37,97 @@ func NewFaceDetectionClient(triton *gotritonclient.TritonGRPCClient, cfg *config
 		tritonClient: triton,
 		ModelParams:  cfg,
 		ModelConfig:  inferenceConfig,
+		outputFormat: outputFormat,
+		requests:     newInferRequestPool(cfg.ModelName, cfg.ModelVersion, inferenceConfig),
 	}, nil
 }
 
-func (c *FaceDetectionClient) preprocess(rawInputTensors []gocv.Mat) ([]*tensor.Dense, []config.Size, error) {
-	outputs := make([]*tensor.Dense, 0)
-	input := rawInputTensors[0]
-	imgH, imgW := input.Size()[0], input.Size()[1]
-	imgRatio := float64(imgW) / float64(imgH)
-	sizes := make([]config.Size, 0)
-	sizes = append(sizes, config.Size{
-		Width:  imgW,
-		Height: imgH,
-	})
-
-	modelRatio := float64(c.ModelConfig.Config.Input[0].Dims[2]) / float64(c.ModelConfig.Config.Input[0].Dims[1])
-
-	var newWidth, newHeight int64
-	if imgRatio > modelRatio {
-		newWidth = c.ModelConfig.Config.Input[0].Dims[2]
-		newHeight = int64(float64(newWidth) / imgRatio)
-	} else {
-		newHeight = c.ModelConfig.Config.Input[0].Dims[1]
-		newWidth = int64(float64(newHeight) * imgRatio)
+// Contract returns the model configuration expected by the client: an image letterboxed to the input size of the model
+// and either the num_dets, boxes, scores, classes and landmarks outputs of an end-to-end export, in that order, or the
+// FP32 score, bbox and keypoint heads of a raw SCRFD export.
+func (c *FaceDetectionClient) Contract() ModelContract {
+	contract := ModelContract{
+		ModelName: c.ModelParams.ModelName,
+		Inputs:    []TensorContract{imageInputContract(0)},
 	}
-
-	resizedImg := gocv.NewMat()
-	defer resizedImg.Close()
-	gocv.Resize(input, &resizedImg, image.Point{X: int(newWidth), Y: int(newHeight)}, 0.0, 0.0, gocv.InterpolationLinear)
-
-	scaledImg := gocv.NewMatWithSizesWithScalar(
-		[]int{
-			int(c.ModelConfig.Config.Input[0].Dims[1]),
-			int(c.ModelConfig.Config.Input[0].Dims[2]),
-		},
-		gocv.MatTypeCV8UC3,
-		gocv.NewScalar(0, 0, 0, 0),
-	)
-	defer scaledImg.Close()
-
-	roi := scaledImg.Region(image.Rect(0, 0, int(newWidth), int(newHeight)))
-
-	gocv.Resize(resizedImg, &roi, image.Point{X: roi.Size()[1], Y: roi.Size()[0]}, 0, 0, gocv.InterpolationLinear)
-	imgTensors := tensor.New(
-		tensor.Of(tensor.Float32),
-		tensor.WithShape(
-			int(c.ModelConfig.Config.Input[0].Dims[0]),
-			int(c.ModelConfig.Config.Input[0].Dims[1]),
-			int(c.ModelConfig.Config.Input[0].Dims[2]),
-		),
-	)
-
-	for z := range 3 {
-		for y := range int(c.ModelConfig.Config.Input[0].Dims[1]) {
-			for x := range int(c.ModelConfig.Config.Input[0].Dims[2]) {
-				err := imgTensors.SetAt((float32(scaledImg.GetVecbAt(y, x)[z])-float32(c.ModelParams.Mean))*float32(c.ModelParams.Scale), z, y, x)
-				if err != nil {
-					return nil, nil, err
-				}
-			}
-		}
+	if c.outputFormat == detectorOutputSCRFDRaw {
+		contract.ExtraOutputs = &TensorContract{DataTypes: fp32Contract.DataTypes}
+		contract.Check = checkSCRFDHeads
+		return contract
 	}
-	err := imgTensors.T(2, 0, 1)
-	if err != nil {
-		return nil, nil, err
+
+	anyNumber := []triton_proto.DataType{triton_proto.DataType_TYPE_FP32, triton_proto.DataType_TYPE_INT32}
+	contract.Outputs = []TensorContract{
+		{Name: endToEndOutputName, DataTypes: anyNumber},
+		fp32Contract,
+		{DataTypes: anyNumber},
+		{DataTypes: anyNumber},
+		fp32Contract,
 	}
-	outputs = append(outputs, imgTensors)
-	return outputs, sizes, nil
+	return contract
 }
 
-func (c *FaceDetectionClient) preprocessBatch(rawInputTensors [][]gocv.Mat) ([]*tensor.Dense, []config.Size, error) {
-	inputs := rawInputTensors[0]
-	outputs := make([]*tensor.Dense, 0)
-	sizes := make([]config.Size, 0)
+// CheckContract validates the model configuration against the contract of the client.
+func (c *FaceDetectionClient) CheckContract() error {
+	return c.Contract().Validate(c.ModelConfig)
+}
 
-	for _, input := range inputs {
-		imgH, imgW := input.Size()[0], input.Size()[1]
-		imgRatio := float64(imgW) / float64(imgH)
-		sizes = append(sizes, config.Size{
-			Width:  imgW,
-			Height: imgH,
-		})
+// ServedModel returns the name of the model and the version that served the last request of the client.
+func (c *FaceDetectionClient) ServedModel() (string, string) {
+	return c.requests.ServedModel()
+}
 
-		modelRatio := float64(c.ModelConfig.Config.Input[0].Dims[2]) / float64(c.ModelConfig.Config.Input[0].Dims[1])
+// Instrument records the duration of the preprocess, network and postprocess stages of the calls of the client.
+func (c *FaceDetectionClient) Instrument(metrics *Metrics) {
+	c.requests.Instrument(metrics)
+}
 
-		var newWidth, newHeight int64
-		if imgRatio > modelRatio {
-			newWidth = c.ModelConfig.Config.Input[0].Dims[2]
-			newHeight = int64(float64(newWidth) / imgRatio)
-		} else {
-			newHeight = c.ModelConfig.Config.Input[0].Dims[1]
-			newWidth = int64(float64(newHeight) * imgRatio)
-		}
+// ColorOrder returns the color order of the images expected by the client, set by the color order of its params.
+// Images are sent to the model as is, the SwapRB param does not apply.
+func (c *FaceDetectionClient) ColorOrder() utils.ColorOrder {
+	order, _ := paramsColorOrder(c.ModelParams.ColorOrder, false)
+	return order
+}
 
-		resizedImg := gocv.NewMat()
-		defer resizedImg.Close()
-		gocv.Resize(input, &resizedImg, image.Point{X: int(newWidth), Y: int(newHeight)}, 0.0, 0.0, gocv.InterpolationLinear)
-
-		scaledImg := gocv.NewMatWithSizesWithScalar(
-			[]int{
-				int(c.ModelConfig.Config.Input[0].Dims[1]),
-				int(c.ModelConfig.Config.Input[0].Dims[2]),
-			},
-			gocv.MatTypeCV8UC3,
-			gocv.NewScalar(0, 0, 0, 0),
-		)
-
-		roi := scaledImg.Region(image.Rect(0, 0, int(newWidth), int(newHeight)))
-
-		gocv.Resize(resizedImg, &roi, image.Point{X: roi.Size()[1], Y: roi.Size()[0]}, 0, 0, gocv.InterpolationLinear)
-		imgTensors := tensor.New(
-			tensor.Of(tensor.Float32),
-			tensor.WithShape(
-				int(c.ModelConfig.Config.Input[0].Dims[0]),
-				int(c.ModelConfig.Config.Input[0].Dims[1]),
-				int(c.ModelConfig.Config.Input[0].Dims[2]),
-			),
-		)
-
-		for z := range 3 {
-			for y := range int(c.ModelConfig.Config.Input[0].Dims[1]) {
-				for x := range int(c.ModelConfig.Config.Input[0].Dims[2]) {
-					err := imgTensors.SetAt((float32(scaledImg.GetVecbAt(y, x)[z])-float32(c.ModelParams.Mean))*float32(c.ModelParams.Scale), z, y, x)
-					if err != nil {
-						return nil, nil, err
-					}
-				}
-			}
-		}
-		err := imgTensors.T(2, 0, 1)
+// inputSize returns the model input height and width.
+func (c *FaceDetectionClient) inputSize() (int, int) {
+	return int(c.ModelConfig.Config.Input[0].Dims[1]), int(c.ModelConfig.Config.Input[0].Dims[2])
+}
+
+func (c *FaceDetectionClient) preprocess(rawInputTensors []gocv.Mat) ([][]float32, []utils.Letterbox, error) {
+	return c.preprocessBatch([][]gocv.Mat{rawInputTensors[:1]})
+}
+
+// preprocessBatch letterboxes each image into a (3, H, W) input buffer from the request pool.
+func (c *FaceDetectionClient) preprocessBatch(rawInputTensors [][]gocv.Mat) ([][]float32, []utils.Letterbox, error) {
+	inputs := rawInputTensors[0]
+	outputs := make([][]float32, 0, len(inputs))
+	letterboxes := make([]utils.Letterbox, 0, len(inputs))
+	inputH, inputW := c.inputSize()
+
+	for _, input := range inputs {
+		chw, lb, err := letterboxInput(input, inputH, inputW, c.ModelParams, 0, c.requests.Buffer(0, 3*inputH*inputW))
 		if err != nil {
 			return nil, nil, err
 		}
-		outputs = append(outputs, imgTensors)
+		outputs = append(outputs, chw)
+		letterboxes = append(letterboxes, lb)
 	}
-	return outputs, sizes, nil
+	return outputs, letterboxes, nil
 }
 
-func (c *FaceDetectionClient) postprocessBatch(rawOutputs []*tensor.Dense, sizes []config.Size) ([][]config.FaceDetectionOutput, error) {
+// normalizedToImage maps interleaved (x, y) coordinates normalized to the model input to image pixel coordinates.
+func normalizedToImage(t tensor.View, inputH, inputW int, lb utils.Letterbox) *tensor.Dense {
+	coords := slices.Clone(t.Data().([]float32))
+	for i := 0; i+1 < len(coords); i += 2 {
+		coords[i] *= float32(inputW)
+		coords[i+1] *= float32(inputH)
+	}
+	lb.PointsToImage(coords)
+	return tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(t.Shape()...), tensor.WithBacking(coords))
+}
+
+func (c *FaceDetectionClient) postprocessBatch(rawOutputs []*tensor.Dense, letterboxes []utils.Letterbox) ([][]config.FaceDetectionOutput, error) {
 
 	results := make([][]config.FaceDetectionOutput, 0)
 
@@ -192,10 +154,7 @@ func (c *FaceDetectionClient) postprocessBatch(rawOutputs []*tensor.Dense, sizes
 			return nil, err
 		}
 
-		scale := slices.Max(c.ModelConfig.Config.Input[0].Dims)
-		if len(sizes) > 0 {
-			scale = int64(sizes[b].Max())
-		}
+		inputH, inputW := c.inputSize()
 		for i := range numDets.Size() {
 			score, err := scores.Slice(tensor.S(i))
 			if err != nil {
@@ -209,29 +168,17 @@ func (c *FaceDetectionClient) postprocessBatch(rawOutputs []*tensor.Dense, sizes
 			if err != nil {
 				return nil, err
 			}
-			scaledBox, err := box.Apply(func(x float32) float32 {
-				return x * float32(scale)
-			})
-			if err != nil {
-				return nil, err
-			}
 
 			landmark, err := landmarks.Slice(tensor.S(i))
 			if err != nil {
 				return nil, err
 			}
-			scaledLandmark, err := landmark.Apply(func(x float32) float32 {
-				return x * float32(scale)
-			})
-			if err != nil {
-				return nil, err
-			}
 
 			res = append(res, config.FaceDetectionOutput{
-				Box:      scaledBox.(*tensor.Dense),
+				Box:      normalizedToImage(box, inputH, inputW, letterboxes[b]),
 				Score:    score.(*tensor.Dense),
 				ClassID:  classID.(*tensor.Dense),
-				Landmark: scaledLandmark.(*tensor.Dense),
+				Landmark: normalizedToImage(landmark, inputH, inputW, letterboxes[b]),
 			})
 		}
 		results = append(results, res)
@@ -241,7 +188,7 @@ func (c *FaceDetectionClient) postprocessBatch(rawOutputs []*tensor.Dense, sizes
 	return results, nil
 }
 
-func (c *FaceDetectionClient) postprocess(rawOutputs []*tensor.Dense, sizes []config.Size) ([]config.FaceDetectionOutput, error) {
+func (c *FaceDetectionClient) postprocess(rawOutputs []*tensor.Dense, letterboxes []utils.Letterbox) ([]config.FaceDetectionOutput, error) {
 
 	results := make([]config.FaceDetectionOutput, 0)
 	numDets, err := rawOutputs[0].Slice(tensor.S(0))
@@ -265,10 +212,7 @@ func (c *FaceDetectionClient) postprocess(rawOutputs []*tensor.Dense, sizes []co
 		return nil, err
 	}
 
-	scale := slices.Max(c.ModelConfig.Config.Input[0].Dims)
-	if len(sizes) > 0 {
-		scale = int64(sizes[0].Max())
-	}
+	inputH, inputW := c.inputSize()
 	for i := range numDets.Size() {
 		score, err := scores.Slice(tensor.S(i))
 		if err != nil {
@@ -282,37 +226,31 @@ func (c *FaceDetectionClient) postprocess(rawOutputs []*tensor.Dense, sizes []co
 		if err != nil {
 			return nil, err
 		}
-		scaledBox, err := box.Apply(func(x float32) float32 {
-			return x * float32(scale)
-		})
-		if err != nil {
-			return nil, err
-		}
 
 		landmark, err := landmarks.Slice(tensor.S(i))
 		if err != nil {
 			return nil, err
 		}
-		scaledLandmark, err := landmark.Apply(func(x float32) float32 {
-			return x * float32(scale)
-		})
-		if err != nil {
-			return nil, err
-		}
 
 		results = append(results, config.FaceDetectionOutput{
-			Box:      scaledBox.(*tensor.Dense),
+			Box:      normalizedToImage(box, inputH, inputW, letterboxes[0]),
 			Score:    score.(*tensor.Dense),
 			ClassID:  classID.(*tensor.Dense),
-			Landmark: scaledLandmark.(*tensor.Dense),
+			Landmark: normalizedToImage(landmark, inputH, inputW, letterboxes[0]),
 		})
 	}
 	return results, nil
 }
 
 func (c *FaceDetectionClient) InferBatch(rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error) {
+	return c.InferBatchContext(context.Background(), rawInputTensors)
+}
 
-	inputTensors, sizes, err := c.preprocessBatch(rawInputTensors)
+func (c *FaceDetectionClient) InferBatchContext(ctx context.Context, rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error) {
+
+	start := time.Now()
+	inputTensors, letterboxes, err := c.preprocessBatch(rawInputTensors)
+	c.requests.Observe(StagePreprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -322,30 +260,22 @@ func (c *FaceDetectionClient) InferBatch(rawInputTensors [][]gocv.Mat) ([][]conf
 		outputs[idx] = make([]*tensor.Dense, 0)
 	}
 
+	dims := c.ModelConfig.Config.Input[0].Dims
+	rawResults := make([][]config.FaceDetectionOutput, 0, len(inputTensors))
 	for idx := 0; idx < len(inputTensors); idx++ {
-		modelRequest := &triton_proto.ModelInferRequest{
-			ModelName: c.ModelParams.ModelName,
-		}
+		modelRequest := c.requests.Get()
+		modelRequest.SetInput(0, inputTensors[idx], 1, dims[0], dims[1], dims[2])
 
-		modelInputs := make([]*triton_proto.ModelInferRequest_InferInputTensor, 0)
-		for _, inputCfg := range c.ModelConfig.Config.Input {
-			modelInput := &triton_proto.ModelInferRequest_InferInputTensor{
-				Name:     inputCfg.Name,
-				Datatype: inputCfg.DataType.String()[5:],
-				Shape:    []int64{1, inputCfg.Dims[0], inputCfg.Dims[1], inputCfg.Dims[2]},
-				Contents: &triton_proto.InferTensorContents{
-					Fp32Contents: inputTensors[idx].Float32s(),
-				},
-			}
-			modelInputs = append(modelInputs, modelInput)
-		}
-
-		modelRequest.Inputs = modelInputs
-		inferResp, err := c.tritonClient.ModelGRPCInfer(c.ModelParams.Timeout, modelRequest)
+		sent := time.Now()
+		inferResp, err := modelInfer(ctx, c.tritonClient, c.ModelParams.Timeout, modelRequest.Request)
+		c.requests.Observe(StageNetwork, sent, err)
+		c.requests.Put(modelRequest)
 		if err != nil {
 			return nil, err
 		}
+		c.requests.Record(inferResp)
 
+		imageOutputs := make([]*tensor.Dense, 0, len(inferResp.GetOutputs()))
 		for oIdx, output := range inferResp.GetOutputs() {
 			outputShape := make([]int, 0, len(output.Shape))
 			for _, shp := range output.Shape {
@@ -371,7 +301,22 @@ func (c *FaceDetectionClient) InferBatch(rawInputTensors [][]gocv.Mat) ([][]conf
 
 			}
 			outputs[oIdx] = append(outputs[oIdx], tensors)
+			imageOutputs = append(imageOutputs, tensors)
 		}
+
+		// Raw SCRFD heads are decoded per image since the number of detections differs between images.
+		if c.outputFormat == detectorOutputSCRFDRaw {
+			start = time.Now()
+			detOutputs, err := c.postprocessRaw(imageOutputs, letterboxes[idx])
+			c.requests.Observe(StagePostprocess, start, err)
+			if err != nil {
+				return nil, err
+			}
+			rawResults = append(rawResults, detOutputs)
+		}
+	}
+	if c.outputFormat == detectorOutputSCRFDRaw {
+		return rawResults, nil
 	}
 
 	concatenatedOutputs := make([]*tensor.Dense, len(outputs))
@@ -382,7 +327,9 @@ func (c *FaceDetectionClient) InferBatch(rawInputTensors [][]gocv.Mat) ([][]conf
 		}
 		concatenatedOutputs[i] = concatenated
 	}
-	detOutputs, err := c.postprocessBatch(concatenatedOutputs, sizes)
+	start = time.Now()
+	detOutputs, err := c.postprocessBatch(concatenatedOutputs, letterboxes)
+	c.requests.Observe(StagePostprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -391,35 +338,31 @@ func (c *FaceDetectionClient) InferBatch(rawInputTensors [][]gocv.Mat) ([][]conf
 }
 
 func (c *FaceDetectionClient) InferSingle(rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error) {
-	inputTensors, sizes, err := c.preprocess(rawInputTensors)
+	return c.InferSingleContext(context.Background(), rawInputTensors)
+}
+
+func (c *FaceDetectionClient) InferSingleContext(ctx context.Context, rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	start := time.Now()
+	inputTensors, letterboxes, err := c.preprocess(rawInputTensors)
+	c.requests.Observe(StagePreprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
 
 	outputs := make([]*tensor.Dense, 0)
 
-	modelRequest := &triton_proto.ModelInferRequest{
-		ModelName: c.ModelParams.ModelName,
-	}
+	dims := c.ModelConfig.Config.Input[0].Dims
+	modelRequest := c.requests.Get()
+	modelRequest.SetInput(0, inputTensors[0], 1, dims[0], dims[1], dims[2])
 
-	modelInputs := make([]*triton_proto.ModelInferRequest_InferInputTensor, 0)
-	for _, inputCfg := range c.ModelConfig.Config.Input {
-		modelInput := &triton_proto.ModelInferRequest_InferInputTensor{
-			Name:     inputCfg.Name,
-			Datatype: inputCfg.DataType.String()[5:],
-			Shape:    []int64{1, inputCfg.Dims[0], inputCfg.Dims[1], inputCfg.Dims[2]},
-			Contents: &triton_proto.InferTensorContents{
-				Fp32Contents: inputTensors[0].Float32s(),
-			},
-		}
-		modelInputs = append(modelInputs, modelInput)
-	}
-
-	modelRequest.Inputs = modelInputs
-	inferResp, err := c.tritonClient.ModelGRPCInfer(c.ModelParams.Timeout, modelRequest)
+	sent := time.Now()
+	inferResp, err := modelInfer(ctx, c.tritonClient, c.ModelParams.Timeout, modelRequest.Request)
+	c.requests.Observe(StageNetwork, sent, err)
+	c.requests.Put(modelRequest)
 	if err != nil {
 		return nil, err
 	}
+	c.requests.Record(inferResp)
 
 	for oIdx, output := range inferResp.GetOutputs() {
 		outputShape := make([]int, 0, len(output.Shape))
@@ -447,7 +390,14 @@ func (c *FaceDetectionClient) InferSingle(rawInputTensors []gocv.Mat) ([]config.
 		}
 		outputs = append(outputs, tensors)
 	}
-	detOutputs, err := c.postprocess(outputs, sizes)
+	start = time.Now()
+	var detOutputs []config.FaceDetectionOutput
+	if c.outputFormat == detectorOutputSCRFDRaw {
+		detOutputs, err = c.postprocessRaw(outputs, letterboxes[0])
+	} else {
+		detOutputs, err = c.postprocess(outputs, letterboxes)
+	}
+	c.requests.Observe(StagePostprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
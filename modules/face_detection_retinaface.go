@@ -0,0 +1,154 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"gorgonia.org/tensor"
+	"math"
+)
+
+// RetinaFaceClient detects faces with a RetinaFace model exporting prior-box regressions (loc), softmax face
+// probabilities (conf) and landmark regressions (landms) without NMS.
+type RetinaFaceClient struct {
+	*tritonFaceDetector
+}
+
+var (
+	retinaFaceSteps    = []int{8, 16, 32}
+	retinaFaceMinSizes = [][]float32{{16, 32}, {64, 128}, {256, 512}}
+	retinaFaceVariance = [2]float32{0.1, 0.2}
+)
+
+// NewRetinaFaceClient initializes a new RetinaFaceClient.
+func NewRetinaFaceClient(triton InferenceClient, cfg *config.FaceDetectionParams) (*RetinaFaceClient, error) {
+	detector, err := newTritonFaceDetector(triton, cfg, 0, decodeRetinaFace)
+	if err != nil {
+		return nil, err
+	}
+	detector.checkOutputs = checkRetinaFaceOutputs
+	return &RetinaFaceClient{detector}, nil
+}
+
+// retinaFacePriors returns the normalized (cx, cy, w, h) prior boxes of the model input.
+func retinaFacePriors(inputH, inputW int) [][4]float32 {
+	priors := make([][4]float32, 0)
+	for idx, step := range retinaFaceSteps {
+		rows := int(math.Ceil(float64(inputH) / float64(step)))
+		cols := int(math.Ceil(float64(inputW) / float64(step)))
+		for y := 0; y < rows; y++ {
+			for x := 0; x < cols; x++ {
+				for _, minSize := range retinaFaceMinSizes[idx] {
+					priors = append(priors, [4]float32{
+						(float32(x) + 0.5) * float32(step) / float32(inputW),
+						(float32(y) + 0.5) * float32(step) / float32(inputH),
+						minSize / float32(inputW),
+						minSize / float32(inputH),
+					})
+				}
+			}
+		}
+	}
+	return priors
+}
+
+// checkRetinaFaceOutputs checks that the model configuration has a single loc, conf and landms output, identified by
+// their last dimension, see decodeRetinaFace.
+func checkRetinaFaceOutputs(modelConfig *triton_proto.ModelConfig) []string {
+	diffs := make([]string, 0)
+	for _, dim := range []int64{4, 2, 10} {
+		found := 0
+		for _, output := range modelConfig.GetOutput() {
+			if dims := output.GetDims(); len(dims) > 0 && dims[len(dims)-1] == dim {
+				found++
+			}
+		}
+		if found != 1 {
+			diffs = append(diffs, fmt.Sprintf("RetinaFace outputs: got %d outputs with last dim %d, want 1", found, dim))
+		}
+	}
+	return diffs
+}
+
+// outputByLastDim returns the single output whose last dimension is dim.
+func outputByLastDim(outputs map[string]*tensor.Dense, dim int) (*tensor.Dense, error) {
+	var found *tensor.Dense
+	for name, output := range outputs {
+		shape := output.Shape()
+		if shape[len(shape)-1] != dim {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("ambiguous detector outputs with last dimension %d, including %s", dim, name)
+		}
+		found = output
+	}
+	if found == nil {
+		return nil, fmt.Errorf("missing detector output with last dimension %d", dim)
+	}
+	return found, nil
+}
+
+// decodeRetinaFace decodes RetinaFace outputs into detections in model input pixel coordinates.
+// Outputs are identified by their last dimension: 4 for loc, 2 for conf and 10 for landms.
+//
+// Inputs:
+//
+//   - outputs (map[string]*tensor.Dense): outputs of a single image.
+//   - inputH, inputW (int): model input size.
+//   - scoreThreshold (float32): minimum face score.
+//   - nmsThreshold (float32): IoU threshold of the non-maximum suppression.
+//
+// Outputs:
+//
+//   - boxes ([][4]float32): face bounding boxes.
+//   - scores ([]float32): face scores.
+//   - landmarks ([][10]float32): face landmarks.
+func decodeRetinaFace(outputs map[string]*tensor.Dense, inputH, inputW int, scoreThreshold, nmsThreshold float32) ([][4]float32, []float32, [][10]float32, error) {
+	locOutput, err := outputByLastDim(outputs, 4)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	confOutput, err := outputByLastDim(outputs, 2)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	landmsOutput, err := outputByLastDim(outputs, 10)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	priors := retinaFacePriors(inputH, inputW)
+	loc, conf, landms := locOutput.Float32s(), confOutput.Float32s(), landmsOutput.Float32s()
+	if len(loc) != 4*len(priors) || len(conf) != 2*len(priors) || len(landms) != 10*len(priors) {
+		return nil, nil, nil, fmt.Errorf("RetinaFace outputs do not match the %d priors of the model input %dx%d", len(priors), inputW, inputH)
+	}
+
+	w, h := float32(inputW), float32(inputH)
+	boxes := make([][4]float32, 0)
+	scores := make([]float32, 0)
+	landmarks := make([][10]float32, 0)
+	for i, prior := range priors {
+		score := conf[2*i+1]
+		if score < scoreThreshold {
+			continue
+		}
+		d := loc[4*i : 4*i+4]
+		cx := prior[0] + d[0]*retinaFaceVariance[0]*prior[2]
+		cy := prior[1] + d[1]*retinaFaceVariance[0]*prior[3]
+		bw := prior[2] * float32(math.Exp(float64(d[2]*retinaFaceVariance[1])))
+		bh := prior[3] * float32(math.Exp(float64(d[3]*retinaFaceVariance[1])))
+		boxes = append(boxes, [4]float32{(cx - bw/2) * w, (cy - bh/2) * h, (cx + bw/2) * w, (cy + bh/2) * h})
+		scores = append(scores, score)
+
+		var lmk [10]float32
+		for k := 0; k < 5; k++ {
+			lmk[2*k] = (prior[0] + landms[10*i+2*k]*retinaFaceVariance[0]*prior[2]) * w
+			lmk[2*k+1] = (prior[1] + landms[10*i+2*k+1]*retinaFaceVariance[0]*prior[3]) * h
+		}
+		landmarks = append(landmarks, lmk)
+	}
+
+	keptBoxes, keptScores, keptLandmarks := keepNMS(boxes, scores, landmarks, nmsThreshold)
+	return keptBoxes, keptScores, keptLandmarks, nil
+}
@@ -0,0 +1,44 @@
+package modules
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorgonia.org/tensor"
+	"testing"
+)
+
+func TestDecodeRetinaFace(t *testing.T) {
+	inputH, inputW := 64, 96
+	priors := retinaFacePriors(inputH, inputW)
+	n := len(priors)
+	loc := make([]float32, 4*n)
+	conf := make([]float32, 2*n)
+	landms := make([]float32, 10*n)
+
+	// The first stride 16 prior is centered at (8, 8) with a 64 pixel size.
+	idx := (inputH / 8) * (inputW / 8) * 2
+	assert.InDelta(t, 8.0/96, priors[idx][0], 1e-6)
+	assert.InDelta(t, 64.0/64, priors[idx][3], 1e-6)
+	conf[2*idx+1] = 0.95
+	conf[2*(idx+1)+1] = 0.1
+	copy(landms[10*idx:], []float32{-1, -1, 1, -1, 0, 0, -1, 1, 1, 1})
+
+	outputs := map[string]*tensor.Dense{
+		"loc":    tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, n, 4), tensor.WithBacking(loc)),
+		"conf":   tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, n, 2), tensor.WithBacking(conf)),
+		"landms": tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, n, 10), tensor.WithBacking(landms)),
+	}
+	boxes, scores, landmarks, err := decodeRetinaFace(outputs, inputH, inputW, 0.5, 0.4)
+	assert.NoError(t, err)
+	assert.Len(t, boxes, 1)
+	assert.Equal(t, float32(0.95), scores[0])
+	for i, v := range []float32{-24, -24, 40, 40} {
+		assert.InDelta(t, v, boxes[0][i], 1e-4)
+	}
+	for i, v := range []float32{1.6, 1.6, 14.4, 1.6, 8, 8, 1.6, 14.4, 14.4, 14.4} {
+		assert.InDelta(t, v, landmarks[0][i], 1e-4)
+	}
+
+	delete(outputs, "landms")
+	_, _, _, err = decodeRetinaFace(outputs, inputH, inputW, 0.5, 0.4)
+	assert.Error(t, err)
+}
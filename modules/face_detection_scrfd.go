@@ -0,0 +1,200 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"gorgonia.org/tensor"
+	"slices"
+	"strings"
+)
+
+type detectorOutputFormat int
+
+const (
+	// detectorOutputEndToEnd is a detector exported with NMS, producing num_dets, boxes, scores, classes and landmarks
+	// normalized to the model input.
+	detectorOutputEndToEnd detectorOutputFormat = iota
+	// detectorOutputSCRFDRaw is a plain SCRFD export producing per-stride score, distance-to-bbox and distance-to-kps heads.
+	detectorOutputSCRFDRaw
+)
+
+// endToEndOutputName is the output only present in detectors exported with NMS.
+const endToEndOutputName = "num_dets"
+
+var scrfdStrides = []int{8, 16, 32}
+
+// scrfdHead holds the raw outputs of a single SCRFD stride.
+type scrfdHead struct {
+	stride int
+	scores []float32
+	bboxes []float32
+	kps    []float32
+}
+
+// detectOutputFormat selects the detector decoding path from the outputs of the model configuration: end-to-end if the
+// num_dets output is present, raw SCRFD if the outputs are the score, bbox and keypoint heads checked by
+// checkSCRFDHeads, an error otherwise.
+func detectOutputFormat(modelConfig *triton_proto.ModelConfigResponse) (detectorOutputFormat, error) {
+	for _, output := range modelConfig.GetConfig().GetOutput() {
+		if output.Name == endToEndOutputName {
+			return detectorOutputEndToEnd, nil
+		}
+	}
+	if diffs := checkSCRFDHeads(modelConfig.GetConfig()); len(diffs) > 0 {
+		return 0, fmt.Errorf("model %s outputs are neither end-to-end nor raw SCRFD: %s", modelConfig.GetConfig().GetName(), strings.Join(diffs, "; "))
+	}
+	return detectorOutputSCRFDRaw, nil
+}
+
+// checkSCRFDHeads checks that the outputs of the model configuration hold a score and a bbox head, and optionally a
+// keypoint head, per stride, see groupSCRFDHeads.
+func checkSCRFDHeads(modelConfig *triton_proto.ModelConfig) []string {
+	heads := map[int64]int{}
+	for _, output := range modelConfig.GetOutput() {
+		dims := output.GetDims()
+		if len(dims) == 0 {
+			return []string{fmt.Sprintf("output %q: dims %v, want a last dim of 1, 4 or 10", output.GetName(), dims)}
+		}
+		heads[dims[len(dims)-1]]++
+	}
+
+	diffs := make([]string, 0)
+	if heads[1] != len(scrfdStrides) || heads[4] != len(scrfdStrides) {
+		diffs = append(diffs, fmt.Sprintf("raw SCRFD outputs: got %d score and %d bbox heads, want %d each", heads[1], heads[4], len(scrfdStrides)))
+	}
+	if heads[10] != 0 && heads[10] != len(scrfdStrides) {
+		diffs = append(diffs, fmt.Sprintf("raw SCRFD outputs: got %d keypoint heads, want 0 or %d", heads[10], len(scrfdStrides)))
+	}
+	if other := len(modelConfig.GetOutput()) - heads[1] - heads[4] - heads[10]; other > 0 {
+		diffs = append(diffs, fmt.Sprintf("raw SCRFD outputs: got %d outputs with a last dim other than 1, 4 or 10", other))
+	}
+	return diffs
+}
+
+// groupSCRFDHeads groups raw SCRFD outputs by stride. Heads are identified by their last dimension
+// (1 for scores, 4 for boxes, 10 for keypoints) and ordered by decreasing number of anchors, i.e. increasing stride.
+func groupSCRFDHeads(rawOutputs []*tensor.Dense) ([]scrfdHead, error) {
+	groups := map[int][]*tensor.Dense{}
+	for _, output := range rawOutputs {
+		shape := output.Shape()
+		lastDim := shape[len(shape)-1]
+		groups[lastDim] = append(groups[lastDim], output)
+	}
+
+	scores, bboxes, kps := groups[1], groups[4], groups[10]
+	if len(scores) != len(scrfdStrides) || len(bboxes) != len(scrfdStrides) {
+		return nil, fmt.Errorf("expected %d score and bbox heads in raw SCRFD outputs, got %d and %d", len(scrfdStrides), len(scores), len(bboxes))
+	}
+	if len(kps) != 0 && len(kps) != len(scrfdStrides) {
+		return nil, fmt.Errorf("expected %d keypoint heads in raw SCRFD outputs, got %d", len(scrfdStrides), len(kps))
+	}
+
+	byAnchors := func(a, b *tensor.Dense) int {
+		return b.Size()/b.Shape()[len(b.Shape())-1] - a.Size()/a.Shape()[len(a.Shape())-1]
+	}
+	slices.SortStableFunc(scores, byAnchors)
+	slices.SortStableFunc(bboxes, byAnchors)
+	slices.SortStableFunc(kps, byAnchors)
+
+	heads := make([]scrfdHead, 0, len(scrfdStrides))
+	for idx, stride := range scrfdStrides {
+		head := scrfdHead{
+			stride: stride,
+			scores: scores[idx].Float32s(),
+			bboxes: bboxes[idx].Float32s(),
+		}
+		if len(kps) > 0 {
+			head.kps = kps[idx].Float32s()
+		}
+		heads = append(heads, head)
+	}
+	return heads, nil
+}
+
+// scrfdAnchorCenters returns the (x, y) anchor centers of a stride, each repeated numAnchors times.
+func scrfdAnchorCenters(inputH, inputW, stride, numAnchors int) []float32 {
+	height, width := inputH/stride, inputW/stride
+	centers := make([]float32, 0, height*width*numAnchors*2)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for range numAnchors {
+				centers = append(centers, float32(x*stride), float32(y*stride))
+			}
+		}
+	}
+	return centers
+}
+
+// decodeSCRFD decodes raw SCRFD heads into detections in model input pixel coordinates.
+//
+// Inputs:
+//
+//   - rawOutputs ([]*tensor.Dense): raw outputs of a single image.
+//   - inputH, inputW (int): model input size.
+//   - scoreThreshold (float32): minimum face score.
+//   - nmsThreshold (float32): IoU threshold of the non-maximum suppression.
+//
+// Outputs:
+//
+//   - boxes ([][4]float32): face bounding boxes.
+//   - scores ([]float32): face scores.
+//   - landmarks ([][10]float32): face landmarks, zero if the model has no keypoint head.
+func decodeSCRFD(rawOutputs []*tensor.Dense, inputH, inputW int, scoreThreshold, nmsThreshold float32) ([][4]float32, []float32, [][10]float32, error) {
+	heads, err := groupSCRFDHeads(rawOutputs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	boxes := make([][4]float32, 0)
+	scores := make([]float32, 0)
+	landmarks := make([][10]float32, 0)
+
+	for _, head := range heads {
+		cells := (inputH / head.stride) * (inputW / head.stride)
+		if cells == 0 || len(head.scores)%cells != 0 {
+			return nil, nil, nil, fmt.Errorf("raw SCRFD stride %d head of size %d does not match the model input %dx%d", head.stride, len(head.scores), inputW, inputH)
+		}
+		centers := scrfdAnchorCenters(inputH, inputW, head.stride, len(head.scores)/cells)
+		stride := float32(head.stride)
+
+		for i, score := range head.scores {
+			if score < scoreThreshold {
+				continue
+			}
+			cx, cy := centers[2*i], centers[2*i+1]
+			d := head.bboxes[4*i : 4*i+4]
+			boxes = append(boxes, [4]float32{
+				cx - d[0]*stride,
+				cy - d[1]*stride,
+				cx + d[2]*stride,
+				cy + d[3]*stride,
+			})
+			scores = append(scores, score)
+
+			var lmk [10]float32
+			if head.kps != nil {
+				for k := 0; k < 5; k++ {
+					lmk[2*k] = cx + head.kps[10*i+2*k]*stride
+					lmk[2*k+1] = cy + head.kps[10*i+2*k+1]*stride
+				}
+			}
+			landmarks = append(landmarks, lmk)
+		}
+	}
+
+	keptBoxes, keptScores, keptLandmarks := keepNMS(boxes, scores, landmarks, nmsThreshold)
+	return keptBoxes, keptScores, keptLandmarks, nil
+}
+
+// postprocessRaw decodes the raw SCRFD outputs of a single image and maps them back to the image through its letterbox.
+func (c *FaceDetectionClient) postprocessRaw(rawOutputs []*tensor.Dense, lb utils.Letterbox) ([]config.FaceDetectionOutput, error) {
+	inputH, inputW := c.inputSize()
+
+	boxes, scores, landmarks, err := decodeSCRFD(rawOutputs, inputH, inputW, c.ModelParams.ScoreThreshold, c.ModelParams.NMSThreshold)
+	if err != nil {
+		return nil, err
+	}
+	return toFaceDetectionOutputs(boxes, scores, landmarks, lb), nil
+}
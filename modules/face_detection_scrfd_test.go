@@ -0,0 +1,82 @@
+package modules
+
+import (
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/stretchr/testify/assert"
+	"gorgonia.org/tensor"
+	"testing"
+)
+
+func genTestSCRFDOutputs(inputH, inputW, numAnchors int) []*tensor.Dense {
+	scores := make([]*tensor.Dense, 0)
+	bboxes := make([]*tensor.Dense, 0)
+	kps := make([]*tensor.Dense, 0)
+	for _, stride := range scrfdStrides {
+		n := (inputH / stride) * (inputW / stride) * numAnchors
+		scores = append(scores, tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(n, 1), tensor.WithBacking(make([]float32, n))))
+		bboxes = append(bboxes, tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(n, 4), tensor.WithBacking(make([]float32, 4*n))))
+		kps = append(kps, tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(n, 10), tensor.WithBacking(make([]float32, 10*n))))
+	}
+
+	// Place a face on the stride 16 anchor at cell (x=3, y=2) and a weaker duplicate on its second anchor.
+	width16 := inputW / 16
+	anchor := (2*width16 + 3) * numAnchors
+	s := scores[1].Float32s()
+	b := bboxes[1].Float32s()
+	k := kps[1].Float32s()
+	s[anchor], s[anchor+1] = 0.9, 0.8
+	copy(b[4*anchor:], []float32{1, 1, 2, 2})
+	copy(b[4*(anchor+1):], []float32{1, 1, 2, 2})
+	copy(k[10*anchor:], []float32{-0.5, -0.5, 0.5, -0.5, 0, 0, -0.5, 0.5, 0.5, 0.5})
+
+	// Raw exports do not guarantee head order.
+	return []*tensor.Dense{scores[2], scores[0], scores[1], bboxes[1], bboxes[0], bboxes[2], kps[0], kps[2], kps[1]}
+}
+
+func TestDetectOutputFormat(t *testing.T) {
+	endToEnd := &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{
+		Output: []*triton_proto.ModelOutput{{Name: "num_dets"}, {Name: "boxes"}, {Name: "scores"}, {Name: "classes"}, {Name: "landmarks"}},
+	}}
+	raw := &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{}}
+	for _, dim := range []int64{1, 1, 1, 4, 4, 4, 10, 10, 10} {
+		raw.Config.Output = append(raw.Config.Output, &triton_proto.ModelOutput{Name: "448", Dims: []int64{-1, dim}})
+	}
+	unknown := &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{
+		Output: []*triton_proto.ModelOutput{{Name: "boxes", Dims: []int64{-1, 4}}, {Name: "scores", Dims: []int64{-1, 1}}},
+	}}
+
+	format, err := detectOutputFormat(endToEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, detectorOutputEndToEnd, format)
+	format, err = detectOutputFormat(raw)
+	assert.NoError(t, err)
+	assert.Equal(t, detectorOutputSCRFDRaw, format)
+	_, err = detectOutputFormat(unknown)
+	assert.Error(t, err)
+}
+
+func TestDecodeSCRFD(t *testing.T) {
+	boxes, scores, landmarks, err := decodeSCRFD(genTestSCRFDOutputs(640, 640, 2), 640, 640, 0.5, 0.4)
+	assert.NoError(t, err)
+	assert.Len(t, boxes, 1)
+	assert.Equal(t, [4]float32{32, 16, 80, 64}, boxes[0])
+	assert.Equal(t, float32(0.9), scores[0])
+	assert.Equal(t, [10]float32{40, 24, 56, 24, 48, 32, 40, 40, 56, 40}, landmarks[0])
+}
+
+func TestCheckSCRFDHeads(t *testing.T) {
+	outputs := make([]*triton_proto.ModelOutput, 0)
+	for _, dim := range []int64{1, 1, 1, 4, 4, 4, 10, 10, 10} {
+		outputs = append(outputs, &triton_proto.ModelOutput{Name: "head", DataType: triton_proto.DataType_TYPE_FP32, Dims: []int64{-1, dim}})
+	}
+	assert.Empty(t, checkSCRFDHeads(&triton_proto.ModelConfig{Output: outputs}))
+	assert.Empty(t, checkSCRFDHeads(&triton_proto.ModelConfig{Output: outputs[:6]}))
+
+	assert.Equal(t, []string{
+		"raw SCRFD outputs: got 3 score and 2 bbox heads, want 3 each",
+		"raw SCRFD outputs: got 2 keypoint heads, want 0 or 3",
+	}, checkSCRFDHeads(&triton_proto.ModelConfig{Output: append(outputs[:5:5], outputs[7:]...)}))
+
+	outputs = append(outputs, &triton_proto.ModelOutput{Name: "anchors", Dims: []int64{-1, 2}})
+	assert.Equal(t, []string{"raw SCRFD outputs: got 1 outputs with a last dim other than 1, 4 or 10"}, checkSCRFDHeads(&triton_proto.ModelConfig{Output: outputs}))
+}
@@ -3,12 +3,14 @@ package modules
 import (
 	"fmt"
 	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
 	gotritonclient "github.com/okieraised/go-triton-client"
 	"github.com/stretchr/testify/assert"
 	"gocv.io/x/gocv"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
+	"gorgonia.org/tensor"
 	"testing"
 )
 
@@ -53,3 +55,15 @@ func TestNewFaceDetectionClient_InferSingle(t *testing.T) {
 
 	fmt.Println("outputs", outputs)
 }
+
+func TestNormalizedToImage(t *testing.T) {
+	// A 1000x500 image centered in a 320x640 (h x w) input is resized to 640x320 without padding,
+	// and a 400x400 image is resized to 320x320 with 160 pixels of horizontal padding.
+	lb := utils.NewLetterbox(1000, 500, 640, 320, true)
+	box := tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(4), tensor.WithBacking([]float32{0.25, 0.5, 0.5, 1}))
+	assert.Equal(t, []float32{250, 250, 500, 500}, normalizedToImage(box, 320, 640, lb).Float32s())
+
+	lb = utils.NewLetterbox(400, 400, 640, 320, true)
+	box = tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(4), tensor.WithBacking([]float32{0.25, 0, 0.75, 1}))
+	assert.Equal(t, []float32{0, 0, 400, 400}, normalizedToImage(box, 320, 640, lb).Float32s())
+}
@@ -0,0 +1,224 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"gocv.io/x/gocv"
+	"image"
+	"math"
+)
+
+// tileEdgeMargin is the distance in pixels under which a detection is considered cut by a tile edge.
+const tileEdgeMargin = 2
+
+// TiledFaceDetector runs a face detector on the whole image and on overlapping tiles of an image pyramid, so that small
+// faces of high-resolution images are not lost when the image is downsized to the model input.
+type TiledFaceDetector struct {
+	detector     FaceDetector
+	ModelParams  *config.FaceDetectionTilingParams
+	nmsThreshold float32
+}
+
+// NewTiledFaceDetector initializes a new TiledFaceDetector wrapping detector.
+// Detections of every pass are merged with NMS using nmsThreshold.
+func NewTiledFaceDetector(detector FaceDetector, cfg *config.FaceDetectionTilingParams, nmsThreshold float32) (*TiledFaceDetector, error) {
+	if detector == nil {
+		return nil, errors.New("face detector must not be nil")
+	}
+	if cfg == nil {
+		return nil, errors.New("face detection tiling params must not be nil")
+	}
+	if cfg.TileSize <= 0 {
+		return nil, errors.New("tile size must be positive")
+	}
+	if cfg.TileOverlap < 0 || cfg.TileOverlap >= 1 {
+		return nil, errors.New("tile overlap must be in [0, 1)")
+	}
+	for _, scale := range cfg.Scales {
+		if scale <= 0 {
+			return nil, errors.New("tiling scales must be positive")
+		}
+	}
+
+	return &TiledFaceDetector{
+		detector:     detector,
+		ModelParams:  cfg,
+		nmsThreshold: nmsThreshold,
+	}, nil
+}
+
+// CheckContract validates the model configuration of the wrapped detector, if it is a model client.
+func (c *TiledFaceDetector) CheckContract() error {
+	if checker, ok := c.detector.(ContractChecker); ok {
+		return checker.CheckContract()
+	}
+	return nil
+}
+
+// ServedModel returns the name and served version of the model of the wrapped detector, empty if it is not a model
+// client.
+func (c *TiledFaceDetector) ServedModel() (string, string) {
+	if modeler, ok := c.detector.(ServedModeler); ok {
+		return modeler.ServedModel()
+	}
+	return "", ""
+}
+
+// Instrument records the stages of the calls of the wrapped detector, if it is a model client.
+func (c *TiledFaceDetector) Instrument(metrics *Metrics) {
+	if instrumenter, ok := c.detector.(Instrumenter); ok {
+		instrumenter.Instrument(metrics)
+	}
+}
+
+// tileOrigins returns the origins of the tiles of size tile covering length, with adjacent tiles overlapping
+// by the overlap ratio and the last tile aligned to the end.
+func tileOrigins(length, tile int, overlap float32) []int {
+	if length <= tile {
+		return []int{0}
+	}
+	stride := max(1, int(float32(tile)*(1-overlap)))
+	origins := make([]int, 0, length/stride+1)
+	for origin := 0; origin+tile < length; origin += stride {
+		origins = append(origins, origin)
+	}
+	return append(origins, length-tile)
+}
+
+// isCutByTile determines if a box in tile coordinates touches a tile edge that is not an image edge.
+// Such faces are fully contained in a neighboring tile as long as they are smaller than the tile overlap.
+func isCutByTile(box [4]float32, tile image.Rectangle, imgW, imgH int) bool {
+	return (tile.Min.X > 0 && box[0] <= tileEdgeMargin) ||
+		(tile.Min.Y > 0 && box[1] <= tileEdgeMargin) ||
+		(tile.Max.X < imgW && box[2] >= float32(tile.Dx()-tileEdgeMargin)) ||
+		(tile.Max.Y < imgH && box[3] >= float32(tile.Dy()-tileEdgeMargin))
+}
+
+// appendDetections appends the detections of a pass mapped to image coordinates, given the pass origin and scale.
+func appendDetections(
+	results []config.FaceDetectionOutput,
+	boxes [][4]float32,
+	scores []float32,
+	landmarks [][10]float32,
+	origin image.Point,
+	scale float32,
+	skip func(box [4]float32) bool,
+) ([][4]float32, []float32, [][10]float32) {
+	offset := [2]float32{float32(origin.X), float32(origin.Y)}
+	for _, result := range results {
+		var box [4]float32
+		var lmk [10]float32
+		copy(box[:], result.Box.Float32s())
+		copy(lmk[:], result.Landmark.Float32s())
+		if skip != nil && skip(box) {
+			continue
+		}
+		for i := range box {
+			box[i] = (box[i] + offset[i%2]) / scale
+		}
+		for i := range lmk {
+			lmk[i] = (lmk[i] + offset[i%2]) / scale
+		}
+		boxes = append(boxes, box)
+		scores = append(scores, result.Score.Float32s()[0])
+		landmarks = append(landmarks, lmk)
+	}
+	return boxes, scores, landmarks
+}
+
+// ColorOrder returns the color order of the images expected by the wrapped detector.
+func (c *TiledFaceDetector) ColorOrder() utils.ColorOrder {
+	return c.detector.ColorOrder()
+}
+
+// detectTiles detects faces on the overlapping tiles of the image resized by scale, in image coordinates.
+func (c *TiledFaceDetector) detectTiles(ctx context.Context, img gocv.Mat, scale float32, boxes [][4]float32, scores []float32, landmarks [][10]float32) ([][4]float32, []float32, [][10]float32, error) {
+	tileSize := c.ModelParams.TileSize
+	scaledW := int(math.Round(float64(img.Cols()) * float64(scale)))
+	scaledH := int(math.Round(float64(img.Rows()) * float64(scale)))
+	// The whole-image pass already covers scales fitting in a single tile.
+	if max(scaledW, scaledH) <= tileSize {
+		return boxes, scores, landmarks, nil
+	}
+
+	scaled := img
+	if scale != 1 {
+		scaled = gocv.NewMat()
+		defer scaled.Close()
+		gocv.Resize(img, &scaled, image.Point{X: scaledW, Y: scaledH}, 0, 0, gocv.InterpolationArea)
+	}
+
+	for _, y0 := range tileOrigins(scaledH, tileSize, c.ModelParams.TileOverlap) {
+		for _, x0 := range tileOrigins(scaledW, tileSize, c.ModelParams.TileOverlap) {
+			rect := image.Rect(x0, y0, min(x0+tileSize, scaledW), min(y0+tileSize, scaledH))
+			tile := scaled.Region(rect)
+			results, err := c.detector.InferSingleContext(ctx, []gocv.Mat{tile})
+			_ = tile.Close()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			boxes, scores, landmarks = appendDetections(results, boxes, scores, landmarks, rect.Min, scale, func(box [4]float32) bool {
+				return isCutByTile(box, rect, scaledW, scaledH)
+			})
+		}
+	}
+	return boxes, scores, landmarks, nil
+}
+
+func (c *TiledFaceDetector) detect(ctx context.Context, img gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	full, err := c.detector.InferSingleContext(ctx, []gocv.Mat{img})
+	if err != nil {
+		return nil, err
+	}
+	imgW, imgH := img.Cols(), img.Rows()
+	if max(imgW, imgH) < c.ModelParams.MinImageSide {
+		return full, nil
+	}
+
+	boxes, scores, landmarks := appendDetections(full, nil, nil, nil, image.Point{}, 1, nil)
+	for _, scale := range c.ModelParams.Scales {
+		boxes, scores, landmarks, err = c.detectTiles(ctx, img, scale, boxes, scores, landmarks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keptBoxes, keptScores, keptLandmarks := keepNMS(boxes, scores, landmarks, c.nmsThreshold)
+	return toFaceDetectionOutputs(keptBoxes, keptScores, keptLandmarks, utils.NewLetterbox(imgW, imgH, imgW, imgH, false)), nil
+}
+
+// InferSingle detects faces on the first image.
+func (c *TiledFaceDetector) InferSingle(rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	return c.InferSingleContext(context.Background(), rawInputTensors)
+}
+
+// InferSingleContext detects faces on the first image within ctx.
+func (c *TiledFaceDetector) InferSingleContext(ctx context.Context, rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	if len(rawInputTensors) == 0 {
+		return nil, errors.New("no input image")
+	}
+	return c.detect(ctx, rawInputTensors[0])
+}
+
+// InferBatch detects faces on every image of the batch.
+func (c *TiledFaceDetector) InferBatch(rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error) {
+	return c.InferBatchContext(context.Background(), rawInputTensors)
+}
+
+// InferBatchContext detects faces on every image of the batch within ctx.
+func (c *TiledFaceDetector) InferBatchContext(ctx context.Context, rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error) {
+	if len(rawInputTensors) == 0 {
+		return nil, errors.New("no input image")
+	}
+	results := make([][]config.FaceDetectionOutput, 0, len(rawInputTensors[0]))
+	for _, img := range rawInputTensors[0] {
+		detOutputs, err := c.detect(ctx, img)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, detOutputs)
+	}
+	return results, nil
+}
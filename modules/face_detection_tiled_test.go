@@ -0,0 +1,112 @@
+package modules
+
+import (
+	"context"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// brightSpotDetector mimics a face detector losing small faces on large inputs: it returns the bounding box
+// of the bright pixels as a face only if the image fits in maxSide.
+type brightSpotDetector struct {
+	maxSide int
+}
+
+func (d brightSpotDetector) InferSingleContext(_ context.Context, rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	return d.InferSingle(rawInputTensors)
+}
+
+func (d brightSpotDetector) InferBatchContext(_ context.Context, rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error) {
+	return d.InferBatch(rawInputTensors)
+}
+
+func (d brightSpotDetector) InferSingle(rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	img := rawInputTensors[0]
+	if max(img.Rows(), img.Cols()) > d.maxSide {
+		return nil, nil
+	}
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(img, &gray, gocv.ColorRGBToGray)
+
+	x1, y1, x2, y2 := gray.Cols(), gray.Rows(), -1, -1
+	for y := range gray.Rows() {
+		for x := range gray.Cols() {
+			if gray.GetUCharAt(y, x) > 127 {
+				x1, y1, x2, y2 = min(x1, x), min(y1, y), max(x2, x+1), max(y2, y+1)
+			}
+		}
+	}
+	if x2 < 0 {
+		return nil, nil
+	}
+	box := [4]float32{float32(x1), float32(y1), float32(x2), float32(y2)}
+	lmk := [10]float32{}
+	lb := utils.NewLetterbox(img.Cols(), img.Rows(), img.Cols(), img.Rows(), false)
+	return toFaceDetectionOutputs([][4]float32{box}, []float32{0.9}, [][10]float32{lmk}, lb), nil
+}
+
+func (d brightSpotDetector) ColorOrder() utils.ColorOrder {
+	return utils.ColorOrderRGB
+}
+
+func (d brightSpotDetector) InferBatch(rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error) {
+	results := make([][]config.FaceDetectionOutput, 0)
+	for _, img := range rawInputTensors[0] {
+		res, err := d.InferSingle([]gocv.Mat{img})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func TestTileOrigins(t *testing.T) {
+	assert.Equal(t, []int{0}, tileOrigins(500, 640, 0.25))
+	assert.Equal(t, []int{0, 480, 960, 1360}, tileOrigins(2000, 640, 0.25))
+	assert.Equal(t, []int{0, 360}, tileOrigins(1000, 640, 0))
+}
+
+func TestIsCutByTile(t *testing.T) {
+	tile := image.Rect(480, 0, 1120, 640)
+	assert.True(t, isCutByTile([4]float32{0, 100, 30, 130}, tile, 2000, 1500))
+	assert.True(t, isCutByTile([4]float32{600, 100, 640, 130}, tile, 2000, 1500))
+	assert.False(t, isCutByTile([4]float32{100, 0, 130, 30}, tile, 2000, 1500))
+	assert.False(t, isCutByTile([4]float32{100, 100, 130, 130}, tile, 2000, 1500))
+}
+
+func TestTiledFaceDetector_InferSingle(t *testing.T) {
+	img := gocv.NewMatWithSize(1500, 2000, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	gocv.Rectangle(&img, image.Rect(1300, 700, 1330, 730), color.RGBA{R: 255, G: 255, B: 255}, -1)
+	// Crosses the right edge of the first tile column.
+	gocv.Rectangle(&img, image.Rect(620, 100, 650, 130), color.RGBA{R: 255, G: 255, B: 255}, -1)
+
+	detector, err := NewTiledFaceDetector(brightSpotDetector{maxSide: 640}, config.DefaultFaceDetectionTilingParams, 0.4)
+	assert.NoError(t, err)
+
+	results, err := detector.InferSingle([]gocv.Mat{img})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	expected := [][]float32{{620, 100, 650, 130}, {1300, 700, 1330, 730}}
+	for _, result := range results {
+		box := result.Box.Float32s()
+		idx := 0
+		if box[0] > 1000 {
+			idx = 1
+		}
+		for i := range box {
+			assert.InDelta(t, expected[idx][i], box[i], 2)
+		}
+	}
+
+	_, err = NewTiledFaceDetector(brightSpotDetector{}, &config.FaceDetectionTilingParams{TileSize: 640, TileOverlap: 1}, 0.4)
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"gorgonia.org/tensor"
+)
+
+// YOLOv8FaceClient detects faces with a YOLOv8-face pose model exporting a single (1, 20, N) output of
+// (cx, cy, w, h, score) followed by five (x, y, visibility) keypoints, without NMS.
+type YOLOv8FaceClient struct {
+	*tritonFaceDetector
+}
+
+// yoloV8FaceChannels is the number of channels per anchor of the YOLOv8-face output.
+const yoloV8FaceChannels = 4 + 1 + 5*3
+
+// yoloV8FacePadValue is the gray letterbox padding used when training YOLO models.
+const yoloV8FacePadValue = 114
+
+// NewYOLOv8FaceClient initializes a new YOLOv8FaceClient.
+func NewYOLOv8FaceClient(triton InferenceClient, cfg *config.FaceDetectionParams) (*YOLOv8FaceClient, error) {
+	detector, err := newTritonFaceDetector(triton, cfg, yoloV8FacePadValue, decodeYOLOv8Face)
+	if err != nil {
+		return nil, err
+	}
+	detector.checkOutputs = checkYOLOv8FaceOutputs
+	return &YOLOv8FaceClient{detector}, nil
+}
+
+// checkYOLOv8FaceOutputs checks that the model configuration has a single output of yoloV8FaceChannels channels per
+// anchor, in either layout.
+func checkYOLOv8FaceOutputs(modelConfig *triton_proto.ModelConfig) []string {
+	outputs := modelConfig.GetOutput()
+	if len(outputs) != 1 {
+		return []string{fmt.Sprintf("YOLOv8-face outputs: got %d, want 1", len(outputs))}
+	}
+	dims := outputs[0].GetDims()
+	if len(dims) < 2 || (dims[len(dims)-2] != yoloV8FaceChannels && dims[len(dims)-1] != yoloV8FaceChannels) {
+		return []string{fmt.Sprintf("output 0 %q: dims %v, want %d channels per anchor", outputs[0].GetName(), dims, yoloV8FaceChannels)}
+	}
+	return nil
+}
+
+// decodeYOLOv8Face decodes the YOLOv8-face output into detections in model input pixel coordinates.
+// Both the channel-first (1, 20, N) export and its transposed (1, N, 20) form are accepted.
+//
+// Inputs:
+//
+//   - outputs (map[string]*tensor.Dense): outputs of a single image.
+//   - inputH, inputW (int): model input size.
+//   - scoreThreshold (float32): minimum face score.
+//   - nmsThreshold (float32): IoU threshold of the non-maximum suppression.
+//
+// Outputs:
+//
+//   - boxes ([][4]float32): face bounding boxes.
+//   - scores ([]float32): face scores.
+//   - landmarks ([][10]float32): face landmarks.
+func decodeYOLOv8Face(outputs map[string]*tensor.Dense, inputH, inputW int, scoreThreshold, nmsThreshold float32) ([][4]float32, []float32, [][10]float32, error) {
+	if len(outputs) != 1 {
+		return nil, nil, nil, fmt.Errorf("expected a single YOLOv8-face output, got %d", len(outputs))
+	}
+	var output *tensor.Dense
+	for _, o := range outputs {
+		output = o
+	}
+
+	shape := output.Shape()
+	if len(shape) < 2 {
+		return nil, nil, nil, fmt.Errorf("invalid YOLOv8-face output shape %v", shape)
+	}
+	data := output.Float32s()
+
+	// at returns channel c of anchor i for either memory layout.
+	var numAnchors int
+	var at func(i, c int) float32
+	switch {
+	case shape[len(shape)-2] == yoloV8FaceChannels:
+		numAnchors = shape[len(shape)-1]
+		at = func(i, c int) float32 { return data[c*numAnchors+i] }
+	case shape[len(shape)-1] == yoloV8FaceChannels:
+		numAnchors = shape[len(shape)-2]
+		at = func(i, c int) float32 { return data[i*yoloV8FaceChannels+c] }
+	default:
+		return nil, nil, nil, fmt.Errorf("YOLOv8-face output shape %v has no dimension of %d channels", shape, yoloV8FaceChannels)
+	}
+	if len(data) != numAnchors*yoloV8FaceChannels {
+		return nil, nil, nil, fmt.Errorf("YOLOv8-face output shape %v must have a batch size of 1", shape)
+	}
+
+	boxes := make([][4]float32, 0)
+	scores := make([]float32, 0)
+	landmarks := make([][10]float32, 0)
+	for i := 0; i < numAnchors; i++ {
+		score := at(i, 4)
+		if score < scoreThreshold {
+			continue
+		}
+		cx, cy, bw, bh := at(i, 0), at(i, 1), at(i, 2), at(i, 3)
+		boxes = append(boxes, [4]float32{cx - bw/2, cy - bh/2, cx + bw/2, cy + bh/2})
+		scores = append(scores, score)
+
+		var lmk [10]float32
+		for k := 0; k < 5; k++ {
+			lmk[2*k] = at(i, 5+3*k)
+			lmk[2*k+1] = at(i, 5+3*k+1)
+		}
+		landmarks = append(landmarks, lmk)
+	}
+
+	keptBoxes, keptScores, keptLandmarks := keepNMS(boxes, scores, landmarks, nmsThreshold)
+	return keptBoxes, keptScores, keptLandmarks, nil
+}
@@ -0,0 +1,41 @@
+package modules
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorgonia.org/tensor"
+	"testing"
+)
+
+func TestDecodeYOLOv8Face(t *testing.T) {
+	numAnchors := 3
+	anchors := [][]float32{
+		{50, 60, 20, 40, 0.9, 45, 50, 1, 55, 50, 1, 50, 60, 1, 46, 70, 1, 54, 70, 1},
+		{51, 61, 20, 40, 0.7, 45, 50, 1, 55, 50, 1, 50, 60, 1, 46, 70, 1, 54, 70, 1},
+		{10, 10, 4, 4, 0.1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	channelFirst := make([]float32, numAnchors*yoloV8FaceChannels)
+	channelLast := make([]float32, 0, numAnchors*yoloV8FaceChannels)
+	for i, anchor := range anchors {
+		for c, v := range anchor {
+			channelFirst[c*numAnchors+i] = v
+		}
+		channelLast = append(channelLast, anchor...)
+	}
+
+	for _, output := range []*tensor.Dense{
+		tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, yoloV8FaceChannels, numAnchors), tensor.WithBacking(channelFirst)),
+		tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, numAnchors, yoloV8FaceChannels), tensor.WithBacking(channelLast)),
+	} {
+		boxes, scores, landmarks, err := decodeYOLOv8Face(map[string]*tensor.Dense{"output0": output}, 640, 640, 0.5, 0.45)
+		assert.NoError(t, err)
+		assert.Len(t, boxes, 1)
+		assert.Equal(t, float32(0.9), scores[0])
+		assert.Equal(t, [4]float32{40, 40, 60, 80}, boxes[0])
+		assert.Equal(t, [10]float32{45, 50, 55, 50, 50, 60, 46, 70, 54, 70}, landmarks[0])
+	}
+
+	_, _, _, err := decodeYOLOv8Face(map[string]*tensor.Dense{
+		"output0": tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 6, 3), tensor.WithBacking(make([]float32, 18))),
+	}, 640, 640, 0.5, 0.45)
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"gorgonia.org/tensor"
+	"math"
+)
+
+// YuNetClient detects faces with an OpenCV YuNet model exporting cls_<stride>, obj_<stride>, bbox_<stride>
+// and kps_<stride> heads, one anchor per cell, without NMS.
+type YuNetClient struct {
+	*tritonFaceDetector
+}
+
+var yuNetStrides = []int{8, 16, 32}
+
+// NewYuNetClient initializes a new YuNetClient.
+func NewYuNetClient(triton InferenceClient, cfg *config.FaceDetectionParams) (*YuNetClient, error) {
+	detector, err := newTritonFaceDetector(triton, cfg, 0, decodeYuNet)
+	if err != nil {
+		return nil, err
+	}
+	detector.checkOutputs = checkYuNetOutputs
+	return &YuNetClient{detector}, nil
+}
+
+// checkYuNetOutputs checks that the model configuration has the cls, obj, bbox and kps outputs of every stride.
+func checkYuNetOutputs(modelConfig *triton_proto.ModelConfig) []string {
+	names := make(map[string]bool, len(modelConfig.GetOutput()))
+	for _, output := range modelConfig.GetOutput() {
+		names[output.GetName()] = true
+	}
+	diffs := make([]string, 0)
+	for _, stride := range yuNetStrides {
+		for _, name := range []string{"cls", "obj", "bbox", "kps"} {
+			if !names[fmt.Sprintf("%s_%d", name, stride)] {
+				diffs = append(diffs, fmt.Sprintf("YuNet outputs: missing %s_%d", name, stride))
+			}
+		}
+	}
+	return diffs
+}
+
+// decodeYuNet decodes YuNet outputs into detections in model input pixel coordinates.
+//
+// Inputs:
+//
+//   - outputs (map[string]*tensor.Dense): outputs of a single image.
+//   - inputH, inputW (int): model input size.
+//   - scoreThreshold (float32): minimum face score.
+//   - nmsThreshold (float32): IoU threshold of the non-maximum suppression.
+//
+// Outputs:
+//
+//   - boxes ([][4]float32): face bounding boxes.
+//   - scores ([]float32): face scores.
+//   - landmarks ([][10]float32): face landmarks.
+func decodeYuNet(outputs map[string]*tensor.Dense, inputH, inputW int, scoreThreshold, nmsThreshold float32) ([][4]float32, []float32, [][10]float32, error) {
+	boxes := make([][4]float32, 0)
+	scores := make([]float32, 0)
+	landmarks := make([][10]float32, 0)
+
+	for _, stride := range yuNetStrides {
+		heads := make(map[string][]float32, 4)
+		for _, name := range []string{"cls", "obj", "bbox", "kps"} {
+			output, ok := outputs[fmt.Sprintf("%s_%d", name, stride)]
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("missing YuNet output %s_%d", name, stride)
+			}
+			heads[name] = output.Float32s()
+		}
+
+		cols := inputW / stride
+		cells := (inputH / stride) * cols
+		if len(heads["cls"]) != cells || len(heads["obj"]) != cells || len(heads["bbox"]) != 4*cells || len(heads["kps"]) != 10*cells {
+			return nil, nil, nil, fmt.Errorf("YuNet stride %d outputs do not match the model input %dx%d", stride, inputW, inputH)
+		}
+
+		s := float32(stride)
+		for i := 0; i < cells; i++ {
+			score := float32(math.Sqrt(float64(utils.Clip(heads["cls"][i], 0, 1) * utils.Clip(heads["obj"][i], 0, 1))))
+			if score < scoreThreshold {
+				continue
+			}
+			col, row := float32(i%cols), float32(i/cols)
+			d := heads["bbox"][4*i : 4*i+4]
+			cx, cy := (col+d[0])*s, (row+d[1])*s
+			bw := float32(math.Exp(float64(d[2]))) * s
+			bh := float32(math.Exp(float64(d[3]))) * s
+			boxes = append(boxes, [4]float32{cx - bw/2, cy - bh/2, cx + bw/2, cy + bh/2})
+			scores = append(scores, score)
+
+			var lmk [10]float32
+			kps := heads["kps"][10*i : 10*i+10]
+			for k := 0; k < 5; k++ {
+				lmk[2*k] = (col + kps[2*k]) * s
+				lmk[2*k+1] = (row + kps[2*k+1]) * s
+			}
+			landmarks = append(landmarks, lmk)
+		}
+	}
+
+	keptBoxes, keptScores, keptLandmarks := keepNMS(boxes, scores, landmarks, nmsThreshold)
+	return keptBoxes, keptScores, keptLandmarks, nil
+}
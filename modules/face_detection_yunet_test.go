@@ -0,0 +1,40 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorgonia.org/tensor"
+	"testing"
+)
+
+func TestDecodeYuNet(t *testing.T) {
+	inputH, inputW := 64, 64
+	outputs := map[string]*tensor.Dense{}
+	for _, stride := range yuNetStrides {
+		cells := (inputH / stride) * (inputW / stride)
+		for name, channels := range map[string]int{"cls": 1, "obj": 1, "bbox": 4, "kps": 10} {
+			outputs[fmt.Sprintf("%s_%d", name, stride)] = tensor.New(
+				tensor.Of(tensor.Float32),
+				tensor.WithShape(1, cells, channels),
+				tensor.WithBacking(make([]float32, cells*channels)),
+			)
+		}
+	}
+
+	// Face on the stride 16 cell (x=1, y=2) with a 16 pixel box centered on the cell corner.
+	cell := 2*(inputW/16) + 1
+	outputs["cls_16"].Float32s()[cell] = 0.81
+	outputs["obj_16"].Float32s()[cell] = 1
+	copy(outputs["kps_16"].Float32s()[10*cell:], []float32{0, 0, 1, 0, 0.5, 0.5, 0, 1, 1, 1})
+
+	boxes, scores, landmarks, err := decodeYuNet(outputs, inputH, inputW, 0.5, 0.3)
+	assert.NoError(t, err)
+	assert.Len(t, boxes, 1)
+	assert.InDelta(t, 0.9, scores[0], 1e-6)
+	assert.Equal(t, [4]float32{8, 24, 24, 40}, boxes[0])
+	assert.Equal(t, [10]float32{16, 32, 32, 32, 24, 40, 16, 48, 32, 48}, landmarks[0])
+
+	delete(outputs, "kps_32")
+	_, _, _, err = decodeYuNet(outputs, inputH, inputW, 0.5, 0.3)
+	assert.Error(t, err)
+}
@@ -0,0 +1,281 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"time"
+)
+
+// FaceDetector defines a face detection model returning boxes, scores and 5-point landmarks in image pixel coordinates.
+// Landmarks are ordered left eye, right eye, nose, left mouth corner, right mouth corner. The Context variants run
+// the inference calls within the context of the caller.
+type FaceDetector interface {
+	InferSingle(rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error)
+	InferBatch(rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error)
+	InferSingleContext(ctx context.Context, rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error)
+	InferBatchContext(ctx context.Context, rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error)
+	ColorOrderer
+}
+
+// NewFaceDetector initializes the face detector selected by the architecture of the params.
+// An empty architecture selects SCRFD. The detector is wrapped in a TiledFaceDetector if tiling params are set.
+func NewFaceDetector(triton InferenceClient, cfg *config.FaceDetectionParams) (FaceDetector, error) {
+	var detector FaceDetector
+	var err error
+	switch cfg.Architecture {
+	case "", config.FaceDetectorSCRFD:
+		detector, err = NewFaceDetectionClient(triton, cfg)
+	case config.FaceDetectorRetinaFace:
+		detector, err = NewRetinaFaceClient(triton, cfg)
+	case config.FaceDetectorYuNet:
+		detector, err = NewYuNetClient(triton, cfg)
+	case config.FaceDetectorYOLOv8Face:
+		detector, err = NewYOLOv8FaceClient(triton, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported face detector architecture %q", cfg.Architecture)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Tiling != nil {
+		return NewTiledFaceDetector(detector, cfg.Tiling, cfg.NMSThreshold)
+	}
+	return detector, nil
+}
+
+// faceDetectorDecoder decodes the outputs of a single image into detections in model input pixel coordinates.
+type faceDetectorDecoder func(outputs map[string]*tensor.Dense, inputH, inputW int, scoreThreshold, nmsThreshold float32) ([][4]float32, []float32, [][10]float32, error)
+
+// tritonFaceDetector implements the preprocessing, inference and rescaling shared by the face detectors
+// whose outputs are decoded in Go.
+type tritonFaceDetector struct {
+	tritonClient InferenceClient
+	ModelConfig  *triton_proto.ModelConfigResponse
+	ModelParams  *config.FaceDetectionParams
+	padValue     float64
+	decode       faceDetectorDecoder
+	checkOutputs func(modelConfig *triton_proto.ModelConfig) []string
+	requests     *inferRequestPool
+}
+
+func newTritonFaceDetector(triton InferenceClient, cfg *config.FaceDetectionParams, padValue float64, decode faceDetectorDecoder) (*tritonFaceDetector, error) {
+	if _, err := paramsColorOrder(cfg.ColorOrder, cfg.SwapRB); err != nil {
+		return nil, err
+	}
+	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, cfg.ModelVersion)
+	if err != nil {
+		return nil, err
+	}
+	if len(inferenceConfig.GetConfig().GetInput()) != 1 {
+		return nil, fmt.Errorf("face detector %s must have exactly one input", cfg.ModelName)
+	}
+	if dims := inferenceConfig.GetConfig().GetInput()[0].GetDims(); len(dims) != 3 && len(dims) != 4 {
+		return nil, fmt.Errorf("face detector %s input must be CHW or NCHW, got dims %v", cfg.ModelName, dims)
+	}
+
+	return &tritonFaceDetector{
+		tritonClient: triton,
+		ModelConfig:  inferenceConfig,
+		ModelParams:  cfg,
+		padValue:     padValue,
+		decode:       decode,
+		requests:     newInferRequestPool(cfg.ModelName, cfg.ModelVersion, inferenceConfig),
+	}, nil
+}
+
+// Contract returns the model configuration expected by the detector: an image letterboxed to the input size of the
+// model, with or without the batch dimension, and FP32 outputs identified by the decoder of the architecture.
+func (c *tritonFaceDetector) Contract() ModelContract {
+	return ModelContract{
+		ModelName:    c.ModelParams.ModelName,
+		Inputs:       []TensorContract{{DataTypes: fp32Contract.DataTypes}},
+		Outputs:      []TensorContract{fp32Contract},
+		ExtraOutputs: &TensorContract{DataTypes: fp32Contract.DataTypes},
+		Check: func(modelConfig *triton_proto.ModelConfig) []string {
+			diffs := make([]string, 0)
+			if inputs := modelConfig.GetInput(); len(inputs) > 0 {
+				dims := inputs[0].GetDims()
+				if len(dims) < 3 || !dimsMatch([]int64{3, DimStatic, DimStatic}, dims[len(dims)-3:]) {
+					diffs = append(diffs, fmt.Sprintf("input 0 %q: dims %v, want trailing dims [3 static static]", inputs[0].GetName(), dims))
+				}
+			}
+			if c.checkOutputs != nil {
+				diffs = append(diffs, c.checkOutputs(modelConfig)...)
+			}
+			return diffs
+		},
+	}
+}
+
+// CheckContract validates the model configuration against the contract of the detector.
+func (c *tritonFaceDetector) CheckContract() error {
+	return c.Contract().Validate(c.ModelConfig)
+}
+
+// ServedModel returns the name of the model and the version that served the last request of the detector.
+func (c *tritonFaceDetector) ServedModel() (string, string) {
+	return c.requests.ServedModel()
+}
+
+// Instrument records the duration of the preprocess, network and postprocess stages of the calls of the detector.
+func (c *tritonFaceDetector) Instrument(metrics *Metrics) {
+	c.requests.Instrument(metrics)
+}
+
+// ColorOrder returns the color order of the images expected by the detector, set by the color order and the SwapRB
+// param of its params.
+func (c *tritonFaceDetector) ColorOrder() utils.ColorOrder {
+	order, _ := paramsColorOrder(c.ModelParams.ColorOrder, c.ModelParams.SwapRB)
+	return order
+}
+
+// inputSize returns the model input height and width, with or without the batch dimension in the model configuration.
+func (c *tritonFaceDetector) inputSize() (int, int) {
+	dims := c.ModelConfig.Config.Input[0].Dims
+	return int(dims[len(dims)-2]), int(dims[len(dims)-1])
+}
+
+// letterboxInput letterboxes the image into the model input, normalizes it and returns the CHW input, written to dst
+// if it is large enough, with its letterbox geometry.
+func letterboxInput(img gocv.Mat, inputH, inputW int, params *config.FaceDetectionParams, padValue float64, dst []float32) ([]float32, utils.Letterbox, error) {
+	if img.Empty() || img.Channels() != 3 {
+		return nil, utils.Letterbox{}, errors.New("face detector input must be a non-empty 3-channel image")
+	}
+	lb := utils.NewLetterbox(img.Cols(), img.Rows(), inputW, inputH, params.CenterPad)
+	padded, err := lb.Apply(img, padValue)
+	if err != nil {
+		return nil, lb, err
+	}
+	defer padded.Close()
+
+	norm := utils.ChannelNormalization{Mean: params.Mean, Scale: params.Scale, SwapRB: params.SwapRB}
+	chw, err := utils.MatToCHW(padded, norm, dst)
+	if err != nil {
+		return nil, lb, err
+	}
+	return chw, lb, nil
+}
+
+// infer sends a single preprocessed image to the model and returns its outputs by name. The input buffer is returned
+// to the request pool.
+func (c *tritonFaceDetector) infer(ctx context.Context, chw []float32) (map[string]*tensor.Dense, error) {
+	dims := c.ModelConfig.Config.Input[0].Dims
+	modelRequest := c.requests.Get()
+	modelRequest.SetInput(0, chw, 1, dims[len(dims)-3], dims[len(dims)-2], dims[len(dims)-1])
+
+	sent := time.Now()
+	inferResp, err := modelInfer(ctx, c.tritonClient, c.ModelParams.Timeout, modelRequest.Request)
+	c.requests.Observe(StageNetwork, sent, err)
+	c.requests.Put(modelRequest)
+	if err != nil {
+		return nil, err
+	}
+	c.requests.Record(inferResp)
+
+	outputs := make(map[string]*tensor.Dense, len(inferResp.GetOutputs()))
+	for oIdx, output := range inferResp.GetOutputs() {
+		if output.Datatype != "FP32" {
+			return nil, fmt.Errorf("face detector output %s has unsupported datatype %s", output.Name, output.Datatype)
+		}
+		outputShape := make([]int, 0, len(output.Shape))
+		for _, shp := range output.Shape {
+			outputShape = append(outputShape, int(shp))
+		}
+		outputs[output.Name] = tensor.New(
+			tensor.Of(tensor.Float32),
+			tensor.WithShape(outputShape...),
+			tensor.WithBacking(utils.BytesToT32[float32](inferResp.RawOutputContents[oIdx])),
+		)
+	}
+	return outputs, nil
+}
+
+func (c *tritonFaceDetector) detect(ctx context.Context, img gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	inputH, inputW := c.inputSize()
+	start := time.Now()
+	chw, lb, err := letterboxInput(img, inputH, inputW, c.ModelParams, c.padValue, c.requests.Buffer(0, 3*inputH*inputW))
+	c.requests.Observe(StagePreprocess, start, err)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := c.infer(ctx, chw)
+	if err != nil {
+		return nil, err
+	}
+	start = time.Now()
+	boxes, scores, landmarks, err := c.decode(outputs, inputH, inputW, c.ModelParams.ScoreThreshold, c.ModelParams.NMSThreshold)
+	c.requests.Observe(StagePostprocess, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return toFaceDetectionOutputs(boxes, scores, landmarks, lb), nil
+}
+
+func (c *tritonFaceDetector) InferSingle(rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	return c.InferSingleContext(context.Background(), rawInputTensors)
+}
+
+func (c *tritonFaceDetector) InferSingleContext(ctx context.Context, rawInputTensors []gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	if len(rawInputTensors) == 0 {
+		return nil, errors.New("no input image")
+	}
+	return c.detect(ctx, rawInputTensors[0])
+}
+
+func (c *tritonFaceDetector) InferBatch(rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error) {
+	return c.InferBatchContext(context.Background(), rawInputTensors)
+}
+
+func (c *tritonFaceDetector) InferBatchContext(ctx context.Context, rawInputTensors [][]gocv.Mat) ([][]config.FaceDetectionOutput, error) {
+	if len(rawInputTensors) == 0 {
+		return nil, errors.New("no input image")
+	}
+	results := make([][]config.FaceDetectionOutput, 0, len(rawInputTensors[0]))
+	for _, img := range rawInputTensors[0] {
+		detOutputs, err := c.detect(ctx, img)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, detOutputs)
+	}
+	return results, nil
+}
+
+// keepNMS applies non-maximum suppression to decoded detections.
+func keepNMS(boxes [][4]float32, scores []float32, landmarks [][10]float32, nmsThreshold float32) ([][4]float32, []float32, [][10]float32) {
+	keep := utils.NMS(boxes, scores, nmsThreshold)
+	keptBoxes := make([][4]float32, 0, len(keep))
+	keptScores := make([]float32, 0, len(keep))
+	keptLandmarks := make([][10]float32, 0, len(keep))
+	for _, idx := range keep {
+		keptBoxes = append(keptBoxes, boxes[idx])
+		keptScores = append(keptScores, scores[idx])
+		keptLandmarks = append(keptLandmarks, landmarks[idx])
+	}
+	return keptBoxes, keptScores, keptLandmarks
+}
+
+// toFaceDetectionOutputs maps decoded detections from model input to image pixel coordinates and wraps them
+// as detection outputs.
+func toFaceDetectionOutputs(boxes [][4]float32, scores []float32, landmarks [][10]float32, lb utils.Letterbox) []config.FaceDetectionOutput {
+	results := make([]config.FaceDetectionOutput, 0, len(boxes))
+	for idx := range boxes {
+		box := lb.BoxToImage(boxes[idx])
+		lmk := landmarks[idx]
+		lb.PointsToImage(lmk[:])
+		results = append(results, config.FaceDetectionOutput{
+			Box:      tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(4), tensor.WithBacking(box[:])),
+			Score:    tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1), tensor.WithBacking([]float32{scores[idx]})),
+			ClassID:  tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1), tensor.WithBacking([]float32{0})),
+			Landmark: tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(10), tensor.WithBacking(lmk[:])),
+		})
+	}
+	return results
+}
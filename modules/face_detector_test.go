@@ -0,0 +1,24 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"gocv.io/x/gocv"
+	"testing"
+)
+
+func BenchmarkLetterboxInput(b *testing.B) {
+	img := gocv.NewMatWithSize(1080, 1920, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	requests := newInferRequestPool("detector", "", genTestModelConfig(3, 640, 640))
+
+	b.ReportAllocs()
+	for range b.N {
+		chw, _, err := letterboxInput(img, 640, 640, config.DefaultFaceDetectionParams, 0, requests.Buffer(0, 3*640*640))
+		if err != nil {
+			b.Fatal(err)
+		}
+		request := requests.Get()
+		request.SetInput(0, chw, 1, 3, 640, 640)
+		requests.Put(request)
+	}
+}
@@ -1,10 +1,10 @@
 package modules
 
 import (
+	"context"
 	"errors"
 	"github.com/okieraised/go-ekyc-pipeline/config"
 	"github.com/okieraised/go-ekyc-pipeline/utils"
-	gotritonclient "github.com/okieraised/go-triton-client"
 	"gocv.io/x/gocv"
 	"gorgonia.org/tensor"
 	"image"
@@ -25,18 +25,22 @@ type FaceHelperClient struct {
 	faceTemplate *tensor.Dense
 	fasTemplate  *tensor.Dense
 	faTemplate   *tensor.Dense
-	faceDet      *FaceDetectionClient
+	faceDet      FaceDetector
+
+	AlignmentParams *config.AlignmentParams
 }
 
 // NewFaceHelperClient initializes a new FaceHelperClient.
+// The face detector is selected by the architecture of faceDetParams, SCRFD with default params if nil.
 func NewFaceHelperClient(
-	tritonClient *gotritonclient.TritonGRPCClient,
+	tritonClient InferenceClient,
 	faceSize,
 	fasSize,
 	faSize int,
 	faceTemplate,
 	fasTemplate,
 	faTemplate *tensor.Dense,
+	faceDetParams *config.FaceDetectionParams,
 ) (*FaceHelperClient, error) {
 
 	var err error
@@ -105,15 +109,49 @@ func NewFaceHelperClient(
 		)
 	}
 	faceHelper.faTemplate = faTemplate
-	faceDet, err := NewFaceDetectionClient(tritonClient, config.DefaultFaceDetectionParams)
+	if faceDetParams == nil {
+		faceDetParams = config.DefaultFaceDetectionParams
+	}
+	faceDet, err := NewFaceDetector(tritonClient, faceDetParams)
 	if err != nil {
 		return nil, err
 	}
 	faceHelper.faceDet = faceDet
+	faceHelper.AlignmentParams = config.DefaultAlignmentParams
 
 	return faceHelper, nil
 }
 
+// ColorOrder returns the color order of the images expected by the face detector of the helper. Alignment itself
+// preserves the color order of its input.
+func (c *FaceHelperClient) ColorOrder() utils.ColorOrder {
+	return c.faceDet.ColorOrder()
+}
+
+// CheckContract validates the model configuration of the face detector of the helper.
+func (c *FaceHelperClient) CheckContract() error {
+	if checker, ok := c.faceDet.(ContractChecker); ok {
+		return checker.CheckContract()
+	}
+	return nil
+}
+
+// ServedModel returns the name and served version of the model of the face detector of the helper.
+func (c *FaceHelperClient) ServedModel() (string, string) {
+	if modeler, ok := c.faceDet.(ServedModeler); ok {
+		return modeler.ServedModel()
+	}
+	return "", ""
+}
+
+// Instrument records the stages of the calls of the face detector of the helper.
+func (c *FaceHelperClient) Instrument(metrics *Metrics) {
+	if instrumenter, ok := c.faceDet.(Instrumenter); ok {
+		instrumenter.Instrument(metrics)
+	}
+}
+
+// SwapRGBs returns new Mats of the images with the first and last channels swapped. The caller must close them.
 func (c *FaceHelperClient) SwapRGBs(batchImages []gocv.Mat) []gocv.Mat {
 
 	outputs := make([]gocv.Mat, 0, len(batchImages))
@@ -126,6 +164,7 @@ func (c *FaceHelperClient) SwapRGBs(batchImages []gocv.Mat) []gocv.Mat {
 	return outputs
 }
 
+// SwapRGB returns a new Mat of the image with the first and last channels swapped. The caller must close it.
 func (c *FaceHelperClient) SwapRGB(srcMat gocv.Mat) gocv.Mat {
 
 	dstMat := gocv.NewMat()
@@ -144,6 +183,8 @@ func getLocation(val, length float32) float32 {
 	}
 }
 
+// padImage returns a new Mat of the image with a black border of ratio times its size on each side, and the border
+// offsets. The caller must close the returned Mat.
 func padImage(img gocv.Mat, ratio float32) (gocv.Mat, int, int) {
 	dims := img.Size()
 	h, w := dims[0], dims[1]
@@ -236,7 +277,22 @@ func (c *FaceHelperClient) GetFaceLandmarks5(
 	keepCenter *bool,
 	scoreThreshold,
 	eyeDistanceThreshold *float32,
-	tryPadding *bool,
+	tryPadding,
+	tryRotation *bool,
+) ([]*tensor.Dense, []*tensor.Dense, error) {
+	return c.GetFaceLandmarks5Context(context.Background(), batchImages, keepLargest, keepCenter, scoreThreshold, eyeDistanceThreshold, tryPadding, tryRotation)
+}
+
+// GetFaceLandmarks5Context is GetFaceLandmarks5 run within ctx.
+func (c *FaceHelperClient) GetFaceLandmarks5Context(
+	ctx context.Context,
+	batchImages []gocv.Mat,
+	keepLargest,
+	keepCenter *bool,
+	scoreThreshold,
+	eyeDistanceThreshold *float32,
+	tryPadding,
+	tryRotation *bool,
 ) ([]*tensor.Dense, []*tensor.Dense, error) {
 
 	if keepLargest == nil {
@@ -248,6 +304,9 @@ func (c *FaceHelperClient) GetFaceLandmarks5(
 	if tryPadding == nil {
 		tryPadding = utils.RefPointer(false)
 	}
+	if tryRotation == nil {
+		tryRotation = utils.RefPointer(false)
+	}
 	if scoreThreshold == nil {
 		scoreThreshold = utils.RefPointer(float32(0.5))
 	}
@@ -256,7 +315,7 @@ func (c *FaceHelperClient) GetFaceLandmarks5(
 		return nil, nil, nil
 	}
 
-	batchResults, err := c.faceDet.InferBatch([][]gocv.Mat{batchImages})
+	batchResults, err := c.faceDet.InferBatchContext(ctx, [][]gocv.Mat{batchImages})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -274,7 +333,18 @@ func (c *FaceHelperClient) GetFaceLandmarks5(
 		if utils.DerefPointer(tryPadding) && len(results) == 0 {
 			var paddedImage gocv.Mat
 			paddedImage, offX, offY = padImage(batchImages[idx], 0.5)
-			results, err = c.faceDet.InferSingle([]gocv.Mat{paddedImage})
+			results, err = c.faceDet.InferSingleContext(ctx, []gocv.Mat{paddedImage})
+			_ = paddedImage.Close()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if utils.DerefPointer(tryRotation) && len(results) == 0 {
+			offX, offY = 0, 0
+			results, err = c.detectRotated(ctx, batchImages[idx])
+			if err != nil {
+				return nil, nil, err
+			}
 		}
 		for _, result := range results {
 			bboxOffset := tensor.New(
@@ -376,6 +446,163 @@ func (c *FaceHelperClient) GetFaceLandmarks5(
 	return batchBBoxes, batchLandmarks, nil
 }
 
+// detectRotated detects faces on the image rotated clockwise by 90, 180 and 270 degrees, and returns the detections
+// of the first rotation with a face, mapped back to the original orientation.
+func (c *FaceHelperClient) detectRotated(ctx context.Context, img gocv.Mat) ([]config.FaceDetectionOutput, error) {
+	w, h := img.Cols(), img.Rows()
+	for _, rotation := range []int{90, 180, 270} {
+		rotated, err := utils.RotateImage(img, rotation)
+		if err != nil {
+			return nil, err
+		}
+		results, err := c.faceDet.InferSingleContext(ctx, []gocv.Mat{rotated})
+		_ = rotated.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(results) == 0 {
+			continue
+		}
+
+		boxes := make([][4]float32, 0, len(results))
+		scores := make([]float32, 0, len(results))
+		landmarks := make([][10]float32, 0, len(results))
+		for _, result := range results {
+			box := result.Box.Float32s()
+			x1, y1 := utils.UnrotatePoint(box[0], box[1], rotation, w, h)
+			x2, y2 := utils.UnrotatePoint(box[2], box[3], rotation, w, h)
+			boxes = append(boxes, [4]float32{min(x1, x2), min(y1, y2), max(x1, x2), max(y1, y2)})
+			scores = append(scores, result.Score.Float32s()[0])
+
+			var lmk [10]float32
+			points := result.Landmark.Float32s()
+			for k := 0; k < 5; k++ {
+				lmk[2*k], lmk[2*k+1] = utils.UnrotatePoint(points[2*k], points[2*k+1], rotation, w, h)
+			}
+			landmarks = append(landmarks, lmk)
+		}
+		return toFaceDetectionOutputs(boxes, scores, landmarks, utils.NewLetterbox(w, h, w, h, false)), nil
+	}
+	return nil, nil
+}
+
+// UprightRotation returns the clockwise rotation of utils.RotateImage, 0, 90, 180 or 270 degrees, turning the face of
+// a (5, 2) landmark of a w x h image upright, with the eyes above the mouth.
+func UprightRotation(landmark *tensor.Dense, w, h int) int {
+	points := landmark.Float32s()
+	if len(points) < 10 {
+		return 0
+	}
+	eyeX, eyeY := (points[0]+points[2])/2, (points[1]+points[3])/2
+	mouthX, mouthY := (points[6]+points[8])/2, (points[7]+points[9])/2
+
+	upright, drop := 0, float32(math.Inf(-1))
+	for _, rotation := range []int{0, 90, 180, 270} {
+		_, ey := utils.RotatePoint(eyeX, eyeY, rotation, w, h)
+		_, my := utils.RotatePoint(mouthX, mouthY, rotation, w, h)
+		if my-ey > drop {
+			upright, drop = rotation, my-ey
+		}
+	}
+	return upright
+}
+
+// RotateLandmark maps a (5, 2) landmark of a w x h image into the image rotated clockwise by utils.RotateImage.
+func RotateLandmark(landmark *tensor.Dense, rotation, w, h int) *tensor.Dense {
+	points := landmark.Float32s()
+	rotated := make([]float32, len(points))
+	for k := 0; k+1 < len(points); k += 2 {
+		rotated[k], rotated[k+1] = utils.RotatePoint(points[k], points[k+1], rotation, w, h)
+	}
+	return tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(landmark.Shape()...), tensor.WithBacking(rotated))
+}
+
+// DetectLargestFace returns the largest face detected in the input image, or nil if no face is found.
+func (c *FaceHelperClient) DetectLargestFace(img gocv.Mat) (*config.FaceDetectionOutput, error) {
+	return c.DetectLargestFaceContext(context.Background(), img)
+}
+
+// DetectLargestFaceContext is DetectLargestFace run within ctx.
+func (c *FaceHelperClient) DetectLargestFaceContext(ctx context.Context, img gocv.Mat) (*config.FaceDetectionOutput, error) {
+	if c.faceDet == nil {
+		return nil, nil
+	}
+
+	results, err := c.faceDet.InferSingleContext(ctx, []gocv.Mat{img})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	bBoxes := make([]*tensor.Dense, 0, len(results))
+	for _, result := range results {
+		bBoxes = append(bBoxes, result.Box)
+	}
+	shapes := img.Size()
+	_, largestIdx, err := getLargestFace(bBoxes, shapes[0], shapes[1])
+	if err != nil {
+		return nil, err
+	}
+	return &results[largestIdx], nil
+}
+
+// maxLandmarkMatchError is the mean keypoint distance, relative to the inter-eye distance, under which a detection
+// matches a landmark.
+const maxLandmarkMatchError = 0.25
+
+// DetectMatchingFace returns the face detected in the input image whose keypoints match the (5, 2) landmark, the
+// closest one if several do, or nil if none does.
+func (c *FaceHelperClient) DetectMatchingFace(img gocv.Mat, landmark *tensor.Dense) (*config.FaceDetectionOutput, error) {
+	return c.DetectMatchingFaceContext(context.Background(), img, landmark)
+}
+
+// DetectMatchingFaceContext is DetectMatchingFace run within ctx.
+func (c *FaceHelperClient) DetectMatchingFaceContext(ctx context.Context, img gocv.Mat, landmark *tensor.Dense) (*config.FaceDetectionOutput, error) {
+	if c.faceDet == nil {
+		return nil, nil
+	}
+
+	results, err := c.faceDet.InferSingleContext(ctx, []gocv.Mat{img})
+	if err != nil {
+		return nil, err
+	}
+	matchIdx := matchLandmark(results, landmark.Float32s())
+	if matchIdx < 0 {
+		return nil, nil
+	}
+	return &results[matchIdx], nil
+}
+
+// matchLandmark returns the index of the detection with the smallest mean keypoint distance to lmk, or -1 if no
+// detection is within maxLandmarkMatchError.
+func matchLandmark(results []config.FaceDetectionOutput, lmk []float32) int {
+	eyeDist := utils.EuclideanDistance(lmk[0], lmk[1], lmk[2], lmk[3])
+	if eyeDist == 0 {
+		return -1
+	}
+
+	matchIdx, matchError := -1, float32(maxLandmarkMatchError)
+	for idx, result := range results {
+		if result.Landmark == nil {
+			continue
+		}
+		keypoints := result.Landmark.Float32s()
+		if len(keypoints) != len(lmk) {
+			continue
+		}
+		var total float32
+		for point := 0; point < len(lmk)/2; point++ {
+			total += utils.EuclideanDistance(keypoints[2*point], keypoints[2*point+1], lmk[2*point], lmk[2*point+1])
+		}
+		if meanError := total / float32(len(lmk)/2) / eyeDist; meanError <= matchError {
+			matchIdx, matchError = idx, meanError
+		}
+	}
+	return matchIdx
+}
+
 //func (c *FaceHelperClient) CropFace(imgs []gocv.Mat, bboxes []*tensor.Dense, paddings []int) error {
 //	if paddings == nil {
 //		paddings = []int{0, 13, 0, 0}
@@ -389,6 +616,107 @@ func (c *FaceHelperClient) GetFaceLandmarks5(
 //	return nil
 //}
 
+// estimateAlignment estimates the similarity transform from the landmarks to the template and measures its fit.
+// The caller must close the returned Mat.
+func estimateAlignment(landmark, template *tensor.Dense) (gocv.Mat, config.AlignmentQuality, error) {
+	from, err := utils.TensorToPoint2fVector(landmark)
+	if err != nil {
+		return gocv.NewMat(), config.AlignmentQuality{}, err
+	}
+	defer from.Close()
+
+	to, err := utils.TensorToPoint2fVector(template)
+	if err != nil {
+		return gocv.NewMat(), config.AlignmentQuality{}, err
+	}
+	defer to.Close()
+
+	inliers := gocv.NewMat()
+	defer inliers.Close()
+	affineMatrix := gocv.EstimateAffinePartial2DWithParams(
+		from,
+		to,
+		inliers,
+		int(gocv.HomograpyMethodLMEDS),
+		3.0,
+		2000,
+		0.99,
+		10,
+	)
+	if affineMatrix.Empty() {
+		return affineMatrix, config.AlignmentQuality{}, errors.New("cannot estimate alignment transform from degenerate landmarks")
+	}
+
+	var affine [6]float64
+	for row := 0; row < 2; row++ {
+		for col := 0; col < 3; col++ {
+			affine[3*row+col] = affineMatrix.GetDoubleAt(row, col)
+		}
+	}
+	quality := alignmentQuality(affine, landmark.Float32s(), template.Float32s(), gocv.CountNonZero(inliers))
+	return affineMatrix, quality, nil
+}
+
+// alignmentQuality measures how well the [a, -b, tx, b, a, ty] similarity transform maps the interleaved (x, y) source
+// points onto the destination points.
+func alignmentQuality(affine [6]float64, src, dst []float32, numInliers int) config.AlignmentQuality {
+	numPoints := min(len(src), len(dst)) / 2
+	var sumSq float64
+	for i := 0; i < numPoints; i++ {
+		x, y := float64(src[2*i]), float64(src[2*i+1])
+		dx := affine[0]*x + affine[1]*y + affine[2] - float64(dst[2*i])
+		dy := affine[3]*x + affine[4]*y + affine[5] - float64(dst[2*i+1])
+		sumSq += dx*dx + dy*dy
+	}
+
+	quality := config.AlignmentQuality{
+		Scale:      float32(math.Hypot(affine[0], affine[3])),
+		Rotation:   float32(-math.Atan2(affine[3], affine[0]) * 180 / math.Pi),
+		NumInliers: numInliers,
+		NumPoints:  numPoints,
+	}
+	if numPoints == 0 {
+		return quality
+	}
+	quality.ReprojectionError = float32(math.Sqrt(sumSq / float64(numPoints)))
+	// The first two template points are the eye centers.
+	if numPoints >= 2 {
+		eyeDist := math.Hypot(float64(dst[2]-dst[0]), float64(dst[3]-dst[1]))
+		if eyeDist > 0 {
+			quality.NormalizedError = quality.ReprojectionError / float32(eyeDist)
+		}
+	}
+	return quality
+}
+
+// IsPlausibleAlignment checks that the alignment fit is within the limits of AlignmentParams, i.e. that the landmarks
+// form a face. A zero limit is not checked.
+func (c *FaceHelperClient) IsPlausibleAlignment(quality config.AlignmentQuality) bool {
+	params := c.AlignmentParams
+	if params == nil {
+		params = config.DefaultAlignmentParams
+	}
+	if quality.NumPoints == 0 {
+		return false
+	}
+	if params.MaxReprojectionError > 0 && quality.NormalizedError > params.MaxReprojectionError {
+		return false
+	}
+	if params.MinInlierRatio > 0 && float32(quality.NumInliers) < params.MinInlierRatio*float32(quality.NumPoints) {
+		return false
+	}
+	if params.MinScale > 0 && quality.Scale < params.MinScale {
+		return false
+	}
+	if params.MaxScale > 0 && quality.Scale > params.MaxScale {
+		return false
+	}
+	if params.MaxRotation > 0 && float32(math.Abs(float64(quality.Rotation))) > params.MaxRotation {
+		return false
+	}
+	return true
+}
+
 // AlignWarpFaces aligns input images using input landmarks and face template.
 //
 // Inputs:
@@ -400,7 +728,10 @@ func (c *FaceHelperClient) GetFaceLandmarks5(
 //
 //   - croppedFaces ([]gocv.Mat): list of cropped faces.
 //   - affineMatrices ([]gocv.Mat): list of affine matrices.
-func (c *FaceHelperClient) AlignWarpFaces(inputImgs []gocv.Mat, landmarks []*tensor.Dense, borderMode *gocv.BorderType) ([]gocv.Mat, []gocv.Mat, error) {
+//   - qualities ([]config.AlignmentQuality): list of alignment fit qualities.
+//
+// The caller must close the returned Mats.
+func (c *FaceHelperClient) AlignWarpFaces(inputImgs []gocv.Mat, landmarks []*tensor.Dense, borderMode *gocv.BorderType) ([]gocv.Mat, []gocv.Mat, []config.AlignmentQuality, error) {
 	defaultBorderMode := gocv.BorderConstant
 	if borderMode == nil {
 		borderMode = &defaultBorderMode
@@ -408,44 +739,28 @@ func (c *FaceHelperClient) AlignWarpFaces(inputImgs []gocv.Mat, landmarks []*ten
 
 	affineMatrices := make([]gocv.Mat, 0, len(inputImgs))
 	croppedFaces := make([]gocv.Mat, 0, len(inputImgs))
+	qualities := make([]config.AlignmentQuality, 0, len(inputImgs))
 
 	if len(inputImgs) != len(landmarks) {
-		return croppedFaces, affineMatrices, errors.New("number of input images and landmarks must be equal")
+		return croppedFaces, affineMatrices, qualities, errors.New("number of input images and landmarks must be equal")
 	}
 
+	// Partial results are closed on error.
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
 	for i := 0; i < len(inputImgs); i++ {
 		inputImg := inputImgs[i]
-		landmark := landmarks[i]
-		landmark.Float32s()
-		from, err := utils.TensorToPoint2fVector(landmark)
-		if err != nil {
-			return croppedFaces, affineMatrices, err
-		}
-
-		to, err := utils.TensorToPoint2fVector(c.faceTemplate)
+		affineMatrix, quality, err := estimateAlignment(landmarks[i], c.faceTemplate)
+		scope.Track(affineMatrix)
 		if err != nil {
-			return croppedFaces, affineMatrices, err
-		}
-
-		inliers := gocv.NewMat()
-		affineMatrix := gocv.EstimateAffinePartial2DWithParams(
-			from,
-			to,
-			inliers,
-			int(gocv.HomograpyMethodLMEDS),
-			3.0,
-			2000,
-			0.99,
-			10,
-		)
-		err = inliers.Close()
-		if err != nil {
-			return croppedFaces, affineMatrices, err
+			return nil, nil, nil, err
 		}
 
 		affineMatrices = append(affineMatrices, affineMatrix)
+		qualities = append(qualities, quality)
 
-		croppedFace := gocv.NewMat()
+		croppedFace := scope.NewMat()
 		gocv.WarpAffineWithParams(
 			inputImg,
 			&croppedFace,
@@ -465,7 +780,11 @@ func (c *FaceHelperClient) AlignWarpFaces(inputImgs []gocv.Mat, landmarks []*ten
 		)
 		croppedFaces = append(croppedFaces, croppedFace)
 	}
-	return croppedFaces, affineMatrices, nil
+	for idx := range croppedFaces {
+		scope.Detach(croppedFaces[idx])
+		scope.Detach(affineMatrices[idx])
+	}
+	return croppedFaces, affineMatrices, qualities, nil
 }
 
 // AlignWarpFace aligns input image using input landmark and face template.
@@ -486,31 +805,8 @@ func (c *FaceHelperClient) AlignWarpFace(img gocv.Mat, landmark *tensor.Dense, b
 	}
 
 	croppedFace := gocv.NewMat()
-	affineMatrix := gocv.NewMat()
-	var err error
-
-	from, err := utils.TensorToPoint2fVector(landmark)
-	if err != nil {
-		return croppedFace, affineMatrix, err
-	}
 
-	to, err := utils.TensorToPoint2fVector(c.faceTemplate)
-	if err != nil {
-		return croppedFace, affineMatrix, err
-	}
-
-	inliers := gocv.NewMat()
-	affineMatrix = gocv.EstimateAffinePartial2DWithParams(
-		from,
-		to,
-		inliers,
-		int(gocv.HomograpyMethodLMEDS),
-		3.0,
-		2000,
-		0.99,
-		10,
-	)
-	err = inliers.Close()
+	affineMatrix, _, err := estimateAlignment(landmark, c.faceTemplate)
 	if err != nil {
 		return croppedFace, affineMatrix, err
 	}
@@ -547,7 +843,10 @@ func (c *FaceHelperClient) AlignWarpFace(img gocv.Mat, landmark *tensor.Dense, b
 //
 //   - croppedFaces ([]gocv.Mat): list of cropped faces.
 //   - affineMatrices ([]gocv.Mat): list of affine matrices.
-func (c *FaceHelperClient) AlignFASFaces(inputImgs []gocv.Mat, landmarks []*tensor.Dense, borderMode *gocv.BorderType) ([]gocv.Mat, []gocv.Mat, error) {
+//   - qualities ([]config.AlignmentQuality): list of alignment fit qualities.
+//
+// The caller must close the returned Mats.
+func (c *FaceHelperClient) AlignFASFaces(inputImgs []gocv.Mat, landmarks []*tensor.Dense, borderMode *gocv.BorderType) ([]gocv.Mat, []gocv.Mat, []config.AlignmentQuality, error) {
 	defaultBorderMode := gocv.BorderConstant
 	if borderMode == nil {
 		borderMode = &defaultBorderMode
@@ -555,44 +854,28 @@ func (c *FaceHelperClient) AlignFASFaces(inputImgs []gocv.Mat, landmarks []*tens
 
 	affineMatrices := make([]gocv.Mat, 0, len(inputImgs))
 	croppedFaces := make([]gocv.Mat, 0, len(inputImgs))
+	qualities := make([]config.AlignmentQuality, 0, len(inputImgs))
 
 	if len(inputImgs) != len(landmarks) {
-		return croppedFaces, affineMatrices, errors.New("number of input images and landmarks must be equal")
+		return croppedFaces, affineMatrices, qualities, errors.New("number of input images and landmarks must be equal")
 	}
 
+	// Partial results are closed on error.
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
 	for i := 0; i < len(inputImgs); i++ {
 		inputImg := inputImgs[i]
-		landmark := landmarks[i]
-		landmark.Float32s()
-		from, err := utils.TensorToPoint2fVector(landmark)
-		if err != nil {
-			return croppedFaces, affineMatrices, err
-		}
-
-		to, err := utils.TensorToPoint2fVector(c.fasTemplate)
-		if err != nil {
-			return croppedFaces, affineMatrices, err
-		}
-
-		inliers := gocv.NewMat()
-		affineMatrix := gocv.EstimateAffinePartial2DWithParams(
-			from,
-			to,
-			inliers,
-			int(gocv.HomograpyMethodLMEDS),
-			3.0,
-			2000,
-			0.99,
-			10,
-		)
-		err = inliers.Close()
+		affineMatrix, quality, err := estimateAlignment(landmarks[i], c.fasTemplate)
+		scope.Track(affineMatrix)
 		if err != nil {
-			return croppedFaces, affineMatrices, err
+			return nil, nil, nil, err
 		}
 
 		affineMatrices = append(affineMatrices, affineMatrix)
+		qualities = append(qualities, quality)
 
-		croppedFace := gocv.NewMat()
+		croppedFace := scope.NewMat()
 		gocv.WarpAffineWithParams(
 			inputImg,
 			&croppedFace,
@@ -612,7 +895,11 @@ func (c *FaceHelperClient) AlignFASFaces(inputImgs []gocv.Mat, landmarks []*tens
 		)
 		croppedFaces = append(croppedFaces, croppedFace)
 	}
-	return croppedFaces, affineMatrices, nil
+	for idx := range croppedFaces {
+		scope.Detach(croppedFaces[idx])
+		scope.Detach(affineMatrices[idx])
+	}
+	return croppedFaces, affineMatrices, qualities, nil
 }
 
 // AlignFASFace aligns input image using input landmark and face anti-spoofing template.
@@ -633,35 +920,12 @@ func (c *FaceHelperClient) AlignFASFace(img gocv.Mat, landmark *tensor.Dense, bo
 	}
 
 	croppedFace := gocv.NewMat()
-	affineMatrix := gocv.NewMat()
-
-	from, err := utils.TensorToPoint2fVector(landmark)
-	if err != nil {
-		return croppedFace, affineMatrix, err
-	}
 
-	to, err := utils.TensorToPoint2fVector(c.fasTemplate)
-	if err != nil {
-		return croppedFace, affineMatrix, err
-	}
-
-	inliers := gocv.NewMat()
-	affineMatrix = gocv.EstimateAffinePartial2DWithParams(
-		from,
-		to,
-		inliers,
-		int(gocv.HomograpyMethodLMEDS),
-		3.0,
-		2000,
-		0.99,
-		10,
-	)
-	err = inliers.Close()
+	affineMatrix, _, err := estimateAlignment(landmark, c.fasTemplate)
 	if err != nil {
 		return croppedFace, affineMatrix, err
 	}
 
-	croppedFace = gocv.NewMat()
 	gocv.WarpAffineWithParams(
 		img,
 		&croppedFace,
@@ -833,28 +1097,8 @@ func (c *FaceHelperClient) AlignFaceIDCard(img gocv.Mat, lmk, bbox *tensor.Dense
 		return croppedFace, affineMatrix, err
 	}
 
-	from, err := utils.TensorToPoint2fVector(lmk)
-	if err != nil {
-		return croppedFace, affineMatrix, err
-	}
-
-	to, err := utils.TensorToPoint2fVector(faceTemplate)
-	if err != nil {
-		return croppedFace, affineMatrix, err
-	}
-
-	inliers := gocv.NewMat()
-	affineMatrix = gocv.EstimateAffinePartial2DWithParams(
-		from,
-		to,
-		inliers,
-		int(gocv.HomograpyMethodLMEDS),
-		3.0,
-		2000,
-		0.99,
-		10,
-	)
-	err = inliers.Close()
+	_ = affineMatrix.Close()
+	affineMatrix, _, err = estimateAlignment(lmk, faceTemplate)
 	if err != nil {
 		return croppedFace, affineMatrix, err
 	}
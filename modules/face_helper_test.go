@@ -12,7 +12,9 @@ import (
 	_ "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 	"io"
+	"math"
 	"os"
+	"slices"
 	"testing"
 )
 
@@ -133,7 +135,7 @@ func TestNewFaceHelper(t *testing.T) {
 	far, err := genTestFarData()
 	assert.NoError(t, err)
 
-	faceHelper, err := NewFaceHelperClient(triton, 112, 224, 128, nil, nil, nil)
+	faceHelper, err := NewFaceHelperClient(triton, 112, 224, 128, nil, nil, nil, nil)
 	assert.NoError(t, err)
 	batchBBoxes, batchLandmarks, err := faceHelper.GetFaceLandmarks5(
 		[]gocv.Mat{*far, *mid, *near},
@@ -142,6 +144,7 @@ func TestNewFaceHelper(t *testing.T) {
 		utils.RefPointer(float32(0.5)),
 		nil,
 		utils.RefPointer(true),
+		nil,
 	)
 	assert.NoError(t, err)
 	assert.NotNil(t, batchBBoxes)
@@ -165,7 +168,7 @@ func TestFaceHelper_AlignWarpFaces(t *testing.T) {
 	far, err := genTestFarData()
 	assert.NoError(t, err)
 
-	faceHelper, err := NewFaceHelperClient(triton, 112, 224, 128, nil, nil, nil)
+	faceHelper, err := NewFaceHelperClient(triton, 112, 224, 128, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
 	lmkFar := config.ConvertMetadataToTensors(&config.FaceLandmark{
@@ -237,6 +240,117 @@ func TestFaceHelper_AlignWarpFaces(t *testing.T) {
 		},
 	})
 
-	_, _, err = faceHelper.AlignWarpFaces([]gocv.Mat{*far, *mid, *near}, []*tensor.Dense{lmkFar, lmkMid, lmkNear}, nil)
+	_, _, _, err = faceHelper.AlignWarpFaces([]gocv.Mat{*far, *mid, *near}, []*tensor.Dense{lmkFar, lmkMid, lmkNear}, nil)
 	assert.NoError(t, err)
 }
+
+func TestAlignmentQuality(t *testing.T) {
+	template := []float32{
+		38.2946, 51.6963,
+		73.5318, 51.5014,
+		56.0252, 71.7366,
+		41.5493, 92.3655,
+		70.7299, 92.2041,
+	}
+	// Map image points to the template with scale 0.5 and a 30 degree clockwise rotation, which undoes a face tilted
+	// 30 degrees counterclockwise.
+	a, b := 0.5*math.Cos(math.Pi/6), 0.5*math.Sin(math.Pi/6)
+	affine := [6]float64{a, -b, 10, b, a, -20}
+	det := a*a + b*b
+	src := make([]float32, len(template))
+	for i := 0; i < len(template); i += 2 {
+		x, y := float64(template[i])-10, float64(template[i+1])+20
+		src[i], src[i+1] = float32((a*x+b*y)/det), float32((-b*x+a*y)/det)
+	}
+
+	quality := alignmentQuality(affine, src, template, 5)
+	assert.InDelta(t, 0, quality.ReprojectionError, 1e-3)
+	assert.InDelta(t, 0.5, quality.Scale, 1e-6)
+	assert.InDelta(t, -30, quality.Rotation, 1e-4)
+	assert.Equal(t, 5, quality.NumPoints)
+
+	helper := &FaceHelperClient{AlignmentParams: config.DefaultAlignmentParams}
+	assert.True(t, helper.IsPlausibleAlignment(quality))
+
+	// Mouth corners above the eyes do not form a face.
+	swapped := slices.Clone(src)
+	copy(swapped[6:], src[:4])
+	copy(swapped[:4], src[6:])
+	quality = alignmentQuality(affine, swapped, template, 5)
+	assert.Greater(t, quality.NormalizedError, config.DefaultAlignmentParams.MaxReprojectionError)
+	assert.False(t, helper.IsPlausibleAlignment(quality))
+
+	assert.False(t, helper.IsPlausibleAlignment(config.AlignmentQuality{NumInliers: 2, NumPoints: 5, Scale: 1}))
+	assert.False(t, helper.IsPlausibleAlignment(config.AlignmentQuality{NumInliers: 5, NumPoints: 5, Scale: 8}))
+	assert.False(t, helper.IsPlausibleAlignment(config.AlignmentQuality{NumInliers: 5, NumPoints: 5, Scale: 1, Rotation: 90}))
+}
+
+func TestUprightRotation(t *testing.T) {
+	w, h := 100, 80
+	upright := tensor.New(
+		tensor.Of(tensor.Float32),
+		tensor.WithShape(5, 2),
+		tensor.WithBacking([]float32{30, 30, 50, 30, 40, 40, 32, 55, 48, 55}),
+	)
+	assert.Equal(t, 0, UprightRotation(upright, w, h))
+
+	for _, rotation := range []int{90, 180, 270} {
+		rw, rh := h, w
+		if rotation == 180 {
+			rw, rh = w, h
+		}
+		rotated := RotateLandmark(upright, rotation, w, h)
+		assert.Equal(t, []int{5, 2}, []int(rotated.Shape()))
+		assert.Equal(t, 360-rotation, UprightRotation(rotated, rw, rh))
+		assert.Equal(t, upright.Float32s(), RotateLandmark(rotated, 360-rotation, rw, rh).Float32s())
+	}
+}
+
+func TestFaceHelper_AlignWarpFacesMatLeaks(t *testing.T) {
+	if !utils.IsMatProfileEnabled {
+		t.Skip("run with -tags matprofile to track Mat allocations")
+	}
+
+	faceHelper := &FaceHelperClient{
+		faceSize: [2]int{112, 112},
+		faceTemplate: tensor.New(
+			tensor.Of(tensor.Float32),
+			tensor.WithShape(5, 2),
+			tensor.WithBacking([]float32{38.2946, 51.6963, 73.5318, 51.5014, 56.0252, 71.7366, 41.5493, 92.3655, 70.7299, 92.2041}),
+		),
+	}
+	img := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	leaked := utils.CountMatLeaks(func() {
+		croppedFaces, affineMatrices, qualities, err := faceHelper.AlignWarpFaces(
+			[]gocv.Mat{img, img},
+			[]*tensor.Dense{genTestChallengeLandmark(0, 80), genTestChallengeLandmark(10, 80)},
+			nil,
+		)
+		assert.NoError(t, err)
+		assert.Len(t, qualities, 2)
+		assert.NoError(t, utils.CloseMats(croppedFaces))
+		assert.NoError(t, utils.CloseMats(affineMatrices))
+
+		padded, _, _ := padImage(img, 0.5)
+		assert.NoError(t, padded.Close())
+	})
+	assert.Zero(t, leaked)
+}
+
+func TestMatchLandmark(t *testing.T) {
+	detection := func(offset float32) config.FaceDetectionOutput {
+		return config.FaceDetectionOutput{
+			Landmark: tensor.New(tensor.WithShape(5, 2), tensor.WithBacking([]float32{
+				100 + offset, 100, 140 + offset, 100, 120 + offset, 120, 105 + offset, 140, 135 + offset, 140,
+			})),
+		}
+	}
+	lmk := []float32{102, 101, 142, 99, 121, 121, 106, 140, 136, 141}
+
+	// The closest of the faces matching the landmark is picked, faces elsewhere in the image are not.
+	assert.Equal(t, 1, matchLandmark([]config.FaceDetectionOutput{detection(300), detection(0), detection(5)}, lmk))
+	assert.Equal(t, -1, matchLandmark([]config.FaceDetectionOutput{detection(300), detection(30)}, lmk))
+	assert.Equal(t, -1, matchLandmark(nil, lmk))
+}
@@ -1,24 +1,29 @@
 package modules
 
 import (
+	"context"
 	"github.com/okieraised/go-ekyc-pipeline/config"
 	"github.com/okieraised/go-ekyc-pipeline/utils"
-	gotritonclient "github.com/okieraised/go-triton-client"
 	"github.com/okieraised/go-triton-client/triton_proto"
 	"gocv.io/x/gocv"
 	"gorgonia.org/tensor"
 	"image"
+	"time"
 )
 
 type FaceIDClient struct {
-	tritonClient *gotritonclient.TritonGRPCClient
+	tritonClient InferenceClient
 	ModelParams  *config.FaceIDParams
 	ModelConfig  *triton_proto.ModelConfigResponse
+	requests     *inferRequestPool
 }
 
-func NewFaceIDClient(triton *gotritonclient.TritonGRPCClient, cfg *config.FaceIDParams) (*FaceIDClient, error) {
+func NewFaceIDClient(triton InferenceClient, cfg *config.FaceIDParams) (*FaceIDClient, error) {
+	if _, err := paramsColorOrder(cfg.ColorOrder, false); err != nil {
+		return nil, err
+	}
 
-	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, "")
+	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, cfg.ModelVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -27,21 +32,56 @@ func NewFaceIDClient(triton *gotritonclient.TritonGRPCClient, cfg *config.FaceID
 		tritonClient: triton,
 		ModelParams:  cfg,
 		ModelConfig:  inferenceConfig,
+		requests:     newInferRequestPool(cfg.ModelName, cfg.ModelVersion, inferenceConfig),
 	}, nil
 }
 
+// Contract returns the model configuration expected by the client: a face resized to the image size of the params and
+// FP32 embeddings as first output.
+func (c *FaceIDClient) Contract() ModelContract {
+	return ModelContract{
+		ModelName:    c.ModelParams.ModelName,
+		Inputs:       []TensorContract{imageInputContract(c.ModelParams.ImgSize)},
+		Outputs:      []TensorContract{fp32Contract},
+		ExtraOutputs: &TensorContract{},
+	}
+}
+
+// CheckContract validates the model configuration against the contract of the client.
+func (c *FaceIDClient) CheckContract() error {
+	return c.Contract().Validate(c.ModelConfig)
+}
+
+// ServedModel returns the name of the model and the version that served the last request of the client.
+func (c *FaceIDClient) ServedModel() (string, string) {
+	return c.requests.ServedModel()
+}
+
+// Instrument records the duration of the preprocess, network and postprocess stages of the calls of the client.
+func (c *FaceIDClient) Instrument(metrics *Metrics) {
+	c.requests.Instrument(metrics)
+}
+
+// ColorOrder returns the color order of the images expected by the client, set by the color order of its params.
+func (c *FaceIDClient) ColorOrder() utils.ColorOrder {
+	order, _ := paramsColorOrder(c.ModelParams.ColorOrder, false)
+	return order
+}
+
 func (c *FaceIDClient) preprocess(rawInputTensors []gocv.Mat) error {
 	return nil
 }
 
-func (c *FaceIDClient) preprocessBatch(rawInputTensors [][]gocv.Mat) ([]*tensor.Dense, []config.Size, error) {
+// preprocessBatch normalizes each face into a (3, H, W) input buffer from the request pool.
+func (c *FaceIDClient) preprocessBatch(rawInputTensors [][]gocv.Mat) ([][]float32, []config.Size, error) {
 	inputs := rawInputTensors[0]
-	outputs := make([]*tensor.Dense, 0)
+	outputs := make([][]float32, 0, len(inputs))
 	sizes := make([]config.Size, 0)
+	mean, scale := c.ModelParams.Mean, c.ModelParams.Scale
+	norm := utils.ChannelNormalization{Mean: [3]float64{mean, mean, mean}, Scale: [3]float64{scale, scale, scale}}
 
 	for _, input := range inputs {
 		resizedImg := gocv.NewMat()
-		defer resizedImg.Close()
 		gocv.Resize(
 			input,
 			&resizedImg,
@@ -59,36 +99,12 @@ func (c *FaceIDClient) preprocessBatch(rawInputTensors [][]gocv.Mat) ([]*tensor.
 			Height: imgH,
 		})
 
-		imgTensors := tensor.New(
-			tensor.Of(tensor.Float32),
-			tensor.WithShape(
-				int(c.ModelConfig.Config.Input[0].Dims[1]),
-				int(c.ModelConfig.Config.Input[0].Dims[2]),
-				int(c.ModelConfig.Config.Input[0].Dims[0]),
-			),
-		)
-
-		for z := range int(c.ModelConfig.Config.Input[0].Dims[0]) {
-			for y := range int(c.ModelConfig.Config.Input[0].Dims[1]) {
-				for x := range int(c.ModelConfig.Config.Input[0].Dims[2]) {
-					err := imgTensors.SetAt((float32(resizedImg.GetVecbAt(y, x)[z])-float32(c.ModelParams.Mean))*float32(c.ModelParams.Scale), y, x, z)
-					if err != nil {
-						return nil, nil, err
-					}
-				}
-			}
-		}
-		err := imgTensors.T(2, 0, 1)
-		if err != nil {
-			return nil, nil, err
-		}
-		newShape := []int{1}
-		newShape = append(newShape, imgTensors.Shape()...)
-		err = imgTensors.Reshape(newShape...)
+		chw, err := utils.MatToCHW(resizedImg, norm, c.requests.Buffer(0, 3*imgH*imgW))
+		_ = resizedImg.Close()
 		if err != nil {
 			return nil, nil, err
 		}
-		outputs = append(outputs, imgTensors)
+		outputs = append(outputs, chw)
 	}
 
 	return outputs, sizes, nil
@@ -99,7 +115,13 @@ func (c *FaceIDClient) postprocessBatch(rawOutputs [][]*tensor.Dense, sizes []co
 }
 
 func (c *FaceIDClient) InferBatch(rawInputTensors [][]gocv.Mat) ([]*tensor.Dense, error) {
+	return c.InferBatchContext(context.Background(), rawInputTensors)
+}
+
+func (c *FaceIDClient) InferBatchContext(ctx context.Context, rawInputTensors [][]gocv.Mat) ([]*tensor.Dense, error) {
+	start := time.Now()
 	inputTensors, sizes, err := c.preprocessBatch(rawInputTensors)
+	c.requests.Observe(StagePreprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -109,29 +131,19 @@ func (c *FaceIDClient) InferBatch(rawInputTensors [][]gocv.Mat) ([]*tensor.Dense
 		outputs[idx] = make([]*tensor.Dense, 0)
 	}
 
+	dims := c.ModelConfig.Config.Input[0].Dims
 	for idx := 0; idx < len(inputTensors); idx++ {
-		modelRequest := &triton_proto.ModelInferRequest{
-			ModelName: c.ModelParams.ModelName,
-		}
-
-		modelInputs := make([]*triton_proto.ModelInferRequest_InferInputTensor, 0)
-		for _, inputCfg := range c.ModelConfig.Config.Input {
-			modelInput := &triton_proto.ModelInferRequest_InferInputTensor{
-				Name:     inputCfg.Name,
-				Datatype: inputCfg.DataType.String()[5:],
-				Shape:    []int64{1, inputCfg.Dims[0], inputCfg.Dims[1], inputCfg.Dims[2]},
-				Contents: &triton_proto.InferTensorContents{
-					Fp32Contents: inputTensors[idx].Float32s(),
-				},
-			}
-			modelInputs = append(modelInputs, modelInput)
-		}
+		modelRequest := c.requests.Get()
+		modelRequest.SetInput(0, inputTensors[idx], 1, dims[0], dims[1], dims[2])
 
-		modelRequest.Inputs = modelInputs
-		inferResp, err := c.tritonClient.ModelGRPCInfer(c.ModelParams.Timeout, modelRequest)
+		sent := time.Now()
+		inferResp, err := modelInfer(ctx, c.tritonClient, c.ModelParams.Timeout, modelRequest.Request)
+		c.requests.Observe(StageNetwork, sent, err)
+		c.requests.Put(modelRequest)
 		if err != nil {
 			return nil, err
 		}
+		c.requests.Record(inferResp)
 
 		for oIdx, output := range inferResp.GetOutputs() {
 			outputShape := make([]int, 0, len(output.Shape))
@@ -149,7 +161,9 @@ func (c *FaceIDClient) InferBatch(rawInputTensors [][]gocv.Mat) ([]*tensor.Dense
 		}
 	}
 
+	start = time.Now()
 	faceIDOutputs, err := c.postprocessBatch(outputs, sizes)
+	c.requests.Observe(StagePostprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/okieraised/go-ekyc-pipeline/config"
 	gotritonclient "github.com/okieraised/go-triton-client"
+	"github.com/okieraised/go-triton-client/triton_proto"
 	"github.com/stretchr/testify/assert"
 	"gocv.io/x/gocv"
 	"google.golang.org/grpc"
@@ -40,3 +41,31 @@ func TestFaceIDClient_InferBatch(t *testing.T) {
 	assert.NoError(t, err)
 	fmt.Println("v", len(v), v)
 }
+
+// genTestModelConfig returns the configuration of a model with a single FP32 input of the given dimensions.
+func genTestModelConfig(dims ...int64) *triton_proto.ModelConfigResponse {
+	return &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{
+		Input: []*triton_proto.ModelInput{{Name: "input", DataType: triton_proto.DataType_TYPE_FP32, Dims: dims}},
+	}}
+}
+
+func BenchmarkFaceIDClient_preprocessBatch(b *testing.B) {
+	modelConfig := genTestModelConfig(3, 112, 112)
+	client := &FaceIDClient{ModelParams: config.DefaultFaceIDParams, ModelConfig: modelConfig, requests: newInferRequestPool("face_id", "", modelConfig)}
+	img := gocv.NewMatWithSize(112, 112, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	inputs := [][]gocv.Mat{{img, img, img}}
+
+	b.ReportAllocs()
+	for range b.N {
+		inputs, _, err := client.preprocessBatch(inputs)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, input := range inputs {
+			request := client.requests.Get()
+			request.SetInput(0, input, 1, 3, 112, 112)
+			client.requests.Put(request)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package modules
+
+import (
+	"errors"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"math"
+)
+
+type FaceImageQualityClient struct {
+	ModelParams *config.FaceImageQualityParams
+	PoseParams  *config.HeadPoseParams
+}
+
+// NewFaceImageQualityClient initializes a new FaceImageQualityClient.
+// The pose limits are used as the angles at which the pose component drops to zero.
+func NewFaceImageQualityClient(cfg *config.FaceImageQualityParams, poseCfg *config.HeadPoseParams) (*FaceImageQualityClient, error) {
+	if cfg == nil {
+		return nil, errors.New("face image quality params must not be nil")
+	}
+	if poseCfg == nil {
+		return nil, errors.New("head pose params must not be nil")
+	}
+
+	return &FaceImageQualityClient{
+		ModelParams: cfg,
+		PoseParams:  poseCfg,
+	}, nil
+}
+
+// ratioScore maps value/target to [0, 1].
+func ratioScore(value, target float32) float32 {
+	if target <= 0 {
+		return 1
+	}
+	return utils.Clip(value/target, 0, 1)
+}
+
+// angleScore decreases linearly from 1 at a frontal angle to 0 at the limit.
+func angleScore(angle, limit float32) float32 {
+	if limit <= 0 {
+		return 1
+	}
+	return utils.Clip(1-float32(math.Abs(float64(angle)))/limit, 0, 1)
+}
+
+// Score combines the local image quality, head pose, detection confidence and face quality model output
+// into a unified 0-100 face image quality score.
+//
+// Inputs:
+//
+//   - local (*config.ImageQuality): local image quality metrics.
+//   - pose (*config.HeadPose): head pose.
+//   - detectionScore (float32): face detector confidence.
+//   - coverScore (float32): obstruction score from the face quality model.
+//
+// Outputs:
+//
+//   - quality (*config.FaceImageQuality): unified score with component breakdown.
+func (c *FaceImageQualityClient) Score(local *config.ImageQuality, pose *config.HeadPose, detectionScore, coverScore float32) (*config.FaceImageQuality, error) {
+	if local == nil || pose == nil {
+		return nil, errors.New("local image quality and head pose must not be nil")
+	}
+	params := c.ModelParams
+
+	exposure := 1 - float32(math.Abs(float64(local.Brightness)-127.5))/127.5
+	exposure *= 1 - utils.Clip(local.OverexposureRatio+local.UnderexposureRatio, 0, 1)
+
+	compression := float32(1)
+	if params.MaxBlockiness > 1 {
+		compression = utils.Clip((params.MaxBlockiness-local.Blockiness)/(params.MaxBlockiness-1), 0, 1)
+	}
+
+	pose3 := (angleScore(pose.Yaw, c.PoseParams.MaxYaw) +
+		angleScore(pose.Pitch, c.PoseParams.MaxPitch) +
+		angleScore(pose.Roll, c.PoseParams.MaxRoll)) / 3
+
+	components := config.FaceImageQualityComponents{
+		Sharpness:   100 * ratioScore(local.Sharpness, params.TargetSharpness),
+		Exposure:    100 * utils.Clip(exposure, 0, 1),
+		Contrast:    100 * ratioScore(local.Contrast, params.TargetContrast),
+		Compression: 100 * compression,
+		Resolution:  100 * ratioScore(local.EyeDistance, params.TargetEyeDistance),
+		Pose:        100 * pose3,
+		Detection:   100 * utils.Clip(detectionScore, 0, 1),
+		Occlusion:   100 * utils.Clip(1-coverScore, 0, 1),
+	}
+
+	weights := []float32{
+		params.WeightSharpness, params.WeightExposure, params.WeightContrast, params.WeightCompression,
+		params.WeightResolution, params.WeightPose, params.WeightDetection, params.WeightOcclusion,
+	}
+	values := []float32{
+		components.Sharpness, components.Exposure, components.Contrast, components.Compression,
+		components.Resolution, components.Pose, components.Detection, components.Occlusion,
+	}
+
+	var totalWeight, weighted float32
+	for idx, weight := range weights {
+		totalWeight += weight
+		weighted += weight * values[idx]
+	}
+	if totalWeight <= 0 {
+		return nil, errors.New("sum of face image quality weights must be positive")
+	}
+
+	return &config.FaceImageQuality{
+		Score:          weighted / totalWeight,
+		Components:     components,
+		DetectionScore: detectionScore,
+		CoverScore:     coverScore,
+		HeadPose:       *pose,
+		ImageQuality:   *local,
+	}, nil
+}
@@ -0,0 +1,37 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFaceImageQualityClient_Score(t *testing.T) {
+	client, err := NewFaceImageQualityClient(config.DefaultFaceImageQualityParams, config.DefaultHeadPoseParams)
+	assert.NoError(t, err)
+
+	good := &config.ImageQuality{
+		Sharpness:   500,
+		Brightness:  127.5,
+		Contrast:    60,
+		Blockiness:  1,
+		EyeDistance: 120,
+	}
+	quality, err := client.Score(good, &config.HeadPose{}, 1, 0)
+	assert.NoError(t, err)
+	assert.InDelta(t, 100, quality.Score, 1e-3)
+
+	bad := &config.ImageQuality{
+		Sharpness:         30,
+		Brightness:        240,
+		Contrast:          10,
+		OverexposureRatio: 0.5,
+		Blockiness:        2.5,
+		EyeDistance:       30,
+	}
+	quality, err = client.Score(bad, &config.HeadPose{Yaw: 40}, 0.6, 0.9)
+	assert.NoError(t, err)
+	assert.Less(t, quality.Score, float32(40))
+	assert.Equal(t, float32(0), quality.Components.Compression)
+	assert.InDelta(t, 10, quality.Components.Occlusion, 1e-3)
+}
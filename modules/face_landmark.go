@@ -0,0 +1,217 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"image"
+	"time"
+)
+
+// FaceLandmarkClient predicts dense (68 or 106 point) facial landmarks from a square crop around a detected face.
+type FaceLandmarkClient struct {
+	tritonClient InferenceClient
+	ModelConfig  *triton_proto.ModelConfigResponse
+	ModelParams  *config.FaceLandmarkParams
+	requests     *inferRequestPool
+}
+
+// NewFaceLandmarkClient initializes a new FaceLandmarkClient.
+func NewFaceLandmarkClient(triton InferenceClient, cfg *config.FaceLandmarkParams) (*FaceLandmarkClient, error) {
+	if cfg == nil {
+		return nil, errors.New("face landmark params must not be nil")
+	}
+	if cfg.Layout.NumPoints() == 0 {
+		return nil, fmt.Errorf("unsupported landmark layout %q", cfg.Layout)
+	}
+	if cfg.ImgSize <= 0 || cfg.CropScale <= 0 {
+		return nil, errors.New("face landmark image size and crop scale must be positive")
+	}
+	if _, err := paramsColorOrder(cfg.ColorOrder, cfg.SwapRB); err != nil {
+		return nil, err
+	}
+
+	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, cfg.ModelVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FaceLandmarkClient{
+		tritonClient: triton,
+		ModelConfig:  inferenceConfig,
+		ModelParams:  cfg,
+		requests:     newInferRequestPool(cfg.ModelName, cfg.ModelVersion, inferenceConfig),
+	}, nil
+}
+
+// Contract returns the model configuration expected by the client: a face crop of the image size of the params and
+// FP32 coordinates as first output.
+func (c *FaceLandmarkClient) Contract() ModelContract {
+	return ModelContract{
+		ModelName:    c.ModelParams.ModelName,
+		Inputs:       []TensorContract{imageInputContract(c.ModelParams.ImgSize)},
+		Outputs:      []TensorContract{fp32Contract},
+		ExtraOutputs: &TensorContract{},
+	}
+}
+
+// CheckContract validates the model configuration against the contract of the client.
+func (c *FaceLandmarkClient) CheckContract() error {
+	return c.Contract().Validate(c.ModelConfig)
+}
+
+// ServedModel returns the name of the model and the version that served the last request of the client.
+func (c *FaceLandmarkClient) ServedModel() (string, string) {
+	return c.requests.ServedModel()
+}
+
+// Instrument records the duration of the preprocess, network and postprocess stages of the calls of the client.
+func (c *FaceLandmarkClient) Instrument(metrics *Metrics) {
+	c.requests.Instrument(metrics)
+}
+
+// ColorOrder returns the color order of the images expected by the client, set by the color order and the SwapRB
+// param of its params.
+func (c *FaceLandmarkClient) ColorOrder() utils.ColorOrder {
+	order, _ := paramsColorOrder(c.ModelParams.ColorOrder, c.ModelParams.SwapRB)
+	return order
+}
+
+// cropTransform returns the scale and offset mapping image pixels to the square model input crop centered on the box.
+func (c *FaceLandmarkClient) cropTransform(bbox []float32) (float32, float32, float32) {
+	cx, cy := (bbox[0]+bbox[2])/2, (bbox[1]+bbox[3])/2
+	side := max(bbox[2]-bbox[0], bbox[3]-bbox[1]) * c.ModelParams.CropScale
+	size := float32(c.ModelParams.ImgSize)
+	scale := size / side
+	return scale, size/2 - scale*cx, size/2 - scale*cy
+}
+
+func (c *FaceLandmarkClient) preprocess(img gocv.Mat, bbox []float32) ([]float32, error) {
+	if img.Empty() || img.Channels() != 3 {
+		return nil, errors.New("face landmark input must be a non-empty 3-channel image")
+	}
+	if len(bbox) < 4 || bbox[2] <= bbox[0] || bbox[3] <= bbox[1] {
+		return nil, errors.New("invalid face bounding box")
+	}
+
+	scale, offX, offY := c.cropTransform(bbox)
+	m := gocv.NewMatWithSize(2, 3, gocv.MatTypeCV64F)
+	defer m.Close()
+	m.SetDoubleAt(0, 0, float64(scale))
+	m.SetDoubleAt(0, 2, float64(offX))
+	m.SetDoubleAt(1, 1, float64(scale))
+	m.SetDoubleAt(1, 2, float64(offY))
+
+	crop := gocv.NewMat()
+	defer crop.Close()
+	size := c.ModelParams.ImgSize
+	gocv.WarpAffine(img, &crop, m, image.Point{X: size, Y: size})
+
+	norm := utils.ChannelNormalization{Mean: c.ModelParams.Mean, Scale: c.ModelParams.Scale, SwapRB: c.ModelParams.SwapRB}
+	return utils.MatToCHW(crop, norm, c.requests.Buffer(0, 3*size*size))
+}
+
+// postprocess maps the model output from the crop back to image pixel coordinates.
+func (c *FaceLandmarkClient) postprocess(output []float32, bbox []float32) (*config.Landmarks, error) {
+	scale, offX, offY := c.cropTransform(bbox)
+	size := float32(c.ModelParams.ImgSize)
+
+	coordinates := make([]float32, len(output))
+	for i, v := range output {
+		if c.ModelParams.IsCenteredOutput {
+			// Coordinates in [-1, 1] relative to the crop center.
+			v = (v + 1) * size / 2
+		} else {
+			// Coordinates in [0, 1] relative to the crop.
+			v *= size
+		}
+		if i%2 == 0 {
+			coordinates[i] = (v - offX) / scale
+		} else {
+			coordinates[i] = (v - offY) / scale
+		}
+	}
+	return config.NewLandmarks(c.ModelParams.Layout, coordinates)
+}
+
+// InferSingle predicts the dense landmarks of a face.
+//
+// Inputs:
+//
+//   - img (gocv.Mat): RGB image.
+//   - bbox (*tensor.Dense): [x1, y1, x2, y2] face bounding box from the face detector.
+//
+// Outputs:
+//
+//   - landmarks (*config.Landmarks): dense landmarks in image pixel coordinates.
+func (c *FaceLandmarkClient) InferSingle(img gocv.Mat, bbox *tensor.Dense) (*config.Landmarks, error) {
+	return c.InferSingleContext(context.Background(), img, bbox)
+}
+
+// InferSingleContext is InferSingle run within ctx.
+func (c *FaceLandmarkClient) InferSingleContext(ctx context.Context, img gocv.Mat, bbox *tensor.Dense) (*config.Landmarks, error) {
+	if bbox == nil {
+		return nil, errors.New("face bounding box must not be nil")
+	}
+	box := bbox.Float32s()
+	start := time.Now()
+	chw, err := c.preprocess(img, box)
+	c.requests.Observe(StagePreprocess, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(c.ModelParams.ImgSize)
+	modelRequest := c.requests.Get()
+	modelRequest.SetInput(0, chw, 1, 3, size, size)
+
+	sent := time.Now()
+	inferResp, err := modelInfer(ctx, c.tritonClient, c.ModelParams.Timeout, modelRequest.Request)
+	c.requests.Observe(StageNetwork, sent, err)
+	c.requests.Put(modelRequest)
+	if err != nil {
+		return nil, err
+	}
+	c.requests.Record(inferResp)
+	if len(inferResp.GetOutputs()) == 0 || inferResp.GetOutputs()[0].Datatype != "FP32" {
+		return nil, errors.New("face landmark model must output FP32 coordinates")
+	}
+	if len(inferResp.RawOutputContents) == 0 {
+		return nil, errors.New("face landmark model returned no raw output contents")
+	}
+
+	start = time.Now()
+	landmarks, err := c.postprocess(utils.BytesToT32[float32](inferResp.RawOutputContents[0]), box)
+	c.requests.Observe(StagePostprocess, start, err)
+	return landmarks, err
+}
+
+// InferBatch predicts the dense landmarks of one face per image. Images without a face bounding box get nil landmarks.
+func (c *FaceLandmarkClient) InferBatch(imgs []gocv.Mat, bboxes []*tensor.Dense) ([]*config.Landmarks, error) {
+	return c.InferBatchContext(context.Background(), imgs, bboxes)
+}
+
+// InferBatchContext is InferBatch run within ctx.
+func (c *FaceLandmarkClient) InferBatchContext(ctx context.Context, imgs []gocv.Mat, bboxes []*tensor.Dense) ([]*config.Landmarks, error) {
+	if len(imgs) != len(bboxes) {
+		return nil, errors.New("number of input images and bounding boxes must be equal")
+	}
+	results := make([]*config.Landmarks, 0, len(imgs))
+	for idx := range imgs {
+		if bboxes[idx] == nil {
+			results = append(results, nil)
+			continue
+		}
+		landmarks, err := c.InferSingleContext(ctx, imgs[idx], bboxes[idx])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, landmarks)
+	}
+	return results, nil
+}
@@ -0,0 +1,70 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"testing"
+)
+
+func TestFaceLandmarkClient_Postprocess(t *testing.T) {
+	client := &FaceLandmarkClient{ModelParams: config.DefaultFaceLandmarkParams}
+	bbox := []float32{100, 50, 200, 170}
+
+	// The crop is 180 pixels wide, centered on the box center (150, 110).
+	scale, offX, offY := client.cropTransform(bbox)
+	assert.InDelta(t, 192.0/180, scale, 1e-6)
+	assert.InDelta(t, 96, scale*150+offX, 1e-4)
+	assert.InDelta(t, 96, scale*110+offY, 1e-4)
+
+	output := make([]float32, 2*106)
+	output[0], output[1] = -1, 1
+	landmarks, err := client.postprocess(output, bbox)
+	assert.NoError(t, err)
+	assert.Equal(t, config.LandmarkLayout106, landmarks.Layout)
+	assert.InDelta(t, 60, landmarks.Points[0].X, 1e-3)
+	assert.InDelta(t, 200, landmarks.Points[0].Y, 1e-3)
+	assert.InDelta(t, 150, landmarks.Points[1].X, 1e-3)
+	assert.InDelta(t, 110, landmarks.Points[1].Y, 1e-3)
+
+	_, err = client.postprocess(output[:10], bbox)
+	assert.Error(t, err)
+}
+
+func TestFaceLandmarkClient_InferSingle_NoRawOutput(t *testing.T) {
+	modelConfig := genTestModelConfig(3, 192, 192)
+	triton := &funcClient{infer: func(_ int64, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+		return &triton_proto.ModelInferResponse{
+			ModelName: request.GetModelName(),
+			Outputs:   []*triton_proto.ModelInferResponse_InferOutputTensor{{Name: "fc1", Datatype: "FP32", Shape: []int64{1, 212}}},
+		}, nil
+	}}
+	client := &FaceLandmarkClient{tritonClient: triton, ModelParams: config.DefaultFaceLandmarkParams, ModelConfig: modelConfig, requests: newInferRequestPool("landmark", "", modelConfig)}
+	img := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	bbox := tensor.New(tensor.WithShape(4), tensor.WithBacking([]float32{200, 120, 380, 340}))
+
+	_, err := client.InferSingle(img, bbox)
+	assert.Error(t, err)
+}
+
+func BenchmarkFaceLandmarkClient_preprocess(b *testing.B) {
+	modelConfig := genTestModelConfig(3, 192, 192)
+	client := &FaceLandmarkClient{ModelParams: config.DefaultFaceLandmarkParams, ModelConfig: modelConfig, requests: newInferRequestPool("landmark", "", modelConfig)}
+	img := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	bbox := []float32{200, 120, 380, 340}
+
+	b.ReportAllocs()
+	for range b.N {
+		chw, err := client.preprocess(img, bbox)
+		if err != nil {
+			b.Fatal(err)
+		}
+		request := client.requests.Get()
+		request.SetInput(0, chw, 1, 3, 192, 192)
+		client.requests.Put(request)
+	}
+}
@@ -0,0 +1,130 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"math"
+)
+
+const (
+	solvePnPIterative = 0
+	solvePnPEPnP      = 1
+)
+
+// canonicalFaceModel5 is a generic 3D face in millimeters matching the 5-point landmark order, expressed in
+// camera-aligned coordinates (x right, y down, z away from the camera) with the nose tip as origin.
+var canonicalFaceModel5 = []gocv.Point3f{
+	{X: -32.0, Y: -35.0, Z: 30.0},
+	{X: 32.0, Y: -35.0, Z: 30.0},
+	{X: 0.0, Y: 0.0, Z: 0.0},
+	{X: -25.0, Y: 32.0, Z: 22.0},
+	{X: 25.0, Y: 32.0, Z: 22.0},
+}
+
+type HeadPoseClient struct {
+	ModelParams *config.HeadPoseParams
+}
+
+// NewHeadPoseClient initializes a new HeadPoseClient.
+func NewHeadPoseClient(cfg *config.HeadPoseParams) (*HeadPoseClient, error) {
+	if cfg == nil {
+		return nil, errors.New("head pose params must not be nil")
+	}
+
+	return &HeadPoseClient{
+		ModelParams: cfg,
+	}, nil
+}
+
+// EstimateHeadPose estimates the head pose by fitting the canonical 3D face to a (5, 2) facial landmark.
+//
+// Inputs:
+//
+//   - imgSize (config.Size): size of the image the landmark belongs to.
+//   - landmark (*tensor.Dense): face landmark.
+//
+// Outputs:
+//
+//   - pose (*config.HeadPose): yaw, pitch and roll in degrees.
+func EstimateHeadPose(imgSize config.Size, landmark *tensor.Dense) (*config.HeadPose, error) {
+	if landmark == nil {
+		return nil, errors.New("landmark must not be nil")
+	}
+	points, err := utils.TensorToPoints(landmark)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) != len(canonicalFaceModel5) {
+		return nil, fmt.Errorf("expected %d landmark points, got %d", len(canonicalFaceModel5), len(points))
+	}
+
+	objectPoints := gocv.NewPoint3fVectorFromPoints(canonicalFaceModel5)
+	defer objectPoints.Close()
+	imagePoints := gocv.NewPoint2fVectorFromPoints(points)
+	defer imagePoints.Close()
+
+	// Approximate the camera intrinsics with a focal length equal to the image width and no distortion.
+	focal := float64(imgSize.Width)
+	cameraMatrix := gocv.Zeros(3, 3, gocv.MatTypeCV64F)
+	defer cameraMatrix.Close()
+	cameraMatrix.SetDoubleAt(0, 0, focal)
+	cameraMatrix.SetDoubleAt(1, 1, focal)
+	cameraMatrix.SetDoubleAt(0, 2, float64(imgSize.Width)/2)
+	cameraMatrix.SetDoubleAt(1, 2, float64(imgSize.Height)/2)
+	cameraMatrix.SetDoubleAt(2, 2, 1)
+
+	distCoeffs := gocv.Zeros(4, 1, gocv.MatTypeCV64F)
+	defer distCoeffs.Close()
+
+	rvec := gocv.NewMat()
+	defer rvec.Close()
+	tvec := gocv.NewMat()
+	defer tvec.Close()
+
+	// The iterative solver needs an initial guess with fewer than 6 points, which EPnP provides.
+	if !gocv.SolvePnP(objectPoints, imagePoints, cameraMatrix, distCoeffs, &rvec, &tvec, false, solvePnPEPnP) {
+		return nil, errors.New("cannot estimate head pose from landmark")
+	}
+	if !gocv.SolvePnP(objectPoints, imagePoints, cameraMatrix, distCoeffs, &rvec, &tvec, true, solvePnPIterative) {
+		return nil, errors.New("cannot estimate head pose from landmark")
+	}
+
+	rotation := gocv.NewMat()
+	defer rotation.Close()
+	gocv.Rodrigues(rvec, &rotation)
+
+	r00, r10 := rotation.GetDoubleAt(0, 0), rotation.GetDoubleAt(1, 0)
+	r20, r21, r22 := rotation.GetDoubleAt(2, 0), rotation.GetDoubleAt(2, 1), rotation.GetDoubleAt(2, 2)
+
+	toDegrees := func(rad float64) float32 {
+		return float32(rad * 180 / math.Pi)
+	}
+
+	return &config.HeadPose{
+		Yaw:   toDegrees(math.Atan2(r20, math.Hypot(r00, r10))),
+		Pitch: toDegrees(math.Atan2(r21, r22)),
+		Roll:  toDegrees(math.Atan2(r10, r00)),
+	}, nil
+}
+
+// Estimate returns the head pose of the face in the input image.
+func (c *HeadPoseClient) Estimate(img gocv.Mat, landmark *tensor.Dense) (*config.HeadPose, error) {
+	dims := img.Size()
+	return EstimateHeadPose(config.Size{Width: dims[1], Height: dims[0]}, landmark)
+}
+
+// IsWithinLimits reports whether every angle of the pose is within the configured limits.
+// A limit of zero disables the check for that angle.
+func (c *HeadPoseClient) IsWithinLimits(pose *config.HeadPose) bool {
+	withinLimit := func(angle, limit float32) bool {
+		return limit <= 0 || float32(math.Abs(float64(angle))) <= limit
+	}
+
+	return withinLimit(pose.Yaw, c.ModelParams.MaxYaw) &&
+		withinLimit(pose.Pitch, c.ModelParams.MaxPitch) &&
+		withinLimit(pose.Roll, c.ModelParams.MaxRoll)
+}
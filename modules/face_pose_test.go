@@ -0,0 +1,45 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/stretchr/testify/assert"
+	"gorgonia.org/tensor"
+	"math"
+	"testing"
+)
+
+// genTestPoseLandmark projects the canonical face rotated around the vertical axis onto a 640x480 image.
+func genTestPoseLandmark(theta float64) *tensor.Dense {
+	backing := make([]float32, 0, 2*len(canonicalFaceModel5))
+	for _, p := range canonicalFaceModel5 {
+		x := float64(p.X)*math.Cos(theta) + float64(p.Z)*math.Sin(theta)
+		y := float64(p.Y)
+		z := -float64(p.X)*math.Sin(theta) + float64(p.Z)*math.Cos(theta) + 600
+		backing = append(backing, float32(320+640*x/z), float32(240+640*y/z))
+	}
+	return tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(5, 2), tensor.WithBacking(backing))
+}
+
+func TestEstimateHeadPose(t *testing.T) {
+	size := config.Size{Width: 640, Height: 480}
+
+	pose, err := EstimateHeadPose(size, genTestPoseLandmark(0))
+	assert.NoError(t, err)
+	assert.InDelta(t, 0, pose.Yaw, 2)
+	assert.InDelta(t, 0, pose.Pitch, 2)
+	assert.InDelta(t, 0, pose.Roll, 2)
+
+	// Turning the nose towards the right of the image is a positive yaw.
+	pose, err = EstimateHeadPose(size, genTestPoseLandmark(-20*math.Pi/180))
+	assert.NoError(t, err)
+	assert.InDelta(t, 20, pose.Yaw, 2)
+}
+
+func TestHeadPoseClient_IsWithinLimits(t *testing.T) {
+	client, err := NewHeadPoseClient(config.NewHeadPoseParams(30, 20, 0))
+	assert.NoError(t, err)
+
+	assert.True(t, client.IsWithinLimits(&config.HeadPose{Yaw: -25, Pitch: 10, Roll: 80}))
+	assert.False(t, client.IsWithinLimits(&config.HeadPose{Yaw: 35, Pitch: 10, Roll: 0}))
+	assert.False(t, client.IsWithinLimits(&config.HeadPose{Yaw: 0, Pitch: -21, Roll: 0}))
+}
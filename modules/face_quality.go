@@ -1,23 +1,30 @@
 package modules
 
 import (
+	"context"
+	"errors"
 	"github.com/okieraised/go-ekyc-pipeline/config"
 	"github.com/okieraised/go-ekyc-pipeline/utils"
-	gotritonclient "github.com/okieraised/go-triton-client"
 	"github.com/okieraised/go-triton-client/triton_proto"
 	"gocv.io/x/gocv"
 	"gorgonia.org/tensor"
+	"image"
+	"time"
 )
 
 type FaceQualityClient struct {
-	tritonClient *gotritonclient.TritonGRPCClient
+	tritonClient InferenceClient
 	ModelParams  *config.FaceQualityParams
 	ModelConfig  *triton_proto.ModelConfigResponse
+	requests     *inferRequestPool
 }
 
-func NewFaceQualityClient(triton *gotritonclient.TritonGRPCClient, cfg *config.FaceQualityParams) (*FaceQualityClient, error) {
+func NewFaceQualityClient(triton InferenceClient, cfg *config.FaceQualityParams) (*FaceQualityClient, error) {
+	if _, err := paramsColorOrder(cfg.ColorOrder, false); err != nil {
+		return nil, err
+	}
 
-	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, "")
+	inferenceConfig, err := triton.GetModelConfiguration(cfg.Timeout, cfg.ModelName, cfg.ModelVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -26,96 +33,80 @@ func NewFaceQualityClient(triton *gotritonclient.TritonGRPCClient, cfg *config.F
 		tritonClient: triton,
 		ModelParams:  cfg,
 		ModelConfig:  inferenceConfig,
+		requests:     newInferRequestPool(cfg.ModelName, cfg.ModelVersion, inferenceConfig),
 	}, nil
 }
 
-func (c *FaceQualityClient) preprocess(rawInputTensors []gocv.Mat) ([]*tensor.Dense, []config.Size, error) {
-	outputs := make([]*tensor.Dense, 0)
-	sizes := make([]config.Size, 0)
+// Contract returns the model configuration expected by the client: faces resized to the input size of the model and
+// FP32 scores as outputs.
+func (c *FaceQualityClient) Contract() ModelContract {
+	return ModelContract{
+		ModelName:    c.ModelParams.ModelName,
+		Inputs:       []TensorContract{imageInputContract(0)},
+		Outputs:      []TensorContract{fp32Contract},
+		ExtraOutputs: &TensorContract{DataTypes: fp32Contract.DataTypes},
+	}
+}
 
-	for _, input := range rawInputTensors {
-		imgH, imgW := input.Size()[0], input.Size()[1]
-		sizes = append(sizes, config.Size{
-			Width:  imgW,
-			Height: imgH,
-		})
+// CheckContract validates the model configuration against the contract of the client.
+func (c *FaceQualityClient) CheckContract() error {
+	return c.Contract().Validate(c.ModelConfig)
+}
 
-		imgTensors := tensor.New(
-			tensor.Of(tensor.Float32),
-			tensor.WithShape(
-				int(c.ModelConfig.Config.Input[0].Dims[1]),
-				int(c.ModelConfig.Config.Input[0].Dims[2]),
-				int(c.ModelConfig.Config.Input[0].Dims[0]),
-			),
-		)
+// ServedModel returns the name of the model and the version that served the last request of the client.
+func (c *FaceQualityClient) ServedModel() (string, string) {
+	return c.requests.ServedModel()
+}
 
-		for z := range int(c.ModelConfig.Config.Input[0].Dims[0]) {
-			for y := range int(c.ModelConfig.Config.Input[0].Dims[1]) {
-				for x := range int(c.ModelConfig.Config.Input[0].Dims[2]) {
-					err := imgTensors.SetAt((float32(input.GetVecbAt(y, x)[z])-float32(c.ModelParams.Mean[z]))*float32(c.ModelParams.Scale[z]), y, x, z)
-					if err != nil {
-						return nil, nil, err
-					}
-				}
-			}
-		}
-		err := imgTensors.T(2, 0, 1)
-		if err != nil {
-			return nil, nil, err
-		}
-		outputs = append(outputs, imgTensors)
-	}
-	preprocessed, err := outputs[0].Stack(0, outputs[1:]...)
-	if err != nil {
-		return nil, nil, err
-	}
+// Instrument records the duration of the preprocess, network and postprocess stages of the calls of the client.
+func (c *FaceQualityClient) Instrument(metrics *Metrics) {
+	c.requests.Instrument(metrics)
+}
 
-	return []*tensor.Dense{preprocessed}, sizes, nil
+// ColorOrder returns the color order of the images expected by the client, set by the color order of its params.
+func (c *FaceQualityClient) ColorOrder() utils.ColorOrder {
+	order, _ := paramsColorOrder(c.ModelParams.ColorOrder, false)
+	return order
 }
 
-func (c *FaceQualityClient) preprocessBatch(rawInputTensors [][]gocv.Mat) ([]*tensor.Dense, []config.Size, error) {
+func (c *FaceQualityClient) preprocess(rawInputTensors []gocv.Mat) ([]float32, []config.Size, error) {
+	return c.preprocessBatch([][]gocv.Mat{rawInputTensors})
+}
+
+// preprocessBatch normalizes the aligned faces into a single (N, C, H, W) input buffer from the request pool.
+func (c *FaceQualityClient) preprocessBatch(rawInputTensors [][]gocv.Mat) ([]float32, []config.Size, error) {
 	inputs := rawInputTensors[0]
-	outputs := make([]*tensor.Dense, 0)
-	sizes := make([]config.Size, 0)
+	if len(inputs) == 0 {
+		return nil, nil, errors.New("face quality input must not be empty")
+	}
+	sizes := make([]config.Size, 0, len(inputs))
+	norm := utils.ChannelNormalization{Mean: c.ModelParams.Mean, Scale: c.ModelParams.Scale}
 
-	for _, input := range inputs {
+	dims := c.ModelConfig.Config.Input[0].Dims
+	channels, inputH, inputW := int(dims[0]), int(dims[1]), int(dims[2])
+	plane := channels * inputH * inputW
+	batch := c.requests.Buffer(0, len(inputs)*plane)
+
+	for idx, input := range inputs {
 		imgH, imgW := input.Size()[0], input.Size()[1]
 		sizes = append(sizes, config.Size{
 			Width:  imgW,
 			Height: imgH,
 		})
-
-		imgTensors := tensor.New(
-			tensor.Of(tensor.Float32),
-			tensor.WithShape(
-				int(c.ModelConfig.Config.Input[0].Dims[1]),
-				int(c.ModelConfig.Config.Input[0].Dims[2]),
-				int(c.ModelConfig.Config.Input[0].Dims[0]),
-			),
-		)
-
-		for z := range int(c.ModelConfig.Config.Input[0].Dims[0]) {
-			for y := range int(c.ModelConfig.Config.Input[0].Dims[1]) {
-				for x := range int(c.ModelConfig.Config.Input[0].Dims[2]) {
-					err := imgTensors.SetAt((float32(input.GetVecbAt(y, x)[z])-float32(c.ModelParams.Mean[z]))*float32(c.ModelParams.Scale[z]), y, x, z)
-					if err != nil {
-						return nil, nil, err
-					}
-				}
-			}
+		if imgH != inputH || imgW != inputW {
+			resizedImg := gocv.NewMat()
+			defer resizedImg.Close()
+			gocv.Resize(input, &resizedImg, image.Point{X: inputW, Y: inputH}, 0, 0, gocv.InterpolationLinear)
+			input = resizedImg
 		}
-		err := imgTensors.T(2, 0, 1)
+
+		_, err := utils.MatToCHW(input, norm, batch[idx*plane:(idx+1)*plane])
 		if err != nil {
 			return nil, nil, err
 		}
-		outputs = append(outputs, imgTensors)
-	}
-	preprocessed, err := outputs[0].Stack(0, outputs[1:]...)
-	if err != nil {
-		return nil, nil, err
 	}
 
-	return []*tensor.Dense{preprocessed}, sizes, nil
+	return batch, sizes, nil
 }
 
 func (c *FaceQualityClient) postprocessBatch(rawOutputs []*tensor.Dense, sizes []config.Size) ([]*tensor.Dense, error) {
@@ -156,57 +147,56 @@ func (c *FaceQualityClient) postprocess(rawOutputs []*tensor.Dense, sizes []conf
 	return output, nil
 }
 
-func (c *FaceQualityClient) InferSingle(rawInputTensors []gocv.Mat) ([]*tensor.Dense, error) {
-	inputTensors, sizes, err := c.preprocess(rawInputTensors)
-	if err != nil {
-		return nil, err
-	}
-	outputs := make([]*tensor.Dense, 0)
-
-	modelRequest := &triton_proto.ModelInferRequest{
-		ModelName: c.ModelParams.ModelName,
-	}
+// infer sends the (N, C, H, W) input of n faces to the model and returns its outputs.
+func (c *FaceQualityClient) infer(ctx context.Context, input []float32, n int) ([]*tensor.Dense, error) {
+	dims := c.ModelConfig.Config.Input[0].Dims
+	modelRequest := c.requests.Get()
+	modelRequest.SetInput(0, input, int64(n), dims[0], dims[1], dims[2])
 
-	modelInputs := make([]*triton_proto.ModelInferRequest_InferInputTensor, 0)
-	for _, inputCfg := range c.ModelConfig.Config.Input {
-		tShapes := inputTensors[0].Shape()
-		inputShapes := make([]int64, 0)
-		for _, s := range tShapes {
-			inputShapes = append(inputShapes, int64(s))
-		}
-
-		modelInput := &triton_proto.ModelInferRequest_InferInputTensor{
-			Name:     inputCfg.Name,
-			Datatype: inputCfg.DataType.String()[5:],
-			Shape:    inputShapes,
-			Contents: &triton_proto.InferTensorContents{
-				Fp32Contents: inputTensors[0].Float32s(),
-			},
-		}
-		modelInputs = append(modelInputs, modelInput)
-	}
-
-	modelRequest.Inputs = modelInputs
-	inferResp, err := c.tritonClient.ModelGRPCInfer(c.ModelParams.Timeout, modelRequest)
+	sent := time.Now()
+	inferResp, err := modelInfer(ctx, c.tritonClient, c.ModelParams.Timeout, modelRequest.Request)
+	c.requests.Observe(StageNetwork, sent, err)
+	c.requests.Put(modelRequest)
 	if err != nil {
 		return nil, err
 	}
+	c.requests.Record(inferResp)
 
+	outputs := make([]*tensor.Dense, 0, len(inferResp.GetOutputs()))
 	for oIdx, output := range inferResp.GetOutputs() {
 		outputShape := make([]int, 0, len(output.Shape))
 		for _, shp := range output.Shape {
 			outputShape = append(outputShape, int(shp))
 		}
-		var tensors *tensor.Dense
 		content := utils.BytesToT32[float32](inferResp.RawOutputContents[oIdx])
-		tensors = tensor.New(
+		tensors := tensor.New(
 			tensor.Of(tensor.Float32),
 			tensor.WithShape(outputShape...),
 			tensor.WithBacking(content),
 		)
 		outputs = append(outputs, tensors)
 	}
+	return outputs, nil
+}
+
+func (c *FaceQualityClient) InferSingle(rawInputTensors []gocv.Mat) ([]*tensor.Dense, error) {
+	return c.InferSingleContext(context.Background(), rawInputTensors)
+}
+
+func (c *FaceQualityClient) InferSingleContext(ctx context.Context, rawInputTensors []gocv.Mat) ([]*tensor.Dense, error) {
+	start := time.Now()
+	input, sizes, err := c.preprocess(rawInputTensors)
+	c.requests.Observe(StagePreprocess, start, err)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := c.infer(ctx, input, len(sizes))
+	if err != nil {
+		return nil, err
+	}
+	start = time.Now()
 	qualityOutputs, err := c.postprocess(outputs, sizes)
+	c.requests.Observe(StagePostprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -214,72 +204,23 @@ func (c *FaceQualityClient) InferSingle(rawInputTensors []gocv.Mat) ([]*tensor.D
 }
 
 func (c *FaceQualityClient) InferBatch(rawInputTensors [][]gocv.Mat) ([]*tensor.Dense, error) {
-	inputTensors, sizes, err := c.preprocessBatch(rawInputTensors)
+	return c.InferBatchContext(context.Background(), rawInputTensors)
+}
+
+func (c *FaceQualityClient) InferBatchContext(ctx context.Context, rawInputTensors [][]gocv.Mat) ([]*tensor.Dense, error) {
+	start := time.Now()
+	input, sizes, err := c.preprocessBatch(rawInputTensors)
+	c.requests.Observe(StagePreprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
-
-	outputs := make([][]*tensor.Dense, len(c.ModelConfig.Config.Output))
-	for idx := 0; idx < len(c.ModelConfig.Config.Output); idx++ {
-		outputs[idx] = make([]*tensor.Dense, 0)
-	}
-
-	for idx := 0; idx < len(inputTensors); idx++ {
-		modelRequest := &triton_proto.ModelInferRequest{
-			ModelName: c.ModelParams.ModelName,
-		}
-
-		modelInputs := make([]*triton_proto.ModelInferRequest_InferInputTensor, 0)
-		for _, inputCfg := range c.ModelConfig.Config.Input {
-			tShapes := inputTensors[idx].Shape()
-			inputShapes := make([]int64, 0)
-			for _, s := range tShapes {
-				inputShapes = append(inputShapes, int64(s))
-			}
-
-			modelInput := &triton_proto.ModelInferRequest_InferInputTensor{
-				Name:     inputCfg.Name,
-				Datatype: inputCfg.DataType.String()[5:],
-				Shape:    inputShapes,
-				Contents: &triton_proto.InferTensorContents{
-					Fp32Contents: inputTensors[idx].Float32s(),
-				},
-			}
-			modelInputs = append(modelInputs, modelInput)
-		}
-
-		modelRequest.Inputs = modelInputs
-		inferResp, err := c.tritonClient.ModelGRPCInfer(c.ModelParams.Timeout, modelRequest)
-		if err != nil {
-			return nil, err
-		}
-
-		for oIdx, output := range inferResp.GetOutputs() {
-			outputShape := make([]int, 0, len(output.Shape))
-			for _, shp := range output.Shape {
-				outputShape = append(outputShape, int(shp))
-			}
-			var tensors *tensor.Dense
-			content := utils.BytesToT32[float32](inferResp.RawOutputContents[oIdx])
-			tensors = tensor.New(
-				tensor.Of(tensor.Float32),
-				tensor.WithShape(outputShape...),
-				tensor.WithBacking(content),
-			)
-			outputs[oIdx] = append(outputs[oIdx], tensors)
-		}
-	}
-
-	concatenatedOutputs := make([]*tensor.Dense, len(outputs))
-	for i, output := range outputs {
-		concatenated, err := output[0].Concat(0, output[1:]...)
-		if err != nil {
-			return nil, err
-		}
-		concatenatedOutputs[i] = concatenated
+	outputs, err := c.infer(ctx, input, len(sizes))
+	if err != nil {
+		return nil, err
 	}
-
-	qualityOutputs, err := c.postprocessBatch(concatenatedOutputs, sizes)
+	start = time.Now()
+	qualityOutputs, err := c.postprocessBatch(outputs, sizes)
+	c.requests.Observe(StagePostprocess, start, err)
 	if err != nil {
 		return nil, err
 	}
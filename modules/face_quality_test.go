@@ -31,7 +31,7 @@ func TestFaceQualityClient_InferBatch(t *testing.T) {
 	far, err := genTestFarData()
 	assert.NoError(t, err)
 
-	faceHelper, err := NewFaceHelperClient(triton, 112, 224, 128, nil, nil, nil)
+	faceHelper, err := NewFaceHelperClient(triton, 112, 224, 128, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
 	_, batchLandmarks, err := faceHelper.GetFaceLandmarks5(
@@ -41,10 +41,11 @@ func TestFaceQualityClient_InferBatch(t *testing.T) {
 		nil,
 		nil,
 		utils.RefPointer(true),
+		nil,
 	)
 	assert.NoError(t, err)
 
-	croppedFaces, _, err := faceHelper.AlignWarpFaces([]gocv.Mat{*far, *mid, *near}, []*tensor.Dense{
+	croppedFaces, _, _, err := faceHelper.AlignWarpFaces([]gocv.Mat{*far, *mid, *near}, []*tensor.Dense{
 		batchLandmarks[0],
 		batchLandmarks[1],
 		batchLandmarks[2],
@@ -65,3 +66,22 @@ func TestFaceQualityClient_InferBatch(t *testing.T) {
 	assert.NoError(t, err)
 	fmt.Println("res2", res2)
 }
+
+func BenchmarkFaceQualityClient_preprocessBatch(b *testing.B) {
+	modelConfig := genTestModelConfig(3, 112, 112)
+	client := &FaceQualityClient{ModelParams: config.DefaultFaceQualityParams, ModelConfig: modelConfig, requests: newInferRequestPool("quality", "", modelConfig)}
+	img := gocv.NewMatWithSize(112, 112, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	inputs := [][]gocv.Mat{{img, img, img}}
+
+	b.ReportAllocs()
+	for range b.N {
+		input, _, err := client.preprocessBatch(inputs)
+		if err != nil {
+			b.Fatal(err)
+		}
+		request := client.requests.Get()
+		request.SetInput(0, input, 3, 3, 112, 112)
+		client.requests.Put(request)
+	}
+}
@@ -0,0 +1,121 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"gocv.io/x/gocv"
+	"slices"
+)
+
+type frameCandidate struct {
+	position    int
+	eyeDistance float32
+	sharpness   float64
+}
+
+// SelectDistanceFrames selects the far-, mid- and near-distance frames from a sequence of video frames.
+//
+// Frames are ranked by the inter-eye distance of their largest face and split into three equal groups,
+// from each of which the sharpest frame is selected.
+//
+// Inputs:
+//
+//   - frames ([]gocv.Mat): list of video frames, in the color order of the face detector.
+//   - params (*config.VideoFrameSelectionParams): selection parameters.
+//
+// Outputs:
+//
+//   - selection (*config.VideoFrameSelection): positions of the selected frames in the input list.
+func (c *FaceHelperClient) SelectDistanceFrames(frames []gocv.Mat, params *config.VideoFrameSelectionParams) (*config.VideoFrameSelection, error) {
+	return c.SelectDistanceFramesContext(context.Background(), frames, params)
+}
+
+// SelectDistanceFramesContext is SelectDistanceFrames run within ctx.
+func (c *FaceHelperClient) SelectDistanceFramesContext(ctx context.Context, frames []gocv.Mat, params *config.VideoFrameSelectionParams) (*config.VideoFrameSelection, error) {
+	if params == nil {
+		params = config.DefaultVideoFrameSelectionParams
+	}
+
+	batchBBoxes, batchLandmarks, err := c.GetFaceLandmarks5Context(
+		ctx,
+		frames,
+		utils.RefPointer(true),
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]frameCandidate, 0, len(frames))
+	for idx, landmark := range batchLandmarks {
+		if landmark == nil {
+			continue
+		}
+		lmk := landmark.Float32s()
+		eyeDist := utils.EuclideanDistance(lmk[0], lmk[1], lmk[2], lmk[3])
+		if eyeDist < params.MinEyeDistance {
+			continue
+		}
+		sharpness := regionSharpness(frames[idx], c.ColorOrder(), faceRegion(frames[idx], batchBBoxes[idx], landmark))
+		if sharpness < params.MinSharpness {
+			continue
+		}
+		candidates = append(candidates, frameCandidate{
+			position:    idx,
+			eyeDistance: eyeDist,
+			sharpness:   sharpness,
+		})
+	}
+
+	if len(candidates) < 3 {
+		return nil, errors.New("not enough usable face frames in input video")
+	}
+
+	slices.SortStableFunc(candidates, func(a, b frameCandidate) int {
+		switch {
+		case a.eyeDistance < b.eyeDistance:
+			return -1
+		case a.eyeDistance > b.eyeDistance:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	n := len(candidates)
+	sharpest := func(group []frameCandidate) frameCandidate {
+		return slices.MaxFunc(group, func(a, b frameCandidate) int {
+			switch {
+			case a.sharpness < b.sharpness:
+				return -1
+			case a.sharpness > b.sharpness:
+				return 1
+			default:
+				return 0
+			}
+		})
+	}
+	far := sharpest(candidates[:n/3])
+	mid := sharpest(candidates[n/3 : 2*n/3])
+	near := sharpest(candidates[2*n/3:])
+
+	if near.eyeDistance < far.eyeDistance*params.MinDistanceRatio {
+		return nil, errors.New("insufficient face distance variation in input video")
+	}
+
+	return &config.VideoFrameSelection{
+		FarIndex:        far.position,
+		MidIndex:        mid.position,
+		NearIndex:       near.position,
+		FarEyeDistance:  far.eyeDistance,
+		MidEyeDistance:  mid.eyeDistance,
+		NearEyeDistance: near.eyeDistance,
+		NumCandidates:   n,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	gotritonclient "github.com/okieraised/go-triton-client"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"testing"
+)
+
+func TestFaceHelperClient_SelectDistanceFrames(t *testing.T) {
+	triton, err := gotritonclient.NewTritonGRPCClient(
+		tritonTestURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	)
+	assert.NoError(t, err)
+
+	near, err := genTestNearData()
+	assert.NoError(t, err)
+
+	mid, err := genTestMidData()
+	assert.NoError(t, err)
+
+	far, err := genTestFarData()
+	assert.NoError(t, err)
+
+	faceHelper, err := NewFaceHelperClient(triton, 112, 224, 128, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	selection, err := faceHelper.SelectDistanceFrames([]gocv.Mat{*near, *far, *mid}, config.DefaultVideoFrameSelectionParams)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, selection.FarIndex)
+	assert.Equal(t, 2, selection.MidIndex)
+	assert.Equal(t, 0, selection.NearIndex)
+	fmt.Println("selection", selection)
+}
@@ -0,0 +1,264 @@
+package modules
+
+import (
+	"errors"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"image"
+	"math"
+	"slices"
+)
+
+const (
+	overexposedLevel  = 250
+	underexposedLevel = 5
+	jpegBlockSize     = 8
+)
+
+type ImageQualityClient struct {
+	ModelParams *config.ImageQualityParams
+}
+
+// NewImageQualityClient initializes a new ImageQualityClient.
+func NewImageQualityClient(cfg *config.ImageQualityParams) (*ImageQualityClient, error) {
+	if cfg == nil {
+		return nil, errors.New("image quality params must not be nil")
+	}
+	if _, err := paramsColorOrder(cfg.ColorOrder, false); err != nil {
+		return nil, err
+	}
+
+	return &ImageQualityClient{
+		ModelParams: cfg,
+	}, nil
+}
+
+// ColorOrder returns the color order of the images expected by the client, set by the color order of its params.
+func (c *ImageQualityClient) ColorOrder() utils.ColorOrder {
+	order, _ := paramsColorOrder(c.ModelParams.ColorOrder, false)
+	return order
+}
+
+// faceRegion returns the face region of the image from the bounding box, or from the landmark if the bounding box is nil.
+func faceRegion(img gocv.Mat, bbox, landmark *tensor.Dense) image.Rectangle {
+	dims := img.Size()
+	h, w := dims[0], dims[1]
+	bounds := image.Rect(0, 0, w, h)
+
+	if bbox != nil {
+		box := bbox.Float32s()
+		return image.Rect(int(box[0]), int(box[1]), int(box[2]), int(box[3])).Intersect(bounds)
+	}
+	if landmark == nil {
+		return bounds
+	}
+
+	lmk := landmark.Float32s()
+	xs := []float32{lmk[0], lmk[2], lmk[4], lmk[6], lmk[8]}
+	ys := []float32{lmk[1], lmk[3], lmk[5], lmk[7], lmk[9]}
+	eyeDist := utils.EuclideanDistance(lmk[0], lmk[1], lmk[2], lmk[3])
+
+	return image.Rect(
+		int(slices.Min(xs)-0.5*eyeDist),
+		int(slices.Min(ys)-0.8*eyeDist),
+		int(slices.Max(xs)+0.5*eyeDist),
+		int(slices.Max(ys)+0.6*eyeDist),
+	).Intersect(bounds)
+}
+
+// laplacianVariance returns the variance of the Laplacian of the input gray image.
+func laplacianVariance(gray gocv.Mat) float64 {
+	laplacian := gocv.NewMat()
+	defer laplacian.Close()
+	gocv.Laplacian(gray, &laplacian, gocv.MatTypeCV64F, 1, 1, 0, gocv.BorderDefault)
+
+	mean := gocv.NewMat()
+	defer mean.Close()
+	stdDev := gocv.NewMat()
+	defer stdDev.Close()
+	gocv.MeanStdDev(laplacian, &mean, &stdDev)
+
+	std := stdDev.GetDoubleAt(0, 0)
+	return std * std
+}
+
+// regionSharpness returns the variance of the Laplacian of a region of the input RGB or BGR image.
+func regionSharpness(img gocv.Mat, order utils.ColorOrder, region image.Rectangle) float64 {
+	if region.Empty() {
+		return 0
+	}
+	roi := img.Region(region)
+	defer roi.Close()
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(roi, &gray, grayConversion(order))
+
+	return laplacianVariance(gray)
+}
+
+// grayHistogram returns the 256-bin histogram of the input gray pixels.
+func grayHistogram(pixels []byte) [256]int {
+	var hist [256]int
+	for _, p := range pixels {
+		hist[p]++
+	}
+	return hist
+}
+
+// histogramStats returns the mean, the standard deviation and the ratios of over- and under-exposed pixels of a histogram.
+func histogramStats(hist [256]int) (float64, float64, float64, float64) {
+	var total, sum, sumSquare, over, under float64
+	for level, count := range hist {
+		c := float64(count)
+		total += c
+		sum += c * float64(level)
+		sumSquare += c * float64(level) * float64(level)
+		if level >= overexposedLevel {
+			over += c
+		}
+		if level <= underexposedLevel {
+			under += c
+		}
+	}
+	if total == 0 {
+		return 0, 0, 0, 0
+	}
+	mean := sum / total
+	variance := math.Max(sumSquare/total-mean*mean, 0)
+	return mean, math.Sqrt(variance), over / total, under / total
+}
+
+// blockiness returns the ratio of the mean gradient across 8x8 block borders to the mean gradient inside blocks.
+// JPEG compression artifacts push the ratio above 1.
+func blockiness(pixels []byte, h, w int) float64 {
+	var border, inner float64
+	var nBorder, nInner int
+
+	accumulate := func(a, b byte, isBorder bool) {
+		d := math.Abs(float64(a) - float64(b))
+		if isBorder {
+			border += d
+			nBorder++
+		} else {
+			inner += d
+			nInner++
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			accumulate(pixels[y*w+x], pixels[y*w+x+1], (x+1)%jpegBlockSize == 0)
+		}
+	}
+	for y := 0; y < h-1; y++ {
+		for x := 0; x < w; x++ {
+			accumulate(pixels[y*w+x], pixels[(y+1)*w+x], (y+1)%jpegBlockSize == 0)
+		}
+	}
+
+	if nBorder == 0 || nInner == 0 || inner == 0 {
+		return 1
+	}
+	return (border / float64(nBorder)) / (inner / float64(nInner))
+}
+
+// Assess computes the local quality metrics of an RGB capture.
+//
+// Inputs:
+//
+//   - img (gocv.Mat): face image.
+//   - landmark (*tensor.Dense): face landmark.
+//   - bbox (*tensor.Dense): face bounding box, estimated from the landmark if nil.
+//
+// Outputs:
+//
+//   - quality (*config.ImageQuality): quality metrics and threshold decision.
+func (c *ImageQualityClient) Assess(img gocv.Mat, landmark, bbox *tensor.Dense) (*config.ImageQuality, error) {
+	if img.Empty() {
+		return nil, errors.New("input image is empty")
+	}
+	if landmark == nil {
+		return nil, errors.New("landmark must not be nil")
+	}
+
+	dims := img.Size()
+	h, w := dims[0], dims[1]
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(img, &gray, grayConversion(c.ColorOrder()))
+
+	region := faceRegion(img, bbox, landmark)
+	if region.Empty() {
+		return nil, errors.New("face region is outside of the input image")
+	}
+
+	faceGray := gray.Region(region)
+	defer faceGray.Close()
+	faceGrayCont := faceGray.Clone()
+	defer faceGrayCont.Close()
+
+	brightness, contrast, overexposure, underexposure := histogramStats(grayHistogram(faceGrayCont.ToBytes()))
+
+	lmk := landmark.Float32s()
+	quality := &config.ImageQuality{
+		Sharpness:          float32(laplacianVariance(faceGrayCont)),
+		Brightness:         float32(brightness),
+		Contrast:           float32(contrast),
+		OverexposureRatio:  float32(overexposure),
+		UnderexposureRatio: float32(underexposure),
+		Blockiness:         float32(blockiness(gray.ToBytes(), h, w)),
+		EyeDistance:        utils.EuclideanDistance(lmk[0], lmk[1], lmk[2], lmk[3]),
+		FaceFrameRatio:     float32(region.Dx()*region.Dy()) / float32(h*w),
+		Failures:           make([]string, 0),
+	}
+
+	params := c.ModelParams
+	if quality.Sharpness < params.MinSharpness {
+		quality.Failures = append(quality.Failures, "sharpness")
+	}
+	if quality.Brightness < params.MinBrightness || quality.Brightness > params.MaxBrightness {
+		quality.Failures = append(quality.Failures, "brightness")
+	}
+	if quality.Contrast < params.MinContrast {
+		quality.Failures = append(quality.Failures, "contrast")
+	}
+	if quality.OverexposureRatio > params.MaxOverexposure {
+		quality.Failures = append(quality.Failures, "overexposure")
+	}
+	if quality.UnderexposureRatio > params.MaxUnderexposure {
+		quality.Failures = append(quality.Failures, "underexposure")
+	}
+	if quality.Blockiness > params.MaxBlockiness {
+		quality.Failures = append(quality.Failures, "blockiness")
+	}
+	if quality.EyeDistance < params.MinEyeDistance {
+		quality.Failures = append(quality.Failures, "eye_distance")
+	}
+	if quality.FaceFrameRatio < params.MinFaceFrameRatio {
+		quality.Failures = append(quality.Failures, "face_frame_ratio")
+	}
+	quality.IsAcceptable = len(quality.Failures) == 0
+
+	return quality, nil
+}
+
+// AssessBatch computes the local quality metrics of a list of RGB captures.
+func (c *ImageQualityClient) AssessBatch(images []gocv.Mat, landmarks []*tensor.Dense) ([]config.ImageQuality, error) {
+	if len(images) != len(landmarks) {
+		return nil, errors.New("number of input images and landmarks must be equal")
+	}
+
+	qualities := make([]config.ImageQuality, 0, len(images))
+	for idx := range images {
+		quality, err := c.Assess(images[idx], landmarks[idx], nil)
+		if err != nil {
+			return nil, err
+		}
+		qualities = append(qualities, *quality)
+	}
+	return qualities, nil
+}
@@ -0,0 +1,61 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"testing"
+)
+
+func TestHistogramStats(t *testing.T) {
+	pixels := []byte{0, 0, 255, 255, 100, 100, 100, 100}
+
+	mean, std, over, under := histogramStats(grayHistogram(pixels))
+	assert.InDelta(t, 113.75, mean, 1e-6)
+	assert.Greater(t, std, 0.0)
+	assert.InDelta(t, 0.25, over, 1e-6)
+	assert.InDelta(t, 0.25, under, 1e-6)
+}
+
+func TestBlockiness(t *testing.T) {
+	h, w := 16, 16
+	smooth := make([]byte, h*w)
+	blocky := make([]byte, h*w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			smooth[y*w+x] = byte(8 * x)
+			blocky[y*w+x] = byte(60*(x/8) + 60*(y/8) + x%2)
+		}
+	}
+
+	assert.InDelta(t, 1, blockiness(smooth, h, w), 0.2)
+	assert.Greater(t, blockiness(blocky, h, w), 2.0)
+}
+
+func TestImageQualityClient_Assess(t *testing.T) {
+	client, err := NewImageQualityClient(config.DefaultImageQualityParams)
+	assert.NoError(t, err)
+
+	img := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(255, 255, 255, 0), 480, 640, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	lmk := tensor.New(
+		tensor.Of(tensor.Float32),
+		tensor.WithShape(5, 2),
+		tensor.WithBacking([]float32{
+			266.14566, 220.05692,
+			397.149, 221.45383,
+			332.13202, 258.6127,
+			284.05356, 294.186,
+			380.22375, 294.31165,
+		}),
+	)
+
+	quality, err := client.Assess(img, lmk, nil)
+	assert.NoError(t, err)
+	assert.False(t, quality.IsAcceptable)
+	assert.Contains(t, quality.Failures, "sharpness")
+	assert.Contains(t, quality.Failures, "overexposure")
+	assert.InDelta(t, 1, quality.OverexposureRatio, 1e-6)
+}
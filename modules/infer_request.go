@@ -0,0 +1,137 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// inferRequestPool reuses the inference requests of a model and the buffers of their inputs. Buffers are sized from
+// the input dims of the model configuration and requests are built once from it, so that serving a call does not
+// allocate new input tensors or protobuf messages. It also records the model version serving the requests and the
+// duration of the calls.
+type inferRequestPool struct {
+	modelName    string
+	modelVersion string
+	inputs       []*triton_proto.ModelInput
+	buffers      []*utils.Float32Pool
+	requests     sync.Pool
+	served       atomic.Pointer[string]
+	metrics      atomic.Pointer[Metrics]
+}
+
+// inferRequest is a pooled inference request. Its inputs are sent as raw contents viewing the input buffers without
+// copy, the buffers are returned to the pool with the request.
+type inferRequest struct {
+	Request *triton_proto.ModelInferRequest
+	buffers [][]float32
+}
+
+// newInferRequestPool initializes the request pool of a model from its configuration. Requests ask for modelVersion,
+// the latest version if empty.
+func newInferRequestPool(modelName, modelVersion string, modelConfig *triton_proto.ModelConfigResponse) *inferRequestPool {
+	inputs := modelConfig.GetConfig().GetInput()
+	pool := &inferRequestPool{
+		modelName:    modelName,
+		modelVersion: modelVersion,
+		inputs:       inputs,
+		buffers:      make([]*utils.Float32Pool, len(inputs)),
+	}
+	for idx, input := range inputs {
+		pool.buffers[idx] = utils.NewFloat32Pool(inputSampleSize(input.GetDims()))
+	}
+	return pool
+}
+
+// inputSampleSize returns the number of elements of one sample of an input, the product of its static dims.
+// Dynamic dims (-1) are skipped, so the batch dimension does not count either way.
+func inputSampleSize(dims []int64) int {
+	size := 1
+	for _, dim := range dims {
+		if dim > 0 {
+			size *= int(dim)
+		}
+	}
+	return size
+}
+
+// Buffer returns a buffer of n elements for the input idx. Its content is undefined.
+func (p *inferRequestPool) Buffer(idx, n int) []float32 {
+	return p.buffers[idx].Get(n)
+}
+
+// Get returns a request of the model with named inputs but no contents, see inferRequest.SetInput.
+func (p *inferRequestPool) Get() *inferRequest {
+	if req, ok := p.requests.Get().(*inferRequest); ok {
+		return req
+	}
+
+	request := &triton_proto.ModelInferRequest{
+		ModelName:        p.modelName,
+		ModelVersion:     p.modelVersion,
+		Inputs:           make([]*triton_proto.ModelInferRequest_InferInputTensor, len(p.inputs)),
+		RawInputContents: make([][]byte, len(p.inputs)),
+	}
+	for idx, input := range p.inputs {
+		request.Inputs[idx] = &triton_proto.ModelInferRequest_InferInputTensor{
+			Name:     input.GetName(),
+			Datatype: input.GetDataType().String()[5:],
+			Shape:    make([]int64, 0, 4),
+		}
+	}
+	return &inferRequest{
+		Request: request,
+		buffers: make([][]float32, len(p.inputs)),
+	}
+}
+
+// Record records the model version of a response.
+func (p *inferRequestPool) Record(resp *triton_proto.ModelInferResponse) {
+	if version := resp.GetModelVersion(); version != "" {
+		p.served.Store(&version)
+	}
+}
+
+// ServedModel returns the name of the model and the version of the last response, the requested version if no
+// response was recorded yet.
+func (p *inferRequestPool) ServedModel() (string, string) {
+	if version := p.served.Load(); version != nil {
+		return p.modelName, *version
+	}
+	return p.modelName, p.modelVersion
+}
+
+// Instrument records the stages of the calls of the model to metrics, nothing if nil.
+func (p *inferRequestPool) Instrument(metrics *Metrics) {
+	p.metrics.Store(metrics)
+}
+
+// Observe records the duration of a stage of a call of the model since start and its outcome, see
+// Metrics.ObserveStage.
+func (p *inferRequestPool) Observe(stage string, start time.Time, err error) {
+	p.metrics.Load().ObserveStage(p.modelName, stage, start, err)
+}
+
+// Put returns a request and the buffers of its inputs to the pool once the call has returned.
+// Neither must be used afterwards.
+func (p *inferRequestPool) Put(req *inferRequest) {
+	for idx := range req.buffers {
+		if req.buffers[idx] != nil {
+			p.buffers[idx].Put(req.buffers[idx])
+			req.buffers[idx] = nil
+		}
+		req.Request.RawInputContents[idx] = nil
+		req.Request.Inputs[idx].Shape = req.Request.Inputs[idx].Shape[:0]
+	}
+	p.requests.Put(req)
+}
+
+// SetInput sets the content of the input idx, usually a buffer obtained from inferRequestPool.Buffer, and its shape.
+// The content is not copied and must not change until the request is returned to the pool.
+func (r *inferRequest) SetInput(idx int, content []float32, shape ...int64) {
+	r.buffers[idx] = content
+	r.Request.RawInputContents[idx] = utils.T32ToBytes(content)
+	r.Request.Inputs[idx].Shape = append(r.Request.Inputs[idx].Shape[:0], shape...)
+}
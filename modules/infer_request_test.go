@@ -0,0 +1,133 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"gorgonia.org/tensor"
+	"testing"
+	"time"
+)
+
+func TestInputSampleSize(t *testing.T) {
+	assert.Equal(t, 3*112*112, inputSampleSize([]int64{3, 112, 112}))
+	assert.Equal(t, 3*224*224, inputSampleSize([]int64{1, 3, 224, 224}))
+	assert.Equal(t, 3*640*640, inputSampleSize([]int64{-1, 3, 640, 640}))
+}
+
+func TestInferRequestPool(t *testing.T) {
+	requests := newInferRequestPool("face_id", "", genTestModelConfig(3, 2, 2))
+
+	input := requests.Buffer(0, 12)
+	assert.Len(t, input, 12)
+	for idx := range input {
+		input[idx] = float32(idx)
+	}
+
+	request := requests.Get()
+	request.SetInput(0, input, 1, 3, 2, 2)
+	assert.Equal(t, "face_id", request.Request.ModelName)
+	assert.Equal(t, "input", request.Request.Inputs[0].Name)
+	assert.Equal(t, "FP32", request.Request.Inputs[0].Datatype)
+	assert.Equal(t, []int64{1, 3, 2, 2}, request.Request.Inputs[0].Shape)
+	assert.Nil(t, request.Request.Inputs[0].Contents)
+
+	// Raw contents view the input buffer without copy.
+	raw := request.Request.RawInputContents[0]
+	assert.Len(t, raw, 4*12)
+	assert.Equal(t, input, utils.BytesToT32[float32](raw))
+	input[0] = 42
+	assert.Equal(t, float32(42), utils.BytesToT32[float32](raw)[0])
+
+	requests.Put(request)
+	assert.Nil(t, request.Request.RawInputContents[0])
+	assert.Empty(t, request.Request.Inputs[0].Shape)
+
+	// A reused request carries no content of the previous call.
+	request = requests.Get()
+	assert.Nil(t, request.Request.RawInputContents[0])
+	requests.Put(request)
+}
+
+func TestInferRequestPool_ModelVersion(t *testing.T) {
+	requests := newInferRequestPool("face_id", "2", genTestModelConfig(3, 2, 2))
+	request := requests.Get()
+	assert.Equal(t, "2", request.Request.ModelVersion)
+	requests.Put(request)
+
+	name, version := requests.ServedModel()
+	assert.Equal(t, "face_id", name)
+	assert.Equal(t, "2", version)
+
+	// The latest version is requested without a pinned version, the served version is known from the responses.
+	requests = newInferRequestPool("face_id", "", genTestModelConfig(3, 2, 2))
+	_, version = requests.ServedModel()
+	assert.Empty(t, version)
+	requests.Record(&triton_proto.ModelInferResponse{ModelName: "face_id", ModelVersion: "3"})
+	requests.Record(&triton_proto.ModelInferResponse{ModelName: "face_id"})
+	_, version = requests.ServedModel()
+	assert.Equal(t, "3", version)
+}
+
+func TestInferRequestPool_Observe(t *testing.T) {
+	requests := newInferRequestPool("face_id", "", genTestModelConfig(3, 2, 2))
+	requests.Observe(StageNetwork, time.Now(), nil)
+
+	metrics := NewMetrics("")
+	requests.Instrument(metrics)
+	requests.Observe(StageNetwork, time.Now(), nil)
+	requests.Observe(StagePostprocess, time.Now(), nil)
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.stageDuration))
+
+	requests.Instrument(nil)
+	requests.Observe(StagePreprocess, time.Now(), nil)
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.stageDuration))
+}
+
+// BenchmarkInferRequest_Fp32Contents measures a request built per call from a new input tensor with typed contents.
+// Marshaling appends to a reused buffer, as the gRPC codec does with its buffer pool.
+func BenchmarkInferRequest_Fp32Contents(b *testing.B) {
+	inputCfg := genTestModelConfig(3, 640, 640).Config.Input[0]
+	var buf []byte
+
+	b.ReportAllocs()
+	for range b.N {
+		input := tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 3, 640, 640))
+		request := &triton_proto.ModelInferRequest{
+			ModelName: "detector",
+			Inputs: []*triton_proto.ModelInferRequest_InferInputTensor{
+				{
+					Name:     inputCfg.Name,
+					Datatype: inputCfg.DataType.String()[5:],
+					Shape:    []int64{1, 3, 640, 640},
+					Contents: &triton_proto.InferTensorContents{
+						Fp32Contents: input.Float32s(),
+					},
+				},
+			},
+		}
+		var err error
+		if buf, err = (proto.MarshalOptions{}).MarshalAppend(buf[:0], request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkInferRequest_Pooled measures a pooled request with raw contents viewing a pooled input buffer.
+func BenchmarkInferRequest_Pooled(b *testing.B) {
+	requests := newInferRequestPool("detector", "", genTestModelConfig(3, 640, 640))
+	var buf []byte
+
+	b.ReportAllocs()
+	for range b.N {
+		request := requests.Get()
+		request.SetInput(0, requests.Buffer(0, 3*640*640), 1, 3, 640, 640)
+		var err error
+		if buf, err = (proto.MarshalOptions{}).MarshalAppend(buf[:0], request.Request); err != nil {
+			b.Fatal(err)
+		}
+		requests.Put(request)
+	}
+}
@@ -0,0 +1,20 @@
+package modules
+
+import (
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"time"
+)
+
+// InferenceClient is the inference backend of the model clients. It is implemented by the Triton gRPC client, and
+// by in-process backends exposing their models through the same configuration and request messages.
+type InferenceClient interface {
+	GetModelConfiguration(timeout time.Duration, modelName, modelVersion string) (*triton_proto.ModelConfigResponse, error)
+	ModelGRPCInfer(timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error)
+}
+
+// ServedModeler is implemented by the model clients. ServedModel returns the name of the model and the version that
+// served the last request of the client, the version pinned by its params, empty for the latest, until a request is
+// served.
+type ServedModeler interface {
+	ServedModel() (string, string)
+}
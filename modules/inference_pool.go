@@ -0,0 +1,304 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TritonEndpoint is a Triton server of an InferencePool. It is implemented by the Triton gRPC clients, the context of
+// the calls is passed to the endpoints implementing ContextInferenceClient.
+type TritonEndpoint interface {
+	InferenceClient
+	ServerReady(timeout time.Duration) (bool, error)
+	ModelRepositoryIndex(timeout time.Duration, repoName string, isReady bool) (*triton_proto.RepositoryIndexResponse, error)
+}
+
+// failoverCodes are the gRPC codes of calls retried on another endpoint. Other errors, such as invalid requests,
+// would fail the same way on every endpoint and are returned as is.
+var failoverCodes = map[codes.Code]bool{
+	codes.Unavailable:       true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+	codes.Aborted:           true,
+	codes.Internal:          true,
+	codes.NotFound:          true,
+}
+
+type poolEndpoint struct {
+	client      TritonEndpoint
+	healthy     atomic.Bool
+	outstanding atomic.Int64
+	failures    atomic.Int64 // failures counts the consecutive calls of the endpoint failing over.
+}
+
+// InferencePool spreads the calls of the model clients across several Triton endpoints serving the same models.
+// Calls go to healthy endpoints, round-robin or to the endpoint with the fewest outstanding calls, and fail over to
+// another endpoint on unavailability errors. Endpoints are health-checked with the server and model readiness RPCs.
+// It implements InferenceClient, so that it can be passed to NewEKYCPipeline in place of a single client.
+type InferencePool struct {
+	Params *config.InferencePoolParams
+
+	endpoints []*poolEndpoint
+	next      atomic.Uint64
+	owned     []*TritonClient
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewInferencePool initializes a pool over endpoints, with default params if nil. Endpoints are health-checked once
+// before returning, then periodically in the background until Close if the health check interval is positive.
+func NewInferencePool(endpoints []TritonEndpoint, params *config.InferencePoolParams) (*InferencePool, error) {
+	if params == nil {
+		params = config.DefaultInferencePoolParams
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("inference pool requires at least one endpoint")
+	}
+	switch params.Balancing {
+	case config.InferenceBalancingRoundRobin, config.InferenceBalancingLeastOutstanding:
+	default:
+		return nil, fmt.Errorf("unsupported inference balancing %q", params.Balancing)
+	}
+
+	pool := &InferencePool{
+		Params:    params,
+		endpoints: make([]*poolEndpoint, len(endpoints)),
+		stop:      make(chan struct{}),
+	}
+	for idx, client := range endpoints {
+		pool.endpoints[idx] = &poolEndpoint{client: client}
+	}
+	pool.CheckHealth()
+
+	if params.HealthCheckInterval > 0 {
+		pool.wg.Add(1)
+		go pool.healthCheckLoop()
+	}
+	return pool, nil
+}
+
+// DialInferencePool connects to the Triton servers at urls with TritonClients and initializes a pool over them. The
+// connections are closed with the pool.
+func DialInferencePool(urls []string, params *config.InferencePoolParams, grpcOpts ...grpc.DialOption) (*InferencePool, error) {
+	clients := make([]*TritonClient, 0, len(urls))
+	endpoints := make([]TritonEndpoint, 0, len(urls))
+	for _, url := range urls {
+		client, err := NewTritonClient(url, grpcOpts...)
+		if err != nil {
+			for _, c := range clients {
+				_ = c.Close()
+			}
+			return nil, fmt.Errorf("connect to %s: %w", url, err)
+		}
+		clients = append(clients, client)
+		endpoints = append(endpoints, client)
+	}
+
+	pool, err := NewInferencePool(endpoints, params)
+	if err != nil {
+		for _, c := range clients {
+			_ = c.Close()
+		}
+		return nil, err
+	}
+	pool.owned = clients
+	return pool, nil
+}
+
+// Close stops the health checks and closes the connections opened by DialInferencePool.
+func (p *InferencePool) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+		for _, client := range p.owned {
+			err = errors.Join(err, client.Close())
+		}
+	})
+	return err
+}
+
+func (p *InferencePool) healthCheckLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.Params.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.CheckHealth()
+		}
+	}
+}
+
+// CheckHealth checks all endpoints concurrently and updates their health.
+func (p *InferencePool) CheckHealth() {
+	var wg sync.WaitGroup
+	for _, ep := range p.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			healthy := p.isHealthy(ep.client)
+			if healthy {
+				ep.failures.Store(0)
+			}
+			ep.healthy.Store(healthy)
+		}()
+	}
+	wg.Wait()
+}
+
+// isHealthy reports whether the server of an endpoint and the models of the params are ready.
+func (p *InferencePool) isHealthy(client TritonEndpoint) bool {
+	ready, err := client.ServerReady(p.Params.HealthCheckTimeout)
+	if err != nil || !ready {
+		return false
+	}
+	if len(p.Params.Models) == 0 {
+		return true
+	}
+
+	index, err := client.ModelRepositoryIndex(p.Params.HealthCheckTimeout, "", true)
+	if err != nil {
+		return false
+	}
+	readyModels := make(map[string]bool, len(index.GetModels()))
+	for _, model := range index.GetModels() {
+		if model.GetState() == "READY" {
+			readyModels[model.GetName()] = true
+		}
+	}
+	for _, name := range p.Params.Models {
+		if !readyModels[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// Healthy returns the number of endpoints in rotation.
+func (p *InferencePool) Healthy() int {
+	healthy := 0
+	for _, ep := range p.endpoints {
+		if ep.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// pick returns the index of the next endpoint not tried yet, or -1 if all were. Unhealthy endpoints are only picked
+// once no healthy endpoint is left, so that calls are still attempted while health information is stale.
+func (p *InferencePool) pick(tried []bool) int {
+	n := len(p.endpoints)
+	start := int(p.next.Add(1) % uint64(n))
+
+	best := -1
+	for _, healthy := range []bool{true, false} {
+		for i := range n {
+			idx := (start + i) % n
+			ep := p.endpoints[idx]
+			if tried[idx] || ep.healthy.Load() != healthy {
+				continue
+			}
+			if p.Params.Balancing == config.InferenceBalancingRoundRobin {
+				return idx
+			}
+			if best < 0 || ep.outstanding.Load() < p.endpoints[best].outstanding.Load() {
+				best = idx
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return best
+}
+
+// call runs fn on endpoints until it succeeds, fails with an error that is not worth a failover, or the attempts are
+// exhausted. Endpoints failing over are taken out of rotation as set by the params, and put back by a successful
+// call or health check.
+func (p *InferencePool) call(fn func(client TritonEndpoint) error) error {
+	attempts := p.Params.MaxAttempts
+	if attempts <= 0 || attempts > len(p.endpoints) {
+		attempts = len(p.endpoints)
+	}
+
+	tried := make([]bool, len(p.endpoints))
+	var err error
+	for range attempts {
+		idx := p.pick(tried)
+		if idx < 0 {
+			break
+		}
+		tried[idx] = true
+
+		ep := p.endpoints[idx]
+		ep.outstanding.Add(1)
+		err = fn(ep.client)
+		ep.outstanding.Add(-1)
+		if err == nil {
+			ep.failures.Store(0)
+			ep.healthy.Store(true)
+			return nil
+		}
+		if !failoverCodes[status.Code(err)] {
+			return err
+		}
+		p.recordFailure(ep, err)
+	}
+	return err
+}
+
+// recordFailure takes an endpoint out of rotation after a call failing over with err if it is unavailable, or if its
+// consecutive failures reached the eviction threshold of the params.
+func (p *InferencePool) recordFailure(ep *poolEndpoint, err error) {
+	failures := ep.failures.Add(1)
+	evictionFailures := int64(p.Params.EvictionFailures)
+	if status.Code(err) == codes.Unavailable || (evictionFailures > 0 && failures >= evictionFailures) {
+		ep.healthy.Store(false)
+	}
+}
+
+// GetModelConfiguration returns the configuration of a model from an endpoint of the pool.
+func (p *InferencePool) GetModelConfiguration(timeout time.Duration, modelName, modelVersion string) (*triton_proto.ModelConfigResponse, error) {
+	var resp *triton_proto.ModelConfigResponse
+	err := p.call(func(client TritonEndpoint) error {
+		var err error
+		resp, err = client.GetModelConfiguration(timeout, modelName, modelVersion)
+		return err
+	})
+	return resp, err
+}
+
+// ModelGRPCInfer runs an inference request on an endpoint of the pool. The timeout applies to each attempt.
+func (p *InferencePool) ModelGRPCInfer(timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return p.ModelGRPCInferContext(context.Background(), timeout, request)
+}
+
+// ModelGRPCInferContext runs an inference request within ctx on an endpoint of the pool, see ModelGRPCInfer. Calls
+// cancelled by ctx do not fail over.
+func (p *InferencePool) ModelGRPCInferContext(ctx context.Context, timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	var resp *triton_proto.ModelInferResponse
+	err := p.call(func(client TritonEndpoint) error {
+		var err error
+		resp, err = inferContext(ctx, client, timeout, request)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,245 @@
+package modules
+
+import (
+	"errors"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeEndpoint is a Triton endpoint answering inference calls with its name as the model version.
+type fakeEndpoint struct {
+	name     string
+	ready    atomic.Bool
+	models   []string
+	inferErr atomic.Value
+	calls    atomic.Int64
+	block    chan struct{}
+}
+
+func newFakeEndpoint(name string, models ...string) *fakeEndpoint {
+	ep := &fakeEndpoint{name: name, models: models}
+	ep.ready.Store(true)
+	return ep
+}
+
+func (e *fakeEndpoint) setInferErr(err error) {
+	e.inferErr.Store(&err)
+}
+
+func (e *fakeEndpoint) ServerReady(time.Duration) (bool, error) {
+	return e.ready.Load(), nil
+}
+
+func (e *fakeEndpoint) ModelRepositoryIndex(time.Duration, string, bool) (*triton_proto.RepositoryIndexResponse, error) {
+	index := &triton_proto.RepositoryIndexResponse{}
+	for _, model := range e.models {
+		index.Models = append(index.Models, &triton_proto.RepositoryIndexResponse_ModelIndex{Name: model, State: "READY"})
+	}
+	return index, nil
+}
+
+func (e *fakeEndpoint) GetModelConfiguration(_ time.Duration, modelName, _ string) (*triton_proto.ModelConfigResponse, error) {
+	return &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{Name: modelName}}, nil
+}
+
+func (e *fakeEndpoint) ModelGRPCInfer(_ time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	e.calls.Add(1)
+	if e.block != nil {
+		<-e.block
+	}
+	if err, ok := e.inferErr.Load().(*error); ok && *err != nil {
+		return nil, *err
+	}
+	return &triton_proto.ModelInferResponse{ModelName: request.GetModelName(), ModelVersion: e.name}, nil
+}
+
+func genTestPoolParams(balancing string) *config.InferencePoolParams {
+	return config.NewInferencePoolParams(balancing, 0, time.Second, 3, 3, nil)
+}
+
+func TestNewInferencePool(t *testing.T) {
+	_, err := NewInferencePool(nil, nil)
+	assert.Error(t, err)
+	_, err = NewInferencePool([]TritonEndpoint{newFakeEndpoint("a")}, genTestPoolParams("random"))
+	assert.Error(t, err)
+
+	pool, err := NewInferencePool([]TritonEndpoint{newFakeEndpoint("a")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, config.DefaultInferencePoolParams, pool.Params)
+	assert.Equal(t, 1, pool.Healthy())
+	assert.NoError(t, pool.Close())
+	assert.NoError(t, pool.Close())
+}
+
+func TestInferencePool_RoundRobin(t *testing.T) {
+	endpoints := []*fakeEndpoint{newFakeEndpoint("a"), newFakeEndpoint("b"), newFakeEndpoint("c")}
+	pool, err := NewInferencePool([]TritonEndpoint{endpoints[0], endpoints[1], endpoints[2]}, genTestPoolParams(config.InferenceBalancingRoundRobin))
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	for range 9 {
+		_, err := pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+		assert.NoError(t, err)
+	}
+	for _, ep := range endpoints {
+		assert.Equal(t, int64(3), ep.calls.Load())
+	}
+}
+
+func TestInferencePool_LeastOutstanding(t *testing.T) {
+	busy, idle := newFakeEndpoint("busy"), newFakeEndpoint("idle")
+	busy.block = make(chan struct{})
+	pool, err := NewInferencePool([]TritonEndpoint{busy, idle}, genTestPoolParams(config.InferenceBalancingLeastOutstanding))
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	// Occupy the busy endpoint, whichever endpoint the first call lands on.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for busy.calls.Load() == 0 {
+			_, _ = pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+		}
+	}()
+	assert.Eventually(t, func() bool { return busy.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	for range 4 {
+		resp, err := pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, "idle", resp.GetModelVersion())
+	}
+	close(busy.block)
+	wg.Wait()
+}
+
+func TestInferencePool_Failover(t *testing.T) {
+	down, up := newFakeEndpoint("down"), newFakeEndpoint("up")
+	down.setInferErr(status.Error(codes.Unavailable, "connection refused"))
+	pool, err := NewInferencePool([]TritonEndpoint{down, up}, genTestPoolParams(config.InferenceBalancingRoundRobin))
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	for range 4 {
+		resp, err := pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, "up", resp.GetModelVersion())
+	}
+	// The failing endpoint is out of rotation after its first failure.
+	assert.Equal(t, int64(1), down.calls.Load())
+	assert.Equal(t, 1, pool.Healthy())
+
+	// It is back in rotation once a health check passes.
+	down.setInferErr(nil)
+	pool.CheckHealth()
+	assert.Equal(t, 2, pool.Healthy())
+
+	// Errors of the request itself are not retried on another endpoint.
+	invalid := status.Error(codes.InvalidArgument, "unexpected shape")
+	down.setInferErr(invalid)
+	up.setInferErr(invalid)
+	calls := down.calls.Load() + up.calls.Load()
+	_, err = pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, calls+1, down.calls.Load()+up.calls.Load())
+
+	// The last error is returned once all endpoints failed.
+	down.setInferErr(status.Error(codes.Unavailable, "connection refused"))
+	up.setInferErr(status.Error(codes.Unavailable, "connection reset"))
+	_, err = pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 0, pool.Healthy())
+
+	// Unhealthy endpoints are still tried when no healthy endpoint is left.
+	up.setInferErr(nil)
+	resp, err := pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "up", resp.GetModelVersion())
+}
+
+func TestInferencePool_Eviction(t *testing.T) {
+	slow, up := newFakeEndpoint("slow"), newFakeEndpoint("up")
+	params := genTestPoolParams(config.InferenceBalancingRoundRobin)
+	params.EvictionFailures = 2
+	pool, err := NewInferencePool([]TritonEndpoint{slow, up}, params)
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	// A single timeout fails over without taking the endpoint out of rotation.
+	slow.setInferErr(status.Error(codes.DeadlineExceeded, "deadline exceeded"))
+	for slow.calls.Load() < 1 {
+		_, err = pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, pool.Healthy())
+
+	// Consecutive failures do.
+	for slow.calls.Load() < 2 {
+		_, err = pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, pool.Healthy())
+
+	// A successful call puts it back in rotation.
+	slow.setInferErr(nil)
+	up.setInferErr(status.Error(codes.Unavailable, "connection refused"))
+	resp, err := pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "slow", resp.GetModelVersion())
+	assert.Equal(t, 1, pool.Healthy())
+	assert.True(t, pool.endpoints[0].healthy.Load())
+}
+
+func TestInferencePool_CheckHealth(t *testing.T) {
+	serving, loading := newFakeEndpoint("serving", "face_id", "scrfd"), newFakeEndpoint("loading", "scrfd")
+	params := genTestPoolParams(config.InferenceBalancingRoundRobin)
+	params.Models = []string{"face_id", "scrfd"}
+	pool, err := NewInferencePool([]TritonEndpoint{serving, loading}, params)
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	// The endpoint missing a model is not healthy.
+	assert.Equal(t, 1, pool.Healthy())
+	for range 4 {
+		resp, err := pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, "serving", resp.GetModelVersion())
+	}
+
+	serving.ready.Store(false)
+	loading.models = append(loading.models, "face_id")
+	pool.CheckHealth()
+	resp, err := pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "loading", resp.GetModelVersion())
+
+	cfg, err := pool.GetModelConfiguration(time.Second, "face_id", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "face_id", cfg.GetConfig().GetName())
+}
+
+func TestInferencePool_HealthCheckLoop(t *testing.T) {
+	ep := newFakeEndpoint("a")
+	ep.setInferErr(errors.New("not a grpc error"))
+	params := genTestPoolParams(config.InferenceBalancingRoundRobin)
+	params.HealthCheckInterval = time.Millisecond
+	pool, err := NewInferencePool([]TritonEndpoint{ep}, params)
+	assert.NoError(t, err)
+
+	ep.ready.Store(false)
+	assert.Eventually(t, func() bool { return pool.Healthy() == 0 }, time.Second, time.Millisecond)
+	ep.ready.Store(true)
+	assert.Eventually(t, func() bool { return pool.Healthy() == 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, pool.Close())
+
+	// Errors that are not gRPC status errors are returned without failover.
+	_, err = pool.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{})
+	assert.EqualError(t, err, "not a grpc error")
+}
@@ -0,0 +1,327 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for the calls of a model rejected by its open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitState is the state of the circuit breaker of a model.
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // CircuitClosed lets calls through.
+	CircuitOpen     CircuitState = "open"      // CircuitOpen rejects calls until the cooldown has passed.
+	CircuitHalfOpen CircuitState = "half_open" // CircuitHalfOpen lets a single probe through, closing on its success.
+)
+
+// retryCodes are the gRPC codes of transient failures worth a retry.
+var retryCodes = map[codes.Code]bool{
+	codes.Unavailable:       true,
+	codes.ResourceExhausted: true,
+	codes.Aborted:           true,
+}
+
+// breakerCodes are the gRPC codes counted as failures of a model by its circuit breaker. Errors of the request
+// itself do not count.
+var breakerCodes = map[codes.Code]bool{
+	codes.Unavailable:       true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+	codes.Aborted:           true,
+	codes.Internal:          true,
+}
+
+// latencyWindow is the number of latencies of a model kept to compute the hedging delay.
+const latencyWindow = 128
+
+type modelResilience struct {
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	latencies []time.Duration
+	next      int
+}
+
+// ResilientClient wraps an inference backend with retries, per-model circuit breakers and hedged requests.
+// It implements InferenceClient, so that it can wrap a Triton client or an InferencePool and be passed to
+// NewEKYCPipeline. Requests of stateful models, carrying a sequence id, are neither retried nor hedged.
+type ResilientClient struct {
+	client InferenceClient
+	Params *config.InferenceResilienceParams
+
+	mu     sync.Mutex
+	models map[string]*modelResilience
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewResilientClient wraps client, with default params if nil.
+func NewResilientClient(client InferenceClient, params *config.InferenceResilienceParams) *ResilientClient {
+	if params == nil {
+		params = config.DefaultInferenceResilienceParams
+	}
+	return &ResilientClient{
+		client: client,
+		Params: params,
+		models: map[string]*modelResilience{},
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+// sleepContext waits for d, returning the error of ctx early if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *ResilientClient) model(name string) *modelResilience {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.models[name]
+	if !ok {
+		m = &modelResilience{state: CircuitClosed}
+		c.models[name] = m
+	}
+	return m
+}
+
+// BreakerState returns the state of the circuit breaker of a model.
+func (c *ResilientClient) BreakerState(modelName string) CircuitState {
+	m := c.model(modelName)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stateAt(c.now(), c.Params.BreakerCooldown)
+}
+
+// BreakerStates returns the state of the circuit breakers of the models called so far.
+func (c *ResilientClient) BreakerStates() map[string]CircuitState {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.models))
+	for name := range c.models {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+
+	states := make(map[string]CircuitState, len(names))
+	for _, name := range names {
+		states[name] = c.BreakerState(name)
+	}
+	return states
+}
+
+// stateAt returns the state of the breaker, half-open once the cooldown of an open breaker has passed.
+func (m *modelResilience) stateAt(now time.Time, cooldown time.Duration) CircuitState {
+	if m.state == CircuitOpen && now.Sub(m.openedAt) >= cooldown {
+		return CircuitHalfOpen
+	}
+	return m.state
+}
+
+// allow reports whether a call may go through the breaker, taking the probe slot of a half-open breaker.
+func (c *ResilientClient) allow(m *modelResilience) bool {
+	if c.Params.BreakerFailures <= 0 {
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch m.stateAt(c.now(), c.Params.BreakerCooldown) {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if m.probing {
+			return false
+		}
+		m.state, m.probing = CircuitHalfOpen, true
+	}
+	return true
+}
+
+// record updates the breaker and the latencies of a model with the outcome of a call.
+func (c *ResilientClient) record(m *modelResilience, err error, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		m.state, m.failures, m.probing = CircuitClosed, 0, false
+		if len(m.latencies) < latencyWindow {
+			m.latencies = append(m.latencies, latency)
+		} else {
+			m.latencies[m.next] = latency
+			m.next = (m.next + 1) % latencyWindow
+		}
+		return
+	}
+	if !breakerCodes[status.Code(err)] {
+		// The probe of a half-open breaker ended without telling anything about the model.
+		m.probing = false
+		return
+	}
+
+	m.failures++
+	if c.Params.BreakerFailures > 0 && (m.probing || m.failures >= c.Params.BreakerFailures) {
+		m.state, m.openedAt, m.probing = CircuitOpen, c.now(), false
+	}
+}
+
+// hedgeDelay returns the delay after which a call of the model is hedged, or 0 if hedging is disabled or not enough
+// latencies are known.
+func (c *ResilientClient) hedgeDelay(m *modelResilience) time.Duration {
+	if c.Params.HedgePercentile <= 0 {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.latencies) < max(c.Params.HedgeMinSamples, 1) {
+		return 0
+	}
+
+	latencies := slices.Clone(m.latencies)
+	slices.Sort(latencies)
+	idx := int(math.Ceil(c.Params.HedgePercentile*float64(len(latencies)))) - 1
+	return latencies[min(max(idx, 0), len(latencies)-1)]
+}
+
+// backoff returns the jittered delay before the retry attempt, counted from 1.
+func (c *ResilientClient) backoff(attempt int) time.Duration {
+	limit := float64(c.Params.InitialBackoff) * math.Pow(max(c.Params.BackoffMultiplier, 1), float64(attempt-1))
+	if c.Params.MaxBackoff > 0 {
+		limit = min(limit, float64(c.Params.MaxBackoff))
+	}
+	if limit < 1 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(limit)) + 1)
+}
+
+// isStateful reports whether a request belongs to a sequence of a stateful model.
+func isStateful(request *triton_proto.ModelInferRequest) bool {
+	_, ok := request.GetParameters()["sequence_id"]
+	return ok
+}
+
+// GetModelConfiguration returns the configuration of a model, retrying transient failures.
+func (c *ResilientClient) GetModelConfiguration(timeout time.Duration, modelName, modelVersion string) (*triton_proto.ModelConfigResponse, error) {
+	var resp *triton_proto.ModelConfigResponse
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = c.client.GetModelConfiguration(timeout, modelName, modelVersion)
+		if err == nil || attempt >= c.Params.MaxRetries || !retryCodes[status.Code(err)] {
+			return resp, err
+		}
+		_ = c.sleep(context.Background(), c.backoff(attempt+1))
+	}
+}
+
+// ModelGRPCInfer runs an inference request through the circuit breaker of its model, retrying transient failures
+// and hedging slow attempts. The timeout applies to each attempt.
+func (c *ResilientClient) ModelGRPCInfer(timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return c.ModelGRPCInferContext(context.Background(), timeout, request)
+}
+
+// ModelGRPCInferContext runs an inference request within ctx, see ModelGRPCInfer. The context is passed to the
+// wrapped backend, and calls cancelled by ctx are neither retried nor kept waiting for a backoff.
+func (c *ResilientClient) ModelGRPCInferContext(ctx context.Context, timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	m := c.model(request.GetModelName())
+	retries := c.Params.MaxRetries
+	if isStateful(request) {
+		retries = 0
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if !c.allow(m) {
+			if err == nil {
+				err = fmt.Errorf("model %q: %w", request.GetModelName(), ErrCircuitOpen)
+			}
+			return nil, err
+		}
+
+		var resp *triton_proto.ModelInferResponse
+		if delay := c.hedgeDelay(m); delay > 0 && !isStateful(request) {
+			resp, err = c.hedgedInfer(ctx, m, timeout, delay, request)
+		} else {
+			start := c.now()
+			resp, err = inferContext(ctx, c.client, timeout, request)
+			c.record(m, err, c.now().Sub(start))
+		}
+		if err == nil || attempt >= retries || !retryCodes[status.Code(err)] || ctx.Err() != nil {
+			return resp, err
+		}
+		if err := c.sleep(ctx, c.backoff(attempt+1)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// hedgedInfer sends the request and, if no response arrived after delay, sends it a second time, returning the first
+// success. Both attempts send a copy of the request, so that the attempt still in flight after the call has returned
+// never reads the request, whose buffers the model clients reuse. That attempt is cancelled once the call returns,
+// and is not recorded by the circuit breaker.
+func (c *ResilientClient) hedgedInfer(ctx context.Context, m *modelResilience, timeout, delay time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	type result struct {
+		resp *triton_proto.ModelInferResponse
+		err  error
+	}
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sent := proto.Clone(request).(*triton_proto.ModelInferRequest)
+	results := make(chan result, 2)
+	send := func() {
+		start := c.now()
+		resp, err := inferContext(hedgeCtx, c.client, timeout, sent)
+		if hedgeCtx.Err() == nil || ctx.Err() != nil {
+			c.record(m, err, c.now().Sub(start))
+		}
+		results <- result{resp, err}
+	}
+
+	go send()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	inFlight := 1
+	var err error
+	for {
+		select {
+		case res := <-results:
+			inFlight--
+			if res.err == nil {
+				return res.resp, nil
+			}
+			// An attempt failing before the hedge is due is not hedged, the failure is left to the retries.
+			err = res.err
+			if inFlight == 0 {
+				return nil, err
+			}
+		case <-timer.C:
+			inFlight++
+			go send()
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package modules
+
+import (
+	"context"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// funcClient is an inference backend answering calls, counted from 0, with infer.
+type funcClient struct {
+	calls atomic.Int64
+	infer func(call int64, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error)
+}
+
+func (c *funcClient) GetModelConfiguration(_ time.Duration, modelName, _ string) (*triton_proto.ModelConfigResponse, error) {
+	_, err := c.infer(c.calls.Add(1)-1, &triton_proto.ModelInferRequest{ModelName: modelName})
+	if err != nil {
+		return nil, err
+	}
+	return &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{Name: modelName}}, nil
+}
+
+func (c *funcClient) ModelGRPCInfer(_ time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return c.infer(c.calls.Add(1)-1, request)
+}
+
+// failingFirst returns an infer function failing the first n calls with code.
+func failingFirst(n int64, code codes.Code) func(int64, *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return func(call int64, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+		if call < n {
+			return nil, status.Error(code, "failure")
+		}
+		return &triton_proto.ModelInferResponse{ModelName: request.GetModelName()}, nil
+	}
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func genTestResilientClient(backend InferenceClient, params *config.InferenceResilienceParams) (*ResilientClient, *[]time.Duration, *fakeClock) {
+	client := NewResilientClient(backend, params)
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var sleeps []time.Duration
+	client.now = clock.Now
+	client.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return client, &sleeps, clock
+}
+
+func TestResilientClient_Retry(t *testing.T) {
+	params := config.NewInferenceResilienceParams(2, 10*time.Millisecond, 15*time.Millisecond, 2, 0, 0, 0, 0)
+	backend := &funcClient{infer: failingFirst(2, codes.Unavailable)}
+	client, sleeps, _ := genTestResilientClient(backend, params)
+
+	resp, err := client.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+	assert.NoError(t, err)
+	assert.Equal(t, "face_id", resp.GetModelName())
+	assert.Equal(t, int64(3), backend.calls.Load())
+
+	// Backoffs are jittered below the exponential limit, capped by the max backoff.
+	assert.Len(t, *sleeps, 2)
+	assert.LessOrEqual(t, (*sleeps)[0], 10*time.Millisecond)
+	assert.LessOrEqual(t, (*sleeps)[1], 15*time.Millisecond)
+	assert.Positive(t, (*sleeps)[1])
+
+	// Retries are exhausted.
+	backend = &funcClient{infer: failingFirst(3, codes.Unavailable)}
+	client, _, _ = genTestResilientClient(backend, params)
+	_, err = client.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int64(3), backend.calls.Load())
+
+	// Errors of the request are not retried.
+	backend = &funcClient{infer: failingFirst(1, codes.InvalidArgument)}
+	client, _, _ = genTestResilientClient(backend, params)
+	_, err = client.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, int64(1), backend.calls.Load())
+
+	// Requests of stateful models are not retried.
+	backend = &funcClient{infer: failingFirst(1, codes.Unavailable)}
+	client, _, _ = genTestResilientClient(backend, params)
+	_, err = client.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{
+		ModelName:  "tracker",
+		Parameters: map[string]*triton_proto.InferParameter{"sequence_id": {}},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), backend.calls.Load())
+
+	backend = &funcClient{infer: failingFirst(1, codes.Unavailable)}
+	client, _, _ = genTestResilientClient(backend, params)
+	cfg, err := client.GetModelConfiguration(time.Second, "face_id", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "face_id", cfg.GetConfig().GetName())
+	assert.Equal(t, int64(2), backend.calls.Load())
+}
+
+func TestResilientClient_CircuitBreaker(t *testing.T) {
+	params := config.NewInferenceResilienceParams(0, 0, 0, 0, 2, 10*time.Second, 0, 0)
+	failing := true
+	backend := &funcClient{infer: func(_ int64, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+		if failing {
+			return nil, status.Error(codes.Unavailable, "connection refused")
+		}
+		return &triton_proto.ModelInferResponse{ModelName: request.GetModelName()}, nil
+	}}
+	client, _, clock := genTestResilientClient(backend, params)
+	request := &triton_proto.ModelInferRequest{ModelName: "scrfd"}
+
+	for range 2 {
+		_, err := client.ModelGRPCInfer(time.Second, request)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	}
+	assert.Equal(t, CircuitOpen, client.BreakerState("scrfd"))
+
+	// Calls are rejected without reaching the backend, other models are not affected.
+	_, err := client.ModelGRPCInfer(time.Second, request)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int64(2), backend.calls.Load())
+	assert.Equal(t, CircuitClosed, client.BreakerState("face_id"))
+
+	// A failed probe opens the breaker again.
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, CircuitHalfOpen, client.BreakerState("scrfd"))
+	_, err = client.ModelGRPCInfer(time.Second, request)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, CircuitOpen, client.BreakerState("scrfd"))
+
+	// A successful probe closes it.
+	clock.Advance(10 * time.Second)
+	failing = false
+	_, err = client.ModelGRPCInfer(time.Second, request)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]CircuitState{"scrfd": CircuitClosed, "face_id": CircuitClosed}, client.BreakerStates())
+
+	// Errors of the request do not count as failures of the model.
+	backend.infer = func(int64, *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+		return nil, status.Error(codes.InvalidArgument, "unexpected shape")
+	}
+	for range 3 {
+		_, err = client.ModelGRPCInfer(time.Second, request)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+	assert.Equal(t, CircuitClosed, client.BreakerState("scrfd"))
+}
+
+func TestResilientClient_RetryOpensBreaker(t *testing.T) {
+	params := config.NewInferenceResilienceParams(5, time.Millisecond, time.Millisecond, 2, 2, time.Minute, 0, 0)
+	backend := &funcClient{infer: failingFirst(10, codes.Unavailable)}
+	client, _, _ := genTestResilientClient(backend, params)
+
+	// Retries stop once the breaker opens and the last failure is returned.
+	_, err := client.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{ModelName: "scrfd"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int64(2), backend.calls.Load())
+	assert.Equal(t, CircuitOpen, client.BreakerState("scrfd"))
+}
+
+func TestResilientClient_Hedge(t *testing.T) {
+	params := config.NewInferenceResilienceParams(0, 0, 0, 0, 0, 0, 0.9, 4)
+	release := make(chan struct{})
+	var requests sync.Map
+	backend := &funcClient{infer: func(call int64, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+		requests.Store(call, request)
+		// The first attempt of the fifth call stalls until released.
+		if call == 4 {
+			<-release
+		}
+		return &triton_proto.ModelInferResponse{ModelName: request.GetModelName(), Id: request.GetId()}, nil
+	}}
+	client := NewResilientClient(backend, params)
+
+	for range 4 {
+		_, err := client.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+		assert.NoError(t, err)
+	}
+	assert.Positive(t, client.hedgeDelay(client.model("face_id")))
+
+	original := &triton_proto.ModelInferRequest{ModelName: "face_id", Id: "request"}
+	resp, err := client.ModelGRPCInfer(time.Second, original)
+	assert.NoError(t, err)
+	assert.Equal(t, "request", resp.GetId())
+	assert.Equal(t, int64(6), backend.calls.Load())
+
+	// Hedged attempts send a copy of the request.
+	sent, _ := requests.Load(int64(5))
+	assert.NotSame(t, original, sent)
+	close(release)
+}
+
+// stallingClient is an inference backend carrying the context of its calls. The call numbered stall waits for its
+// context to be done and reports it on cancelled.
+type stallingClient struct {
+	calls     atomic.Int64
+	stall     int64
+	cancelled chan error
+}
+
+func (c *stallingClient) GetModelConfiguration(_ time.Duration, modelName, _ string) (*triton_proto.ModelConfigResponse, error) {
+	return &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{Name: modelName}}, nil
+}
+
+func (c *stallingClient) ModelGRPCInfer(timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return c.ModelGRPCInferContext(context.Background(), timeout, request)
+}
+
+func (c *stallingClient) ModelGRPCInferContext(ctx context.Context, _ time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	if c.calls.Add(1)-1 == c.stall {
+		<-ctx.Done()
+		c.cancelled <- ctx.Err()
+		return nil, status.Error(codes.Canceled, ctx.Err().Error())
+	}
+	return &triton_proto.ModelInferResponse{ModelName: request.GetModelName()}, nil
+}
+
+func TestResilientClient_HedgeCancelsLoser(t *testing.T) {
+	params := config.NewInferenceResilienceParams(0, 0, 0, 0, 3, time.Minute, 0.9, 4)
+	backend := &stallingClient{stall: 4, cancelled: make(chan error, 1)}
+	client := NewResilientClient(backend, params)
+
+	for range 4 {
+		_, err := client.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+		assert.NoError(t, err)
+	}
+	_, err := client.ModelGRPCInfer(time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+	assert.NoError(t, err)
+
+	// The stalled first attempt is cancelled once the hedge has answered, without counting as a failure.
+	select {
+	case err := <-backend.cancelled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("losing attempt was not cancelled")
+	}
+	assert.Equal(t, CircuitClosed, client.BreakerState("face_id"))
+}
+
+func TestResilientClient_BackoffCancelled(t *testing.T) {
+	params := config.NewInferenceResilienceParams(1, time.Hour, time.Hour, 2, 0, 0, 0, 0)
+	backend := &funcClient{infer: failingFirst(1, codes.Unavailable)}
+	client := NewResilientClient(backend, params)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.ModelGRPCInferContext(ctx, time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int64(1), backend.calls.Load())
+}
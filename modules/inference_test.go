@@ -0,0 +1,14 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/onnx"
+	gotritonclient "github.com/okieraised/go-triton-client"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInferenceClient(t *testing.T) {
+	var clients []InferenceClient
+	clients = append(clients, (*gotritonclient.TritonGRPCClient)(nil), onnx.NewBackend())
+	assert.Len(t, clients, 2)
+}
@@ -0,0 +1,159 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"gorgonia.org/tensor"
+	"math"
+)
+
+// LandmarkValidatorClient checks client-supplied (5, 2) face landmarks before they are used to crop faces.
+type LandmarkValidatorClient struct {
+	ModelParams *config.LandmarkValidationParams
+}
+
+// NewLandmarkValidatorClient initializes a new LandmarkValidatorClient.
+func NewLandmarkValidatorClient(cfg *config.LandmarkValidationParams) (*LandmarkValidatorClient, error) {
+	if cfg == nil {
+		return nil, errors.New("landmark validation params must not be nil")
+	}
+	switch cfg.Mode {
+	case config.LandmarkValidationGeometry, config.LandmarkValidationVerify, config.LandmarkValidationRedetect:
+	default:
+		return nil, fmt.Errorf("unsupported landmark validation mode %q", cfg.Mode)
+	}
+
+	return &LandmarkValidatorClient{
+		ModelParams: cfg,
+	}, nil
+}
+
+// IsDetectionRequired determines if the server-side detection is needed to validate client landmarks.
+func (c *LandmarkValidatorClient) IsDetectionRequired() bool {
+	return c.ModelParams.Mode != config.LandmarkValidationGeometry
+}
+
+// CheckGeometry checks that a landmark lies inside a w x h image and forms an upright face: the eyes are far enough
+// apart, the nose lies between the eye line and the mouth, and the mouth corners are on the same sides as the eyes.
+func (c *LandmarkValidatorClient) CheckGeometry(landmark *tensor.Dense, w, h int) error {
+	if landmark == nil {
+		return errors.New("landmark must not be nil")
+	}
+	lmk := landmark.Float32s()
+	if len(lmk) != 10 {
+		return fmt.Errorf("landmark must have 5 points, got %d values", len(lmk))
+	}
+	for i := 0; i < 10; i += 2 {
+		x, y := float64(lmk[i]), float64(lmk[i+1])
+		if math.IsNaN(x) || math.IsNaN(y) || x < 0 || y < 0 || x >= float64(w) || y >= float64(h) {
+			return fmt.Errorf("landmark point %d (%.1f, %.1f) is outside the %dx%d image", i/2, x, y, w, h)
+		}
+	}
+
+	// Face frame: u along the eyes towards the right eye, v perpendicular towards the chin.
+	eyeDist := math.Hypot(float64(lmk[2]-lmk[0]), float64(lmk[3]-lmk[1]))
+	if eyeDist < float64(c.ModelParams.MinEyeDistance) || eyeDist == 0 {
+		return fmt.Errorf("eye distance %.1f is below %.1f pixels", eyeDist, c.ModelParams.MinEyeDistance)
+	}
+	ux, uy := float64(lmk[2]-lmk[0])/eyeDist, float64(lmk[3]-lmk[1])/eyeDist
+	if ux <= 0 {
+		return errors.New("right eye is not on the right of the left eye")
+	}
+	eyeX, eyeY := float64(lmk[0]+lmk[2])/2, float64(lmk[1]+lmk[3])/2
+	project := func(idx int) (float64, float64) {
+		dx, dy := float64(lmk[2*idx])-eyeX, float64(lmk[2*idx+1])-eyeY
+		return (dx*ux + dy*uy) / eyeDist, (-dx*uy + dy*ux) / eyeDist
+	}
+
+	_, noseV := project(2)
+	leftMouthU, leftMouthV := project(3)
+	rightMouthU, rightMouthV := project(4)
+	mouthV := (leftMouthV + rightMouthV) / 2
+	if noseV <= 0 || mouthV <= noseV {
+		return errors.New("nose does not lie between the eyes and the mouth")
+	}
+	if leftMouthU >= rightMouthU {
+		return errors.New("mouth corners are swapped")
+	}
+	// A frontal face has the mouth about one eye distance below the eyes.
+	if mouthV > 3 {
+		return errors.New("mouth is too far from the eyes")
+	}
+	return nil
+}
+
+// Deviation returns the mean distance between corresponding points of two landmarks over the eye distance of the
+// reference landmark.
+func (c *LandmarkValidatorClient) Deviation(landmark, reference *tensor.Dense) (float32, error) {
+	if landmark == nil || reference == nil {
+		return -1, errors.New("landmarks must not be nil")
+	}
+	lmk, ref := landmark.Float32s(), reference.Float32s()
+	if len(lmk) != 10 || len(ref) != 10 {
+		return -1, errors.New("landmarks must have 5 points")
+	}
+	eyeDist := math.Hypot(float64(ref[2]-ref[0]), float64(ref[3]-ref[1]))
+	if eyeDist == 0 {
+		return -1, errors.New("reference landmark has no eye distance")
+	}
+
+	var sum float64
+	for i := 0; i < 10; i += 2 {
+		sum += math.Hypot(float64(lmk[i]-ref[i]), float64(lmk[i+1]-ref[i+1]))
+	}
+	return float32(sum / 5 / eyeDist), nil
+}
+
+// Validate checks a client landmark against the validation mode and returns the landmark to use.
+//
+// Inputs:
+//
+//   - landmark (*tensor.Dense): client landmark, nil if not supplied.
+//   - detected (*tensor.Dense): server-side landmark of the same image, nil if no face is found or not detected.
+//   - w, h (int): image size.
+//
+// Outputs:
+//
+//   - landmark (*tensor.Dense): client landmark if it passes, server-side landmark if re-detecting or not supplied.
+//   - check (config.LandmarkCheck): validation result.
+func (c *LandmarkValidatorClient) Validate(landmark, detected *tensor.Dense, w, h int) (*tensor.Dense, config.LandmarkCheck, error) {
+	check := config.LandmarkCheck{
+		IsProvided: landmark != nil,
+		Deviation:  -1,
+	}
+
+	if landmark == nil {
+		if detected == nil {
+			check.Reason = "no landmarks supplied and no face detected"
+			return nil, check, errors.New("cannot detect face in image")
+		}
+		check.IsRedetected = true
+		return detected, check, nil
+	}
+
+	if err := c.CheckGeometry(landmark, w, h); err != nil {
+		check.Reason = err.Error()
+	} else if c.IsDetectionRequired() {
+		if detected == nil {
+			check.Reason = "no face detected"
+		} else if check.Deviation, err = c.Deviation(landmark, detected); err != nil {
+			check.Reason = err.Error()
+		} else if c.ModelParams.MaxDeviation > 0 && check.Deviation > c.ModelParams.MaxDeviation {
+			check.Reason = fmt.Sprintf("landmarks deviate %.2f eye distances from the detected face", check.Deviation)
+		}
+	}
+	check.IsValid = check.Reason == ""
+
+	if c.ModelParams.Mode == config.LandmarkValidationRedetect {
+		if detected == nil {
+			return nil, check, errors.New("cannot detect face in image")
+		}
+		check.IsRedetected = true
+		return detected, check, nil
+	}
+	if !check.IsValid {
+		return nil, check, fmt.Errorf("invalid face landmarks: %s", check.Reason)
+	}
+	return landmark, check, nil
+}
@@ -0,0 +1,80 @@
+package modules
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/stretchr/testify/assert"
+	"gorgonia.org/tensor"
+	"testing"
+)
+
+func genTestValidationLandmark(coordinates ...float32) *tensor.Dense {
+	return tensor.New(
+		tensor.Of(tensor.Float32),
+		tensor.WithShape(5, 2),
+		tensor.WithBacking(coordinates),
+	)
+}
+
+func TestLandmarkValidatorClient_CheckGeometry(t *testing.T) {
+	client, err := NewLandmarkValidatorClient(config.DefaultLandmarkValidationParams)
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.CheckGeometry(genTestValidationLandmark(200, 200, 300, 200, 250, 250, 210, 300, 290, 300), 640, 480))
+	// Tilted faces keep their geometry.
+	assert.NoError(t, client.CheckGeometry(genTestValidationLandmark(200, 200, 290, 240, 230, 270, 200, 320, 270, 350), 640, 480))
+
+	assert.Error(t, client.CheckGeometry(genTestValidationLandmark(200, 200, 240, 200, 220, 220, 205, 340, 235, 340), 640, 480))
+	assert.Error(t, client.CheckGeometry(genTestValidationLandmark(300, 200, 200, 200, 250, 250, 210, 300, 290, 300), 640, 480))
+	assert.Error(t, client.CheckGeometry(genTestValidationLandmark(200, 200, 300, 200, 250, 320, 210, 300, 290, 300), 640, 480))
+	assert.Error(t, client.CheckGeometry(genTestValidationLandmark(200, 200, 300, 200, 250, 250, 290, 300, 210, 300), 640, 480))
+	assert.Error(t, client.CheckGeometry(genTestValidationLandmark(200, 200, 203, 200, 201, 202, 200, 204, 203, 204), 640, 480))
+	assert.Error(t, client.CheckGeometry(genTestValidationLandmark(-5, 200, 95, 200, 45, 250, 5, 300, 85, 300), 640, 480))
+
+	_, err = NewLandmarkValidatorClient(config.NewLandmarkValidationParams("trust", 0.25, 8))
+	assert.Error(t, err)
+}
+
+func TestLandmarkValidatorClient_Validate(t *testing.T) {
+	detected := genTestValidationLandmark(200, 200, 300, 200, 250, 250, 210, 300, 290, 300)
+	nearby := genTestValidationLandmark(205, 200, 305, 200, 255, 250, 215, 300, 295, 300)
+	shifted := genTestValidationLandmark(240, 200, 340, 200, 290, 250, 250, 300, 330, 300)
+
+	client, err := NewLandmarkValidatorClient(config.DefaultLandmarkValidationParams)
+	assert.NoError(t, err)
+
+	lmk, check, err := client.Validate(nearby, detected, 640, 480)
+	assert.NoError(t, err)
+	assert.Equal(t, nearby, lmk)
+	assert.True(t, check.IsValid)
+	assert.InDelta(t, 0.05, check.Deviation, 1e-6)
+
+	_, check, err = client.Validate(shifted, detected, 640, 480)
+	assert.Error(t, err)
+	assert.False(t, check.IsValid)
+	assert.InDelta(t, 0.4, check.Deviation, 1e-6)
+
+	_, _, err = client.Validate(nearby, nil, 640, 480)
+	assert.Error(t, err)
+
+	lmk, check, err = client.Validate(nil, detected, 640, 480)
+	assert.NoError(t, err)
+	assert.Equal(t, detected, lmk)
+	assert.False(t, check.IsProvided)
+	assert.True(t, check.IsRedetected)
+
+	client, err = NewLandmarkValidatorClient(config.NewLandmarkValidationParams(config.LandmarkValidationRedetect, 0.25, 8))
+	assert.NoError(t, err)
+	lmk, check, err = client.Validate(shifted, detected, 640, 480)
+	assert.NoError(t, err)
+	assert.Equal(t, detected, lmk)
+	assert.False(t, check.IsValid)
+	assert.True(t, check.IsRedetected)
+
+	client, err = NewLandmarkValidatorClient(config.NewLandmarkValidationParams(config.LandmarkValidationGeometry, 0.25, 8))
+	assert.NoError(t, err)
+	assert.False(t, client.IsDetectionRequired())
+	lmk, check, err = client.Validate(shifted, nil, 640, 480)
+	assert.NoError(t, err)
+	assert.Equal(t, shifted, lmk)
+	assert.Equal(t, float32(-1), check.Deviation)
+}
@@ -0,0 +1,138 @@
+package modules
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+)
+
+const (
+	StagePreprocess  = "preprocess"  // StagePreprocess is the conversion of the images to input tensors.
+	StageNetwork     = "network"     // StageNetwork is the inference call, transfer and queueing included.
+	StagePostprocess = "postprocess" // StagePostprocess is the decoding of the output tensors.
+)
+
+const (
+	OutcomeOK    = "ok"    // OutcomeOK labels the stages and operations that succeeded.
+	OutcomeError = "error" // OutcomeError labels the stages and operations that returned an error.
+)
+
+// Metrics collects the Prometheus metrics of a pipeline and its model clients:
+//
+//   - <namespace>_inference_stage_duration_seconds{model, stage, outcome}: time of each stage of the inference calls.
+//   - <namespace>_operation_duration_seconds{operation, outcome}: time of the pipeline operations.
+//   - <namespace>_faces_not_found_total{image}: images in which no face was detected.
+//   - <namespace>_decisions_total{check, decision}: decisions of the pipeline checks.
+//   - <namespace>_scores{check}: distribution of the scores the decisions are made on.
+//
+// The outcome label is OutcomeOK or OutcomeError, so that the counts of the histograms give the failure rates of
+// each operation, model and stage. Metrics implements prometheus.Collector, it is registered once and shared by the instrumented clients. The methods
+// of a nil Metrics do nothing, so that uninstrumented clients need no check.
+type Metrics struct {
+	stageDuration     *prometheus.HistogramVec
+	operationDuration *prometheus.HistogramVec
+	facesNotFound     *prometheus.CounterVec
+	decisions         *prometheus.CounterVec
+	scores            *prometheus.HistogramVec
+}
+
+// NewMetrics initializes the metrics with names prefixed by namespace, unprefixed if empty.
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		stageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "inference_stage_duration_seconds",
+			Help:      "Duration of the preprocess, network and postprocess stages of the inference calls.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"model", "stage", "outcome"}),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of the pipeline operations.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, []string{"operation", "outcome"}),
+		facesNotFound: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "faces_not_found_total",
+			Help:      "Number of input images in which no face was detected.",
+		}, []string{"image"}),
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Number of decisions of the pipeline checks by outcome.",
+		}, []string{"check", "decision"}),
+		scores: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "scores",
+			Help:      "Distribution of the scores the pipeline checks decide on.",
+			Buckets:   prometheus.LinearBuckets(0, 0.05, 21),
+		}, []string{"check"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.stageDuration.Describe(ch)
+	m.operationDuration.Describe(ch)
+	m.facesNotFound.Describe(ch)
+	m.decisions.Describe(ch)
+	m.scores.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.stageDuration.Collect(ch)
+	m.operationDuration.Collect(ch)
+	m.facesNotFound.Collect(ch)
+	m.decisions.Collect(ch)
+	m.scores.Collect(ch)
+}
+
+// outcome returns the outcome label of a stage or operation that returned err.
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeOK
+}
+
+// ObserveStage records the duration of a stage of an inference call of model since start, and its outcome.
+func (m *Metrics) ObserveStage(model, stage string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.stageDuration.WithLabelValues(model, stage, outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveOperation records the duration of a pipeline operation since start, and its outcome.
+func (m *Metrics) ObserveOperation(operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.operationDuration.WithLabelValues(operation, outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// FaceNotFound counts an input image in which no face was detected, image being its role, e.g. far or card.
+func (m *Metrics) FaceNotFound(image string) {
+	if m == nil {
+		return
+	}
+	m.facesNotFound.WithLabelValues(image).Inc()
+}
+
+// ObserveDecision counts the decision of a check and records the scores it was made on.
+func (m *Metrics) ObserveDecision(check string, decision bool, scores ...float32) {
+	if m == nil {
+		return
+	}
+	m.decisions.WithLabelValues(check, strconv.FormatBool(decision)).Inc()
+	for _, score := range scores {
+		m.scores.WithLabelValues(check).Observe(float64(score))
+	}
+}
+
+// Instrumenter is implemented by the model clients. Instrument makes the client record the duration of each stage of
+// its inference calls to metrics, or stop recording if nil.
+type Instrumenter interface {
+	Instrument(metrics *Metrics)
+}
@@ -0,0 +1,52 @@
+package modules
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics("ekyc")
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(metrics))
+
+	start := time.Now().Add(-10 * time.Millisecond)
+	metrics.ObserveStage("face_id", StageNetwork, start, nil)
+	metrics.ObserveStage("face_id", StageNetwork, start, errors.New("unavailable"))
+	metrics.ObserveStage("face_id", StagePreprocess, start, nil)
+	metrics.ObserveOperation("active_verify", start, nil)
+	metrics.ObserveOperation("active_verify", start, errors.New("no face"))
+	metrics.FaceNotFound("card")
+	metrics.FaceNotFound("card")
+	metrics.ObserveDecision("same_person", true, 0.8, 0.9)
+	metrics.ObserveDecision("same_person", false, 0.2, 0.3)
+	metrics.ObserveDecision("liveness", true)
+
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics, "ekyc_inference_stage_duration_seconds"))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics, "ekyc_operation_duration_seconds"))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.facesNotFound.WithLabelValues("card")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.decisions.WithLabelValues("same_person", "false")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.decisions.WithLabelValues("liveness", "true")))
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP ekyc_faces_not_found_total Number of input images in which no face was detected.
+# TYPE ekyc_faces_not_found_total counter
+ekyc_faces_not_found_total{image="card"} 2
+`), "ekyc_faces_not_found_total")
+	assert.NoError(t, err)
+
+	// Scores of decisions without scores are not recorded.
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.scores))
+
+	// Uninstrumented clients record nothing.
+	var disabled *Metrics
+	disabled.ObserveStage("face_id", StageNetwork, start, nil)
+	disabled.ObserveOperation("active_verify", start, nil)
+	disabled.FaceNotFound("card")
+	disabled.ObserveDecision("same_person", true, 0.8)
+}
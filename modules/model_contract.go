@@ -0,0 +1,211 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	DimAny    int64 = -1 // DimAny matches any size of a dimension, dynamic included.
+	DimStatic int64 = 0  // DimStatic matches any static size of a dimension.
+)
+
+// TensorContract is the input or output tensor a client expects from the configuration of its model.
+type TensorContract struct {
+	Name      string                  // Name of the tensor, any name if empty.
+	DataTypes []triton_proto.DataType // DataTypes accepted for the tensor, any datatype if nil.
+	Dims      []int64                 // Dims of the tensor without the batch dimension, any dims if nil.
+}
+
+// ModelContract is the configuration a client expects from its model: the inputs it fills in order and the outputs it
+// reads in order.
+type ModelContract struct {
+	ModelName string
+	Inputs    []TensorContract
+	Outputs   []TensorContract
+	// ExtraOutputs is the contract of the outputs following Outputs. No other output is expected if nil.
+	ExtraOutputs *TensorContract
+	// Check reports the mismatches of the constraints spanning several tensors, e.g. outputs identified by their dims.
+	Check func(modelConfig *triton_proto.ModelConfig) []string
+}
+
+// ContractChecker is implemented by the model clients. CheckContract validates the model configuration fetched by the
+// client against the contract of the client, see ModelContract.Validate.
+type ContractChecker interface {
+	CheckContract() error
+}
+
+// CheckModelContracts validates the model configurations of the clients, so that a model not matching its client fails
+// at startup rather than on the first request. The mismatches of all models are returned.
+func CheckModelContracts(clients ...ContractChecker) error {
+	var err error
+	for _, client := range clients {
+		err = errors.Join(err, client.CheckContract())
+	}
+	return err
+}
+
+// Validate checks the model configuration against the contract and returns an error listing every mismatch.
+func (c ModelContract) Validate(modelConfig *triton_proto.ModelConfigResponse) error {
+	cfg := modelConfig.GetConfig()
+	if cfg == nil {
+		return fmt.Errorf("model %s has no configuration", c.ModelName)
+	}
+
+	inputs := make([]TensorContract, 0, len(cfg.GetInput()))
+	for _, input := range cfg.GetInput() {
+		inputs = append(inputs, TensorContract{Name: input.GetName(), DataTypes: []triton_proto.DataType{input.GetDataType()}, Dims: input.GetDims()})
+	}
+	outputs := make([]TensorContract, 0, len(cfg.GetOutput()))
+	for _, output := range cfg.GetOutput() {
+		outputs = append(outputs, TensorContract{Name: output.GetName(), DataTypes: []triton_proto.DataType{output.GetDataType()}, Dims: output.GetDims()})
+	}
+
+	diffs := diffTensors("input", c.Inputs, nil, inputs)
+	diffs = append(diffs, diffTensors("output", c.Outputs, c.ExtraOutputs, outputs)...)
+	if c.Check != nil {
+		diffs = append(diffs, c.Check(cfg)...)
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("model %s does not match the contract of its client:\n  %s", c.ModelName, strings.Join(diffs, "\n  "))
+}
+
+// diffTensors lists the mismatches between the expected tensors, followed by any number of extra tensors if extra is
+// set, and the configured ones.
+func diffTensors(kind string, expected []TensorContract, extra *TensorContract, configured []TensorContract) []string {
+	diffs := make([]string, 0)
+	if len(configured) < len(expected) || (extra == nil && len(configured) > len(expected)) {
+		want := strconv.Itoa(len(expected))
+		if extra != nil {
+			want = "at least " + want
+		}
+		diffs = append(diffs, fmt.Sprintf("%ss: got %d, want %s", kind, len(configured), want))
+	}
+
+	for idx := range max(len(expected), len(configured)) {
+		var want *TensorContract
+		switch {
+		case idx < len(expected):
+			want = &expected[idx]
+		case extra != nil:
+			want = extra
+		default:
+			diffs = append(diffs, fmt.Sprintf("%s %d %q: unexpected", kind, idx, configured[idx].Name))
+			continue
+		}
+		if idx >= len(configured) {
+			diffs = append(diffs, fmt.Sprintf("%s %d: missing, want %s", kind, idx, want))
+			continue
+		}
+
+		got := configured[idx]
+		prefix := fmt.Sprintf("%s %d %q", kind, idx, got.Name)
+		if want.Name != "" && got.Name != want.Name {
+			diffs = append(diffs, fmt.Sprintf("%s: name %q, want %q", prefix, got.Name, want.Name))
+		}
+		if want.DataTypes != nil && !slices.Contains(want.DataTypes, got.DataTypes[0]) {
+			diffs = append(diffs, fmt.Sprintf("%s: datatype %s, want %s", prefix, dataTypeName(got.DataTypes[0]), dataTypeNames(want.DataTypes)))
+		}
+		if want.Dims != nil && !dimsMatch(want.Dims, got.Dims) {
+			diffs = append(diffs, fmt.Sprintf("%s: dims %v, want %s", prefix, got.Dims, formatDims(want.Dims)))
+		}
+	}
+	return diffs
+}
+
+// dimsMatch reports whether the configured dims match the expected dims and their wildcards.
+func dimsMatch(want, got []int64) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for idx, dim := range want {
+		switch dim {
+		case DimAny:
+		case DimStatic:
+			if got[idx] <= 0 {
+				return false
+			}
+		default:
+			if got[idx] != dim {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func dataTypeName(dataType triton_proto.DataType) string {
+	return strings.TrimPrefix(dataType.String(), "TYPE_")
+}
+
+func dataTypeNames(dataTypes []triton_proto.DataType) string {
+	names := make([]string, 0, len(dataTypes))
+	for _, dt := range dataTypes {
+		names = append(names, dataTypeName(dt))
+	}
+	return strings.Join(names, " or ")
+}
+
+func formatDims(dims []int64) string {
+	parts := make([]string, 0, len(dims))
+	for _, dim := range dims {
+		switch dim {
+		case DimAny:
+			parts = append(parts, "any")
+		case DimStatic:
+			parts = append(parts, "static")
+		default:
+			parts = append(parts, strconv.FormatInt(dim, 10))
+		}
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// String formats the tensor contract for the mismatch reports.
+func (t TensorContract) String() string {
+	parts := make([]string, 0, 3)
+	if t.Name != "" {
+		parts = append(parts, strconv.Quote(t.Name))
+	}
+	if t.DataTypes != nil {
+		parts = append(parts, dataTypeNames(t.DataTypes))
+	}
+	if t.Dims != nil {
+		parts = append(parts, formatDims(t.Dims))
+	}
+	if len(parts) == 0 {
+		return "any tensor"
+	}
+	return strings.Join(parts, " ")
+}
+
+// imageInputContract is the contract of a CHW image input the client resizes to size, or to the size of the model if
+// size is not positive.
+func imageInputContract(size int) TensorContract {
+	dims := []int64{3, DimStatic, DimStatic}
+	if size > 0 {
+		dims = []int64{3, int64(size), int64(size)}
+	}
+	return TensorContract{DataTypes: []triton_proto.DataType{triton_proto.DataType_TYPE_FP32}, Dims: dims}
+}
+
+// fp32Contract is the contract of an FP32 tensor of any name and dims.
+var fp32Contract = TensorContract{DataTypes: []triton_proto.DataType{triton_proto.DataType_TYPE_FP32}}
+
+// staticSize returns the number of elements of a tensor of static dims, or 0 if a dim is dynamic.
+func staticSize(dims []int64) int64 {
+	size := int64(1)
+	for _, dim := range dims {
+		if dim <= 0 {
+			return 0
+		}
+		size *= dim
+	}
+	return size
+}
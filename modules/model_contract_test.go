@@ -0,0 +1,84 @@
+package modules
+
+import (
+	"errors"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type funcChecker func() error
+
+func (f funcChecker) CheckContract() error {
+	return f()
+}
+
+func genTestContractConfig(inputs []*triton_proto.ModelInput, outputs []*triton_proto.ModelOutput) *triton_proto.ModelConfigResponse {
+	return &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{Name: "face_as", Input: inputs, Output: outputs}}
+}
+
+func TestModelContract_Validate(t *testing.T) {
+	face := imageInputContract(224)
+	contract := ModelContract{
+		ModelName:    "face_as",
+		Inputs:       []TensorContract{face, face, face},
+		Outputs:      []TensorContract{{Name: "score", DataTypes: fp32Contract.DataTypes, Dims: []int64{DimAny, 2}}},
+		ExtraOutputs: &TensorContract{DataTypes: fp32Contract.DataTypes},
+	}
+	input := func(name string, dataType triton_proto.DataType, dims ...int64) *triton_proto.ModelInput {
+		return &triton_proto.ModelInput{Name: name, DataType: dataType, Dims: dims}
+	}
+	fp32 := triton_proto.DataType_TYPE_FP32
+
+	matching := genTestContractConfig(
+		[]*triton_proto.ModelInput{input("far", fp32, 3, 224, 224), input("mid", fp32, 3, 224, 224), input("near", fp32, 3, 224, 224)},
+		[]*triton_proto.ModelOutput{{Name: "score", DataType: fp32, Dims: []int64{-1, 2}}, {Name: "logits", DataType: fp32, Dims: []int64{2}}},
+	)
+	assert.NoError(t, contract.Validate(matching))
+
+	mismatching := genTestContractConfig(
+		[]*triton_proto.ModelInput{input("far", triton_proto.DataType_TYPE_FP16, 3, 224, 224), input("mid", fp32, 1, 3, 224, 224)},
+		[]*triton_proto.ModelOutput{{Name: "prob", DataType: fp32, Dims: []int64{1, 2}}, {Name: "label", DataType: triton_proto.DataType_TYPE_INT64, Dims: []int64{1}}},
+	)
+	assert.EqualError(t, contract.Validate(mismatching), `model face_as does not match the contract of its client:
+  inputs: got 2, want 3
+  input 0 "far": datatype FP16, want FP32
+  input 1 "mid": dims [1 3 224 224], want [3 224 224]
+  input 2: missing, want FP32 [3 224 224]
+  output 0 "prob": name "prob", want "score"
+  output 1 "label": datatype INT64, want FP32`)
+
+	// Without extra outputs, outputs beyond the contract are reported.
+	contract.ExtraOutputs = nil
+	assert.EqualError(t, contract.Validate(matching), `model face_as does not match the contract of its client:
+  outputs: got 2, want 1
+  output 1 "logits": unexpected`)
+
+	// Dynamic dims do not match static dims.
+	contract = ModelContract{ModelName: "face_quality", Inputs: []TensorContract{imageInputContract(0)}}
+	assert.NoError(t, contract.Validate(genTestContractConfig([]*triton_proto.ModelInput{input("data", fp32, 3, 112, 112)}, nil)))
+	assert.EqualError(t, contract.Validate(genTestContractConfig([]*triton_proto.ModelInput{input("data", fp32, 3, -1, -1)}, nil)), `model face_quality does not match the contract of its client:
+  input 0 "data": dims [3 -1 -1], want [3 static static]`)
+
+	contract.Check = func(*triton_proto.ModelConfig) []string {
+		return []string{"custom mismatch"}
+	}
+	assert.ErrorContains(t, contract.Validate(genTestContractConfig([]*triton_proto.ModelInput{input("data", fp32, 3, 112, 112)}, nil)), "\n  custom mismatch")
+	assert.EqualError(t, contract.Validate(&triton_proto.ModelConfigResponse{}), "model face_quality has no configuration")
+}
+
+func TestCheckModelContracts(t *testing.T) {
+	valid := funcChecker(func() error { return nil })
+	assert.NoError(t, CheckModelContracts(valid, valid))
+
+	first, second := errors.New("face_id mismatch"), errors.New("scrfd mismatch")
+	err := CheckModelContracts(funcChecker(func() error { return first }), valid, funcChecker(func() error { return second }))
+	assert.ErrorIs(t, err, first)
+	assert.ErrorIs(t, err, second)
+}
+
+func TestStaticSize(t *testing.T) {
+	assert.Equal(t, int64(6), staticSize([]int64{2, 3}))
+	assert.Equal(t, int64(1), staticSize(nil))
+	assert.Equal(t, int64(0), staticSize([]int64{-1, 2}))
+}
@@ -0,0 +1,114 @@
+package modules
+
+import (
+	"context"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	"time"
+)
+
+// TracerName is the name of the OpenTelemetry tracer of the pipeline. Spans are recorded by the tracer provider set
+// with otel.SetTracerProvider, none by default.
+const TracerName = "github.com/okieraised/go-ekyc-pipeline"
+
+// ContextInferenceClient is implemented by the inference backends carrying the context of a call, e.g. to propagate
+// its trace to the inference server. Backends implementing only InferenceClient are called without the context.
+type ContextInferenceClient interface {
+	ModelGRPCInferContext(ctx context.Context, timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error)
+}
+
+// StartSpan starts a span of the pipeline tracer, ended by calling the returned function with the error of the step.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(err error)) {
+	ctx, span := otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// inferContext sends request to client with the context of the call if the client carries it.
+func inferContext(ctx context.Context, client InferenceClient, timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	if ctxClient, ok := client.(ContextInferenceClient); ok {
+		return ctxClient.ModelGRPCInferContext(ctx, timeout, request)
+	}
+	return client.ModelGRPCInfer(timeout, request)
+}
+
+// modelInfer sends an inference request of a model client within a span recording the model, its version, the batch
+// size and the size of the request and response contents.
+func modelInfer(ctx context.Context, client InferenceClient, timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	var batchSize int64
+	if inputs := request.GetInputs(); len(inputs) > 0 && len(inputs[0].GetShape()) > 0 {
+		batchSize = inputs[0].GetShape()[0]
+	}
+	ctx, span := otel.Tracer(TracerName).Start(ctx, "triton.infer", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("triton.model.name", request.GetModelName()),
+		attribute.String("triton.model.version", request.GetModelVersion()),
+		attribute.Int64("triton.batch_size", batchSize),
+		attribute.Int("triton.request.bytes", contentSize(request.GetRawInputContents())),
+	))
+	defer span.End()
+
+	resp, err := inferContext(ctx, client, timeout, request)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.String("triton.model.served_version", resp.GetModelVersion()),
+		attribute.Int("triton.response.bytes", contentSize(resp.GetRawOutputContents())),
+	)
+	return resp, nil
+}
+
+func contentSize(contents [][]byte) int {
+	size := 0
+	for _, content := range contents {
+		size += len(content)
+	}
+	return size
+}
+
+// metadataCarrier carries the trace context of a call in the outgoing gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// InjectTraceContext returns ctx with its trace context added to the outgoing gRPC metadata, in the format of the
+// propagator set with otel.SetTextMapPropagator, e.g. W3C traceparent headers read by Triton when its trace mode is
+// opentelemetry.
+func InjectTraceContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
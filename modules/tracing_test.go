@@ -0,0 +1,124 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/metadata"
+	"testing"
+	"time"
+)
+
+// contextClient is an inference backend recording the outgoing metadata of the context of its calls.
+type contextClient struct {
+	md  metadata.MD
+	err error
+}
+
+func (c *contextClient) GetModelConfiguration(_ time.Duration, modelName, _ string) (*triton_proto.ModelConfigResponse, error) {
+	return &triton_proto.ModelConfigResponse{Config: &triton_proto.ModelConfig{Name: modelName}}, nil
+}
+
+func (c *contextClient) ModelGRPCInfer(timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return c.ModelGRPCInferContext(context.Background(), timeout, request)
+}
+
+func (c *contextClient) ModelGRPCInferContext(ctx context.Context, _ time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	c.md, _ = metadata.FromOutgoingContext(InjectTraceContext(ctx))
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &triton_proto.ModelInferResponse{
+		ModelName:         request.GetModelName(),
+		ModelVersion:      "3",
+		RawOutputContents: [][]byte{make([]byte, 16)},
+	}, nil
+}
+
+func genTestTracer(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := otel.GetTracerProvider()
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestModelInfer_Span(t *testing.T) {
+	recorder := genTestTracer(t)
+	client := &contextClient{}
+
+	ctx, end := StartSpan(context.Background(), "ekyc.test")
+	request := &triton_proto.ModelInferRequest{
+		ModelName:        "face_id",
+		ModelVersion:     "3",
+		Inputs:           []*triton_proto.ModelInferRequest_InferInputTensor{{Shape: []int64{2, 3, 112, 112}}},
+		RawInputContents: [][]byte{make([]byte, 64)},
+	}
+	_, err := modelInfer(ctx, client, time.Second, request)
+	assert.NoError(t, err)
+	end(nil)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	infer, parent := spans[0], spans[1]
+	assert.Equal(t, "triton.infer", infer.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), infer.Parent().SpanID())
+
+	attrs := spanAttributes(infer)
+	assert.Equal(t, "face_id", attrs["triton.model.name"].AsString())
+	assert.Equal(t, "3", attrs["triton.model.served_version"].AsString())
+	assert.Equal(t, int64(2), attrs["triton.batch_size"].AsInt64())
+	assert.Equal(t, int64(64), attrs["triton.request.bytes"].AsInt64())
+	assert.Equal(t, int64(16), attrs["triton.response.bytes"].AsInt64())
+
+	// The trace context of the infer span is sent to the server.
+	traceparent := client.md.Get("traceparent")
+	assert.Len(t, traceparent, 1)
+	assert.Contains(t, traceparent[0], infer.SpanContext().TraceID().String())
+	assert.Contains(t, traceparent[0], infer.SpanContext().SpanID().String())
+}
+
+func TestModelInfer_Error(t *testing.T) {
+	recorder := genTestTracer(t)
+	client := &contextClient{err: errors.New("unavailable")}
+
+	_, err := modelInfer(context.Background(), client, time.Second, &triton_proto.ModelInferRequest{ModelName: "face_id"})
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestInjectTraceContext_KeepsMetadata(t *testing.T) {
+	genTestTracer(t)
+
+	ctx, end := StartSpan(context.Background(), "ekyc.test")
+	defer end(nil)
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", "42")
+
+	md, ok := metadata.FromOutgoingContext(InjectTraceContext(ctx))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"42"}, md.Get("x-request-id"))
+	assert.Len(t, md.Get("traceparent"), 1)
+}
@@ -0,0 +1,76 @@
+package modules
+
+import (
+	"context"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"google.golang.org/grpc"
+	"time"
+)
+
+// TritonClient is a Triton gRPC client carrying the context of the inference calls: their cancellation, and their
+// trace context in the request metadata, so that the traces of the Triton server join the trace of the caller. It
+// implements TritonEndpoint and ContextInferenceClient, and can be passed to NewEKYCPipeline or NewInferencePool in
+// place of the go-triton-client client.
+type TritonClient struct {
+	conn       *grpc.ClientConn
+	grpcClient triton_proto.GRPCInferenceServiceClient
+}
+
+// NewTritonClient initializes a client of the Triton server at serverURL.
+func NewTritonClient(serverURL string, grpcOpts ...grpc.DialOption) (*TritonClient, error) {
+	conn, err := grpc.NewClient(serverURL, grpcOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TritonClient{
+		conn:       conn,
+		grpcClient: triton_proto.NewGRPCInferenceServiceClient(conn),
+	}, nil
+}
+
+// Close closes the connection of the client.
+func (c *TritonClient) Close() error {
+	return c.conn.Close()
+}
+
+// ServerReady reports whether the server is ready.
+func (c *TritonClient) ServerReady(timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := c.grpcClient.ServerReady(ctx, &triton_proto.ServerReadyRequest{})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetReady(), nil
+}
+
+// ModelRepositoryIndex returns the index of the models of a repository, of every repository if repoName is empty.
+func (c *TritonClient) ModelRepositoryIndex(timeout time.Duration, repoName string, isReady bool) (*triton_proto.RepositoryIndexResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.grpcClient.RepositoryIndex(ctx, &triton_proto.RepositoryIndexRequest{RepositoryName: repoName, Ready: isReady})
+}
+
+// GetModelConfiguration returns the configuration of a model, of its latest version if modelVersion is empty.
+func (c *TritonClient) GetModelConfiguration(timeout time.Duration, modelName, modelVersion string) (*triton_proto.ModelConfigResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.grpcClient.ModelConfig(ctx, &triton_proto.ModelConfigRequest{Name: modelName, Version: modelVersion})
+}
+
+// ModelGRPCInfer runs an inference request without a caller context, see ModelGRPCInferContext.
+func (c *TritonClient) ModelGRPCInfer(timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return c.ModelGRPCInferContext(context.Background(), timeout, request)
+}
+
+// ModelGRPCInferContext runs an inference request within ctx, bounded by timeout, and propagates the trace context of
+// ctx to the server, see InjectTraceContext.
+func (c *TritonClient) ModelGRPCInferContext(ctx context.Context, timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return c.grpcClient.ModelInfer(InjectTraceContext(ctx), request)
+}
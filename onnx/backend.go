@@ -0,0 +1,283 @@
+package onnx
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"math"
+	"sync"
+	"time"
+)
+
+// modelVersion is the single version of the models of a backend.
+const modelVersion = "1"
+
+var dataTypes = map[int32]triton_proto.DataType{
+	DataTypeFloat: triton_proto.DataType_TYPE_FP32,
+	DataTypeInt64: triton_proto.DataType_TYPE_INT64,
+}
+
+// Backend serves ONNX models in-process. It exposes them through the configuration and inference messages of
+// Triton, so that the model clients and the pipeline run on it without a server, e.g. on edge devices or in tests.
+// Models are registered under the names the clients are configured with.
+type Backend struct {
+	mu     sync.RWMutex
+	models map[string]*backendModel
+}
+
+type backendModel struct {
+	model  *Model
+	config *triton_proto.ModelConfigResponse
+}
+
+// NewBackend initializes a backend without models.
+func NewBackend() *Backend {
+	return &Backend{
+		models: map[string]*backendModel{},
+	}
+}
+
+// LoadModelFile loads an ONNX model from a file and registers it, see Backend.AddModel.
+func (b *Backend) LoadModelFile(name, path string, maxBatchSize int) error {
+	model, err := LoadModel(path)
+	if err != nil {
+		return err
+	}
+	return b.AddModel(name, model, maxBatchSize)
+}
+
+// AddModel registers a model under a name, replacing any model of the same name. As in Triton, a positive
+// maxBatchSize declares the first dimension of the inputs and outputs as the batch dimension, it is left out of the
+// dims of the model configuration.
+func (b *Backend) AddModel(name string, model *Model, maxBatchSize int) error {
+	config := &triton_proto.ModelConfig{
+		Name:         name,
+		Platform:     "onnxruntime_onnx",
+		Backend:      "onnx",
+		MaxBatchSize: int32(maxBatchSize),
+	}
+	for _, info := range model.Inputs {
+		dataType, dims, err := configDims(info, maxBatchSize)
+		if err != nil {
+			return err
+		}
+		config.Input = append(config.Input, &triton_proto.ModelInput{Name: info.Name, DataType: dataType, Dims: dims})
+	}
+	for _, info := range model.Outputs {
+		dataType, dims, err := configDims(info, maxBatchSize)
+		if err != nil {
+			return err
+		}
+		config.Output = append(config.Output, &triton_proto.ModelOutput{Name: info.Name, DataType: dataType, Dims: dims})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.models[name] = &backendModel{
+		model:  model,
+		config: &triton_proto.ModelConfigResponse{Config: config},
+	}
+	return nil
+}
+
+// configDims returns the data type and the configuration dims of a model input or output.
+func configDims(info ValueInfo, maxBatchSize int) (triton_proto.DataType, []int64, error) {
+	dataType, ok := dataTypes[info.DataType]
+	if !ok {
+		return 0, nil, fmt.Errorf("%q: unsupported data type %d", info.Name, info.DataType)
+	}
+	dims := info.Dims
+	if maxBatchSize > 0 {
+		if len(dims) == 0 {
+			return 0, nil, fmt.Errorf("%q: batching requires a batch dimension", info.Name)
+		}
+		dims = dims[1:]
+	}
+	return dataType, dims, nil
+}
+
+func (b *Backend) model(name, version string) (*backendModel, error) {
+	if version != "" && version != modelVersion {
+		return nil, fmt.Errorf("model %q has no version %s", name, version)
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	model, ok := b.models[name]
+	if !ok {
+		return nil, fmt.Errorf("model %q is not loaded", name)
+	}
+	return model, nil
+}
+
+// GetModelConfiguration returns the configuration of a loaded model. Models have a single version, 1, served for
+// empty versions as the latest.
+func (b *Backend) GetModelConfiguration(_ time.Duration, modelName, modelVersion string) (*triton_proto.ModelConfigResponse, error) {
+	model, err := b.model(modelName, modelVersion)
+	if err != nil {
+		return nil, err
+	}
+	return model.config, nil
+}
+
+// ModelGRPCInfer runs an inference request. Inputs are read from the raw contents of the request, or from its typed
+// contents otherwise. All outputs of the model are returned as raw contents, in the order of the model configuration
+// unless the request names its outputs. A positive timeout bounds the computation, see ModelGRPCInferContext.
+func (b *Backend) ModelGRPCInfer(timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return b.ModelGRPCInferContext(context.Background(), timeout, request)
+}
+
+// ModelGRPCInferContext runs an inference request within ctx, see ModelGRPCInfer. The inputs are copied out of the
+// request before the computation starts, so that the request can be reused as soon as the call returns. The
+// computation stops between nodes once ctx is done or the timeout has expired.
+func (b *Backend) ModelGRPCInferContext(ctx context.Context, timeout time.Duration, request *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	model, err := b.model(request.GetModelName(), request.GetModelVersion())
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := requestInputs(request)
+	if err != nil {
+		return nil, err
+	}
+	response := &triton_proto.ModelInferResponse{
+		ModelName:    request.GetModelName(),
+		ModelVersion: modelVersion,
+		Id:           request.GetId(),
+	}
+	names := make([]string, 0, len(model.model.Outputs))
+	for _, output := range request.GetOutputs() {
+		names = append(names, output.GetName())
+	}
+	if timeout <= 0 && ctx.Done() == nil {
+		return model.infer(ctx, inputs, names, response)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		response *triton_proto.ModelInferResponse
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := model.infer(ctx, inputs, names, response)
+		done <- result{response, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.response, res.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && timeout > 0 {
+			return nil, fmt.Errorf("model %q: inference timed out after %s: %w", response.GetModelName(), timeout, ctx.Err())
+		}
+		return nil, fmt.Errorf("model %q: %w", response.GetModelName(), ctx.Err())
+	}
+}
+
+// requestInputs decodes the inputs of a request into tensors owning their values.
+func requestInputs(request *triton_proto.ModelInferRequest) (map[string]*Tensor, error) {
+	inputs := make(map[string]*Tensor, len(request.GetInputs()))
+	raw := request.GetRawInputContents()
+	for idx, input := range request.GetInputs() {
+		var content []byte
+		if idx < len(raw) {
+			content = raw[idx]
+		}
+		t, err := inputTensor(input, content)
+		if err != nil {
+			return nil, fmt.Errorf("input %q: %w", input.GetName(), err)
+		}
+		inputs[input.GetName()] = t
+	}
+	return inputs, nil
+}
+
+// infer runs the model on its inputs and adds the named outputs, every output if names is empty, to response.
+func (m *backendModel) infer(ctx context.Context, inputs map[string]*Tensor, names []string, response *triton_proto.ModelInferResponse) (*triton_proto.ModelInferResponse, error) {
+	outputs, err := m.model.RunContext(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		for _, info := range m.model.Outputs {
+			names = append(names, info.Name)
+		}
+	}
+	for _, name := range names {
+		t, ok := outputs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown output %q", name)
+		}
+		shape := make([]int64, len(t.Shape))
+		for idx, dim := range t.Shape {
+			shape[idx] = int64(dim)
+		}
+		response.Outputs = append(response.Outputs, &triton_proto.ModelInferResponse_InferOutputTensor{
+			Name:     name,
+			Datatype: dataTypes[t.DataType].String()[5:],
+			Shape:    shape,
+		})
+		response.RawOutputContents = append(response.RawOutputContents, outputContent(t))
+	}
+	return response, nil
+}
+
+// inputTensor decodes an input of a request from its raw content, or from its typed contents if raw is nil. The
+// tensor never shares memory with the request.
+func inputTensor(input *triton_proto.ModelInferRequest_InferInputTensor, raw []byte) (*Tensor, error) {
+	shape := make([]int, len(input.GetShape()))
+	for idx, dim := range input.GetShape() {
+		shape[idx] = int(dim)
+	}
+
+	switch input.GetDatatype() {
+	case "FP32":
+		if raw == nil {
+			return NewFloat32Tensor(append([]float32(nil), input.GetContents().GetFp32Contents()...), shape...)
+		}
+		if len(raw)%4 != 0 {
+			return nil, errors.New("raw content is not a multiple of 4 bytes")
+		}
+		values := make([]float32, len(raw)/4)
+		for idx := range values {
+			values[idx] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*idx:]))
+		}
+		return NewFloat32Tensor(values, shape...)
+	case "INT64":
+		if raw == nil {
+			return NewInt64Tensor(append([]int64(nil), input.GetContents().GetInt64Contents()...), shape...)
+		}
+		if len(raw)%8 != 0 {
+			return nil, errors.New("raw content is not a multiple of 8 bytes")
+		}
+		values := make([]int64, len(raw)/8)
+		for idx := range values {
+			values[idx] = int64(binary.LittleEndian.Uint64(raw[8*idx:]))
+		}
+		return NewInt64Tensor(values, shape...)
+	default:
+		return nil, fmt.Errorf("unsupported data type %s", input.GetDatatype())
+	}
+}
+
+// outputContent encodes the values of an output tensor in little endian order.
+func outputContent(t *Tensor) []byte {
+	if t.isFloat() {
+		content := make([]byte, 4*len(t.Float32s))
+		for idx, v := range t.Float32s {
+			binary.LittleEndian.PutUint32(content[4*idx:], math.Float32bits(v))
+		}
+		return content
+	}
+	content := make([]byte, 8*len(t.Int64s))
+	for idx, v := range t.Int64s {
+		binary.LittleEndian.PutUint64(content[8*idx:], uint64(v))
+	}
+	return content
+}
@@ -0,0 +1,133 @@
+package onnx
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func genTestBackend(t *testing.T, maxBatchSize int) *Backend {
+	model, err := ParseModel(genTestClassifier(11))
+	assert.NoError(t, err)
+
+	backend := NewBackend()
+	assert.NoError(t, backend.AddModel("classifier", model, maxBatchSize))
+	return backend
+}
+
+func float32Bytes(values []float32) []byte {
+	b := make([]byte, 4*len(values))
+	for idx, v := range values {
+		binary.LittleEndian.PutUint32(b[4*idx:], math.Float32bits(v))
+	}
+	return b
+}
+
+func TestBackend_GetModelConfiguration(t *testing.T) {
+	// The batch dimension is left out of the dims of batching models.
+	cfg, err := genTestBackend(t, 8).GetModelConfiguration(time.Second, "classifier", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "classifier", cfg.GetConfig().GetName())
+	assert.Equal(t, int32(8), cfg.GetConfig().GetMaxBatchSize())
+	assert.Equal(t, "input", cfg.GetConfig().GetInput()[0].GetName())
+	assert.Equal(t, triton_proto.DataType_TYPE_FP32, cfg.GetConfig().GetInput()[0].GetDataType())
+	assert.Equal(t, []int64{1, 4, 4}, cfg.GetConfig().GetInput()[0].GetDims())
+	assert.Equal(t, []int64{3}, cfg.GetConfig().GetOutput()[0].GetDims())
+
+	cfg, err = genTestBackend(t, 0).GetModelConfiguration(time.Second, "classifier", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-1, 1, 4, 4}, cfg.GetConfig().GetInput()[0].GetDims())
+
+	_, err = genTestBackend(t, 0).GetModelConfiguration(time.Second, "classifier", "1")
+	assert.NoError(t, err)
+	_, err = genTestBackend(t, 0).GetModelConfiguration(time.Second, "classifier", "2")
+	assert.Error(t, err)
+	_, err = NewBackend().GetModelConfiguration(time.Second, "classifier", "")
+	assert.Error(t, err)
+}
+
+func TestBackend_ModelGRPCInfer(t *testing.T) {
+	backend := genTestBackend(t, 8)
+	input := make([]float32, 16)
+	for idx := range input {
+		input[idx] = 1
+	}
+
+	raw := &triton_proto.ModelInferRequest{
+		ModelName: "classifier",
+		Inputs: []*triton_proto.ModelInferRequest_InferInputTensor{
+			{Name: "input", Datatype: "FP32", Shape: []int64{1, 1, 4, 4}},
+		},
+		RawInputContents: [][]byte{float32Bytes(input)},
+	}
+	response, err := backend.ModelGRPCInfer(time.Second, raw)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", response.GetModelVersion())
+	assert.Equal(t, "output", response.GetOutputs()[0].GetName())
+	assert.Equal(t, "FP32", response.GetOutputs()[0].GetDatatype())
+	assert.Equal(t, []int64{1, 3}, response.GetOutputs()[0].GetShape())
+	assert.Len(t, response.GetRawOutputContents()[0], 12)
+
+	// Typed contents give the same result as raw contents.
+	typed := &triton_proto.ModelInferRequest{
+		ModelName: "classifier",
+		Inputs: []*triton_proto.ModelInferRequest_InferInputTensor{
+			{
+				Name:     "input",
+				Datatype: "FP32",
+				Shape:    []int64{1, 1, 4, 4},
+				Contents: &triton_proto.InferTensorContents{Fp32Contents: input},
+			},
+		},
+	}
+	typedResponse, err := backend.ModelGRPCInfer(0, typed)
+	assert.NoError(t, err)
+	assert.Equal(t, response.GetRawOutputContents(), typedResponse.GetRawOutputContents())
+
+	raw.Outputs = []*triton_proto.ModelInferRequest_InferRequestedOutputTensor{{Name: "unknown"}}
+	_, err = backend.ModelGRPCInfer(time.Second, raw)
+	assert.Error(t, err)
+
+	raw.Outputs = nil
+	raw.RawInputContents = [][]byte{float32Bytes(input[:8])}
+	_, err = backend.ModelGRPCInfer(time.Second, raw)
+	assert.Error(t, err)
+
+	raw.ModelName = "missing"
+	_, err = backend.ModelGRPCInfer(time.Second, raw)
+	assert.Error(t, err)
+}
+
+func TestBackend_ModelGRPCInferContext(t *testing.T) {
+	backend := genTestBackend(t, 8)
+	content := float32Bytes(make([]float32, 16))
+	request := &triton_proto.ModelInferRequest{
+		ModelName: "classifier",
+		Inputs: []*triton_proto.ModelInferRequest_InferInputTensor{
+			{Name: "input", Datatype: "FP32", Shape: []int64{1, 1, 4, 4}},
+		},
+		RawInputContents: [][]byte{content},
+	}
+
+	// Cancelled calls stop before running the model.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := backend.ModelGRPCInferContext(ctx, time.Second, request)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// The inputs are copied out of the request, whose buffers the clients reuse after the call.
+	inputs, err := requestInputs(request)
+	assert.NoError(t, err)
+	for idx := range content {
+		content[idx] = 0xff
+	}
+	assert.Equal(t, make([]float32, 16), inputs["input"].Float32s)
+
+	response, err := backend.ModelGRPCInferContext(context.Background(), time.Second, request)
+	assert.NoError(t, err)
+	assert.Len(t, response.GetRawOutputContents(), 1)
+}
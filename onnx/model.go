@@ -0,0 +1,179 @@
+package onnx
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Model is an ONNX model prepared for inference. Its nodes run in graph order on CPU, the matrix products of
+// convolutions and dense layers through gorgonia tensors. A model is safe for concurrent use.
+type Model struct {
+	Name    string
+	Opset   int64
+	Inputs  []ValueInfo
+	Outputs []ValueInfo
+
+	nodes        []*Node
+	initializers map[string]*Tensor
+	// lastUse is the index of the last node reading each intermediate value, after which it is released.
+	lastUse map[string]int
+}
+
+// LoadModel loads an ONNX model from a file.
+func LoadModel(path string) (*Model, error) {
+	bModel, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseModel(bModel)
+}
+
+// ParseModel parses an ONNX model. Models with weights stored as external data, operators of other domains or
+// unsupported operators are rejected.
+func ParseModel(bModel []byte) (*Model, error) {
+	proto, err := parseModelProto(bModel)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &Model{
+		Name:         proto.Graph.Name,
+		Opset:        proto.OpsetVersion,
+		Outputs:      proto.Graph.Outputs,
+		nodes:        proto.Graph.Nodes,
+		initializers: make(map[string]*Tensor, len(proto.Graph.Initializers)),
+		lastUse:      map[string]int{},
+	}
+	for _, initializer := range proto.Graph.Initializers {
+		t, err := newTensor(initializer)
+		if err != nil {
+			return nil, err
+		}
+		model.initializers[initializer.Name] = t
+	}
+	// Before IR version 4, initializers are listed among the graph inputs.
+	for _, info := range proto.Graph.Inputs {
+		if _, ok := model.initializers[info.Name]; !ok {
+			model.Inputs = append(model.Inputs, info)
+		}
+	}
+
+	defined := map[string]bool{}
+	for name := range model.initializers {
+		defined[name] = true
+	}
+	for _, info := range model.Inputs {
+		defined[info.Name] = true
+	}
+	for idx, node := range model.nodes {
+		if node.Domain != "" && node.Domain != "ai.onnx" {
+			return nil, fmt.Errorf("node %q: unsupported domain %q", node.Name, node.Domain)
+		}
+		if _, ok := operators[node.OpType]; !ok {
+			return nil, fmt.Errorf("node %q: unsupported operator %s", node.Name, node.OpType)
+		}
+		for _, name := range node.Inputs {
+			if name == "" {
+				continue
+			}
+			if !defined[name] {
+				return nil, fmt.Errorf("node %q: input %q is not defined before use", node.Name, name)
+			}
+			if _, ok := model.initializers[name]; !ok {
+				model.lastUse[name] = idx
+			}
+		}
+		for _, name := range node.Outputs {
+			defined[name] = true
+		}
+	}
+	for _, info := range model.Outputs {
+		if !defined[info.Name] {
+			return nil, fmt.Errorf("output %q is not computed by the graph", info.Name)
+		}
+		model.lastUse[info.Name] = len(model.nodes)
+	}
+	return model, nil
+}
+
+// Run computes the outputs of the model from its inputs, keyed by name.
+func (m *Model) Run(inputs map[string]*Tensor) (map[string]*Tensor, error) {
+	return m.RunContext(context.Background(), inputs)
+}
+
+// RunContext is Run stopped between nodes once ctx is done, returning the error of ctx.
+func (m *Model) RunContext(ctx context.Context, inputs map[string]*Tensor) (map[string]*Tensor, error) {
+	values := make(map[string]*Tensor, len(inputs))
+	for _, info := range m.Inputs {
+		t, ok := inputs[info.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing input %q", info.Name)
+		}
+		if err := info.check(t); err != nil {
+			return nil, err
+		}
+		values[info.Name] = t
+	}
+
+	for idx, node := range m.nodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		args := make([]*Tensor, len(node.Inputs))
+		for j, name := range node.Inputs {
+			if name == "" {
+				continue
+			}
+			if t, ok := m.initializers[name]; ok {
+				args[j] = t
+			} else {
+				args[j] = values[name]
+			}
+		}
+
+		results, err := operators[node.OpType](node, args, m.Opset)
+		if err != nil {
+			return nil, fmt.Errorf("node %q (%s): %w", node.Name, node.OpType, err)
+		}
+		for j, name := range node.Outputs {
+			if name != "" && j < len(results) {
+				values[name] = results[j]
+			}
+		}
+		for _, name := range node.Inputs {
+			if last, ok := m.lastUse[name]; ok && last == idx {
+				delete(values, name)
+			}
+		}
+	}
+
+	outputs := make(map[string]*Tensor, len(m.Outputs))
+	for _, info := range m.Outputs {
+		t, ok := values[info.Name]
+		if !ok {
+			return nil, fmt.Errorf("output %q was not computed", info.Name)
+		}
+		outputs[info.Name] = t
+	}
+	return outputs, nil
+}
+
+// check verifies the type and the static dims of an input tensor.
+func (info ValueInfo) check(t *Tensor) error {
+	if (info.DataType == DataTypeFloat) != t.isFloat() {
+		return fmt.Errorf("input %q: unexpected data type", info.Name)
+	}
+	if info.Dims == nil {
+		return nil
+	}
+	if len(info.Dims) != len(t.Shape) {
+		return fmt.Errorf("input %q: shape %v does not match dims %v", info.Name, t.Shape, info.Dims)
+	}
+	for idx, dim := range info.Dims {
+		if dim >= 0 && int(dim) != t.Shape[idx] {
+			return fmt.Errorf("input %q: shape %v does not match dims %v", info.Name, t.Shape, info.Dims)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package onnx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// genTestClassifier returns a small classifier model: a 3x3 convolution, batch normalization and PRelu, global
+// average pooling, a flatten by shape computation, a dense layer and a softmax.
+func genTestClassifier(opset int64) []byte {
+	return genModel(opset, testGraph{
+		nodes: [][]byte{
+			genNode("Conv", []string{"input", "conv.weight", "conv.bias"}, []string{"conv"},
+				genAttrInts("kernel_shape", 3, 3), genAttrInts("pads", 1, 1, 1, 1)),
+			genNode("BatchNormalization", []string{"conv", "bn.scale", "bn.bias", "bn.mean", "bn.var"}, []string{"bn"}),
+			genNode("PRelu", []string{"bn", "prelu.slope"}, []string{"prelu"}),
+			genNode("GlobalAveragePool", []string{"prelu"}, []string{"pool"}),
+			genNode("Shape", []string{"pool"}, []string{"shape"}),
+			genNode("Gather", []string{"shape", "zero"}, []string{"batch"}, genAttrInt("axis", 0)),
+			genNode("Unsqueeze", []string{"batch"}, []string{"batch_1d"}, genAttrInts("axes", 0)),
+			genNode("Concat", []string{"batch_1d", "minus_one"}, []string{"target"}, genAttrInt("axis", 0)),
+			genNode("Reshape", []string{"pool", "target"}, []string{"flat"}),
+			genNode("Gemm", []string{"flat", "fc.weight", "fc.bias"}, []string{"logits"}, genAttrInt("transB", 1)),
+			genNode("Softmax", []string{"logits"}, []string{"output"}, genAttrInt("axis", 1)),
+		},
+		initializers: [][]byte{
+			genFloatTensor("conv.weight", []float32{
+				0, 0, 0, 0, 1, 0, 0, 0, 0, // identity
+				1, 1, 1, 1, 1, 1, 1, 1, 1, // box sum
+			}, 2, 1, 3, 3),
+			genFloatTensor("conv.bias", []float32{0, -1}, 2),
+			genFloatTensor("bn.scale", []float32{1, 1}, 2),
+			genFloatTensor("bn.bias", []float32{0, 0}, 2),
+			genFloatTensor("bn.mean", []float32{0, 0}, 2),
+			genFloatTensor("bn.var", []float32{1, 1}, 2),
+			genFloatTensor("prelu.slope", []float32{0.5, 0}, 2, 1, 1),
+			genInt64Tensor("zero", []int64{0}),
+			genInt64Tensor("minus_one", []int64{-1}, 1),
+			genFloatTensor("fc.weight", []float32{1, 0, 0, 1, 1, 1}, 3, 2),
+			genFloatTensor("fc.bias", []float32{0, 0, -100}, 3),
+		},
+		inputs: [][]byte{
+			genValueInfo("input", DataTypeFloat, -1, 1, 4, 4),
+			// Initializers listed among inputs, as before IR version 4.
+			genValueInfo("conv.weight", DataTypeFloat, 2, 1, 3, 3),
+		},
+		outputs: [][]byte{genValueInfo("output", DataTypeFloat, -1, 3)},
+	})
+}
+
+func TestParseModel(t *testing.T) {
+	model, err := ParseModel(genTestClassifier(11))
+	assert.NoError(t, err)
+	assert.Equal(t, "test_graph", model.Name)
+	assert.Equal(t, int64(11), model.Opset)
+	assert.Equal(t, []ValueInfo{{Name: "input", DataType: DataTypeFloat, Dims: []int64{-1, 1, 4, 4}}}, model.Inputs)
+	assert.Equal(t, "output", model.Outputs[0].Name)
+
+	unsupported := genModel(11, testGraph{
+		nodes:   [][]byte{genNode("NonMaxSuppression", []string{"input"}, []string{"output"})},
+		inputs:  [][]byte{genValueInfo("input", DataTypeFloat, 1)},
+		outputs: [][]byte{genValueInfo("output", DataTypeFloat, 1)},
+	})
+	_, err = ParseModel(unsupported)
+	assert.ErrorContains(t, err, "unsupported operator NonMaxSuppression")
+
+	undefined := genModel(11, testGraph{
+		nodes:   [][]byte{genNode("Relu", []string{"missing"}, []string{"output"})},
+		inputs:  [][]byte{genValueInfo("input", DataTypeFloat, 1)},
+		outputs: [][]byte{genValueInfo("output", DataTypeFloat, 1)},
+	})
+	_, err = ParseModel(undefined)
+	assert.Error(t, err)
+
+	_, err = ParseModel([]byte("not a model"))
+	assert.Error(t, err)
+}
+
+func TestLoadModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "classifier.onnx")
+	assert.NoError(t, os.WriteFile(path, genTestClassifier(11), 0o600))
+
+	model, err := LoadModel(path)
+	assert.NoError(t, err)
+	assert.Len(t, model.Inputs, 1)
+
+	_, err = LoadModel(filepath.Join(t.TempDir(), "missing.onnx"))
+	assert.Error(t, err)
+}
+
+func TestModel_Run(t *testing.T) {
+	model, err := ParseModel(genTestClassifier(11))
+	assert.NoError(t, err)
+
+	// The first sample is zero, the second is one everywhere.
+	values := make([]float32, 2*16)
+	for idx := 16; idx < 32; idx++ {
+		values[idx] = 1
+	}
+	input, err := NewFloat32Tensor(values, 2, 1, 4, 4)
+	assert.NoError(t, err)
+
+	outputs, err := model.Run(map[string]*Tensor{"input": input})
+	assert.NoError(t, err)
+	output := outputs["output"]
+	assert.Equal(t, []int{2, 3}, output.Shape)
+
+	// Sample 0: identity 0, box sum -1 after the bias and 0 after PRelu, so equal scores for the first two classes.
+	assert.InDeltaSlice(t, []float32{0.5, 0.5, 0}, output.Float32s[:3], 1e-6)
+	// Sample 1: identity 1 and box sum averaged over the padded borders (4+6+6+9)*4/16 - 1 = 5.25, class 1 wins.
+	assert.Greater(t, output.Float32s[4], output.Float32s[3])
+	assert.InDelta(t, 1, output.Float32s[3]+output.Float32s[4]+output.Float32s[5], 1e-6)
+
+	// Intermediate values are released, the result of a second run is the same.
+	again, err := model.Run(map[string]*Tensor{"input": input})
+	assert.NoError(t, err)
+	assert.Equal(t, output.Float32s, again["output"].Float32s)
+
+	_, err = model.Run(map[string]*Tensor{})
+	assert.Error(t, err)
+	wrong, err := NewFloat32Tensor(make([]float32, 9), 1, 1, 3, 3)
+	assert.NoError(t, err)
+	_, err = model.Run(map[string]*Tensor{"input": wrong})
+	assert.Error(t, err)
+}
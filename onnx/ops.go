@@ -0,0 +1,985 @@
+package onnx
+
+import (
+	"errors"
+	"fmt"
+	"gorgonia.org/tensor"
+	"math"
+	"slices"
+)
+
+// operator computes the outputs of a node from its inputs. Missing optional inputs are nil. Operators do not modify
+// their inputs, outputs may share the values of an input.
+type operator func(node *Node, inputs []*Tensor, opset int64) ([]*Tensor, error)
+
+// operators are the supported operators of the default ONNX domain.
+var operators = map[string]operator{
+	"Add":                binaryOperator(func(x, y float32) float32 { return x + y }, func(x, y int64) int64 { return x + y }),
+	"AveragePool":        averagePool,
+	"BatchNormalization": batchNormalization,
+	"Cast":               cast,
+	"Clip":               clip,
+	"Concat":             concat,
+	"Constant":           constant,
+	"Conv":               conv,
+	"Div":                binaryOperator(func(x, y float32) float32 { return x / y }, func(x, y int64) int64 { return x / y }),
+	"Dropout":            identity,
+	"Flatten":            flatten,
+	"Gather":             gather,
+	"Gemm":               gemm,
+	"GlobalAveragePool":  globalAveragePool,
+	"Identity":           identity,
+	"LeakyRelu":          leakyRelu,
+	"MatMul":             matMulOperator,
+	"MaxPool":            maxPool,
+	"Mul":                binaryOperator(func(x, y float32) float32 { return x * y }, func(x, y int64) int64 { return x * y }),
+	"PRelu":              pRelu,
+	"Relu":               unaryOperator(func(x float32) float32 { return max(x, 0) }),
+	"Reshape":            reshape,
+	"Shape":              shape,
+	"Sigmoid":            unaryOperator(func(x float32) float32 { return float32(1 / (1 + math.Exp(float64(-x)))) }),
+	"Softmax":            softmax,
+	"Squeeze":            squeeze,
+	"Sub":                binaryOperator(func(x, y float32) float32 { return x - y }, func(x, y int64) int64 { return x - y }),
+	"Tanh":               unaryOperator(func(x float32) float32 { return float32(math.Tanh(float64(x))) }),
+	"Transpose":          transpose,
+	"Unsqueeze":          unsqueeze,
+}
+
+func (n *Node) attrInt(name string, def int64) int64 {
+	if attr, ok := n.Attributes[name]; ok {
+		return attr.I
+	}
+	return def
+}
+
+func (n *Node) attrFloat(name string, def float32) float32 {
+	if attr, ok := n.Attributes[name]; ok {
+		return attr.F
+	}
+	return def
+}
+
+func (n *Node) attrInts(name string, def []int64) []int64 {
+	if attr, ok := n.Attributes[name]; ok {
+		return attr.Ints
+	}
+	return def
+}
+
+func (n *Node) attrString(name string, def string) string {
+	if attr, ok := n.Attributes[name]; ok {
+		return string(attr.S)
+	}
+	return def
+}
+
+// input returns the input idx of a node, or nil if the optional input is missing.
+func input(inputs []*Tensor, idx int) *Tensor {
+	if idx < len(inputs) {
+		return inputs[idx]
+	}
+	return nil
+}
+
+// normalizeAxis maps a negative axis to its positive index for a tensor of the given rank.
+func normalizeAxis(axis int64, rank int) (int, error) {
+	if axis < 0 {
+		axis += int64(rank)
+	}
+	if axis < 0 || axis >= int64(rank) {
+		return 0, fmt.Errorf("axis %d out of range for rank %d", axis, rank)
+	}
+	return int(axis), nil
+}
+
+func identity(_ *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	return []*Tensor{inputs[0]}, nil
+}
+
+func unaryOperator(fn func(x float32) float32) operator {
+	return func(_ *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+		x := inputs[0]
+		if !x.isFloat() {
+			return nil, errors.New("input must be float")
+		}
+		values := make([]float32, len(x.Float32s))
+		for idx, v := range x.Float32s {
+			values[idx] = fn(v)
+		}
+		return []*Tensor{{Shape: x.Shape, DataType: DataTypeFloat, Float32s: values}}, nil
+	}
+}
+
+func leakyRelu(node *Node, inputs []*Tensor, opset int64) ([]*Tensor, error) {
+	alpha := node.attrFloat("alpha", 0.01)
+	return unaryOperator(func(x float32) float32 {
+		if x < 0 {
+			return alpha * x
+		}
+		return x
+	})(node, inputs, opset)
+}
+
+func clip(node *Node, inputs []*Tensor, opset int64) ([]*Tensor, error) {
+	low, high := float32(math.Inf(-1)), float32(math.Inf(1))
+	if opset < 11 {
+		low, high = node.attrFloat("min", low), node.attrFloat("max", high)
+	} else {
+		if t := input(inputs, 1); t != nil {
+			low = t.float32s()[0]
+		}
+		if t := input(inputs, 2); t != nil {
+			high = t.float32s()[0]
+		}
+	}
+	return unaryOperator(func(x float32) float32 {
+		return min(max(x, low), high)
+	})(node, inputs, opset)
+}
+
+// broadcastShape returns the shape of the multidirectional broadcast of a and b.
+func broadcastShape(a, b []int) ([]int, error) {
+	rank := max(len(a), len(b))
+	out := make([]int, rank)
+	for idx := range out {
+		da, db := 1, 1
+		if j := idx - rank + len(a); j >= 0 {
+			da = a[j]
+		}
+		if j := idx - rank + len(b); j >= 0 {
+			db = b[j]
+		}
+		switch {
+		case da == db || db == 1:
+			out[idx] = da
+		case da == 1:
+			out[idx] = db
+		default:
+			return nil, fmt.Errorf("shapes %v and %v cannot be broadcast", a, b)
+		}
+	}
+	return out, nil
+}
+
+// broadcastStrides returns the strides of a tensor of the given shape broadcast to out, zero along broadcast dims.
+func broadcastStrides(shape, out []int) []int {
+	strides := make([]int, len(out))
+	stride := 1
+	for idx := len(shape) - 1; idx >= 0; idx-- {
+		if shape[idx] != 1 {
+			strides[idx+len(out)-len(shape)] = stride
+		}
+		stride *= shape[idx]
+	}
+	return strides
+}
+
+func broadcast[T float32 | int64](a, b []T, aShape, bShape, outShape []int, fn func(x, y T) T) []T {
+	out := make([]T, shapeSize(outShape))
+	if shapeEqual(aShape, bShape) {
+		for idx := range out {
+			out[idx] = fn(a[idx], b[idx])
+		}
+		return out
+	}
+	if len(b) == 1 {
+		for idx := range out {
+			out[idx] = fn(a[idx], b[0])
+		}
+		return out
+	}
+
+	aStrides, bStrides := broadcastStrides(aShape, outShape), broadcastStrides(bShape, outShape)
+	counter := make([]int, len(outShape))
+	ai, bi := 0, 0
+	for idx := range out {
+		out[idx] = fn(a[ai], b[bi])
+		for dim := len(outShape) - 1; dim >= 0; dim-- {
+			counter[dim]++
+			ai += aStrides[dim]
+			bi += bStrides[dim]
+			if counter[dim] < outShape[dim] {
+				break
+			}
+			ai -= aStrides[dim] * outShape[dim]
+			bi -= bStrides[dim] * outShape[dim]
+			counter[dim] = 0
+		}
+	}
+	return out
+}
+
+func binaryOperator(f32 func(x, y float32) float32, i64 func(x, y int64) int64) operator {
+	return func(_ *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+		a, b := inputs[0], inputs[1]
+		outShape, err := broadcastShape(a.Shape, b.Shape)
+		if err != nil {
+			return nil, err
+		}
+		if a.isFloat() || b.isFloat() {
+			values := broadcast(a.float32s(), b.float32s(), a.Shape, b.Shape, outShape, f32)
+			return []*Tensor{{Shape: outShape, DataType: DataTypeFloat, Float32s: values}}, nil
+		}
+		values := broadcast(a.Int64s, b.Int64s, a.Shape, b.Shape, outShape, i64)
+		return []*Tensor{{Shape: outShape, DataType: DataTypeInt64, Int64s: values}}, nil
+	}
+}
+
+func pRelu(_ *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x, slope := inputs[0], inputs[1]
+	if !x.isFloat() || !slope.isFloat() {
+		return nil, errors.New("inputs must be float")
+	}
+	outShape, err := broadcastShape(x.Shape, slope.Shape)
+	if err != nil {
+		return nil, err
+	}
+	values := broadcast(x.Float32s, slope.Float32s, x.Shape, slope.Shape, outShape, func(x, s float32) float32 {
+		if x < 0 {
+			return s * x
+		}
+		return x
+	})
+	return []*Tensor{{Shape: outShape, DataType: DataTypeFloat, Float32s: values}}, nil
+}
+
+func batchNormalization(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x := inputs[0]
+	if len(inputs) < 5 || len(x.Shape) < 2 {
+		return nil, errors.New("expected input, scale, bias, mean and variance")
+	}
+	scale, bias, mean, variance := inputs[1].Float32s, inputs[2].Float32s, inputs[3].Float32s, inputs[4].Float32s
+	channels := x.Shape[1]
+	if len(scale) != channels || len(bias) != channels || len(mean) != channels || len(variance) != channels {
+		return nil, fmt.Errorf("parameters do not match %d channels", channels)
+	}
+	epsilon := node.attrFloat("epsilon", 1e-5)
+
+	// y = x * a + b per channel
+	a, b := make([]float32, channels), make([]float32, channels)
+	for c := range channels {
+		a[c] = scale[c] / float32(math.Sqrt(float64(variance[c]+epsilon)))
+		b[c] = bias[c] - mean[c]*a[c]
+	}
+
+	inner := shapeSize(x.Shape[2:])
+	values := make([]float32, len(x.Float32s))
+	for idx := 0; idx < len(values); idx += inner {
+		c := (idx / inner) % channels
+		for j := idx; j < idx+inner; j++ {
+			values[j] = x.Float32s[j]*a[c] + b[c]
+		}
+	}
+	return []*Tensor{{Shape: x.Shape, DataType: DataTypeFloat, Float32s: values}}, nil
+}
+
+// window is the sliding window of a 2D convolution or pooling.
+type window struct {
+	kernelH, kernelW     int
+	strideH, strideW     int
+	dilationH, dilationW int
+	padTop, padLeft      int
+	outH, outW           int
+}
+
+// newWindow returns the window of a node over an input of height h and width w.
+func newWindow(node *Node, h, w, kernelH, kernelW int) (window, error) {
+	strides := node.attrInts("strides", []int64{1, 1})
+	dilations := node.attrInts("dilations", []int64{1, 1})
+	pads := node.attrInts("pads", []int64{0, 0, 0, 0})
+	if len(strides) != 2 || len(dilations) != 2 || len(pads) != 4 {
+		return window{}, errors.New("only 2d windows are supported")
+	}
+
+	win := window{
+		kernelH:   kernelH,
+		kernelW:   kernelW,
+		strideH:   int(strides[0]),
+		strideW:   int(strides[1]),
+		dilationH: int(dilations[0]),
+		dilationW: int(dilations[1]),
+		padTop:    int(pads[0]),
+		padLeft:   int(pads[1]),
+	}
+	if win.strideH <= 0 || win.strideW <= 0 || win.dilationH <= 0 || win.dilationW <= 0 {
+		return window{}, errors.New("strides and dilations must be positive")
+	}
+	padBottom, padRight := int(pads[2]), int(pads[3])
+	extentH := (kernelH-1)*win.dilationH + 1
+	extentW := (kernelW-1)*win.dilationW + 1
+
+	switch autoPad := node.attrString("auto_pad", "NOTSET"); autoPad {
+	case "NOTSET":
+	case "VALID":
+		win.padTop, win.padLeft, padBottom, padRight = 0, 0, 0, 0
+	case "SAME_UPPER", "SAME_LOWER":
+		totalH := max(((h+win.strideH-1)/win.strideH-1)*win.strideH+extentH-h, 0)
+		totalW := max(((w+win.strideW-1)/win.strideW-1)*win.strideW+extentW-w, 0)
+		win.padTop, win.padLeft = totalH/2, totalW/2
+		if autoPad == "SAME_LOWER" {
+			win.padTop, win.padLeft = totalH-totalH/2, totalW-totalW/2
+		}
+		padBottom, padRight = totalH-win.padTop, totalW-win.padLeft
+	default:
+		return window{}, fmt.Errorf("unsupported auto_pad %q", autoPad)
+	}
+
+	spanH, spanW := h+win.padTop+padBottom-extentH, w+win.padLeft+padRight-extentW
+	if spanH < 0 || spanW < 0 {
+		return window{}, fmt.Errorf("window %dx%d does not fit input %dx%d", kernelH, kernelW, h, w)
+	}
+	if node.attrInt("ceil_mode", 0) == 1 {
+		win.outH, win.outW = (spanH+win.strideH-1)/win.strideH+1, (spanW+win.strideW-1)/win.strideW+1
+	} else {
+		win.outH, win.outW = spanH/win.strideH+1, spanW/win.strideW+1
+	}
+	return win, nil
+}
+
+// im2col unrolls the windows of a CHW image into a (C*kH*kW, outH*outW) matrix.
+func (win window) im2col(img []float32, channels, h, w int, dst []float32) {
+	cols := win.outH * win.outW
+	row := 0
+	for c := range channels {
+		plane := img[c*h*w : (c+1)*h*w]
+		for kh := range win.kernelH {
+			for kw := range win.kernelW {
+				out := dst[row*cols : (row+1)*cols]
+				for oh := range win.outH {
+					ih := oh*win.strideH - win.padTop + kh*win.dilationH
+					for ow := range win.outW {
+						iw := ow*win.strideW - win.padLeft + kw*win.dilationW
+						if ih < 0 || ih >= h || iw < 0 || iw >= w {
+							out[oh*win.outW+ow] = 0
+						} else {
+							out[oh*win.outW+ow] = plane[ih*w+iw]
+						}
+					}
+				}
+				row++
+			}
+		}
+	}
+}
+
+// matMul computes the (m, n) product of the (m, k) matrix a and the (k, n) matrix b into dst.
+func matMul(a []float32, b []float32, dst []float32, m, k, n int) error {
+	ta := tensor.New(tensor.WithShape(m, k), tensor.WithBacking(a[:m*k]))
+	tb := tensor.New(tensor.WithShape(k, n), tensor.WithBacking(b[:k*n]))
+	tc := tensor.New(tensor.WithShape(m, n), tensor.WithBacking(dst[:m*n]))
+	_, err := tensor.MatMul(ta, tb, tensor.WithReuse(tc))
+	return err
+}
+
+func conv(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x, weights, bias := inputs[0], inputs[1], input(inputs, 2)
+	if len(x.Shape) != 4 || len(weights.Shape) != 4 {
+		return nil, errors.New("only 2d convolutions are supported")
+	}
+	n, c, h, w := x.Shape[0], x.Shape[1], x.Shape[2], x.Shape[3]
+	m, groupC, kernelH, kernelW := weights.Shape[0], weights.Shape[1], weights.Shape[2], weights.Shape[3]
+	group := int(node.attrInt("group", 1))
+	if group <= 0 || c != groupC*group || m%group != 0 {
+		return nil, fmt.Errorf("weights %v do not match input %v in %d groups", weights.Shape, x.Shape, group)
+	}
+	if bias != nil && len(bias.Float32s) != m {
+		return nil, fmt.Errorf("bias %v does not match %d output channels", bias.Shape, m)
+	}
+	win, err := newWindow(node, h, w, kernelH, kernelW)
+	if err != nil {
+		return nil, err
+	}
+
+	outPlane := win.outH * win.outW
+	values := make([]float32, n*m*outPlane)
+	groupM := m / group
+	kernelSize := groupC * kernelH * kernelW
+	pointwise := kernelH == 1 && kernelW == 1 && win.strideH == 1 && win.strideW == 1 && win.padTop == 0 &&
+		win.padLeft == 0 && win.outH == h && win.outW == w
+
+	var cols []float32
+	if !pointwise && groupC > 1 {
+		cols = make([]float32, kernelSize*outPlane)
+	}
+	for b := range n {
+		for g := range group {
+			img := x.Float32s[(b*c+g*groupC)*h*w : (b*c+(g+1)*groupC)*h*w]
+			kernel := weights.Float32s[g*groupM*kernelSize : (g+1)*groupM*kernelSize]
+			out := values[(b*m+g*groupM)*outPlane : (b*m+(g+1)*groupM)*outPlane]
+			switch {
+			case pointwise:
+				err = matMul(kernel, img, out, groupM, kernelSize, outPlane)
+			case groupC == 1:
+				// Depthwise windows are computed directly, a product with a single row is not worth the unrolling.
+				win.depthwise(img, h, w, kernel, groupM, out)
+			default:
+				win.im2col(img, groupC, h, w, cols)
+				err = matMul(kernel, cols, out, groupM, kernelSize, outPlane)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if bias != nil {
+		for idx := 0; idx < len(values); idx += outPlane {
+			v := bias.Float32s[(idx/outPlane)%m]
+			for j := idx; j < idx+outPlane; j++ {
+				values[j] += v
+			}
+		}
+	}
+	return []*Tensor{{Shape: []int{n, m, win.outH, win.outW}, DataType: DataTypeFloat, Float32s: values}}, nil
+}
+
+// depthwise convolves a single-channel image with m kernels.
+func (win window) depthwise(img []float32, h, w int, kernels []float32, m int, dst []float32) {
+	kernelSize := win.kernelH * win.kernelW
+	outPlane := win.outH * win.outW
+	for k := range m {
+		kernel := kernels[k*kernelSize : (k+1)*kernelSize]
+		out := dst[k*outPlane : (k+1)*outPlane]
+		for oh := range win.outH {
+			for ow := range win.outW {
+				var sum float32
+				for kh := range win.kernelH {
+					ih := oh*win.strideH - win.padTop + kh*win.dilationH
+					if ih < 0 || ih >= h {
+						continue
+					}
+					for kw := range win.kernelW {
+						iw := ow*win.strideW - win.padLeft + kw*win.dilationW
+						if iw < 0 || iw >= w {
+							continue
+						}
+						sum += img[ih*w+iw] * kernel[kh*win.kernelW+kw]
+					}
+				}
+				out[oh*win.outW+ow] = sum
+			}
+		}
+	}
+}
+
+// pool reduces the windows of each NCHW plane of x.
+func pool(node *Node, x *Tensor, init float32, reduce func(acc, v float32) float32, finish func(acc float32, count, area int) float32) ([]*Tensor, error) {
+	if len(x.Shape) != 4 {
+		return nil, errors.New("only 2d pooling is supported")
+	}
+	kernel := node.attrInts("kernel_shape", nil)
+	if len(kernel) != 2 {
+		return nil, errors.New("kernel_shape must have 2 dims")
+	}
+	if node.attrInt("storage_order", 0) != 0 {
+		return nil, errors.New("only row major storage is supported")
+	}
+	planes, h, w := x.Shape[0]*x.Shape[1], x.Shape[2], x.Shape[3]
+	win, err := newWindow(node, h, w, int(kernel[0]), int(kernel[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	outPlane := win.outH * win.outW
+	area := win.kernelH * win.kernelW
+	values := make([]float32, planes*outPlane)
+	for p := range planes {
+		plane := x.Float32s[p*h*w : (p+1)*h*w]
+		out := values[p*outPlane : (p+1)*outPlane]
+		for oh := range win.outH {
+			for ow := range win.outW {
+				acc, count := init, 0
+				for kh := range win.kernelH {
+					ih := oh*win.strideH - win.padTop + kh*win.dilationH
+					if ih < 0 || ih >= h {
+						continue
+					}
+					for kw := range win.kernelW {
+						iw := ow*win.strideW - win.padLeft + kw*win.dilationW
+						if iw < 0 || iw >= w {
+							continue
+						}
+						acc = reduce(acc, plane[ih*w+iw])
+						count++
+					}
+				}
+				out[oh*win.outW+ow] = finish(acc, count, area)
+			}
+		}
+	}
+	return []*Tensor{{Shape: []int{x.Shape[0], x.Shape[1], win.outH, win.outW}, DataType: DataTypeFloat, Float32s: values}}, nil
+}
+
+func maxPool(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	if len(node.Outputs) > 1 && node.Outputs[1] != "" {
+		return nil, errors.New("indices output is not supported")
+	}
+	return pool(node, inputs[0], float32(math.Inf(-1)), func(acc, v float32) float32 {
+		return max(acc, v)
+	}, func(acc float32, _, _ int) float32 {
+		return acc
+	})
+}
+
+func averagePool(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	includePad := node.attrInt("count_include_pad", 0) == 1
+	return pool(node, inputs[0], 0, func(acc, v float32) float32 {
+		return acc + v
+	}, func(acc float32, count, area int) float32 {
+		if includePad {
+			return acc / float32(area)
+		}
+		return acc / float32(max(count, 1))
+	})
+}
+
+func globalAveragePool(_ *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x := inputs[0]
+	if len(x.Shape) < 3 {
+		return nil, errors.New("input must have spatial dims")
+	}
+	planes, area := x.Shape[0]*x.Shape[1], shapeSize(x.Shape[2:])
+	values := make([]float32, planes)
+	for p := range planes {
+		var sum float32
+		for _, v := range x.Float32s[p*area : (p+1)*area] {
+			sum += v
+		}
+		values[p] = sum / float32(area)
+	}
+	outShape := []int{x.Shape[0], x.Shape[1]}
+	for range x.Shape[2:] {
+		outShape = append(outShape, 1)
+	}
+	return []*Tensor{{Shape: outShape, DataType: DataTypeFloat, Float32s: values}}, nil
+}
+
+// transpose2d returns the transpose of the (rows, cols) matrix values.
+func transpose2d(values []float32, rows, cols int) []float32 {
+	out := make([]float32, len(values))
+	for r := range rows {
+		for c := range cols {
+			out[c*rows+r] = values[r*cols+c]
+		}
+	}
+	return out
+}
+
+func gemm(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	a, b, c := inputs[0], inputs[1], input(inputs, 2)
+	if len(a.Shape) != 2 || len(b.Shape) != 2 {
+		return nil, errors.New("inputs must be matrices")
+	}
+	av, bv := a.Float32s, b.Float32s
+	m, k := a.Shape[0], a.Shape[1]
+	if node.attrInt("transA", 0) == 1 {
+		av, m, k = transpose2d(av, m, k), k, m
+	}
+	kb, n := b.Shape[0], b.Shape[1]
+	if node.attrInt("transB", 0) == 1 {
+		bv, kb, n = transpose2d(bv, kb, n), n, kb
+	}
+	if k != kb {
+		return nil, fmt.Errorf("shapes %v and %v cannot be multiplied", a.Shape, b.Shape)
+	}
+
+	values := make([]float32, m*n)
+	if err := matMul(av, bv, values, m, k, n); err != nil {
+		return nil, err
+	}
+	alpha, beta := node.attrFloat("alpha", 1), node.attrFloat("beta", 1)
+	if alpha != 1 {
+		for idx := range values {
+			values[idx] *= alpha
+		}
+	}
+	if c != nil {
+		outShape := []int{m, n}
+		if _, err := broadcastShape(outShape, c.Shape); err != nil {
+			return nil, err
+		}
+		values = broadcast(values, c.Float32s, outShape, c.Shape, outShape, func(x, y float32) float32 {
+			return x + beta*y
+		})
+	}
+	return []*Tensor{{Shape: []int{m, n}, DataType: DataTypeFloat, Float32s: values}}, nil
+}
+
+func matMulOperator(_ *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	a, b := inputs[0], inputs[1]
+	if len(a.Shape) < 2 || len(b.Shape) < 2 {
+		return nil, errors.New("inputs must have at least 2 dims")
+	}
+	m, k := a.Shape[len(a.Shape)-2], a.Shape[len(a.Shape)-1]
+	kb, n := b.Shape[len(b.Shape)-2], b.Shape[len(b.Shape)-1]
+	if k != kb {
+		return nil, fmt.Errorf("shapes %v and %v cannot be multiplied", a.Shape, b.Shape)
+	}
+
+	switch {
+	case len(b.Shape) == 2:
+		// Leading dims of a are folded into the rows of a single product.
+		rows := a.Size() / k
+		values := make([]float32, rows*n)
+		if err := matMul(a.Float32s, b.Float32s, values, rows, k, n); err != nil {
+			return nil, err
+		}
+		outShape := append(slices.Clone(a.Shape[:len(a.Shape)-1]), n)
+		return []*Tensor{{Shape: outShape, DataType: DataTypeFloat, Float32s: values}}, nil
+	case shapeEqual(a.Shape[:len(a.Shape)-2], b.Shape[:len(b.Shape)-2]):
+		batches := shapeSize(a.Shape[:len(a.Shape)-2])
+		values := make([]float32, batches*m*n)
+		for idx := range batches {
+			err := matMul(a.Float32s[idx*m*k:], b.Float32s[idx*k*n:], values[idx*m*n:], m, k, n)
+			if err != nil {
+				return nil, err
+			}
+		}
+		outShape := append(slices.Clone(a.Shape[:len(a.Shape)-1]), n)
+		return []*Tensor{{Shape: outShape, DataType: DataTypeFloat, Float32s: values}}, nil
+	default:
+		return nil, fmt.Errorf("batch dims of %v and %v differ", a.Shape, b.Shape)
+	}
+}
+
+func softmax(node *Node, inputs []*Tensor, opset int64) ([]*Tensor, error) {
+	x := inputs[0]
+	defaultAxis := int64(-1)
+	if opset < 13 {
+		defaultAxis = 1
+	}
+	axis, err := normalizeAxis(node.attrInt("axis", defaultAxis), len(x.Shape))
+	if err != nil {
+		return nil, err
+	}
+
+	// Before opset 13 the input is coerced to a matrix at axis, later the softmax runs along axis only.
+	outer, dim, inner := shapeSize(x.Shape[:axis]), shapeSize(x.Shape[axis:]), 1
+	if opset >= 13 {
+		dim, inner = x.Shape[axis], shapeSize(x.Shape[axis+1:])
+	}
+
+	values := make([]float32, len(x.Float32s))
+	for o := range outer {
+		for i := range inner {
+			base := o*dim*inner + i
+			maxValue := float32(math.Inf(-1))
+			for d := range dim {
+				maxValue = max(maxValue, x.Float32s[base+d*inner])
+			}
+			var sum float32
+			for d := range dim {
+				v := float32(math.Exp(float64(x.Float32s[base+d*inner] - maxValue)))
+				values[base+d*inner] = v
+				sum += v
+			}
+			for d := range dim {
+				values[base+d*inner] /= sum
+			}
+		}
+	}
+	return []*Tensor{{Shape: x.Shape, DataType: DataTypeFloat, Float32s: values}}, nil
+}
+
+func flatten(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x := inputs[0]
+	axis := node.attrInt("axis", 1)
+	if axis < 0 {
+		axis += int64(len(x.Shape))
+	}
+	if axis < 0 || axis > int64(len(x.Shape)) {
+		return nil, fmt.Errorf("axis %d out of range for rank %d", axis, len(x.Shape))
+	}
+	return []*Tensor{x.reshape([]int{shapeSize(x.Shape[:axis]), shapeSize(x.Shape[axis:])})}, nil
+}
+
+func reshape(_ *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x, target := inputs[0], inputs[1].int64s()
+	outShape := make([]int, len(target))
+	inferred := -1
+	known := 1
+	for idx, dim := range target {
+		switch {
+		case dim == 0 && idx < len(x.Shape):
+			outShape[idx] = x.Shape[idx]
+		case dim == -1 && inferred < 0:
+			inferred = idx
+			continue
+		case dim > 0:
+			outShape[idx] = int(dim)
+		default:
+			return nil, fmt.Errorf("invalid shape %v", target)
+		}
+		known *= outShape[idx]
+	}
+	if inferred >= 0 && known > 0 {
+		outShape[inferred] = x.Size() / known
+	}
+	if shapeSize(outShape) != x.Size() {
+		return nil, fmt.Errorf("cannot reshape %v to %v", x.Shape, target)
+	}
+	return []*Tensor{x.reshape(outShape)}, nil
+}
+
+// axesInput returns the axes of Squeeze and Unsqueeze, an attribute before opset 13 and an input since.
+func axesInput(node *Node, inputs []*Tensor, opset int64) []int64 {
+	if opset < 13 {
+		return node.attrInts("axes", nil)
+	}
+	if t := input(inputs, 1); t != nil {
+		return t.int64s()
+	}
+	return nil
+}
+
+func squeeze(node *Node, inputs []*Tensor, opset int64) ([]*Tensor, error) {
+	x := inputs[0]
+	squeezed := make([]bool, len(x.Shape))
+	axes := axesInput(node, inputs, opset)
+	for _, axis := range axes {
+		idx, err := normalizeAxis(axis, len(x.Shape))
+		if err != nil {
+			return nil, err
+		}
+		if x.Shape[idx] != 1 {
+			return nil, fmt.Errorf("cannot squeeze axis %d of shape %v", axis, x.Shape)
+		}
+		squeezed[idx] = true
+	}
+
+	outShape := []int{}
+	for idx, dim := range x.Shape {
+		if squeezed[idx] || (len(axes) == 0 && dim == 1) {
+			continue
+		}
+		outShape = append(outShape, dim)
+	}
+	return []*Tensor{x.reshape(outShape)}, nil
+}
+
+func unsqueeze(node *Node, inputs []*Tensor, opset int64) ([]*Tensor, error) {
+	x := inputs[0]
+	axes := axesInput(node, inputs, opset)
+	rank := len(x.Shape) + len(axes)
+	inserted := make([]bool, rank)
+	for _, axis := range axes {
+		idx, err := normalizeAxis(axis, rank)
+		if err != nil {
+			return nil, err
+		}
+		inserted[idx] = true
+	}
+
+	outShape := make([]int, 0, rank)
+	next := 0
+	for idx := range rank {
+		if inserted[idx] {
+			outShape = append(outShape, 1)
+			continue
+		}
+		outShape = append(outShape, x.Shape[next])
+		next++
+	}
+	return []*Tensor{x.reshape(outShape)}, nil
+}
+
+func transposeValues[T float32 | int64](values []T, shape, perm []int) []T {
+	strides := make([]int, len(shape))
+	stride := 1
+	for idx := len(shape) - 1; idx >= 0; idx-- {
+		strides[idx] = stride
+		stride *= shape[idx]
+	}
+	outShape := make([]int, len(perm))
+	permStrides := make([]int, len(perm))
+	for idx, p := range perm {
+		outShape[idx] = shape[p]
+		permStrides[idx] = strides[p]
+	}
+
+	out := make([]T, len(values))
+	counter := make([]int, len(outShape))
+	src := 0
+	for idx := range out {
+		out[idx] = values[src]
+		for dim := len(outShape) - 1; dim >= 0; dim-- {
+			counter[dim]++
+			src += permStrides[dim]
+			if counter[dim] < outShape[dim] {
+				break
+			}
+			src -= permStrides[dim] * outShape[dim]
+			counter[dim] = 0
+		}
+	}
+	return out
+}
+
+func transpose(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x := inputs[0]
+	perm := make([]int, len(x.Shape))
+	for idx := range perm {
+		perm[idx] = len(perm) - 1 - idx
+	}
+	if attr := node.attrInts("perm", nil); attr != nil {
+		if len(attr) != len(x.Shape) {
+			return nil, fmt.Errorf("perm %v does not match rank %d", attr, len(x.Shape))
+		}
+		for idx, p := range attr {
+			perm[idx] = int(p)
+		}
+	}
+
+	outShape := make([]int, len(perm))
+	for idx, p := range perm {
+		outShape[idx] = x.Shape[p]
+	}
+	out := &Tensor{Shape: outShape, DataType: x.DataType}
+	if x.isFloat() {
+		out.Float32s = transposeValues(x.Float32s, x.Shape, perm)
+	} else {
+		out.Int64s = transposeValues(x.Int64s, x.Shape, perm)
+	}
+	return []*Tensor{out}, nil
+}
+
+func concatValues[T float32 | int64](values [][]T, blocks []int, outer, size int) []T {
+	out := make([]T, 0, size)
+	for o := range outer {
+		for idx, v := range values {
+			out = append(out, v[o*blocks[idx]:(o+1)*blocks[idx]]...)
+		}
+	}
+	return out
+}
+
+func concat(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	first := inputs[0]
+	axis, err := normalizeAxis(node.attrInt("axis", 0), len(first.Shape))
+	if err != nil {
+		return nil, err
+	}
+
+	outShape := slices.Clone(first.Shape)
+	outShape[axis] = 0
+	blocks := make([]int, len(inputs))
+	for idx, t := range inputs {
+		if len(t.Shape) != len(first.Shape) || t.DataType != first.DataType {
+			return nil, errors.New("inputs must have the same rank and type")
+		}
+		outShape[axis] += t.Shape[axis]
+		blocks[idx] = shapeSize(t.Shape[axis:])
+	}
+	outer := shapeSize(first.Shape[:axis])
+
+	out := &Tensor{Shape: outShape, DataType: first.DataType}
+	if first.isFloat() {
+		values := make([][]float32, len(inputs))
+		for idx, t := range inputs {
+			values[idx] = t.Float32s
+		}
+		out.Float32s = concatValues(values, blocks, outer, shapeSize(outShape))
+	} else {
+		values := make([][]int64, len(inputs))
+		for idx, t := range inputs {
+			values[idx] = t.Int64s
+		}
+		out.Int64s = concatValues(values, blocks, outer, shapeSize(outShape))
+	}
+	return []*Tensor{out}, nil
+}
+
+func gather(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	data, indices := inputs[0], inputs[1]
+	axis, err := normalizeAxis(node.attrInt("axis", 0), len(data.Shape))
+	if err != nil {
+		return nil, err
+	}
+	dim := data.Shape[axis]
+	positions := make([]int, 0, indices.Size())
+	for _, idx := range indices.int64s() {
+		if idx < 0 {
+			idx += int64(dim)
+		}
+		if idx < 0 || idx >= int64(dim) {
+			return nil, fmt.Errorf("index %d out of range for dim %d", idx, dim)
+		}
+		positions = append(positions, int(idx))
+	}
+
+	outShape := append(append(slices.Clone(data.Shape[:axis]), indices.Shape...), data.Shape[axis+1:]...)
+	outer, inner := shapeSize(data.Shape[:axis]), shapeSize(data.Shape[axis+1:])
+	out := &Tensor{Shape: outShape, DataType: data.DataType}
+	if data.isFloat() {
+		out.Float32s = gatherBlocks(data.Float32s, positions, outer, dim, inner)
+	} else {
+		out.Int64s = gatherBlocks(data.Int64s, positions, outer, dim, inner)
+	}
+	return []*Tensor{out}, nil
+}
+
+// gatherBlocks gathers the blocks of inner values at positions along a dim of size dim.
+func gatherBlocks[T float32 | int64](values []T, positions []int, outer, dim, inner int) []T {
+	out := make([]T, 0, outer*len(positions)*inner)
+	for o := range outer {
+		for _, p := range positions {
+			start := (o*dim + p) * inner
+			out = append(out, values[start:start+inner]...)
+		}
+	}
+	return out
+}
+
+func shape(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x := inputs[0]
+	rank := int64(len(x.Shape))
+	start, end := node.attrInt("start", 0), node.attrInt("end", rank)
+	if start < 0 {
+		start += rank
+	}
+	if end < 0 {
+		end += rank
+	}
+	start, end = min(max(start, 0), rank), min(max(end, 0), rank)
+
+	values := []int64{}
+	for _, dim := range x.Shape[start:max(start, end)] {
+		values = append(values, int64(dim))
+	}
+	return []*Tensor{{Shape: []int{len(values)}, DataType: DataTypeInt64, Int64s: values}}, nil
+}
+
+func constant(node *Node, _ []*Tensor, _ int64) ([]*Tensor, error) {
+	for name, attr := range node.Attributes {
+		switch name {
+		case "value":
+			t, err := newTensor(attr.T)
+			if err != nil {
+				return nil, err
+			}
+			return []*Tensor{t}, nil
+		case "value_float":
+			return []*Tensor{{Shape: []int{}, DataType: DataTypeFloat, Float32s: []float32{attr.F}}}, nil
+		case "value_floats":
+			return []*Tensor{{Shape: []int{len(attr.Floats)}, DataType: DataTypeFloat, Float32s: attr.Floats}}, nil
+		case "value_int":
+			return []*Tensor{{Shape: []int{}, DataType: DataTypeInt64, Int64s: []int64{attr.I}}}, nil
+		case "value_ints":
+			return []*Tensor{{Shape: []int{len(attr.Ints)}, DataType: DataTypeInt64, Int64s: attr.Ints}}, nil
+		}
+	}
+	return nil, errors.New("unsupported constant value")
+}
+
+func cast(node *Node, inputs []*Tensor, _ int64) ([]*Tensor, error) {
+	x := inputs[0]
+	switch to := int32(node.attrInt("to", 0)); to {
+	case DataTypeFloat, DataTypeDouble:
+		return []*Tensor{{Shape: x.Shape, DataType: DataTypeFloat, Float32s: x.float32s()}}, nil
+	case DataTypeInt32, DataTypeInt64:
+		return []*Tensor{{Shape: x.Shape, DataType: DataTypeInt64, Int64s: x.int64s()}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported cast to data type %d", to)
+	}
+}
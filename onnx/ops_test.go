@@ -0,0 +1,301 @@
+package onnx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func genTestNode(opType string, attrs map[string]*Attribute) *Node {
+	if attrs == nil {
+		attrs = map[string]*Attribute{}
+	}
+	return &Node{Name: opType, OpType: opType, Outputs: []string{"output"}, Attributes: attrs}
+}
+
+func genRangeTensor(shape ...int) *Tensor {
+	values := make([]float32, shapeSize(shape))
+	for idx := range values {
+		values[idx] = float32(idx%7) - 3
+	}
+	return &Tensor{Shape: shape, DataType: DataTypeFloat, Float32s: values}
+}
+
+func runOperator(t *testing.T, node *Node, opset int64, inputs ...*Tensor) *Tensor {
+	outputs, err := operators[node.OpType](node, inputs, opset)
+	assert.NoError(t, err)
+	return outputs[0]
+}
+
+// refConv is a direct 2D convolution with symmetric padding.
+func refConv(x, w *Tensor, bias []float32, stride, pad, dilation, group int) []float32 {
+	n, c, h, wd := x.Shape[0], x.Shape[1], x.Shape[2], x.Shape[3]
+	m, groupC, kh, kw := w.Shape[0], w.Shape[1], w.Shape[2], w.Shape[3]
+	outH := (h+2*pad-(kh-1)*dilation-1)/stride + 1
+	outW := (wd+2*pad-(kw-1)*dilation-1)/stride + 1
+	groupM := m / group
+
+	out := make([]float32, 0, n*m*outH*outW)
+	for b := range n {
+		for oc := range m {
+			g := oc / groupM
+			for oh := range outH {
+				for ow := range outW {
+					var sum float32
+					if bias != nil {
+						sum = bias[oc]
+					}
+					for ic := range groupC {
+						for i := range kh {
+							for j := range kw {
+								ih, iw := oh*stride-pad+i*dilation, ow*stride-pad+j*dilation
+								if ih < 0 || ih >= h || iw < 0 || iw >= wd {
+									continue
+								}
+								sum += x.Float32s[((b*c+g*groupC+ic)*h+ih)*wd+iw] * w.Float32s[((oc*groupC+ic)*kh+i)*kw+j]
+							}
+						}
+					}
+					out = append(out, sum)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestConv(t *testing.T) {
+	cases := []struct {
+		name                           string
+		c, m, k                        int
+		stride, pad, dilation, group   int
+		withBias                       bool
+		expectedOutH, expectedOutWidth int
+	}{
+		{name: "dense", c: 3, m: 4, k: 3, stride: 2, pad: 1, dilation: 1, group: 1, withBias: true, expectedOutH: 4, expectedOutWidth: 4},
+		{name: "pointwise", c: 4, m: 2, k: 1, stride: 1, pad: 0, dilation: 1, group: 1, expectedOutH: 7, expectedOutWidth: 7},
+		{name: "depthwise", c: 4, m: 4, k: 3, stride: 1, pad: 1, dilation: 1, group: 4, withBias: true, expectedOutH: 7, expectedOutWidth: 7},
+		{name: "grouped dilated", c: 4, m: 6, k: 3, stride: 1, pad: 2, dilation: 2, group: 2, expectedOutH: 7, expectedOutWidth: 7},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			x := genRangeTensor(2, tc.c, 7, 7)
+			w := genRangeTensor(tc.m, tc.c/tc.group, tc.k, tc.k)
+			for idx := range w.Float32s {
+				w.Float32s[idx] *= 0.25
+			}
+			inputs := []*Tensor{x, w}
+			var bias []float32
+			if tc.withBias {
+				bias = genRangeTensor(tc.m).Float32s
+				inputs = append(inputs, &Tensor{Shape: []int{tc.m}, DataType: DataTypeFloat, Float32s: bias})
+			}
+
+			p, s, d := int64(tc.pad), int64(tc.stride), int64(tc.dilation)
+			node := genTestNode("Conv", map[string]*Attribute{
+				"kernel_shape": {Ints: []int64{int64(tc.k), int64(tc.k)}},
+				"pads":         {Ints: []int64{p, p, p, p}},
+				"strides":      {Ints: []int64{s, s}},
+				"dilations":    {Ints: []int64{d, d}},
+				"group":        {I: int64(tc.group)},
+			})
+			out := runOperator(t, node, 11, inputs...)
+			assert.Equal(t, []int{2, tc.m, tc.expectedOutH, tc.expectedOutWidth}, out.Shape)
+			assert.InDeltaSlice(t, refConv(x, w, bias, tc.stride, tc.pad, tc.dilation, tc.group), out.Float32s, 1e-4)
+		})
+	}
+
+	// SAME_UPPER keeps the spatial size at stride 1.
+	node := genTestNode("Conv", map[string]*Attribute{"auto_pad": {S: []byte("SAME_UPPER")}})
+	out := runOperator(t, node, 11, genRangeTensor(1, 2, 5, 5), genRangeTensor(3, 2, 3, 3))
+	assert.Equal(t, []int{1, 3, 5, 5}, out.Shape)
+
+	_, err := conv(genTestNode("Conv", map[string]*Attribute{"group": {I: 3}}), []*Tensor{genRangeTensor(1, 4, 5, 5), genRangeTensor(3, 1, 3, 3)}, 11)
+	assert.Error(t, err)
+}
+
+func TestBatchNormalization(t *testing.T) {
+	x := &Tensor{Shape: []int{1, 2, 1, 2}, DataType: DataTypeFloat, Float32s: []float32{1, 2, 3, 4}}
+	channel := func(a, b float32) *Tensor {
+		return &Tensor{Shape: []int{2}, DataType: DataTypeFloat, Float32s: []float32{a, b}}
+	}
+	node := genTestNode("BatchNormalization", map[string]*Attribute{"epsilon": {F: 0}})
+	out := runOperator(t, node, 11, x, channel(2, 1), channel(0.5, 0), channel(1, 3), channel(4, 1))
+	assert.InDeltaSlice(t, []float32{0.5, 1.5, 0, 1}, out.Float32s, 1e-6)
+}
+
+func TestBinaryOperators(t *testing.T) {
+	a := &Tensor{Shape: []int{2, 3}, DataType: DataTypeFloat, Float32s: []float32{1, 2, 3, 4, 5, 6}}
+	row := &Tensor{Shape: []int{3}, DataType: DataTypeFloat, Float32s: []float32{10, 20, 30}}
+	column := &Tensor{Shape: []int{2, 1}, DataType: DataTypeFloat, Float32s: []float32{2, 3}}
+
+	out := runOperator(t, genTestNode("Add", nil), 11, a, row)
+	assert.Equal(t, []int{2, 3}, out.Shape)
+	assert.Equal(t, []float32{11, 22, 33, 14, 25, 36}, out.Float32s)
+
+	out = runOperator(t, genTestNode("Mul", nil), 11, column, row)
+	assert.Equal(t, []int{2, 3}, out.Shape)
+	assert.Equal(t, []float32{20, 40, 60, 30, 60, 90}, out.Float32s)
+
+	out = runOperator(t, genTestNode("Div", nil), 11, a, &Tensor{Shape: []int{}, DataType: DataTypeFloat, Float32s: []float32{2}})
+	assert.Equal(t, []float32{0.5, 1, 1.5, 2, 2.5, 3}, out.Float32s)
+
+	ints := &Tensor{Shape: []int{2}, DataType: DataTypeInt64, Int64s: []int64{6, 8}}
+	out = runOperator(t, genTestNode("Sub", nil), 11, ints, &Tensor{Shape: []int{1}, DataType: DataTypeInt64, Int64s: []int64{1}})
+	assert.Equal(t, DataTypeInt64, out.DataType)
+	assert.Equal(t, []int64{5, 7}, out.Int64s)
+
+	_, err := operators["Add"](genTestNode("Add", nil), []*Tensor{a, {Shape: []int{2}, DataType: DataTypeFloat, Float32s: []float32{1, 2}}}, 11)
+	assert.Error(t, err)
+}
+
+func TestActivations(t *testing.T) {
+	x := &Tensor{Shape: []int{1, 2, 1, 2}, DataType: DataTypeFloat, Float32s: []float32{-2, 1, -4, 3}}
+
+	assert.Equal(t, []float32{0, 1, 0, 3}, runOperator(t, genTestNode("Relu", nil), 11, x).Float32s)
+	slope := &Tensor{Shape: []int{2, 1, 1}, DataType: DataTypeFloat, Float32s: []float32{0.5, 0.25}}
+	assert.Equal(t, []float32{-1, 1, -1, 3}, runOperator(t, genTestNode("PRelu", nil), 11, x, slope).Float32s)
+	leaky := genTestNode("LeakyRelu", map[string]*Attribute{"alpha": {F: 0.5}})
+	assert.Equal(t, []float32{-1, 1, -2, 3}, runOperator(t, leaky, 11, x).Float32s)
+
+	low := &Tensor{Shape: []int{}, DataType: DataTypeFloat, Float32s: []float32{-1}}
+	high := &Tensor{Shape: []int{}, DataType: DataTypeFloat, Float32s: []float32{2}}
+	assert.Equal(t, []float32{-1, 1, -1, 2}, runOperator(t, genTestNode("Clip", nil), 11, x, low, high).Float32s)
+	clip6 := genTestNode("Clip", map[string]*Attribute{"min": {F: 0}, "max": {F: 2}})
+	assert.Equal(t, []float32{0, 1, 0, 2}, runOperator(t, clip6, 6, x).Float32s)
+
+	sigmoid := runOperator(t, genTestNode("Sigmoid", nil), 11, x)
+	assert.InDelta(t, 1/(1+math.Exp(2)), sigmoid.Float32s[0], 1e-6)
+}
+
+func TestPooling(t *testing.T) {
+	x := &Tensor{Shape: []int{1, 1, 4, 4}, DataType: DataTypeFloat, Float32s: []float32{
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+		9, 10, 11, 12,
+		13, 14, 15, 16,
+	}}
+
+	maxNode := genTestNode("MaxPool", map[string]*Attribute{"kernel_shape": {Ints: []int64{2, 2}}, "strides": {Ints: []int64{2, 2}}})
+	out := runOperator(t, maxNode, 11, x)
+	assert.Equal(t, []int{1, 1, 2, 2}, out.Shape)
+	assert.Equal(t, []float32{6, 8, 14, 16}, out.Float32s)
+
+	// Padding is excluded from the average unless count_include_pad is set.
+	avgNode := genTestNode("AveragePool", map[string]*Attribute{
+		"kernel_shape": {Ints: []int64{2, 2}},
+		"strides":      {Ints: []int64{2, 2}},
+		"pads":         {Ints: []int64{1, 1, 1, 1}},
+	})
+	out = runOperator(t, avgNode, 11, x)
+	assert.Equal(t, []int{1, 1, 3, 3}, out.Shape)
+	assert.Equal(t, float32(1), out.Float32s[0])
+	assert.Equal(t, float32(2.5), out.Float32s[1])
+	avgNode.Attributes["count_include_pad"] = &Attribute{I: 1}
+	out = runOperator(t, avgNode, 11, x)
+	assert.Equal(t, float32(0.25), out.Float32s[0])
+
+	ceilNode := genTestNode("MaxPool", map[string]*Attribute{
+		"kernel_shape": {Ints: []int64{3, 3}},
+		"strides":      {Ints: []int64{2, 2}},
+		"ceil_mode":    {I: 1},
+	})
+	assert.Equal(t, []int{1, 1, 2, 2}, runOperator(t, ceilNode, 11, x).Shape)
+
+	out = runOperator(t, genTestNode("GlobalAveragePool", nil), 11, x)
+	assert.Equal(t, []int{1, 1, 1, 1}, out.Shape)
+	assert.Equal(t, []float32{8.5}, out.Float32s)
+}
+
+func TestGemm(t *testing.T) {
+	a := &Tensor{Shape: []int{2, 3}, DataType: DataTypeFloat, Float32s: []float32{1, 2, 3, 4, 5, 6}}
+	// b is stored transposed, as exported by linear layers.
+	b := &Tensor{Shape: []int{2, 3}, DataType: DataTypeFloat, Float32s: []float32{1, 0, 1, 0, 1, 0}}
+	c := &Tensor{Shape: []int{2}, DataType: DataTypeFloat, Float32s: []float32{10, 20}}
+
+	node := genTestNode("Gemm", map[string]*Attribute{"transB": {I: 1}, "alpha": {F: 2}, "beta": {F: 0.5}})
+	out := runOperator(t, node, 11, a, b, c)
+	assert.Equal(t, []int{2, 2}, out.Shape)
+	assert.Equal(t, []float32{13, 14, 25, 20}, out.Float32s)
+
+	_, err := gemm(genTestNode("Gemm", nil), []*Tensor{a, a}, 11)
+	assert.Error(t, err)
+}
+
+func TestMatMul(t *testing.T) {
+	a := genRangeTensor(2, 2, 3)
+	b := &Tensor{Shape: []int{3, 1}, DataType: DataTypeFloat, Float32s: []float32{1, 1, 1}}
+	out := runOperator(t, genTestNode("MatMul", nil), 11, a, b)
+	assert.Equal(t, []int{2, 2, 1}, out.Shape)
+	assert.Equal(t, []float32{-6, 3, -2, 0}, out.Float32s)
+
+	batched := runOperator(t, genTestNode("MatMul", nil), 11, a, genRangeTensor(2, 3, 2))
+	assert.Equal(t, []int{2, 2, 2}, batched.Shape)
+}
+
+func TestSoftmax(t *testing.T) {
+	x := &Tensor{Shape: []int{1, 2, 2}, DataType: DataTypeFloat, Float32s: []float32{0, 0, 0, 0}}
+
+	// Before opset 13 the default axis 1 coerces the trailing dims into one softmax.
+	out := runOperator(t, genTestNode("Softmax", nil), 11, x)
+	assert.Equal(t, []float32{0.25, 0.25, 0.25, 0.25}, out.Float32s)
+	out = runOperator(t, genTestNode("Softmax", nil), 13, x)
+	assert.Equal(t, []float32{0.5, 0.5, 0.5, 0.5}, out.Float32s)
+
+	scores := &Tensor{Shape: []int{1, 2}, DataType: DataTypeFloat, Float32s: []float32{1, 3}}
+	out = runOperator(t, genTestNode("Softmax", nil), 13, scores)
+	assert.InDelta(t, 1/(1+math.Exp(2)), out.Float32s[0], 1e-6)
+}
+
+func TestShapeOperators(t *testing.T) {
+	x := genRangeTensor(2, 3, 4, 5)
+
+	shape := runOperator(t, genTestNode("Shape", nil), 11, x)
+	assert.Equal(t, []int64{2, 3, 4, 5}, shape.Int64s)
+
+	zero := &Tensor{Shape: []int{}, DataType: DataTypeInt64, Int64s: []int64{0}}
+	batch := runOperator(t, genTestNode("Gather", nil), 11, shape, zero)
+	assert.Equal(t, []int{}, batch.Shape)
+	assert.Equal(t, []int64{2}, batch.Int64s)
+
+	batch = runOperator(t, genTestNode("Unsqueeze", map[string]*Attribute{"axes": {Ints: []int64{0}}}), 11, batch)
+	assert.Equal(t, []int{1}, batch.Shape)
+	axes := &Tensor{Shape: []int{1}, DataType: DataTypeInt64, Int64s: []int64{0}}
+	assert.Equal(t, []int{1}, runOperator(t, genTestNode("Unsqueeze", nil), 13, zero, axes).Shape)
+
+	minusOne := &Tensor{Shape: []int{1}, DataType: DataTypeInt64, Int64s: []int64{-1}}
+	target := runOperator(t, genTestNode("Concat", map[string]*Attribute{"axis": {I: 0}}), 11, batch, minusOne)
+	assert.Equal(t, []int64{2, -1}, target.Int64s)
+
+	flat := runOperator(t, genTestNode("Reshape", nil), 11, x, target)
+	assert.Equal(t, []int{2, 60}, flat.Shape)
+	assert.Equal(t, x.Float32s, flat.Float32s)
+	assert.Equal(t, []int{2, 60}, runOperator(t, genTestNode("Flatten", nil), 11, x).Shape)
+
+	keep := &Tensor{Shape: []int{3}, DataType: DataTypeInt64, Int64s: []int64{0, 0, -1}}
+	assert.Equal(t, []int{2, 3, 20}, runOperator(t, genTestNode("Reshape", nil), 11, x, keep).Shape)
+
+	squeezed := runOperator(t, genTestNode("Squeeze", nil), 11, genRangeTensor(1, 3, 1))
+	assert.Equal(t, []int{3}, squeezed.Shape)
+
+	rows := runOperator(t, genTestNode("Gather", map[string]*Attribute{"axis": {I: 1}}), 11,
+		&Tensor{Shape: []int{2, 3}, DataType: DataTypeFloat, Float32s: []float32{1, 2, 3, 4, 5, 6}},
+		&Tensor{Shape: []int{2}, DataType: DataTypeInt64, Int64s: []int64{2, -3}})
+	assert.Equal(t, []int{2, 2}, rows.Shape)
+	assert.Equal(t, []float32{3, 1, 6, 4}, rows.Float32s)
+
+	cast := runOperator(t, genTestNode("Cast", map[string]*Attribute{"to": {I: int64(DataTypeFloat)}}), 11, shape)
+	assert.Equal(t, []float32{2, 3, 4, 5}, cast.Float32s)
+}
+
+func TestTranspose(t *testing.T) {
+	x := &Tensor{Shape: []int{2, 3}, DataType: DataTypeFloat, Float32s: []float32{1, 2, 3, 4, 5, 6}}
+	out := runOperator(t, genTestNode("Transpose", nil), 11, x)
+	assert.Equal(t, []int{3, 2}, out.Shape)
+	assert.Equal(t, []float32{1, 4, 2, 5, 3, 6}, out.Float32s)
+
+	nchw := genRangeTensor(1, 2, 2, 3)
+	nhwc := runOperator(t, genTestNode("Transpose", map[string]*Attribute{"perm": {Ints: []int64{0, 2, 3, 1}}}), 11, nchw)
+	assert.Equal(t, []int{1, 2, 3, 2}, nhwc.Shape)
+	assert.Equal(t, nchw.Float32s[6], nhwc.Float32s[1])
+}
@@ -0,0 +1,368 @@
+package onnx
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+)
+
+// ONNX tensor element types.
+const (
+	DataTypeFloat  int32 = 1
+	DataTypeInt32  int32 = 6
+	DataTypeInt64  int32 = 7
+	DataTypeDouble int32 = 11
+)
+
+// modelProto is the subset of onnx.ModelProto used for inference.
+type modelProto struct {
+	IRVersion    int64
+	OpsetVersion int64
+	Graph        *graphProto
+}
+
+// graphProto is the subset of onnx.GraphProto used for inference.
+type graphProto struct {
+	Name         string
+	Nodes        []*Node
+	Initializers []*tensorProto
+	Inputs       []ValueInfo
+	Outputs      []ValueInfo
+}
+
+// Node is an operator of the graph.
+type Node struct {
+	Name       string
+	OpType     string
+	Domain     string
+	Inputs     []string
+	Outputs    []string
+	Attributes map[string]*Attribute
+}
+
+// Attribute is an operator attribute.
+type Attribute struct {
+	Name   string
+	Type   int32
+	F      float32
+	I      int64
+	S      []byte
+	T      *tensorProto
+	Floats []float32
+	Ints   []int64
+}
+
+// tensorProto is the subset of onnx.TensorProto holding constant tensors.
+type tensorProto struct {
+	Name       string
+	Dims       []int64
+	DataType   int32
+	FloatData  []float32
+	Int32Data  []int32
+	Int64Data  []int64
+	DoubleData []float64
+	RawData    []byte
+	IsExternal bool
+}
+
+// ValueInfo describes a graph input or output. Dynamic dimensions are -1.
+type ValueInfo struct {
+	Name     string
+	DataType int32
+	Dims     []int64
+}
+
+// field is a decoded protobuf field.
+type field struct {
+	num   protowire.Number
+	typ   protowire.Type
+	value uint64
+	bytes []byte
+}
+
+// forEachField decodes the fields of a protobuf message.
+func forEachField(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.value = uint64(v)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendVarints appends the values of a repeated varint field, packed or not.
+func (f field) appendVarints(dst []int64) ([]int64, error) {
+	if f.typ != protowire.BytesType {
+		return append(dst, int64(f.value)), nil
+	}
+	for b := f.bytes; len(b) > 0; {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		dst = append(dst, int64(v))
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+// appendFloats appends the values of a repeated float field, packed or not.
+func (f field) appendFloats(dst []float32) ([]float32, error) {
+	if f.typ != protowire.BytesType {
+		return append(dst, math.Float32frombits(uint32(f.value))), nil
+	}
+	for b := f.bytes; len(b) > 0; {
+		v, n := protowire.ConsumeFixed32(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		dst = append(dst, math.Float32frombits(v))
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+// appendDoubles appends the values of a repeated double field, packed or not.
+func (f field) appendDoubles(dst []float64) ([]float64, error) {
+	if f.typ != protowire.BytesType {
+		return append(dst, math.Float64frombits(f.value)), nil
+	}
+	for b := f.bytes; len(b) > 0; {
+		v, n := protowire.ConsumeFixed64(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		dst = append(dst, math.Float64frombits(v))
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+func parseModelProto(b []byte) (*modelProto, error) {
+	model := &modelProto{}
+	err := forEachField(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			model.IRVersion = int64(f.value)
+		case 7:
+			model.Graph, err = parseGraphProto(f.bytes)
+		case 8:
+			var domain string
+			var version int64
+			err = forEachField(f.bytes, func(f field) error {
+				switch f.num {
+				case 1:
+					domain = string(f.bytes)
+				case 2:
+					version = int64(f.value)
+				}
+				return nil
+			})
+			if domain == "" || domain == "ai.onnx" {
+				model.OpsetVersion = version
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid onnx model: %w", err)
+	}
+	if model.Graph == nil {
+		return nil, fmt.Errorf("invalid onnx model: no graph")
+	}
+	return model, nil
+}
+
+func parseGraphProto(b []byte) (*graphProto, error) {
+	graph := &graphProto{}
+	err := forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			node, err := parseNode(f.bytes)
+			if err != nil {
+				return err
+			}
+			graph.Nodes = append(graph.Nodes, node)
+		case 2:
+			graph.Name = string(f.bytes)
+		case 5:
+			t, err := parseTensorProto(f.bytes)
+			if err != nil {
+				return err
+			}
+			graph.Initializers = append(graph.Initializers, t)
+		case 11, 12:
+			info, err := parseValueInfo(f.bytes)
+			if err != nil {
+				return err
+			}
+			if f.num == 11 {
+				graph.Inputs = append(graph.Inputs, info)
+			} else {
+				graph.Outputs = append(graph.Outputs, info)
+			}
+		}
+		return nil
+	})
+	return graph, err
+}
+
+func parseNode(b []byte) (*Node, error) {
+	node := &Node{Attributes: map[string]*Attribute{}}
+	err := forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			node.Inputs = append(node.Inputs, string(f.bytes))
+		case 2:
+			node.Outputs = append(node.Outputs, string(f.bytes))
+		case 3:
+			node.Name = string(f.bytes)
+		case 4:
+			node.OpType = string(f.bytes)
+		case 5:
+			attr, err := parseAttribute(f.bytes)
+			if err != nil {
+				return err
+			}
+			node.Attributes[attr.Name] = attr
+		case 7:
+			node.Domain = string(f.bytes)
+		}
+		return nil
+	})
+	return node, err
+}
+
+func parseAttribute(b []byte) (*Attribute, error) {
+	attr := &Attribute{}
+	err := forEachField(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			attr.Name = string(f.bytes)
+		case 2:
+			attr.F = math.Float32frombits(uint32(f.value))
+		case 3:
+			attr.I = int64(f.value)
+		case 4:
+			attr.S = f.bytes
+		case 5:
+			attr.T, err = parseTensorProto(f.bytes)
+		case 7:
+			attr.Floats, err = f.appendFloats(attr.Floats)
+		case 8:
+			attr.Ints, err = f.appendVarints(attr.Ints)
+		case 20:
+			attr.Type = int32(f.value)
+		}
+		return err
+	})
+	return attr, err
+}
+
+func parseTensorProto(b []byte) (*tensorProto, error) {
+	t := &tensorProto{}
+	err := forEachField(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			t.Dims, err = f.appendVarints(t.Dims)
+		case 2:
+			t.DataType = int32(f.value)
+		case 4:
+			t.FloatData, err = f.appendFloats(t.FloatData)
+		case 5:
+			var values []int64
+			values, err = f.appendVarints(nil)
+			for _, v := range values {
+				t.Int32Data = append(t.Int32Data, int32(v))
+			}
+		case 7:
+			t.Int64Data, err = f.appendVarints(t.Int64Data)
+		case 8:
+			t.Name = string(f.bytes)
+		case 9:
+			t.RawData = f.bytes
+		case 10:
+			t.DoubleData, err = f.appendDoubles(t.DoubleData)
+		case 14:
+			t.IsExternal = f.value == 1
+		}
+		return err
+	})
+	return t, err
+}
+
+func parseValueInfo(b []byte) (ValueInfo, error) {
+	info := ValueInfo{}
+	err := forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			info.Name = string(f.bytes)
+		case 2:
+			// TypeProto.tensor_type
+			return forEachField(f.bytes, func(f field) error {
+				if f.num != 1 {
+					return nil
+				}
+				return parseTensorType(f.bytes, &info)
+			})
+		}
+		return nil
+	})
+	return info, err
+}
+
+func parseTensorType(b []byte, info *ValueInfo) error {
+	return forEachField(b, func(f field) error {
+		switch f.num {
+		case 1:
+			info.DataType = int32(f.value)
+		case 2:
+			// TensorShapeProto.dim
+			info.Dims = []int64{}
+			return forEachField(f.bytes, func(f field) error {
+				if f.num != 1 {
+					return nil
+				}
+				dim := int64(-1)
+				err := forEachField(f.bytes, func(f field) error {
+					if f.num == 1 && f.typ == protowire.VarintType {
+						dim = int64(f.value)
+					}
+					return nil
+				})
+				info.Dims = append(info.Dims, dim)
+				return err
+			})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,188 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"testing"
+)
+
+func appendBytesField(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarintField(b []byte, num protowire.Number, v int64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendPackedVarints(b []byte, num protowire.Number, values []int64) []byte {
+	var packed []byte
+	for _, v := range values {
+		packed = protowire.AppendVarint(packed, uint64(v))
+	}
+	return appendBytesField(b, num, packed)
+}
+
+func genAttrInt(name string, v int64) []byte {
+	b := appendBytesField(nil, 1, []byte(name))
+	b = appendVarintField(b, 3, v)
+	return appendVarintField(b, 20, 2)
+}
+
+func genAttrInts(name string, values ...int64) []byte {
+	b := appendBytesField(nil, 1, []byte(name))
+	b = appendPackedVarints(b, 8, values)
+	return appendVarintField(b, 20, 7)
+}
+
+func genAttrFloat(name string, v float32) []byte {
+	b := appendBytesField(nil, 1, []byte(name))
+	b = protowire.AppendTag(b, 2, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, math.Float32bits(v))
+	return appendVarintField(b, 20, 1)
+}
+
+func genAttrString(name, v string) []byte {
+	b := appendBytesField(nil, 1, []byte(name))
+	b = appendBytesField(b, 4, []byte(v))
+	return appendVarintField(b, 20, 3)
+}
+
+func genNode(opType string, inputs, outputs []string, attrs ...[]byte) []byte {
+	var b []byte
+	for _, name := range inputs {
+		b = appendBytesField(b, 1, []byte(name))
+	}
+	for _, name := range outputs {
+		b = appendBytesField(b, 2, []byte(name))
+	}
+	b = appendBytesField(b, 3, []byte(opType+"_"+outputs[0]))
+	b = appendBytesField(b, 4, []byte(opType))
+	for _, attr := range attrs {
+		b = appendBytesField(b, 5, attr)
+	}
+	return b
+}
+
+// genFloatTensor encodes a float tensor in raw data.
+func genFloatTensor(name string, values []float32, dims ...int64) []byte {
+	b := appendPackedVarints(nil, 1, dims)
+	b = appendVarintField(b, 2, int64(DataTypeFloat))
+	b = appendBytesField(b, 8, []byte(name))
+	raw := make([]byte, 4*len(values))
+	for idx, v := range values {
+		binary.LittleEndian.PutUint32(raw[4*idx:], math.Float32bits(v))
+	}
+	return appendBytesField(b, 9, raw)
+}
+
+// genInt64Tensor encodes an int64 tensor in typed data.
+func genInt64Tensor(name string, values []int64, dims ...int64) []byte {
+	b := appendPackedVarints(nil, 1, dims)
+	b = appendVarintField(b, 2, int64(DataTypeInt64))
+	b = appendBytesField(b, 8, []byte(name))
+	return appendPackedVarints(b, 7, values)
+}
+
+// genValueInfo encodes a tensor value info, negative dims are encoded as symbolic dims.
+func genValueInfo(name string, dataType int32, dims ...int64) []byte {
+	var shape []byte
+	for _, dim := range dims {
+		var d []byte
+		if dim < 0 {
+			d = appendBytesField(nil, 2, []byte("batch"))
+		} else {
+			d = appendVarintField(nil, 1, dim)
+		}
+		shape = appendBytesField(shape, 1, d)
+	}
+	tensorType := appendVarintField(nil, 1, int64(dataType))
+	tensorType = appendBytesField(tensorType, 2, shape)
+
+	b := appendBytesField(nil, 1, []byte(name))
+	return appendBytesField(b, 2, appendBytesField(nil, 1, tensorType))
+}
+
+type testGraph struct {
+	nodes        [][]byte
+	initializers [][]byte
+	inputs       [][]byte
+	outputs      [][]byte
+}
+
+func genModel(opset int64, graph testGraph) []byte {
+	var g []byte
+	for _, node := range graph.nodes {
+		g = appendBytesField(g, 1, node)
+	}
+	g = appendBytesField(g, 2, []byte("test_graph"))
+	for _, initializer := range graph.initializers {
+		g = appendBytesField(g, 5, initializer)
+	}
+	for _, input := range graph.inputs {
+		g = appendBytesField(g, 11, input)
+	}
+	for _, output := range graph.outputs {
+		g = appendBytesField(g, 12, output)
+	}
+
+	b := appendVarintField(nil, 1, 8)
+	b = appendBytesField(b, 7, g)
+	opsetID := appendBytesField(nil, 1, []byte(""))
+	opsetID = appendVarintField(opsetID, 2, opset)
+	return appendBytesField(b, 8, opsetID)
+}
+
+func TestParseModelProto(t *testing.T) {
+	// Unpacked repeated fields are accepted as well as packed ones.
+	unpacked := appendVarintField(nil, 1, 2)
+	unpacked = appendVarintField(unpacked, 2, int64(DataTypeFloat))
+	unpacked = appendBytesField(unpacked, 8, []byte("bias"))
+	for _, v := range []float32{1.5, -2} {
+		unpacked = protowire.AppendTag(unpacked, 4, protowire.Fixed32Type)
+		unpacked = protowire.AppendFixed32(unpacked, math.Float32bits(v))
+	}
+
+	bModel := genModel(11, testGraph{
+		nodes: [][]byte{
+			genNode("Conv", []string{"input", "weight", "bias"}, []string{"conv"},
+				genAttrInts("pads", 1, 1, 1, 1), genAttrInt("group", 2), genAttrFloat("alpha", 0.5), genAttrString("auto_pad", "NOTSET")),
+		},
+		initializers: [][]byte{genFloatTensor("weight", make([]float32, 8), 2, 1, 2, 2), unpacked},
+		inputs:       [][]byte{genValueInfo("input", DataTypeFloat, -1, 2, 4, 4)},
+		outputs:      [][]byte{genValueInfo("conv", DataTypeFloat, -1, 2, 5, 5)},
+	})
+
+	proto, err := parseModelProto(bModel)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), proto.IRVersion)
+	assert.Equal(t, int64(11), proto.OpsetVersion)
+	assert.Equal(t, "test_graph", proto.Graph.Name)
+
+	assert.Len(t, proto.Graph.Nodes, 1)
+	node := proto.Graph.Nodes[0]
+	assert.Equal(t, "Conv", node.OpType)
+	assert.Equal(t, []string{"input", "weight", "bias"}, node.Inputs)
+	assert.Equal(t, []string{"conv"}, node.Outputs)
+	assert.Equal(t, []int64{1, 1, 1, 1}, node.attrInts("pads", nil))
+	assert.Equal(t, int64(2), node.attrInt("group", 1))
+	assert.Equal(t, float32(0.5), node.attrFloat("alpha", 0))
+	assert.Equal(t, "NOTSET", node.attrString("auto_pad", ""))
+	assert.Equal(t, int64(1), node.attrInt("missing", 1))
+
+	assert.Len(t, proto.Graph.Initializers, 2)
+	assert.Equal(t, []int64{2, 1, 2, 2}, proto.Graph.Initializers[0].Dims)
+	assert.Len(t, proto.Graph.Initializers[0].RawData, 32)
+	assert.Equal(t, []float32{1.5, -2}, proto.Graph.Initializers[1].FloatData)
+
+	assert.Equal(t, ValueInfo{Name: "input", DataType: DataTypeFloat, Dims: []int64{-1, 2, 4, 4}}, proto.Graph.Inputs[0])
+	assert.Equal(t, "conv", proto.Graph.Outputs[0].Name)
+
+	_, err = parseModelProto(bModel[:len(bModel)-3])
+	assert.Error(t, err)
+	_, err = parseModelProto(appendVarintField(nil, 1, 8))
+	assert.Error(t, err)
+}
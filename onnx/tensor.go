@@ -0,0 +1,176 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Tensor is a dense row-major tensor flowing through a graph. Its values are either float32 or int64, the element
+// types of the tensors of the supported operators, other ONNX types are converted when loaded.
+type Tensor struct {
+	Shape    []int
+	DataType int32
+	Float32s []float32
+	Int64s   []int64
+}
+
+// NewFloat32Tensor returns a float32 tensor of the given shape viewing values.
+func NewFloat32Tensor(values []float32, shape ...int) (*Tensor, error) {
+	if len(values) != shapeSize(shape) {
+		return nil, fmt.Errorf("%d values do not match shape %v", len(values), shape)
+	}
+	return &Tensor{Shape: shape, DataType: DataTypeFloat, Float32s: values}, nil
+}
+
+// NewInt64Tensor returns an int64 tensor of the given shape viewing values.
+func NewInt64Tensor(values []int64, shape ...int) (*Tensor, error) {
+	if len(values) != shapeSize(shape) {
+		return nil, fmt.Errorf("%d values do not match shape %v", len(values), shape)
+	}
+	return &Tensor{Shape: shape, DataType: DataTypeInt64, Int64s: values}, nil
+}
+
+// Size returns the number of elements of the tensor.
+func (t *Tensor) Size() int {
+	return shapeSize(t.Shape)
+}
+
+// isFloat reports whether the tensor holds float32 values.
+func (t *Tensor) isFloat() bool {
+	return t.DataType == DataTypeFloat
+}
+
+// reshape returns a tensor sharing the values of t with another shape.
+func (t *Tensor) reshape(shape []int) *Tensor {
+	return &Tensor{Shape: shape, DataType: t.DataType, Float32s: t.Float32s, Int64s: t.Int64s}
+}
+
+// int64s returns the values of t as int64, converting float values.
+func (t *Tensor) int64s() []int64 {
+	if !t.isFloat() {
+		return t.Int64s
+	}
+	values := make([]int64, len(t.Float32s))
+	for idx, v := range t.Float32s {
+		values[idx] = int64(v)
+	}
+	return values
+}
+
+// float32s returns the values of t as float32, converting int values.
+func (t *Tensor) float32s() []float32 {
+	if t.isFloat() {
+		return t.Float32s
+	}
+	values := make([]float32, len(t.Int64s))
+	for idx, v := range t.Int64s {
+		values[idx] = float32(v)
+	}
+	return values
+}
+
+func shapeSize(shape []int) int {
+	size := 1
+	for _, dim := range shape {
+		size *= dim
+	}
+	return size
+}
+
+func shapeEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// newTensor converts a constant tensor of the model.
+func newTensor(proto *tensorProto) (*Tensor, error) {
+	if proto.IsExternal {
+		return nil, fmt.Errorf("tensor %q: external data is not supported", proto.Name)
+	}
+
+	shape := make([]int, len(proto.Dims))
+	for idx, dim := range proto.Dims {
+		shape[idx] = int(dim)
+	}
+	size := shapeSize(shape)
+	raw := proto.RawData
+
+	t := &Tensor{Shape: shape}
+	switch proto.DataType {
+	case DataTypeFloat:
+		t.DataType = DataTypeFloat
+		switch {
+		case raw != nil:
+			if len(raw) != 4*size {
+				break
+			}
+			// Raw data may be unaligned in the model buffer, values are decoded rather than viewed.
+			t.Float32s = make([]float32, size)
+			for idx := range t.Float32s {
+				t.Float32s[idx] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*idx:]))
+			}
+		default:
+			t.Float32s = proto.FloatData
+		}
+		if len(t.Float32s) != size {
+			return nil, fmt.Errorf("tensor %q: %d values do not match shape %v", proto.Name, len(t.Float32s), shape)
+		}
+	case DataTypeDouble:
+		t.DataType = DataTypeFloat
+		t.Float32s = make([]float32, 0, size)
+		switch {
+		case raw != nil:
+			for idx := 0; idx+8 <= len(raw); idx += 8 {
+				t.Float32s = append(t.Float32s, float32(math.Float64frombits(binary.LittleEndian.Uint64(raw[idx:]))))
+			}
+		default:
+			for _, v := range proto.DoubleData {
+				t.Float32s = append(t.Float32s, float32(v))
+			}
+		}
+		if len(t.Float32s) != size {
+			return nil, fmt.Errorf("tensor %q: %d values do not match shape %v", proto.Name, len(t.Float32s), shape)
+		}
+	case DataTypeInt64:
+		t.DataType = DataTypeInt64
+		switch {
+		case raw != nil:
+			t.Int64s = make([]int64, 0, size)
+			for idx := 0; idx+8 <= len(raw); idx += 8 {
+				t.Int64s = append(t.Int64s, int64(binary.LittleEndian.Uint64(raw[idx:])))
+			}
+		default:
+			t.Int64s = proto.Int64Data
+		}
+		if len(t.Int64s) != size {
+			return nil, fmt.Errorf("tensor %q: %d values do not match shape %v", proto.Name, len(t.Int64s), shape)
+		}
+	case DataTypeInt32:
+		t.DataType = DataTypeInt64
+		t.Int64s = make([]int64, 0, size)
+		switch {
+		case raw != nil:
+			for idx := 0; idx+4 <= len(raw); idx += 4 {
+				t.Int64s = append(t.Int64s, int64(int32(binary.LittleEndian.Uint32(raw[idx:]))))
+			}
+		default:
+			for _, v := range proto.Int32Data {
+				t.Int64s = append(t.Int64s, int64(v))
+			}
+		}
+		if len(t.Int64s) != size {
+			return nil, fmt.Errorf("tensor %q: %d values do not match shape %v", proto.Name, len(t.Int64s), shape)
+		}
+	default:
+		return nil, fmt.Errorf("tensor %q: unsupported data type %d", proto.Name, proto.DataType)
+	}
+	return t, nil
+}
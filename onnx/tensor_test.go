@@ -0,0 +1,44 @@
+package onnx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewTensor(t *testing.T) {
+	raw := &tensorProto{Name: "raw", Dims: []int64{2}, DataType: DataTypeFloat, RawData: []byte{0, 0, 128, 63, 0, 0, 0, 192}}
+	tensor, err := newTensor(raw)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, tensor.Shape)
+	assert.Equal(t, []float32{1, -2}, tensor.Float32s)
+
+	// Int32 and double tensors are converted to the supported element types.
+	tensor, err = newTensor(&tensorProto{Name: "int32", Dims: []int64{2}, DataType: DataTypeInt32, Int32Data: []int32{-1, 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, DataTypeInt64, tensor.DataType)
+	assert.Equal(t, []int64{-1, 3}, tensor.Int64s)
+
+	tensor, err = newTensor(&tensorProto{Name: "double", DataType: DataTypeDouble, DoubleData: []float64{0.5}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{}, tensor.Shape)
+	assert.Equal(t, []float32{0.5}, tensor.Float32s)
+
+	_, err = newTensor(&tensorProto{Name: "short", Dims: []int64{3}, DataType: DataTypeFloat, FloatData: []float32{1}})
+	assert.Error(t, err)
+	_, err = newTensor(&tensorProto{Name: "external", DataType: DataTypeFloat, IsExternal: true})
+	assert.Error(t, err)
+	_, err = newTensor(&tensorProto{Name: "string", DataType: 8})
+	assert.Error(t, err)
+}
+
+func TestNewFloat32Tensor(t *testing.T) {
+	tensor, err := NewFloat32Tensor([]float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, tensor.Size())
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6}, tensor.int64s())
+
+	_, err = NewFloat32Tensor([]float32{1, 2}, 2, 3)
+	assert.Error(t, err)
+	_, err = NewInt64Tensor([]int64{1, 2}, 3)
+	assert.Error(t, err)
+}
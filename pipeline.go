@@ -1,35 +1,57 @@
 package go_ekyc_pipeline
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/okieraised/go-ekyc-pipeline/config"
 	"github.com/okieraised/go-ekyc-pipeline/modules"
 	"github.com/okieraised/go-ekyc-pipeline/utils"
-	gotritonclient "github.com/okieraised/go-triton-client"
 	"gocv.io/x/gocv"
 	"gorgonia.org/tensor"
-	"math"
+	"sync/atomic"
+	"time"
 )
 
 // EKYCPipeline defines the structure of the EKYC pipeline
 type EKYCPipeline struct {
-	FaceID      *modules.FaceIDClient
-	FaceQuality *modules.FaceQualityClient
-	FaceHelper  *modules.FaceHelperClient
-	FaceASFull  *modules.FaceAntiSpoofingClient
-	FaceASCrop  *modules.FaceAntiSpoofingClient
+	FaceID            *modules.FaceIDClient
+	FaceQuality       *modules.FaceQualityClient
+	FaceHelper        *modules.FaceHelperClient
+	FaceASFull        *modules.FaceAntiSpoofingClient
+	FaceASCrop        *modules.FaceAntiSpoofingClient
+	Challenge         *modules.LivenessChallengeClient
+	HeadPose          *modules.HeadPoseClient
+	Quality           *modules.ImageQualityClient
+	ImageQualityScore *modules.FaceImageQualityClient
+	Validator         *modules.LandmarkValidatorClient
+	Landmark          *modules.FaceLandmarkClient // Landmark is the optional dense landmark client, blinks use the eye aspect ratio if set.
+	Params            *config.EKYCPipelineParams  // Params are the params the clients were initialized with.
+	Shadow            *ShadowEvaluator            // Shadow evaluates the candidate models of Params.Shadow, nil if not set.
+	metrics           atomic.Pointer[modules.Metrics]
 }
 
-// NewEKYCPipeline initializes new pipelines.
-func NewEKYCPipeline(tritonClient *gotritonclient.TritonGRPCClient) (*EKYCPipeline, error) {
+// NewEKYCPipeline initializes new pipelines on an inference backend: a Triton gRPC client, a modules.InferencePool
+// over several Triton servers, or an in-process onnx.Backend serving the models under the names of the default
+// parameters. Any of them can be wrapped by a modules.ResilientClient to retry transient failures.
+//
+// The Context variants of the operations are traced with the OpenTelemetry tracer provider and cancelled with their
+// context. A modules.TritonClient backend also propagates the trace context to the Triton servers, so that their
+// traces join the trace of the operation.
+func NewEKYCPipeline(tritonClient modules.InferenceClient) (*EKYCPipeline, error) {
+	return NewEKYCPipelineWithParams(tritonClient, config.DefaultEKYCPipelineParams)
+}
+
+// NewEKYCPipelineWithParams initializes new pipelines on an inference backend with the models, versions and
+// thresholds of params, see NewEKYCPipeline.
+func NewEKYCPipelineWithParams(tritonClient modules.InferenceClient, params *config.EKYCPipelineParams) (*EKYCPipeline, error) {
 
-	pipeline := &EKYCPipeline{}
+	pipeline := &EKYCPipeline{Params: params}
 
 	// Init face id client
 	faceIDClient, err := modules.NewFaceIDClient(
 		tritonClient,
-		config.DefaultFaceIDParams,
+		params.FaceID,
 	)
 	if err != nil {
 		return pipeline, err
@@ -39,7 +61,7 @@ func NewEKYCPipeline(tritonClient *gotritonclient.TritonGRPCClient) (*EKYCPipeli
 	// Init face quality client
 	faceQualityClient, err := modules.NewFaceQualityClient(
 		tritonClient,
-		config.DefaultFaceQualityParams,
+		params.FaceQuality,
 	)
 	if err != nil {
 		return pipeline, err
@@ -49,7 +71,7 @@ func NewEKYCPipeline(tritonClient *gotritonclient.TritonGRPCClient) (*EKYCPipeli
 	// Init face anti-spoofing client
 	faceASFullClient, err := modules.NewFaceAntiSpoofingClient(
 		tritonClient,
-		config.DefaultFullFaceAntiSpoofingParams,
+		params.FullFaceAS,
 	)
 	if err != nil {
 		return pipeline, err
@@ -58,7 +80,7 @@ func NewEKYCPipeline(tritonClient *gotritonclient.TritonGRPCClient) (*EKYCPipeli
 
 	faceASCropClient, err := modules.NewFaceAntiSpoofingClient(
 		tritonClient,
-		config.DefaultCropFaceAntiSpoofingParams,
+		params.CropFaceAS,
 	)
 	if err != nil {
 		return pipeline, err
@@ -74,39 +96,295 @@ func NewEKYCPipeline(tritonClient *gotritonclient.TritonGRPCClient) (*EKYCPipeli
 		nil,
 		nil,
 		nil,
+		params.FaceDetection,
 	)
+	if err != nil {
+		return pipeline, err
+	}
+	if params.Alignment != nil {
+		faceHelper.AlignmentParams = params.Alignment
+	}
 	pipeline.FaceHelper = faceHelper
 
+	// Init optional dense landmark client
+	contracted := []modules.ContractChecker{faceIDClient, faceQualityClient, faceASFullClient, faceASCropClient, faceHelper}
+	if params.FaceLandmark != nil {
+		landmarkClient, err := modules.NewFaceLandmarkClient(tritonClient, params.FaceLandmark)
+		if err != nil {
+			return pipeline, err
+		}
+		pipeline.Landmark = landmarkClient
+		contracted = append(contracted, landmarkClient)
+	}
+
+	// Init liveness challenge client
+	challengeClient, err := modules.NewLivenessChallengeClient(params.LivenessChallenge)
+	if err != nil {
+		return pipeline, err
+	}
+	pipeline.Challenge = challengeClient
+
+	// Init head pose client
+	headPoseClient, err := modules.NewHeadPoseClient(params.HeadPose)
+	if err != nil {
+		return pipeline, err
+	}
+	pipeline.HeadPose = headPoseClient
+
+	// Init local image quality client
+	imageQualityClient, err := modules.NewImageQualityClient(params.ImageQuality)
+	if err != nil {
+		return pipeline, err
+	}
+	pipeline.Quality = imageQualityClient
+
+	// Init unified face image quality client
+	faceImageQualityClient, err := modules.NewFaceImageQualityClient(params.FaceImageQuality, params.HeadPose)
+	if err != nil {
+		return pipeline, err
+	}
+	pipeline.ImageQualityScore = faceImageQualityClient
+
+	// Init client landmark validator
+	validatorClient, err := modules.NewLandmarkValidatorClient(params.LandmarkValidation)
+	if err != nil {
+		return pipeline, err
+	}
+	pipeline.Validator = validatorClient
+
+	// Models are checked against the inputs and outputs the clients rely on before serving any request
+	err = modules.CheckModelContracts(contracted...)
+	if err != nil {
+		return pipeline, err
+	}
+
+	// Input images are converted once to the color order shared by every client
+	colorOrdered := []modules.ColorOrderer{faceIDClient, faceQualityClient, faceASFullClient, faceASCropClient, challengeClient, imageQualityClient}
+	if pipeline.Landmark != nil {
+		colorOrdered = append(colorOrdered, pipeline.Landmark)
+	}
+
+	// Init optional shadow evaluation of candidate models
+	if params.Shadow != nil {
+		shadow, err := NewShadowEvaluator(tritonClient, params.Shadow)
+		if err != nil {
+			return pipeline, err
+		}
+		pipeline.Shadow = shadow
+		colorOrdered = append(colorOrdered, shadow.colorOrdered()...)
+	}
+
+	err = modules.CheckColorOrders(pipeline.ColorOrder(), colorOrdered...)
+	if err != nil {
+		return pipeline, err
+	}
+
 	return pipeline, nil
 }
 
+// ModelVersions returns the version of each model of the pipeline by model name, the version that served its last
+// request, or the pinned version if none was served yet. Empty versions are the latest versions of unserved models.
+func (c *EKYCPipeline) ModelVersions() map[string]string {
+	modelers := []modules.ServedModeler{c.FaceID, c.FaceQuality, c.FaceASFull, c.FaceASCrop, c.FaceHelper}
+	if c.Landmark != nil {
+		modelers = append(modelers, c.Landmark)
+	}
+	versions := make(map[string]string, len(modelers))
+	for _, modeler := range modelers {
+		if name, version := modeler.ServedModel(); name != "" {
+			versions[name] = version
+		}
+	}
+	return versions
+}
+
+// Instrument records the metrics of the pipeline checks and the stages of the calls of its model clients, shadow
+// candidates included, to metrics. Metrics are registered once with a prometheus.Registerer, nil stops recording.
+func (c *EKYCPipeline) Instrument(metrics *modules.Metrics) {
+	c.metrics.Store(metrics)
+	instrumenters := []modules.Instrumenter{c.FaceID, c.FaceQuality, c.FaceASFull, c.FaceASCrop, c.FaceHelper}
+	if c.Landmark != nil {
+		instrumenters = append(instrumenters, c.Landmark)
+	}
+	if c.Shadow != nil {
+		for _, client := range c.Shadow.clients() {
+			instrumenters = append(instrumenters, client)
+		}
+	}
+	for _, instrumenter := range instrumenters {
+		instrumenter.Instrument(metrics)
+	}
+}
+
+// ColorOrder returns the color order expected by the pipeline clients, input images are converted to it.
+func (c *EKYCPipeline) ColorOrder() utils.ColorOrder {
+	return c.FaceHelper.ColorOrder()
+}
+
+// outputImage hands a Mat in the pipeline color order owned by scope over to the caller as an Image in the given order.
+func (c *EKYCPipeline) outputImage(scope *utils.MatScope, mat gocv.Mat, order utils.ColorOrder) (*utils.Image, error) {
+	if order == c.ColorOrder() {
+		return &utils.Image{Mat: scope.Detach(mat), Order: order}, nil
+	}
+	img, err := utils.Image{Mat: mat, Order: c.ColorOrder()}.ConvertTo(order)
+	if err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+// prepareImages returns the Mats of the input images in the pipeline color order, converted Mats are owned by scope.
+func (c *EKYCPipeline) prepareImages(scope *utils.MatScope, images ...utils.Image) ([]gocv.Mat, error) {
+	return modules.PrepareImages(c.FaceHelper, scope, images...)
+}
+
 // similarityScore computes cosine similarity
 func (c *EKYCPipeline) similarityScore(A, B *tensor.Dense) (float32, error) {
-	a := A.Float32s()
-	b := B.Float32s()
+	return cosineSimilarity(A.Float32s(), B.Float32s())
+}
 
-	if len(a) != len(b) {
-		return 0, fmt.Errorf("vectors must have the same length")
+// uprightRotation returns the clockwise rotation turning the face of the landmark of img upright if the detection
+// params set TryRotation, see modules.UprightRotation, or 0.
+func (c *EKYCPipeline) uprightRotation(img gocv.Mat, lmk *tensor.Dense) int {
+	if lmk == nil || c.Params == nil || c.Params.FaceDetection == nil || !c.Params.FaceDetection.TryRotation {
+		return 0
+	}
+	return modules.UprightRotation(lmk, img.Cols(), img.Rows())
+}
+
+// estimateHeadPose returns the head pose of the face in img, relative to its upright orientation if the detection params
+// set TryRotation.
+func (c *EKYCPipeline) estimateHeadPose(img gocv.Mat, lmk *tensor.Dense) (*config.HeadPose, error) {
+	rotation := c.uprightRotation(img, lmk)
+	if rotation == 0 {
+		return c.HeadPose.Estimate(img, lmk)
+	}
+	size := config.Size{Width: img.Rows(), Height: img.Cols()}
+	if rotation == 180 {
+		size = config.Size{Width: img.Cols(), Height: img.Rows()}
 	}
+	return modules.EstimateHeadPose(size, modules.RotateLandmark(lmk, rotation, img.Cols(), img.Rows()))
+}
+
+/*
+checkHeadPoses rejects faces whose head pose exceeds the configured limits.
 
-	var dotProduct, normA, normB float64
+Inputs:
 
-	for i := 0; i < len(a); i++ {
-		dotProduct += float64(a[i] * b[i])
-		normA += float64(a[i] * a[i])
-		normB += float64(b[i] * b[i])
+  - images ([]gocv.Mat): input face images.
+  - landmarks ([]*tensor.Dense): input face landmarks.
+  - names ([]string): image names used in the error message.
+*/
+func (c *EKYCPipeline) checkHeadPoses(images []gocv.Mat, landmarks []*tensor.Dense, names []string) error {
+	for idx := range images {
+		pose, err := c.estimateHeadPose(images[idx], landmarks[idx])
+		if err != nil {
+			return err
+		}
+		if !c.HeadPose.IsWithinLimits(pose) {
+			return fmt.Errorf("face pose in %s image exceeds limits (yaw %.1f, pitch %.1f, roll %.1f)", names[idx], pose.Yaw, pose.Pitch, pose.Roll)
+		}
+	}
+	return nil
+}
+
+/*
+checkAlignments rejects landmarks that do not fit the alignment template, e.g. client-supplied landmarks that do not
+form a face, before inference on the aligned faces.
+*/
+func (c *EKYCPipeline) checkAlignments(qualities []config.AlignmentQuality, names []string) error {
+	for idx, quality := range qualities {
+		if !c.FaceHelper.IsPlausibleAlignment(quality) {
+			return fmt.Errorf("face landmarks in %s image are implausible (alignment error %.2f, scale %.2f, rotation %.1f, %d/%d inliers)", names[idx], quality.NormalizedError, quality.Scale, quality.Rotation, quality.NumInliers, quality.NumPoints)
+		}
 	}
+	return nil
+}
 
-	normA = math.Sqrt(normA)
-	normB = math.Sqrt(normB)
+/*
+validateLandmarks checks the client-supplied landmarks of the input images against a server-side detection, which is
+also used when the landmarks are missing.
+
+Inputs:
+
+  - images ([]gocv.Mat): input face images.
+  - landmarks ([]*tensor.Dense): client landmarks, nil if not supplied.
+  - names ([]string): image names for error messages.
+
+Outputs:
 
-	if normA == 0 || normB == 0 {
-		return 0, fmt.Errorf("zero vector encountered")
+  - landmarks ([]*tensor.Dense): landmarks to use for each image.
+  - checks ([]config.LandmarkCheck): validation result of each image checked so far.
+*/
+func (c *EKYCPipeline) validateLandmarks(ctx context.Context, images []gocv.Mat, landmarks []*tensor.Dense, names []string) ([]*tensor.Dense, []config.LandmarkCheck, error) {
+	validated := make([]*tensor.Dense, 0, len(images))
+	checks := make([]config.LandmarkCheck, 0, len(images))
+
+	for idx := range images {
+		var detected *tensor.Dense
+		if landmarks[idx] == nil || c.Validator.IsDetectionRequired() {
+			_, detLmks, err := c.detectLandmarks(ctx, []gocv.Mat{images[idx]}, nil, utils.RefPointer(true), utils.RefPointer(true))
+			if err != nil {
+				return nil, checks, err
+			}
+			if len(detLmks) > 0 {
+				detected = detLmks[0]
+			} else if landmarks[idx] == nil {
+				c.metrics.Load().FaceNotFound(names[idx])
+			}
+		}
+
+		lmk, check, err := c.Validator.Validate(landmarks[idx], detected, images[idx].Cols(), images[idx].Rows())
+		checks = append(checks, check)
+		if err != nil {
+			return nil, checks, fmt.Errorf("%s image: %w", names[idx], err)
+		}
+		validated = append(validated, lmk)
 	}
+	return validated, checks, nil
+}
+
+// validateLandmark validates the client-supplied landmark of an image, see validateLandmarks. A nil landmark is
+// returned as is, for the caller to detect.
+func (c *EKYCPipeline) validateLandmark(ctx context.Context, img gocv.Mat, lmk *tensor.Dense, name string) (*tensor.Dense, error) {
+	if lmk == nil {
+		return nil, nil
+	}
+	landmarks, _, err := c.validateLandmarks(ctx, []gocv.Mat{img}, []*tensor.Dense{lmk}, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	return landmarks[0], nil
+}
 
-	cosineSimilarity := dotProduct / (normA * normB)
+/*
+imageQualityCheck computes the local image quality of the input captures.
+
+Inputs:
+
+  - images ([]gocv.Mat): input face images.
+  - landmarks ([]*tensor.Dense): input face landmarks.
+
+Outputs:
 
-	return float32(cosineSimilarity), nil
+  - qualities ([]config.ImageQuality): Local quality metrics of each image.
+  - isGoodQuality (bool): Quality decision.
+*/
+func (c *EKYCPipeline) imageQualityCheck(ctx context.Context, images []gocv.Mat, landmarks []*tensor.Dense) (_ []config.ImageQuality, _ bool, err error) {
+	ctx, end := modules.StartSpan(ctx, "ekyc.image_quality_check")
+	defer func() { end(err) }()
+
+	qualities, err := c.Quality.AssessBatch(images, landmarks)
+	if err != nil {
+		return nil, false, err
+	}
+
+	isGoodQuality := true
+	for _, quality := range qualities {
+		isGoodQuality = isGoodQuality && quality.IsAcceptable
+	}
+	c.observeDecision(ctx, "image_quality", isGoodQuality)
+	return qualities, isGoodQuality, nil
 }
 
 /*
@@ -119,8 +397,8 @@ Outputs:
 
   - images ([]*tensor.Dense): N-Dimension Array of face embeddings.
 */
-func (c *EKYCPipeline) embeddingExtraction(images []gocv.Mat) ([]*tensor.Dense, error) {
-	embeddings, err := c.FaceID.InferBatch([][]gocv.Mat{images})
+func (c *EKYCPipeline) embeddingExtraction(ctx context.Context, images []gocv.Mat) ([]*tensor.Dense, error) {
+	embeddings, err := c.FaceID.InferBatchContext(ctx, [][]gocv.Mat{images})
 	if err != nil {
 		return nil, err
 	}
@@ -145,22 +423,33 @@ Outputs:
   - maskScore (float32): Face mask score from model.
   - isFaceMask (float32): Face mask decision.
 */
-func (c *EKYCPipeline) getFaceQuality(imgFar, imgMid, imgNear gocv.Mat, lmkFar, lmkMid, lmkNear *tensor.Dense) (float32, bool, error) {
+func (c *EKYCPipeline) getFaceQuality(ctx context.Context, imgFar, imgMid, imgNear gocv.Mat, lmkFar, lmkMid, lmkNear *tensor.Dense) (_ float32, _ bool, err error) {
+	ctx, end := modules.StartSpan(ctx, "ekyc.face_mask_check")
+	defer func() { end(err) }()
 
-	var err error
 	var maskScore float32
 	var isFaceMask bool
 
-	croppedFace, _, err := c.FaceHelper.AlignWarpFaces(
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	croppedFace, affineMatrices, qualities, err := c.alignFaces(
+		ctx,
+		c.FaceHelper.AlignWarpFaces,
 		[]gocv.Mat{imgFar, imgMid, imgNear},
 		[]*tensor.Dense{lmkFar, lmkMid, lmkNear},
-		nil,
 	)
+	scope.Track(croppedFace...)
+	scope.Track(affineMatrices...)
+	if err != nil {
+		return maskScore, isFaceMask, err
+	}
+	err = c.checkAlignments(qualities, []string{"far-face", "mid-face", "near-face"})
 	if err != nil {
 		return maskScore, isFaceMask, err
 	}
 
-	scoreCover, err := c.FaceQuality.InferBatch([][]gocv.Mat{croppedFace})
+	scoreCover, err := c.FaceQuality.InferBatchContext(ctx, [][]gocv.Mat{croppedFace})
 	if err != nil {
 		return maskScore, isFaceMask, err
 	}
@@ -173,6 +462,8 @@ func (c *EKYCPipeline) getFaceQuality(imgFar, imgMid, imgNear gocv.Mat, lmkFar,
 		}
 	}
 
+	c.observeDecision(ctx, "face_mask", isFaceMask, maskScore)
+
 	return maskScore, isFaceMask, nil
 }
 
@@ -194,32 +485,45 @@ Outputs:
   - livenessScoreFull (float32): Liveness score using full image model.
   - isLiveness (bool): Liveness decision.
 */
-func (c *EKYCPipeline) livenessActiveCheck(imgFar, imgMid, imgNear gocv.Mat, lmkFar, lmkMid, lmkNear *tensor.Dense) (float32, float32, bool, error) {
+func (c *EKYCPipeline) livenessActiveCheck(ctx context.Context, imgFar, imgMid, imgNear gocv.Mat, lmkFar, lmkMid, lmkNear *tensor.Dense) (_, _ float32, _ bool, err error) {
+	ctx, end := modules.StartSpan(ctx, "ekyc.liveness_check")
+	defer func() { end(err) }()
 
-	var err error
 	var livenessScoreCrop, livenessScoreFull float32
 	var isLiveness bool
 
-	croppedFaces, _, err := c.FaceHelper.AlignFASFaces(
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	croppedFaces, affineMatrices, qualities, err := c.alignFaces(
+		ctx,
+		c.FaceHelper.AlignFASFaces,
 		[]gocv.Mat{imgFar, imgMid, imgNear},
 		[]*tensor.Dense{lmkFar, lmkMid, lmkNear},
-		nil,
 	)
+	scope.Track(croppedFaces...)
+	scope.Track(affineMatrices...)
+	if err != nil {
+		return livenessScoreCrop, livenessScoreFull, isLiveness, err
+	}
+	err = c.checkAlignments(qualities, []string{"far-face", "mid-face", "near-face"})
 	if err != nil {
 		return livenessScoreCrop, livenessScoreFull, isLiveness, err
 	}
 
 	// infer fas crop
-	livenessScoreCrop, err = c.FaceASCrop.InferSingle(croppedFaces[0], croppedFaces[1], croppedFaces[2])
+	livenessScoreCrop, err = c.FaceASCrop.InferSingleContext(ctx, croppedFaces[0], croppedFaces[1], croppedFaces[2])
 	if err != nil {
 		return livenessScoreCrop, livenessScoreFull, isLiveness, err
 	}
 	// infer fas full
-	livenessScoreFull, err = c.FaceASFull.InferSingle(imgFar, imgMid, imgNear)
+	livenessScoreFull, err = c.FaceASFull.InferSingleContext(ctx, imgFar, imgMid, imgNear)
 	if err != nil {
 		return livenessScoreCrop, livenessScoreFull, isLiveness, err
 	}
 	isLiveness = (livenessScoreCrop > c.FaceASCrop.ModelParams.Threshold) && (livenessScoreFull > c.FaceASFull.ModelParams.Threshold)
+	c.observeLiveness(ctx, livenessScoreCrop, livenessScoreFull, isLiveness)
+	c.Shadow.liveness(c.FaceASCrop, c.FaceASFull, croppedFaces, []gocv.Mat{imgFar, imgMid, imgNear}, livenessScoreCrop, livenessScoreFull)
 
 	return livenessScoreCrop, livenessScoreFull, isLiveness, nil
 }
@@ -242,26 +546,37 @@ Outputs:
   - livenessScoreFull (float32): Liveness score using full image model.
   - isLiveness (bool): Liveness decision.
 */
-func (c *EKYCPipeline) livenessPassiveCheck(fImgFar, fImgMid, fImgNear, cImgFar, cImgMid, cImgNear gocv.Mat) (float32, float32, bool, error) {
-	var err error
+func (c *EKYCPipeline) livenessPassiveCheck(ctx context.Context, fImgFar, fImgMid, fImgNear, cImgFar, cImgMid, cImgNear gocv.Mat) (_, _ float32, _ bool, err error) {
+	ctx, end := modules.StartSpan(ctx, "ekyc.liveness_check")
+	defer func() { end(err) }()
+
 	var livenessScoreCrop, livenessScoreFull float32
 	var isLiveness bool
 
 	// infer fas crop
-	livenessScoreCrop, err = c.FaceASCrop.InferSingle(cImgFar, cImgMid, cImgNear)
+	livenessScoreCrop, err = c.FaceASCrop.InferSingleContext(ctx, cImgFar, cImgMid, cImgNear)
 	if err != nil {
 		return livenessScoreCrop, livenessScoreFull, isLiveness, err
 	}
 	// infer fas full
-	livenessScoreFull, err = c.FaceASFull.InferSingle(fImgFar, fImgMid, fImgNear)
+	livenessScoreFull, err = c.FaceASFull.InferSingleContext(ctx, fImgFar, fImgMid, fImgNear)
 	if err != nil {
 		return livenessScoreCrop, livenessScoreFull, isLiveness, err
 	}
 	isLiveness = (livenessScoreCrop > c.FaceASCrop.ModelParams.Threshold) && (livenessScoreFull > c.FaceASFull.ModelParams.Threshold)
+	c.observeLiveness(ctx, livenessScoreCrop, livenessScoreFull, isLiveness)
+	c.Shadow.liveness(c.FaceASCrop, c.FaceASFull, []gocv.Mat{cImgFar, cImgMid, cImgNear}, []gocv.Mat{fImgFar, fImgMid, fImgNear}, livenessScoreCrop, livenessScoreFull)
 
 	return livenessScoreCrop, livenessScoreFull, isLiveness, nil
 }
 
+// observeLiveness records the decisions of the anti-spoofing models and the liveness decision they make together.
+func (c *EKYCPipeline) observeLiveness(ctx context.Context, scoreCrop, scoreFull float32, isLiveness bool) {
+	c.observeDecision(ctx, "liveness_crop", scoreCrop > c.FaceASCrop.ModelParams.Threshold, scoreCrop)
+	c.observeDecision(ctx, "liveness_full", scoreFull > c.FaceASFull.ModelParams.Threshold, scoreFull)
+	c.observeDecision(ctx, "liveness", isLiveness)
+}
+
 /*
 samePersonCheck verifies if the input images belong to the same person.
 
@@ -280,22 +595,42 @@ Outputs:
   - scoreMN (float32): Similarity score between mid- and near- images.
   - isSamePerson (bool): Similarity decision.
 */
-func (c *EKYCPipeline) samePersonCheck(imgFar, imgMid, imgNear gocv.Mat, lmkFar, lmkMid, lmkNear *tensor.Dense) (float32, float32, bool, error) {
+func (c *EKYCPipeline) samePersonCheck(ctx context.Context, imgFar, imgMid, imgNear gocv.Mat, lmkFar, lmkMid, lmkNear *tensor.Dense) (_, _ float32, _ bool, err error) {
+	ctx, end := modules.StartSpan(ctx, "ekyc.same_person_check")
+	defer func() { end(err) }()
 
-	var err error
 	var scoreFM, scoreMN float32
 	var isSamePerson bool
 
-	croppedFaces, _, err := c.FaceHelper.AlignWarpFaces(
+	err = c.checkHeadPoses(
 		[]gocv.Mat{imgFar, imgMid, imgNear},
 		[]*tensor.Dense{lmkFar, lmkMid, lmkNear},
-		nil,
+		[]string{"far-face", "mid-face", "near-face"},
 	)
 	if err != nil {
 		return scoreFM, scoreMN, isSamePerson, err
 	}
 
-	faceEmbeddings, err := c.embeddingExtraction(croppedFaces)
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	croppedFaces, affineMatrices, qualities, err := c.alignFaces(
+		ctx,
+		c.FaceHelper.AlignWarpFaces,
+		[]gocv.Mat{imgFar, imgMid, imgNear},
+		[]*tensor.Dense{lmkFar, lmkMid, lmkNear},
+	)
+	scope.Track(croppedFaces...)
+	scope.Track(affineMatrices...)
+	if err != nil {
+		return scoreFM, scoreMN, isSamePerson, err
+	}
+	err = c.checkAlignments(qualities, []string{"far-face", "mid-face", "near-face"})
+	if err != nil {
+		return scoreFM, scoreMN, isSamePerson, err
+	}
+
+	faceEmbeddings, err := c.embeddingExtraction(ctx, croppedFaces)
 	if err != nil {
 		return scoreFM, scoreMN, isSamePerson, err
 	}
@@ -312,6 +647,8 @@ func (c *EKYCPipeline) samePersonCheck(imgFar, imgMid, imgNear gocv.Mat, lmkFar,
 	}
 
 	isSamePerson = scoreFM >= c.FaceID.ModelParams.ThresholdSamePerson && scoreMN >= c.FaceID.ModelParams.ThresholdSamePerson
+	c.observeDecision(ctx, "same_person", isSamePerson, scoreFM, scoreMN)
+	c.Shadow.samePerson(c.FaceID, croppedFaces, scoreFM, scoreMN, isSamePerson)
 
 	return scoreFM, scoreMN, isSamePerson, nil
 }
@@ -320,41 +657,57 @@ func (c *EKYCPipeline) samePersonCheck(imgFar, imgMid, imgNear gocv.Mat, lmkFar,
 GetFaceLandmarks5 returns the facial alndmarks of the input images.
 Inputs:
 
-  - images ([]gocv.Mat): input face images.
+  - images ([]utils.Image): input face images.
 
 Outputs:
 
   - batchLandmarks: ([]*tensor.Dense): Facial landmarks from the input images.
 */
-func (c *EKYCPipeline) GetFaceLandmarks5(batchImages []gocv.Mat) ([]*tensor.Dense, error) {
-	_, batchLandmarks, err := c.FaceHelper.GetFaceLandmarks5(
-		batchImages,
-		nil,
-		utils.RefPointer(true),
-		nil,
-		nil,
-		utils.RefPointer(true),
-	)
+func (c *EKYCPipeline) GetFaceLandmarks5(batchImages []utils.Image) ([]*tensor.Dense, error) {
+	return c.GetFaceLandmarks5Context(context.Background(), batchImages)
+}
+
+// GetFaceLandmarks5Context is GetFaceLandmarks5 run within ctx.
+func (c *EKYCPipeline) GetFaceLandmarks5Context(ctx context.Context, batchImages []utils.Image) (_ []*tensor.Dense, err error) {
+	ctx, end := c.startOperation(ctx, "face_landmarks")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, batchImages...)
+	if err != nil {
+		return nil, err
+	}
+	_, batchLandmarks, err := c.detectLandmarks(ctx, mats, nil, utils.RefPointer(true), utils.RefPointer(true))
 	return batchLandmarks, err
 }
 
 /*
 FaceAntiSpoofingActiveVerify verifies face from input face images and landmarks.
+Client landmarks are validated by the pipeline Validator, missing ones are detected.
 
 Inputs:
 
-  - imgFar (gocv.Mat): Capture far-distance face image.
-  - imgMid (gocv.Mat): Capture mid-distance face image.
-  - imgNear (gocv.Mat): Capture near-distance face image.
-  - lmkFar (*tensor.Dense): imgFar landmarks.
-  - lmkMid (*tensor.Dense): imgMid landmarks.
-  - lmkNear (*tensor.Dense): imgNear landmarks.
+  - far (utils.Image): Capture far-distance face image.
+  - mid (utils.Image): Capture mid-distance face image.
+  - near (utils.Image): Capture near-distance face image.
+  - lmkFar (*tensor.Dense): far landmarks, detected if nil.
+  - lmkMid (*tensor.Dense): mid landmarks, detected if nil.
+  - lmkNear (*tensor.Dense): near landmarks, detected if nil.
 
 Outputs:
 
   - scoreFM (*FaceAntiSpoofingVerify): face anti-spoofing result.
 */
-func (c *EKYCPipeline) FaceAntiSpoofingActiveVerify(imgFar, imgMid, imgNear gocv.Mat, lmkFar, lmkMid, lmkNear *tensor.Dense) (*config.FaceAntiSpoofingVerify, error) {
+func (c *EKYCPipeline) FaceAntiSpoofingActiveVerify(far, mid, near utils.Image, lmkFar, lmkMid, lmkNear *tensor.Dense) (*config.FaceAntiSpoofingVerify, error) {
+	return c.FaceAntiSpoofingActiveVerifyContext(context.Background(), far, mid, near, lmkFar, lmkMid, lmkNear)
+}
+
+// FaceAntiSpoofingActiveVerifyContext is FaceAntiSpoofingActiveVerify run within ctx.
+func (c *EKYCPipeline) FaceAntiSpoofingActiveVerifyContext(ctx context.Context, far, mid, near utils.Image, lmkFar, lmkMid, lmkNear *tensor.Dense) (_ *config.FaceAntiSpoofingVerify, err error) {
+	ctx, end := c.startOperation(ctx, "active_verify")
+	defer func() { end(err) }()
 
 	resp := &config.FaceAntiSpoofingVerify{
 		IsFaceMask:        false,
@@ -367,39 +720,37 @@ func (c *EKYCPipeline) FaceAntiSpoofingActiveVerify(imgFar, imgMid, imgNear gocv
 		FaceMaskScore:     -1,
 	}
 
-	if lmkFar == nil {
-		_, fLmks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{imgFar}, nil, utils.RefPointer(true), nil, nil, utils.RefPointer(true))
-		if err != nil {
-			return resp, err
-		}
-		if len(fLmks) == 0 {
-			return resp, errors.New("cannot detect any face in mid-face image")
-		}
-		lmkFar = fLmks[0]
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, far, mid, near)
+	if err != nil {
+		return resp, err
 	}
-	if lmkMid == nil {
-		_, mLmks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{imgMid}, nil, utils.RefPointer(true), nil, nil, utils.RefPointer(true))
-		if err != nil {
-			return resp, err
-		}
-		if len(mLmks) == 0 {
-			return resp, errors.New("cannot detect any face in mid-face image")
-		}
-		lmkMid = mLmks[0]
+	imgFar, imgMid, imgNear := mats[0], mats[1], mats[2]
+
+	landmarks, checks, err := c.validateLandmarks(
+		ctx,
+		[]gocv.Mat{imgFar, imgMid, imgNear},
+		[]*tensor.Dense{lmkFar, lmkMid, lmkNear},
+		[]string{"far-face", "mid-face", "near-face"},
+	)
+	resp.LandmarkChecks = checks
+	if err != nil {
+		return resp, err
 	}
-	if lmkNear == nil {
-		_, nLmks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{imgNear}, nil, utils.RefPointer(true), nil, nil, utils.RefPointer(true))
-		if err != nil {
-			return resp, err
-		}
-		if len(nLmks) == 0 {
-			return resp, errors.New("cannot detect any face in near-face image")
-		}
-		lmkNear = nLmks[0]
+	lmkFar, lmkMid, lmkNear = landmarks[0], landmarks[1], landmarks[2]
+
+	// Check local image quality
+	qualities, isGoodQuality, err := c.imageQualityCheck(ctx, []gocv.Mat{imgFar, imgMid, imgNear}, []*tensor.Dense{lmkFar, lmkMid, lmkNear})
+	if err != nil {
+		return resp, err
 	}
+	resp.ImageQualities = qualities
+	resp.IsGoodQuality = isGoodQuality
 
 	// Check same person
-	scoreFM, scoreMN, isSamePerson, err := c.samePersonCheck(imgFar, imgMid, imgNear, lmkFar, lmkMid, lmkNear)
+	scoreFM, scoreMN, isSamePerson, err := c.samePersonCheck(ctx, imgFar, imgMid, imgNear, lmkFar, lmkMid, lmkNear)
 	if err != nil {
 		return resp, err
 	}
@@ -409,7 +760,7 @@ func (c *EKYCPipeline) FaceAntiSpoofingActiveVerify(imgFar, imgMid, imgNear gocv
 	resp.IsSamePerson = isSamePerson
 
 	// Check face obstruction
-	maskScore, isFaceMask, err := c.getFaceQuality(imgFar, imgMid, imgNear, lmkFar, lmkMid, lmkNear)
+	maskScore, isFaceMask, err := c.getFaceQuality(ctx, imgFar, imgMid, imgNear, lmkFar, lmkMid, lmkNear)
 	if err != nil {
 		return resp, err
 	}
@@ -417,13 +768,14 @@ func (c *EKYCPipeline) FaceAntiSpoofingActiveVerify(imgFar, imgMid, imgNear gocv
 	resp.IsFaceMask = isFaceMask
 
 	// Check liveness
-	livenessScoreCrop, livenessScoreFull, isLiveness, err := c.livenessActiveCheck(imgFar, imgMid, imgNear, lmkFar, lmkMid, lmkNear)
+	livenessScoreCrop, livenessScoreFull, isLiveness, err := c.livenessActiveCheck(ctx, imgFar, imgMid, imgNear, lmkFar, lmkMid, lmkNear)
 	if err != nil {
 		return resp, err
 	}
 	resp.LivenessScoreCrop = livenessScoreCrop
 	resp.LivenessScoreFull = livenessScoreFull
 	resp.IsLiveness = isLiveness
+	resp.ModelVersions = c.ModelVersions()
 
 	return resp, nil
 }
@@ -433,15 +785,35 @@ FaceAntiSpoofingPassiveVerify verifies face from input face images.
 
 Inputs:
 
-  - fImgFar (gocv.Mat): Capture far-distance face image.
-  - fImgMid (gocv.Mat): Capture mid-distance face image.
-  - fImgNear (gocv.Mat): Capture near-distance face image.
+  - far (utils.Image): Capture far-distance face image.
+  - mid (utils.Image): Capture mid-distance face image.
+  - near (utils.Image): Capture near-distance face image.
 
 Outputs:
 
   - scoreFM (*FaceAntiSpoofingVerify): face anti-spoofing result.
 */
-func (c *EKYCPipeline) FaceAntiSpoofingPassiveVerify(fImgFar, fImgMid, fImgNear gocv.Mat) (*config.FaceAntiSpoofingVerify, error) {
+func (c *EKYCPipeline) FaceAntiSpoofingPassiveVerify(far, mid, near utils.Image) (*config.FaceAntiSpoofingVerify, error) {
+	return c.FaceAntiSpoofingPassiveVerifyContext(context.Background(), far, mid, near)
+}
+
+// FaceAntiSpoofingPassiveVerifyContext is FaceAntiSpoofingPassiveVerify run within ctx.
+func (c *EKYCPipeline) FaceAntiSpoofingPassiveVerifyContext(ctx context.Context, far, mid, near utils.Image) (_ *config.FaceAntiSpoofingVerify, err error) {
+	ctx, end := c.startOperation(ctx, "passive_verify")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, far, mid, near)
+	if err != nil {
+		return nil, err
+	}
+	return c.faceAntiSpoofingPassiveVerify(ctx, mats[0], mats[1], mats[2])
+}
+
+// faceAntiSpoofingPassiveVerify implements FaceAntiSpoofingPassiveVerify on images in the pipeline color order.
+func (c *EKYCPipeline) faceAntiSpoofingPassiveVerify(ctx context.Context, fImgFar, fImgMid, fImgNear gocv.Mat) (*config.FaceAntiSpoofingVerify, error) {
 
 	resp := &config.FaceAntiSpoofingVerify{
 		IsFaceMask:        false,
@@ -454,41 +826,56 @@ func (c *EKYCPipeline) FaceAntiSpoofingPassiveVerify(fImgFar, fImgMid, fImgNear
 		FaceMaskScore:     -1,
 	}
 
-	_, fLmks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{fImgFar}, utils.RefPointer(true), nil, nil, nil, utils.RefPointer(true))
+	_, fLmks, err := c.detectLandmarks(ctx, []gocv.Mat{fImgFar}, utils.RefPointer(true), nil, utils.RefPointer(true))
 	if err != nil {
 		return resp, err
 	}
 	if len(fLmks) == 0 {
+		c.metrics.Load().FaceNotFound("far-face")
 		return resp, errors.New("cannot detect any face in far-face image")
 	}
 	lmkFar := fLmks[0]
 
-	_, mLmks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{fImgMid}, utils.RefPointer(true), nil, nil, nil, utils.RefPointer(true))
+	_, mLmks, err := c.detectLandmarks(ctx, []gocv.Mat{fImgMid}, utils.RefPointer(true), nil, utils.RefPointer(true))
 	if err != nil {
 		return resp, err
 	}
 	if len(mLmks) == 0 {
+		c.metrics.Load().FaceNotFound("mid-face")
 		return resp, errors.New("cannot detect any face in mid-face image")
 	}
 	lmkMid := mLmks[0]
 
-	_, nLmks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{fImgNear}, utils.RefPointer(true), nil, nil, nil, utils.RefPointer(true))
+	_, nLmks, err := c.detectLandmarks(ctx, []gocv.Mat{fImgNear}, utils.RefPointer(true), nil, utils.RefPointer(true))
 	if err != nil {
 		return resp, err
 	}
 	if len(nLmks) == 0 {
+		c.metrics.Load().FaceNotFound("near-face")
 		return resp, errors.New("cannot detect any face in near-face image")
 	}
 	lmkNear := nLmks[0]
 
+	// Check local image quality
+	qualities, isGoodQuality, err := c.imageQualityCheck(ctx, []gocv.Mat{fImgFar, fImgMid, fImgNear}, []*tensor.Dense{lmkFar, lmkMid, lmkNear})
+	if err != nil {
+		return resp, err
+	}
+	resp.ImageQualities = qualities
+	resp.IsGoodQuality = isGoodQuality
+
 	// Check same person
-	scoreFM, scoreMN, isSamePerson, err := c.samePersonCheck(fImgFar, fImgMid, fImgNear, lmkFar, lmkMid, lmkNear)
+	scoreFM, scoreMN, isSamePerson, err := c.samePersonCheck(ctx, fImgFar, fImgMid, fImgNear, lmkFar, lmkMid, lmkNear)
+	if err != nil {
+		return resp, err
+	}
+
 	resp.ScoreFM = scoreFM
 	resp.ScoreMN = scoreMN
 	resp.IsSamePerson = isSamePerson
 
 	// Check face obstruction
-	maskScore, isFaceMask, err := c.getFaceQuality(fImgFar, fImgMid, fImgNear, lmkFar, lmkMid, lmkNear)
+	maskScore, isFaceMask, err := c.getFaceQuality(ctx, fImgFar, fImgMid, fImgNear, lmkFar, lmkMid, lmkNear)
 	if err != nil {
 		return resp, err
 	}
@@ -496,60 +883,97 @@ func (c *EKYCPipeline) FaceAntiSpoofingPassiveVerify(fImgFar, fImgMid, fImgNear
 	resp.IsFaceMask = isFaceMask
 
 	// Check liveness
-	livenessCrop, livenessFull, isLiveness, err := c.livenessActiveCheck(fImgFar, fImgMid, fImgNear, lmkFar, lmkMid, lmkNear)
+	livenessCrop, livenessFull, isLiveness, err := c.livenessActiveCheck(ctx, fImgFar, fImgMid, fImgNear, lmkFar, lmkMid, lmkNear)
 	if err != nil {
 		return resp, err
 	}
 	resp.LivenessScoreCrop = livenessCrop
 	resp.LivenessScoreFull = livenessFull
 	resp.IsLiveness = isLiveness
+	resp.ModelVersions = c.ModelVersions()
 
 	return resp, nil
 }
 
 /*
 PersonIDCardVerify checks if the face on the id card matches with input face image
+Client landmarks are validated by the pipeline Validator, missing ones are detected.
 
 Inputs:
 
-  - cardImg (gocv.Mat): ID card with face.
-  - imgFar (gocv.Mat): Capture far-distance face image.
-  - lmkFar (*tensor.Dense): imgFar landmarks.
+  - card (utils.Image): ID card with face.
+  - far (utils.Image): Capture far-distance face image.
+  - lmkFar (*tensor.Dense): far landmarks, detected if nil.
 
 Outputs:
 
   - scoreFM (*FaceAntiSpoofingVerify): face anti-spoofing result.
 */
-func (c *EKYCPipeline) PersonIDCardVerify(cardImg, ImgFar gocv.Mat, lmkFar *tensor.Dense) (float32, bool, error) {
+func (c *EKYCPipeline) PersonIDCardVerify(card, far utils.Image, lmkFar *tensor.Dense) (float32, bool, error) {
+	return c.PersonIDCardVerifyContext(context.Background(), card, far, lmkFar)
+}
+
+// PersonIDCardVerifyContext is PersonIDCardVerify run within ctx.
+func (c *EKYCPipeline) PersonIDCardVerifyContext(ctx context.Context, card, far utils.Image, lmkFar *tensor.Dense) (_ float32, _ bool, err error) {
+	ctx, end := c.startOperation(ctx, "id_card_verify")
+	defer func() { end(err) }()
 
-	var err error
 	var similarityScore float32
 	var isSamePerson bool
 
-	_, cardLmks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{cardImg}, utils.RefPointer(true), nil, nil, nil, utils.RefPointer(true))
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, card, far)
+	if err != nil {
+		return similarityScore, isSamePerson, err
+	}
+	cardImg, ImgFar := mats[0], mats[1]
+
+	_, cardLmks, err := c.detectLandmarks(ctx, []gocv.Mat{cardImg}, utils.RefPointer(true), nil, utils.RefPointer(true))
 	if err != nil {
 		return similarityScore, isSamePerson, err
 	}
 
 	if len(cardLmks) == 0 {
+		c.metrics.Load().FaceNotFound("card")
 		return similarityScore, isSamePerson, errors.New("cannot detect face in card image")
 	}
 	cardLmk := cardLmks[0]
 
+	lmkFar, err = c.validateLandmark(ctx, ImgFar, lmkFar, "input-face")
+	if err != nil {
+		return similarityScore, isSamePerson, err
+	}
 	if lmkFar == nil {
-		_, landmarks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{ImgFar}, nil, utils.RefPointer(true), nil, nil, utils.RefPointer(true))
+		_, landmarks, err := c.detectLandmarks(ctx, []gocv.Mat{ImgFar}, nil, utils.RefPointer(true), utils.RefPointer(true))
 		if err != nil {
 			return similarityScore, isSamePerson, err
 		}
 		if len(landmarks) == 0 {
+			c.metrics.Load().FaceNotFound("input-face")
 			return similarityScore, isSamePerson, errors.New("cannot detect face in input face image")
 		}
 		lmkFar = landmarks[0]
 	}
 
-	croppedFaces, _, err := c.FaceHelper.AlignWarpFaces([]gocv.Mat{cardImg, ImgFar}, []*tensor.Dense{cardLmk, lmkFar}, nil)
+	err = c.checkHeadPoses([]gocv.Mat{cardImg, ImgFar}, []*tensor.Dense{cardLmk, lmkFar}, []string{"card", "input face"})
+	if err != nil {
+		return similarityScore, isSamePerson, err
+	}
 
-	extractions, err := c.embeddingExtraction(croppedFaces)
+	croppedFaces, affineMatrices, qualities, err := c.alignFaces(ctx, c.FaceHelper.AlignWarpFaces, []gocv.Mat{cardImg, ImgFar}, []*tensor.Dense{cardLmk, lmkFar})
+	scope.Track(croppedFaces...)
+	scope.Track(affineMatrices...)
+	if err != nil {
+		return similarityScore, isSamePerson, err
+	}
+	err = c.checkAlignments(qualities, []string{"card", "input face"})
+	if err != nil {
+		return similarityScore, isSamePerson, err
+	}
+
+	extractions, err := c.embeddingExtraction(ctx, croppedFaces)
 	if err != nil {
 		return similarityScore, isSamePerson, err
 	}
@@ -560,61 +984,117 @@ func (c *EKYCPipeline) PersonIDCardVerify(cardImg, ImgFar gocv.Mat, lmkFar *tens
 		return similarityScore, isSamePerson, err
 	}
 	isSamePerson = similarityScore >= c.FaceID.ModelParams.ThresholdSameEKYC
+	c.observeDecision(ctx, "id_card_same_person", isSamePerson, similarityScore)
 
 	return similarityScore, isSamePerson, nil
 }
 
 /*
 FaceQualityVerify checks for face obstructions
+Client landmarks are validated by the pipeline Validator, missing ones are detected.
 
 Inputs:
 
-  - imgFar (gocv.Mat): Capture far-distance face image.
-  - imgMid (gocv.Mat): Capture mid-distance face image.
-  - imgNear (gocv.Mat): Capture near-distance face image.
-  - lmkFar (*tensor.Dense): imgFar landmarks.
-  - lmkMid (*tensor.Dense): imgMid landmarks.
-  - lmkNear (*tensor.Dense): imgNear landmarks.
+  - far (utils.Image): Capture far-distance face image.
+  - mid (utils.Image): Capture mid-distance face image.
+  - near (utils.Image): Capture near-distance face image.
+  - lmkFar (*tensor.Dense): far landmarks, detected if nil.
+  - lmkMid (*tensor.Dense): mid landmarks, detected if nil.
+  - lmkNear (*tensor.Dense): near landmarks, detected if nil.
 
 Outputs:
 
   - maskScore (float32): Face mask score from model.
   - isFaceMask (float32): Face mask decision.
 */
-func (c *EKYCPipeline) FaceQualityVerify(imgFar, imgMid, imgNear gocv.Mat, lmkFar, lmkMid, lmkNear *tensor.Dense) (float32, bool, error) {
-	return c.getFaceQuality(imgFar, imgMid, imgNear, lmkFar, lmkMid, lmkNear)
+func (c *EKYCPipeline) FaceQualityVerify(far, mid, near utils.Image, lmkFar, lmkMid, lmkNear *tensor.Dense) (float32, bool, error) {
+	return c.FaceQualityVerifyContext(context.Background(), far, mid, near, lmkFar, lmkMid, lmkNear)
+}
+
+// FaceQualityVerifyContext is FaceQualityVerify run within ctx.
+func (c *EKYCPipeline) FaceQualityVerifyContext(ctx context.Context, far, mid, near utils.Image, lmkFar, lmkMid, lmkNear *tensor.Dense) (_ float32, _ bool, err error) {
+	ctx, end := c.startOperation(ctx, "face_quality_verify")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, far, mid, near)
+	if err != nil {
+		return -1, false, err
+	}
+	landmarks, _, err := c.validateLandmarks(
+		ctx,
+		mats,
+		[]*tensor.Dense{lmkFar, lmkMid, lmkNear},
+		[]string{"far-face", "mid-face", "near-face"},
+	)
+	if err != nil {
+		return -1, false, err
+	}
+	return c.getFaceQuality(ctx, mats[0], mats[1], mats[2], landmarks[0], landmarks[1], landmarks[2])
 }
 
 /*
 ExtractFaceVector extracts face from inputs image as slice of float32.
+Client landmarks are validated by the pipeline Validator, missing ones are detected.
 
 Inputs:
 
-  - img (gocv.Mat): Capture face image.
-  - lmk (*tensor.Dense): img landmarks.
+  - image (utils.Image): Capture face image.
+  - lmk (*tensor.Dense): image landmarks, detected if nil.
 
 Outputs:
 
   - vector ([]float32): Vector representations of input face image.
 */
-func (c *EKYCPipeline) ExtractFaceVector(img gocv.Mat, lmk *tensor.Dense) ([]float32, error) {
+func (c *EKYCPipeline) ExtractFaceVector(image utils.Image, lmk *tensor.Dense) ([]float32, error) {
+	return c.ExtractFaceVectorContext(context.Background(), image, lmk)
+}
+
+// ExtractFaceVectorContext is ExtractFaceVector run within ctx.
+func (c *EKYCPipeline) ExtractFaceVectorContext(ctx context.Context, image utils.Image, lmk *tensor.Dense) (_ []float32, err error) {
+	ctx, end := c.startOperation(ctx, "extract_face_vector")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, image)
+	if err != nil {
+		return nil, err
+	}
+	img := mats[0]
+
+	lmk, err = c.validateLandmark(ctx, img, lmk, "input-face")
+	if err != nil {
+		return nil, err
+	}
+
 	if lmk == nil {
-		_, landmarks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{img}, nil, utils.RefPointer(true), nil, nil, nil)
+		_, landmarks, err := c.detectLandmarks(ctx, []gocv.Mat{img}, nil, utils.RefPointer(true), nil)
 		if err != nil {
 			return nil, err
 		}
 		if len(landmarks) == 0 {
+			c.metrics.Load().FaceNotFound("input-face")
 			return nil, errors.New("cannot detect face in input face image")
 		}
 		lmk = landmarks[0]
 	}
 
-	croppedFaces, _, err := c.FaceHelper.AlignWarpFaces([]gocv.Mat{img}, []*tensor.Dense{lmk}, nil)
+	croppedFaces, affineMatrices, qualities, err := c.alignFaces(ctx, c.FaceHelper.AlignWarpFaces, []gocv.Mat{img}, []*tensor.Dense{lmk})
+	scope.Track(croppedFaces...)
+	scope.Track(affineMatrices...)
+	if err != nil {
+		return nil, err
+	}
+	err = c.checkAlignments(qualities, []string{"input face"})
 	if err != nil {
 		return nil, err
 	}
 
-	t, err := c.embeddingExtraction(croppedFaces)
+	t, err := c.embeddingExtraction(ctx, croppedFaces)
 	if err != nil {
 		return nil, err
 	}
@@ -627,19 +1107,37 @@ CropSelfie extracts face roi from input image.
 
 Inputs:
 
-  - img (gocv.Mat): Capture face image.
+  - image (utils.Image): Capture face image.
 
 Outputs:
 
-  - img (*gocv.Mat): ROI of the face.
+  - img (*utils.Image): ROI of the face, in the color order of the input image.
 */
-func (c *EKYCPipeline) CropSelfie(img gocv.Mat) (*gocv.Mat, error) {
-	bBoxes, landmarks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{img, img, img}, utils.RefPointer(true), nil, nil, nil, nil)
+func (c *EKYCPipeline) CropSelfie(image utils.Image) (*utils.Image, error) {
+	return c.CropSelfieContext(context.Background(), image)
+}
+
+// CropSelfieContext is CropSelfie run within ctx.
+func (c *EKYCPipeline) CropSelfieContext(ctx context.Context, image utils.Image) (_ *utils.Image, err error) {
+	ctx, end := c.startOperation(ctx, "crop_selfie")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, image)
+	if err != nil {
+		return nil, err
+	}
+	img := mats[0]
+
+	bBoxes, landmarks, err := c.detectLandmarks(ctx, []gocv.Mat{img, img, img}, utils.RefPointer(true), nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(bBoxes) == 0 {
+		c.metrics.Load().FaceNotFound("input-face")
 		return nil, errors.New("cannot detect face in input face image")
 	}
 	bbox := bBoxes[0]
@@ -648,15 +1146,13 @@ func (c *EKYCPipeline) CropSelfie(img gocv.Mat) (*gocv.Mat, error) {
 	if err != nil {
 		return nil, err
 	}
-	faceImage, _, err := c.FaceHelper.AlignFaceIDCard(img, lmk, bbox, nil)
+	faceImage, affineMatrix, err := c.FaceHelper.AlignFaceIDCard(img, lmk, bbox, nil)
+	scope.Track(faceImage, affineMatrix)
 	if err != nil {
 		return nil, err
 	}
 
-	rgbImage := gocv.NewMat()
-	gocv.CvtColor(faceImage, &rgbImage, gocv.ColorBGRToRGB)
-
-	return &rgbImage, nil
+	return c.outputImage(scope, faceImage, image.Order)
 }
 
 /*
@@ -664,21 +1160,39 @@ CropFaceIDCard extracts face roi from input id card image.
 
 Inputs:
 
-  - img (gocv.Mat): Capture face image.
+  - image (utils.Image): Capture face image.
 
 Outputs:
 
-  - img (*gocv.Mat): ROI of the face.
+  - img (*utils.Image): ROI of the face, in the color order of the input image.
 */
-func (c *EKYCPipeline) CropFaceIDCard(img gocv.Mat) (*gocv.Mat, error) {
+func (c *EKYCPipeline) CropFaceIDCard(image utils.Image) (*utils.Image, error) {
+	return c.CropFaceIDCardContext(context.Background(), image)
+}
+
+// CropFaceIDCardContext is CropFaceIDCard run within ctx.
+func (c *EKYCPipeline) CropFaceIDCardContext(ctx context.Context, image utils.Image) (_ *utils.Image, err error) {
+	ctx, end := c.startOperation(ctx, "crop_face_id_card")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, image)
+	if err != nil {
+		return nil, err
+	}
+	img := mats[0]
+
 	imgShapes := img.Size()
 	h, w := imgShapes[0], imgShapes[1]
 
-	bBoxes, landmarks, err := c.FaceHelper.GetFaceLandmarks5([]gocv.Mat{img}, utils.RefPointer(true), nil, nil, nil, nil)
+	bBoxes, landmarks, err := c.detectLandmarks(ctx, []gocv.Mat{img}, utils.RefPointer(true), nil, nil)
 	if err != nil {
 		return nil, err
 	}
 	if len(bBoxes) == 0 {
+		c.metrics.Load().FaceNotFound("input-face")
 		return nil, errors.New("cannot detect face in input face image")
 	}
 
@@ -686,6 +1200,9 @@ func (c *EKYCPipeline) CropFaceIDCard(img gocv.Mat) (*gocv.Mat, error) {
 		Width:  w % 5,
 		Height: h % 2,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	lmk := landmarks[centerIdx]
 	err = lmk.Reshape(5, 2)
@@ -693,13 +1210,407 @@ func (c *EKYCPipeline) CropFaceIDCard(img gocv.Mat) (*gocv.Mat, error) {
 		return nil, err
 	}
 
-	faceImage, _, err := c.FaceHelper.AlignFaceIDCard(img, lmk, box, nil)
+	faceImage, affineMatrix, err := c.FaceHelper.AlignFaceIDCard(img, lmk, box, nil)
+	scope.Track(faceImage, affineMatrix)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.outputImage(scope, faceImage, image.Order)
+}
+
+/*
+faceAntiSpoofingFramesVerify selects the far-, mid- and near-distance frames from sampled video frames and verifies them.
+
+Inputs:
+
+  - frames ([]gocv.Mat): Sampled RGB video frames.
+  - frameIndices ([]int): Index of each sampled frame in the video.
+  - params (*config.VideoFrameSelectionParams): Frame selection parameters.
+
+Outputs:
+
+  - result (*FaceAntiSpoofingVerify): face anti-spoofing result.
+  - selection (*VideoFrameSelection): selected video frames.
+*/
+func (c *EKYCPipeline) faceAntiSpoofingFramesVerify(ctx context.Context, frames []gocv.Mat, frameIndices []int, params *config.VideoFrameSelectionParams) (_ *config.FaceAntiSpoofingVerify, _ *config.VideoFrameSelection, err error) {
+	ctx, end := c.startOperation(ctx, "video_verify")
+	defer func() { end(err) }()
+
+	images, err := utils.NewImages(frames, utils.ColorOrderRGB)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	frames, err = c.prepareImages(scope, images...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selection, err := c.FaceHelper.SelectDistanceFramesContext(ctx, frames, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := c.faceAntiSpoofingPassiveVerify(ctx, frames[selection.FarIndex], frames[selection.MidIndex], frames[selection.NearIndex])
+
+	selection.FarIndex = frameIndices[selection.FarIndex]
+	selection.MidIndex = frameIndices[selection.MidIndex]
+	selection.NearIndex = frameIndices[selection.NearIndex]
+
+	return resp, selection, err
+}
+
+/*
+FaceAntiSpoofingVideoVerify verifies face from an input video buffer (MP4, MJPEG, ...).
+
+Inputs:
+
+  - bVideo ([]byte): Encoded video.
+  - params (*config.VideoFrameSelectionParams): Frame selection parameters, default if nil.
+
+Outputs:
+
+  - result (*FaceAntiSpoofingVerify): face anti-spoofing result.
+  - selection (*VideoFrameSelection): video frames used as far-, mid- and near-distance images.
+*/
+func (c *EKYCPipeline) FaceAntiSpoofingVideoVerify(bVideo []byte, params *config.VideoFrameSelectionParams) (*config.FaceAntiSpoofingVerify, *config.VideoFrameSelection, error) {
+	return c.FaceAntiSpoofingVideoVerifyContext(context.Background(), bVideo, params)
+}
+
+// FaceAntiSpoofingVideoVerifyContext is FaceAntiSpoofingVideoVerify run within ctx.
+func (c *EKYCPipeline) FaceAntiSpoofingVideoVerifyContext(ctx context.Context, bVideo []byte, params *config.VideoFrameSelectionParams) (*config.FaceAntiSpoofingVerify, *config.VideoFrameSelection, error) {
+	if params == nil {
+		params = config.DefaultVideoFrameSelectionParams
+	}
+
+	frames, frameIndices, err := utils.ReadVideoFramesFromBytes(bVideo, params.SampleStep, params.MaxFrames)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		for _, frame := range frames {
+			_ = frame.Close()
+		}
+	}()
+
+	return c.faceAntiSpoofingFramesVerify(ctx, frames, frameIndices, params)
+}
+
+/*
+FaceAntiSpoofingVideoFileVerify verifies face from an input video file.
+
+Inputs:
+
+  - fPath (string): Path to the video file.
+  - params (*config.VideoFrameSelectionParams): Frame selection parameters, default if nil.
+
+Outputs:
+
+  - result (*FaceAntiSpoofingVerify): face anti-spoofing result.
+  - selection (*VideoFrameSelection): video frames used as far-, mid- and near-distance images.
+*/
+func (c *EKYCPipeline) FaceAntiSpoofingVideoFileVerify(fPath string, params *config.VideoFrameSelectionParams) (*config.FaceAntiSpoofingVerify, *config.VideoFrameSelection, error) {
+	return c.FaceAntiSpoofingVideoFileVerifyContext(context.Background(), fPath, params)
+}
+
+// FaceAntiSpoofingVideoFileVerifyContext is FaceAntiSpoofingVideoFileVerify run within ctx.
+func (c *EKYCPipeline) FaceAntiSpoofingVideoFileVerifyContext(ctx context.Context, fPath string, params *config.VideoFrameSelectionParams) (*config.FaceAntiSpoofingVerify, *config.VideoFrameSelection, error) {
+	if params == nil {
+		params = config.DefaultVideoFrameSelectionParams
+	}
+
+	frames, frameIndices, err := utils.ReadVideoFrames(fPath, params.SampleStep, params.MaxFrames)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		for _, frame := range frames {
+			_ = frame.Close()
+		}
+	}()
+
+	return c.faceAntiSpoofingFramesVerify(ctx, frames, frameIndices, params)
+}
+
+/*
+IssueLivenessChallenges returns a random challenge sequence to issue to the user for active liveness verification,
+identified by a signed challenge ID valid for the challenge TTL of the params.
+
+Outputs:
+
+  - issue (*config.LivenessChallengeIssue): Challenge ID, challenge sequence and expiry.
+*/
+func (c *EKYCPipeline) IssueLivenessChallenges() (*config.LivenessChallengeIssue, error) {
+	return c.Challenge.IssueChallenges()
+}
+
+/*
+FaceActiveLivenessChallengeVerify verifies that the user performed the issued challenges in the input frame sequence.
+The challenge ID must have been issued by IssueLivenessChallenges, within its TTL, and is rejected once verified.
+
+Inputs:
+
+  - images ([]utils.Image): Captured frame sequence.
+  - timestamps ([]time.Duration): Capture timestamp of each frame, durations are not checked if nil.
+  - challengeID (string): ID of the challenge sequence issued to the user.
+
+Outputs:
+
+  - result (*FaceActiveLivenessVerify): per-challenge pass/fail and timing.
+*/
+func (c *EKYCPipeline) FaceActiveLivenessChallengeVerify(images []utils.Image, timestamps []time.Duration, challengeID string) (*config.FaceActiveLivenessVerify, error) {
+	return c.FaceActiveLivenessChallengeVerifyContext(context.Background(), images, timestamps, challengeID)
+}
+
+// FaceActiveLivenessChallengeVerifyContext is FaceActiveLivenessChallengeVerify run within ctx.
+func (c *EKYCPipeline) FaceActiveLivenessChallengeVerifyContext(ctx context.Context, images []utils.Image, timestamps []time.Duration, challengeID string) (_ *config.FaceActiveLivenessVerify, err error) {
+	ctx, end := c.startOperation(ctx, "liveness_challenge_verify")
+	defer func() { end(err) }()
+
+	if len(images) == 0 {
+		return nil, errors.New("input frames must not be empty")
+	}
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	frames, err := c.prepareImages(scope, images...)
+	if err != nil {
+		return nil, err
+	}
+
+	bboxes, landmarks, err := c.detectLandmarks(ctx, frames, utils.RefPointer(true), nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	rgbImage := gocv.NewMat()
-	gocv.CvtColor(faceImage, &rgbImage, gocv.ColorBGRToRGB)
+	if c.Landmark != nil {
+		denseLandmarks, err := c.Landmark.InferBatchContext(ctx, frames, bboxes)
+		if err != nil {
+			return nil, err
+		}
+		result, err := c.Challenge.VerifyDense(frames, denseLandmarks, timestamps, challengeID)
+		if result != nil {
+			result.ModelVersions = c.ModelVersions()
+			c.observeDecision(ctx, "liveness_challenge", result.IsLiveness)
+		}
+		return result, err
+	}
+
+	result, err := c.Challenge.Verify(frames, landmarks, timestamps, challengeID)
+	if result != nil {
+		result.ModelVersions = c.ModelVersions()
+		c.observeDecision(ctx, "liveness_challenge", result.IsLiveness)
+	}
+	return result, err
+}
+
+/*
+EstimateHeadPose returns the head pose of the face in the input image.
+Client landmarks are validated by the pipeline Validator, missing ones are detected.
+
+Inputs:
+
+  - image (utils.Image): Capture face image.
+  - lmk (*tensor.Dense): image landmarks, detected if nil.
+
+Outputs:
+
+  - pose (*config.HeadPose): yaw, pitch and roll in degrees.
+*/
+func (c *EKYCPipeline) EstimateHeadPose(image utils.Image, lmk *tensor.Dense) (*config.HeadPose, error) {
+	return c.EstimateHeadPoseContext(context.Background(), image, lmk)
+}
+
+// EstimateHeadPoseContext is EstimateHeadPose run within ctx.
+func (c *EKYCPipeline) EstimateHeadPoseContext(ctx context.Context, image utils.Image, lmk *tensor.Dense) (_ *config.HeadPose, err error) {
+	ctx, end := c.startOperation(ctx, "estimate_head_pose")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, image)
+	if err != nil {
+		return nil, err
+	}
+	img := mats[0]
+
+	lmk, err = c.validateLandmark(ctx, img, lmk, "input-face")
+	if err != nil {
+		return nil, err
+	}
+
+	if lmk == nil {
+		_, landmarks, err := c.detectLandmarks(ctx, []gocv.Mat{img}, utils.RefPointer(true), nil, utils.RefPointer(true))
+		if err != nil {
+			return nil, err
+		}
+		if len(landmarks) == 0 || landmarks[0] == nil {
+			c.metrics.Load().FaceNotFound("input-face")
+			return nil, errors.New("cannot detect face in input face image")
+		}
+		lmk = landmarks[0]
+	}
+
+	return c.HeadPose.Estimate(img, lmk)
+}
+
+/*
+ImageQualityVerify computes the local image quality metrics of a capture.
+Client landmarks are validated by the pipeline Validator, missing ones are detected.
+
+Inputs:
+
+  - image (utils.Image): Capture face image.
+  - lmk (*tensor.Dense): image landmarks, detected if nil.
+
+Outputs:
+
+  - quality (*config.ImageQuality): Local quality metrics and decision.
+*/
+func (c *EKYCPipeline) ImageQualityVerify(image utils.Image, lmk *tensor.Dense) (*config.ImageQuality, error) {
+	return c.ImageQualityVerifyContext(context.Background(), image, lmk)
+}
+
+// ImageQualityVerifyContext is ImageQualityVerify run within ctx.
+func (c *EKYCPipeline) ImageQualityVerifyContext(ctx context.Context, image utils.Image, lmk *tensor.Dense) (_ *config.ImageQuality, err error) {
+	ctx, end := c.startOperation(ctx, "image_quality_verify")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, image)
+	if err != nil {
+		return nil, err
+	}
+	img := mats[0]
+
+	lmk, err = c.validateLandmark(ctx, img, lmk, "input-face")
+	if err != nil {
+		return nil, err
+	}
+
+	var bbox *tensor.Dense
+	if lmk == nil {
+		bBoxes, landmarks, err := c.detectLandmarks(ctx, []gocv.Mat{img}, utils.RefPointer(true), nil, utils.RefPointer(true))
+		if err != nil {
+			return nil, err
+		}
+		if len(landmarks) == 0 || landmarks[0] == nil {
+			c.metrics.Load().FaceNotFound("input-face")
+			return nil, errors.New("cannot detect face in input face image")
+		}
+		bbox, lmk = bBoxes[0], landmarks[0]
+	}
+
+	return c.Quality.Assess(img, lmk, bbox)
+}
+
+/*
+FaceImageQuality computes the unified 0-100 face image quality score of a capture from the local image quality,
+head pose, face detection confidence and face quality model.
+Client landmarks are validated by the pipeline Validator, missing ones are detected. The bounding box and detection
+confidence are those of the detected face matching client landmarks, the face region is derived from the landmarks
+with a zero detection confidence if no detected face matches.
+
+Inputs:
+
+  - image (utils.Image): Capture face image.
+  - lmk (*tensor.Dense): image landmarks, detected if nil.
+
+Outputs:
+
+  - quality (*config.FaceImageQuality): Unified score with component breakdown.
+*/
+func (c *EKYCPipeline) FaceImageQuality(image utils.Image, lmk *tensor.Dense) (*config.FaceImageQuality, error) {
+	return c.FaceImageQualityContext(context.Background(), image, lmk)
+}
+
+// FaceImageQualityContext is FaceImageQuality run within ctx.
+func (c *EKYCPipeline) FaceImageQualityContext(ctx context.Context, image utils.Image, lmk *tensor.Dense) (_ *config.FaceImageQuality, err error) {
+	ctx, end := c.startOperation(ctx, "face_image_quality")
+	defer func() { end(err) }()
+
+	scope := utils.NewMatScope()
+	defer scope.Close()
+
+	mats, err := c.prepareImages(scope, image)
+	if err != nil {
+		return nil, err
+	}
+	img := mats[0]
+
+	lmk, err = c.validateLandmark(ctx, img, lmk, "input-face")
+	if err != nil {
+		return nil, err
+	}
+
+	var detection *config.FaceDetectionOutput
+	if lmk != nil {
+		detection, err = c.detectMatchingFace(ctx, img, lmk)
+	} else {
+		detection, err = c.detectLargestFace(ctx, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var bbox *tensor.Dense
+	var detectionScore float32
+	if detection != nil {
+		bbox = detection.Box
+		detectionScore = detection.Score.Float32s()[0]
+	}
+	if lmk == nil {
+		if detection == nil {
+			c.metrics.Load().FaceNotFound("input-face")
+			return nil, errors.New("cannot detect face in input face image")
+		}
+		lmk = tensor.New(
+			tensor.Of(tensor.Float32),
+			tensor.WithShape(5, 2),
+			tensor.WithBacking(detection.Landmark.Float32s()),
+		)
+	}
+
+	local, err := c.Quality.Assess(img, lmk, bbox)
+	if err != nil {
+		return nil, err
+	}
+
+	pose, err := c.estimateHeadPose(img, lmk)
+	if err != nil {
+		return nil, err
+	}
+
+	croppedFaces, affineMatrices, qualities, err := c.alignFaces(ctx, c.FaceHelper.AlignWarpFaces, []gocv.Mat{img}, []*tensor.Dense{lmk})
+	scope.Track(croppedFaces...)
+	scope.Track(affineMatrices...)
+	if err != nil {
+		return nil, err
+	}
+	err = c.checkAlignments(qualities, []string{"input face"})
+	if err != nil {
+		return nil, err
+	}
+	scoreCover, err := c.FaceQuality.InferBatchContext(ctx, [][]gocv.Mat{croppedFaces})
+	if err != nil {
+		return nil, err
+	}
+	var coverScore float32
+	for _, t := range scoreCover {
+		for _, v := range t.Float32s() {
+			if v > coverScore {
+				coverScore = v
+			}
+		}
+	}
 
-	return &rgbImage, nil
+	return c.ImageQualityScore.Score(local, pose, detectionScore, coverScore)
 }
@@ -0,0 +1,88 @@
+package go_ekyc_pipeline
+
+import (
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/modules"
+	"sync"
+	"sync/atomic"
+)
+
+// HotSwapPipeline serves an EKYCPipeline that can be switched to new model versions or thresholds at runtime. A switch
+// initializes and validates a new pipeline on the same inference backend, then replaces the current one atomically:
+// calls in flight finish on the pipeline they started with, later calls of Current get the new one. The current
+// pipeline is kept if the new one fails to initialize. The shadow evaluations of a replaced pipeline are reported before
+// its switch returns, and those of its calls still in flight are dropped. Liveness challenges issued by a replaced
+// pipeline are verified by the new one, once, see modules.LivenessChallengeStore.
+type HotSwapPipeline struct {
+	tritonClient modules.InferenceClient
+	mu           sync.Mutex
+	current      atomic.Pointer[EKYCPipeline]
+	metrics      atomic.Pointer[modules.Metrics]
+}
+
+// NewHotSwapPipeline initializes a pipeline on an inference backend with params, default params if nil.
+func NewHotSwapPipeline(tritonClient modules.InferenceClient, params *config.EKYCPipelineParams) (*HotSwapPipeline, error) {
+	if params == nil {
+		params = config.DefaultEKYCPipelineParams
+	}
+	pipeline, err := NewEKYCPipelineWithParams(tritonClient, params)
+	if err != nil {
+		return nil, err
+	}
+
+	hot := &HotSwapPipeline{tritonClient: tritonClient}
+	hot.current.Store(pipeline)
+	return hot, nil
+}
+
+// Current returns the pipeline serving new calls. A call should run on a single pipeline returned by Current, so that
+// all its models and thresholds belong to the same switch.
+func (h *HotSwapPipeline) Current() *EKYCPipeline {
+	return h.current.Load()
+}
+
+// Instrument records the metrics of the current pipeline and of the pipelines switched to afterwards to metrics, see
+// EKYCPipeline.Instrument.
+func (h *HotSwapPipeline) Instrument(metrics *modules.Metrics) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.metrics.Store(metrics)
+	h.Current().Instrument(metrics)
+}
+
+// Params returns a copy of the params of the current pipeline.
+func (h *HotSwapPipeline) Params() *config.EKYCPipelineParams {
+	return h.Current().Params.Clone()
+}
+
+// Switch initializes a pipeline with params and makes it the current pipeline. The params must not be changed
+// afterwards, see Update to change a copy of the current params.
+func (h *HotSwapPipeline) Switch(params *config.EKYCPipelineParams) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.switchTo(params)
+}
+
+// Update switches to a copy of the current params changed by fn, e.g. to pin a new model version or move a threshold.
+// Updates are serialized, so that concurrent updates do not overwrite each other.
+func (h *HotSwapPipeline) Update(fn func(params *config.EKYCPipelineParams)) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	params := h.Params()
+	fn(params)
+	return h.switchTo(params)
+}
+
+func (h *HotSwapPipeline) switchTo(params *config.EKYCPipelineParams) error {
+	pipeline, err := NewEKYCPipelineWithParams(h.tritonClient, params)
+	if err != nil {
+		return err
+	}
+	pipeline.Instrument(h.metrics.Load())
+	pipeline.Challenge.Store = h.Current().Challenge.Store
+	replaced := h.current.Swap(pipeline)
+	if replaced.Shadow != nil {
+		replaced.Shadow.Close()
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package go_ekyc_pipeline
+
+import (
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/modules"
+	"github.com/okieraised/go-triton-client/triton_proto"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// configServer is an inference backend serving the configurations of the default pipeline models, for the versions
+// listed in versions.
+type configServer struct {
+	mu        sync.Mutex
+	versions  map[string]bool
+	requested map[string]string
+}
+
+func (s *configServer) GetModelConfiguration(_ time.Duration, modelName, modelVersion string) (*triton_proto.ModelConfigResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if modelVersion != "" && !s.versions[modelVersion] {
+		return nil, fmt.Errorf("model %s has no version %s", modelName, modelVersion)
+	}
+	s.requested[modelName] = modelVersion
+
+	fp32 := triton_proto.DataType_TYPE_FP32
+	image := func(size int64) *triton_proto.ModelInput {
+		return &triton_proto.ModelInput{Name: "input", DataType: fp32, Dims: []int64{3, size, size}}
+	}
+	cfg := &triton_proto.ModelConfig{Name: modelName, MaxBatchSize: 8}
+	switch modelName {
+	case config.DefaultFaceDetectionParams.ModelName:
+		cfg.Input = []*triton_proto.ModelInput{image(640)}
+		for _, name := range []string{"num_dets", "boxes", "scores", "classes", "landmarks"} {
+			cfg.Output = append(cfg.Output, &triton_proto.ModelOutput{Name: name, DataType: fp32, Dims: []int64{-1}})
+		}
+	case config.DefaultFullFaceAntiSpoofingParams.ModelName, config.DefaultCropFaceAntiSpoofingParams.ModelName:
+		cfg.Input = []*triton_proto.ModelInput{image(224), image(224), image(224)}
+		cfg.Output = []*triton_proto.ModelOutput{{Name: "score", DataType: fp32, Dims: []int64{2}}}
+	default:
+		cfg.Input = []*triton_proto.ModelInput{image(112)}
+		cfg.Output = []*triton_proto.ModelOutput{{Name: "output", DataType: fp32, Dims: []int64{512}}}
+	}
+	return &triton_proto.ModelConfigResponse{Config: cfg}, nil
+}
+
+func (s *configServer) ModelGRPCInfer(time.Duration, *triton_proto.ModelInferRequest) (*triton_proto.ModelInferResponse, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func TestHotSwapPipeline(t *testing.T) {
+	server := &configServer{versions: map[string]bool{"1": true, "2": true}, requested: map[string]string{}}
+	params := config.DefaultEKYCPipelineParams.Clone()
+	params.FaceID.ModelVersion = "1"
+
+	hot, err := NewHotSwapPipeline(server, params)
+	assert.NoError(t, err)
+	initial := hot.Current()
+	assert.Equal(t, "1", server.requested[config.DefaultFaceIDParams.ModelName])
+	assert.Equal(t, "1", initial.ModelVersions()[config.DefaultFaceIDParams.ModelName])
+	assert.Equal(t, "", initial.ModelVersions()[config.DefaultFaceDetectionParams.ModelName])
+
+	metrics := modules.NewMetrics("ekyc")
+	hot.Instrument(metrics)
+	assert.Same(t, metrics, initial.metrics.Load())
+
+	// Switch to a new version and threshold.
+	err = hot.Update(func(params *config.EKYCPipelineParams) {
+		params.FaceID.ModelVersion = "2"
+		params.FaceID.ThresholdSamePerson = 0.5
+	})
+	assert.NoError(t, err)
+	assert.NotSame(t, initial, hot.Current())
+	assert.Equal(t, "2", hot.Current().ModelVersions()[config.DefaultFaceIDParams.ModelName])
+	assert.Equal(t, float32(0.5), hot.Current().FaceID.ModelParams.ThresholdSamePerson)
+	assert.Same(t, metrics, hot.Current().metrics.Load())
+
+	// The previous pipeline and the default params are left unchanged.
+	assert.Equal(t, "1", initial.FaceID.ModelParams.ModelVersion)
+	assert.Empty(t, config.DefaultFaceIDParams.ModelVersion)
+
+	// A failed switch keeps the current pipeline.
+	current := hot.Current()
+	err = hot.Update(func(params *config.EKYCPipelineParams) {
+		params.FaceID.ModelVersion = "3"
+	})
+	assert.Error(t, err)
+	assert.Same(t, current, hot.Current())
+	assert.Equal(t, "2", hot.Params().FaceID.ModelVersion)
+}
+
+func TestHotSwapPipeline_DrainsShadow(t *testing.T) {
+	server := &configServer{versions: map[string]bool{"1": true, "2": true}, requested: map[string]string{}}
+	params := config.DefaultEKYCPipelineParams.Clone()
+	params.Shadow = config.DefaultShadowParams.Clone()
+	params.Shadow.FaceID = config.DefaultFaceIDParams
+
+	hot, err := NewHotSwapPipeline(server, params)
+	assert.NoError(t, err)
+	shadow := hot.Current().Shadow
+	reports := make(chan config.ShadowComparison, 1)
+	shadow.Report = func(comparison config.ShadowComparison) {
+		reports <- comparison
+	}
+
+	// The switch returns once the evaluation pending on the replaced pipeline is reported.
+	release := make(chan struct{})
+	shadow.dispatch(shadowComparison{Check: config.ShadowCheckSamePerson}, nil, 0, func([]gocv.Mat) config.ShadowComparison {
+		<-release
+		return config.ShadowComparison{Check: config.ShadowCheckSamePerson}
+	})
+	switched := make(chan error)
+	go func() {
+		switched <- hot.Switch(params)
+	}()
+	select {
+	case <-switched:
+		t.Fatal("switch returned before the shadow evaluation was reported")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	assert.NoError(t, <-switched)
+	assert.Equal(t, config.ShadowCheckSamePerson, (<-reports).Check)
+
+	// Checks still in flight on the replaced pipeline are not evaluated.
+	shadow.dispatch(shadowComparison{Check: config.ShadowCheckSamePerson}, nil, 0, func([]gocv.Mat) config.ShadowComparison {
+		t.Error("evaluation should be dropped")
+		return config.ShadowComparison{}
+	})
+	shadow.Wait()
+	assert.NotSame(t, shadow, hot.Current().Shadow)
+}
+
+func TestHotSwapPipeline_ChallengeReplay(t *testing.T) {
+	server := &configServer{versions: map[string]bool{}, requested: map[string]string{}}
+	for _, secretKey := range []string{"", "secret"} {
+		params := config.DefaultEKYCPipelineParams.Clone()
+		params.LivenessChallenge.SecretKey = secretKey
+
+		hot, err := NewHotSwapPipeline(server, params)
+		assert.NoError(t, err)
+
+		// Verified challenges are rejected by the new pipeline.
+		verified, err := hot.Current().IssueLivenessChallenges()
+		assert.NoError(t, err)
+		_, err = hot.Current().Challenge.Verify(nil, nil, nil, verified.ID)
+		assert.NotErrorIs(t, err, modules.ErrChallengeUsed)
+		outstanding, err := hot.Current().IssueLivenessChallenges()
+		assert.NoError(t, err)
+
+		err = hot.Update(func(params *config.EKYCPipelineParams) {
+			params.FaceID.ThresholdSamePerson = 0.5
+		})
+		assert.NoError(t, err)
+		_, err = hot.Current().Challenge.Verify(nil, nil, nil, verified.ID)
+		assert.ErrorIs(t, err, modules.ErrChallengeUsed)
+
+		// Outstanding challenges are verified by the new pipeline.
+		_, err = hot.Current().Challenge.Verify(nil, nil, nil, outstanding.ID)
+		assert.NotErrorIs(t, err, modules.ErrChallengeInvalid)
+		assert.NotErrorIs(t, err, modules.ErrChallengeUsed)
+	}
+}
@@ -0,0 +1,302 @@
+package go_ekyc_pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/modules"
+	"github.com/okieraised/go-ekyc-pipeline/utils"
+	"gocv.io/x/gocv"
+	"image"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShadowEvaluator runs candidate FaceID and anti-spoofing models in shadow on live traffic, before promoting them.
+// Sampled checks of the pipeline hand a copy of their inputs to the evaluator, which runs the candidates
+// asynchronously and reports the scores and decisions of each candidate paired with those of its primary model. The
+// evaluations never change the results of the pipeline, and their failures are only reported.
+// The copies are made on the request path of the sampled checks: aligned faces are cloned, and the inputs of the
+// anti-spoofing candidates, full frames included, are resized to the candidate input size rather than cloned whole.
+type ShadowEvaluator struct {
+	FaceID     *modules.FaceIDClient
+	FaceASFull *modules.FaceAntiSpoofingClient
+	FaceASCrop *modules.FaceAntiSpoofingClient
+	Params     *config.ShadowParams
+	// Report receives every comparison from the goroutine of its evaluation, it must be set before the first check.
+	// Comparisons are logged with log/slog by default, disagreements and failures as warnings.
+	Report  func(comparison config.ShadowComparison)
+	pending chan struct{}
+	wg      sync.WaitGroup
+	skipped atomic.Int64
+	mu      sync.Mutex
+	closed  bool
+}
+
+// NewShadowEvaluator initializes the clients of the candidate models of params on an inference backend.
+func NewShadowEvaluator(tritonClient modules.InferenceClient, params *config.ShadowParams) (*ShadowEvaluator, error) {
+	if params.SampleRate < 0 || params.SampleRate > 1 {
+		return nil, fmt.Errorf("shadow sample rate must be between 0 and 1, got %v", params.SampleRate)
+	}
+	if params.MaxPending <= 0 {
+		return nil, fmt.Errorf("shadow max pending evaluations must be positive, got %d", params.MaxPending)
+	}
+
+	evaluator := &ShadowEvaluator{
+		Params:  params,
+		Report:  logShadowComparison,
+		pending: make(chan struct{}, params.MaxPending),
+	}
+
+	var err error
+	if params.FaceID != nil {
+		evaluator.FaceID, err = modules.NewFaceIDClient(tritonClient, params.FaceID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if params.FullFaceAS != nil {
+		evaluator.FaceASFull, err = modules.NewFaceAntiSpoofingClient(tritonClient, params.FullFaceAS)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if params.CropFaceAS != nil {
+		evaluator.FaceASCrop, err = modules.NewFaceAntiSpoofingClient(tritonClient, params.CropFaceAS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	contracted := make([]modules.ContractChecker, 0, 3)
+	for _, client := range evaluator.clients() {
+		contracted = append(contracted, client)
+	}
+	err = modules.CheckModelContracts(contracted...)
+	if err != nil {
+		return nil, err
+	}
+	return evaluator, nil
+}
+
+// shadowClient is implemented by the candidate clients.
+type shadowClient interface {
+	modules.ContractChecker
+	modules.ColorOrderer
+	modules.Instrumenter
+}
+
+// clients returns the clients of the candidate models.
+func (s *ShadowEvaluator) clients() []shadowClient {
+	clients := make([]shadowClient, 0, 3)
+	if s.FaceID != nil {
+		clients = append(clients, s.FaceID)
+	}
+	if s.FaceASFull != nil {
+		clients = append(clients, s.FaceASFull)
+	}
+	if s.FaceASCrop != nil {
+		clients = append(clients, s.FaceASCrop)
+	}
+	return clients
+}
+
+// colorOrdered returns the candidate clients reading the images of the pipeline.
+func (s *ShadowEvaluator) colorOrdered() []modules.ColorOrderer {
+	clients := make([]modules.ColorOrderer, 0, 3)
+	for _, client := range s.clients() {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// Wait blocks until the pending evaluations are reported, e.g. before shutting down.
+func (s *ShadowEvaluator) Wait() {
+	s.wg.Wait()
+}
+
+// Close stops the evaluation of later checks and blocks until the pending evaluations are reported, e.g. once the
+// pipeline of the evaluator is replaced. Checks sampled after Close are dropped.
+func (s *ShadowEvaluator) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Skipped returns the number of sampled evaluations skipped because MaxPending evaluations were running.
+func (s *ShadowEvaluator) Skipped() int64 {
+	return s.skipped.Load()
+}
+
+// sample reports whether a check is evaluated in shadow.
+func (s *ShadowEvaluator) sample() bool {
+	return s.Params.SampleRate >= 1 || rand.Float64() < s.Params.SampleRate
+}
+
+// dispatch runs evaluate on copies of images in a new goroutine and reports the comparison it completes. The check is
+// skipped rather than waiting if MaxPending evaluations are running, so that the pipeline is never slowed down.
+// Copies are made on the request path: images are resized to the inputSize x inputSize input of the candidate if
+// inputSize is positive, so that full frames are not copied whole, and cloned otherwise.
+func (s *ShadowEvaluator) dispatch(comparison shadowComparison, images []gocv.Mat, inputSize int, evaluate func(images []gocv.Mat) config.ShadowComparison) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	select {
+	case s.pending <- struct{}{}:
+	default:
+		s.mu.Unlock()
+		s.skipped.Add(1)
+		return
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	// The inputs are owned by the scope of the check, which closes them before the evaluation ends
+	scope := utils.NewMatScope()
+	clones := make([]gocv.Mat, len(images))
+	for idx := range images {
+		if inputSize > 0 {
+			clones[idx] = gocv.NewMat()
+			gocv.Resize(images[idx], &clones[idx], image.Point{X: inputSize, Y: inputSize}, 0, 0, gocv.InterpolationLinear)
+		} else {
+			clones[idx] = images[idx].Clone()
+		}
+	}
+	scope.Track(clones...)
+
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.pending }()
+		defer scope.Close()
+
+		result := config.ShadowComparison(comparison)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					result.Error = fmt.Sprintf("candidate panicked: %v", r)
+				}
+			}()
+			result = evaluate(clones)
+		}()
+		s.Report(result)
+	}()
+}
+
+// samePerson evaluates the candidate FaceID model on the aligned far, mid and near faces of a same person check.
+func (s *ShadowEvaluator) samePerson(primary *modules.FaceIDClient, faces []gocv.Mat, scoreFM, scoreMN float32, isSamePerson bool) {
+	if s == nil || s.FaceID == nil || !s.sample() {
+		return
+	}
+	comparison := newShadowComparison(config.ShadowCheckSamePerson, primary, []float32{scoreFM, scoreMN}, isSamePerson)
+	s.dispatch(comparison, faces, 0, func(faces []gocv.Mat) config.ShadowComparison {
+		start := time.Now()
+		embeddings, err := s.FaceID.InferBatch([][]gocv.Mat{faces})
+		if err == nil && len(embeddings) != 3 {
+			err = fmt.Errorf("got %d embeddings, want 3", len(embeddings))
+		}
+		var candidateFM, candidateMN float32
+		if err == nil {
+			candidateFM, err = cosineSimilarity(embeddings[0].Float32s(), embeddings[1].Float32s())
+		}
+		if err == nil {
+			candidateMN, err = cosineSimilarity(embeddings[1].Float32s(), embeddings[2].Float32s())
+		}
+		threshold := s.FaceID.ModelParams.ThresholdSamePerson
+		return comparison.withCandidate(s.FaceID, time.Since(start), err, []float32{candidateFM, candidateMN}, candidateFM >= threshold && candidateMN >= threshold)
+	})
+}
+
+// liveness evaluates the candidate anti-spoofing models on the cropped and full far, mid and near faces of a liveness
+// check. Each candidate is compared with the primary model of the same input.
+func (s *ShadowEvaluator) liveness(primaryCrop, primaryFull *modules.FaceAntiSpoofingClient, crops, fulls []gocv.Mat, scoreCrop, scoreFull float32) {
+	if s == nil || (s.FaceASCrop == nil && s.FaceASFull == nil) || !s.sample() {
+		return
+	}
+	if s.FaceASCrop != nil {
+		s.antiSpoofing(config.ShadowCheckLivenessCrop, primaryCrop, s.FaceASCrop, crops, scoreCrop)
+	}
+	if s.FaceASFull != nil {
+		s.antiSpoofing(config.ShadowCheckLivenessFull, primaryFull, s.FaceASFull, fulls, scoreFull)
+	}
+}
+
+// antiSpoofing evaluates a candidate anti-spoofing model on images resized to its input size, which its preprocessing
+// resizes them to in any case.
+func (s *ShadowEvaluator) antiSpoofing(check string, primary, candidate *modules.FaceAntiSpoofingClient, images []gocv.Mat, score float32) {
+	comparison := newShadowComparison(check, primary, []float32{score}, score > primary.ModelParams.Threshold)
+	s.dispatch(comparison, images, candidate.ModelParams.ImgSize, func(images []gocv.Mat) config.ShadowComparison {
+		start := time.Now()
+		candidateScore, err := candidate.InferSingle(images[0], images[1], images[2])
+		return comparison.withCandidate(candidate, time.Since(start), err, []float32{candidateScore}, candidateScore > candidate.ModelParams.Threshold)
+	})
+}
+
+// shadowComparison builds the comparison of a primary model with its candidate. The primary model is recorded when
+// the check is dispatched, the candidate once evaluated.
+type shadowComparison config.ShadowComparison
+
+func newShadowComparison(check string, primary modules.ServedModeler, scores []float32, decision bool) shadowComparison {
+	comparison := shadowComparison{Check: check, PrimaryScores: scores, PrimaryDecision: decision}
+	comparison.PrimaryModel, comparison.PrimaryVersion = primary.ServedModel()
+	return comparison
+}
+
+func (c shadowComparison) withCandidate(candidate modules.ServedModeler, latency time.Duration, err error, scores []float32, decision bool) config.ShadowComparison {
+	c.CandidateModel, c.CandidateVersion = candidate.ServedModel()
+	c.Latency = latency
+	if err != nil {
+		c.Error = err.Error()
+		return config.ShadowComparison(c)
+	}
+	c.CandidateScores = scores
+	c.CandidateDecision = decision
+	c.IsDisagreement = c.CandidateDecision != c.PrimaryDecision
+	return config.ShadowComparison(c)
+}
+
+// cosineSimilarity computes the cosine similarity of two embeddings.
+func cosineSimilarity(a, b []float32) (float32, error) {
+	if len(a) != len(b) {
+		return 0, errors.New("vectors must have the same length")
+	}
+	var dotProduct, normA, normB float64
+	for i := range a {
+		dotProduct += float64(a[i] * b[i])
+		normA += float64(a[i] * a[i])
+		normB += float64(b[i] * b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0, errors.New("zero vector encountered")
+	}
+	return float32(dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))), nil
+}
+
+// logShadowComparison is the default report of the comparisons.
+func logShadowComparison(comparison config.ShadowComparison) {
+	level := slog.LevelInfo
+	if comparison.IsDisagreement || comparison.Error != "" {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, "shadow evaluation",
+		slog.String("check", comparison.Check),
+		slog.String("primary_model", comparison.PrimaryModel),
+		slog.String("primary_version", comparison.PrimaryVersion),
+		slog.String("candidate_model", comparison.CandidateModel),
+		slog.String("candidate_version", comparison.CandidateVersion),
+		slog.Any("primary_scores", comparison.PrimaryScores),
+		slog.Any("candidate_scores", comparison.CandidateScores),
+		slog.Bool("primary_decision", comparison.PrimaryDecision),
+		slog.Bool("candidate_decision", comparison.CandidateDecision),
+		slog.Bool("is_disagreement", comparison.IsDisagreement),
+		slog.Duration("latency", comparison.Latency),
+		slog.String("error", comparison.Error),
+	)
+}
@@ -0,0 +1,113 @@
+package go_ekyc_pipeline
+
+import (
+	"errors"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"testing"
+	"time"
+)
+
+func TestNewShadowEvaluator(t *testing.T) {
+	server := &configServer{versions: map[string]bool{"1": true, "2": true}, requested: map[string]string{}}
+	params := config.DefaultEKYCPipelineParams.Clone()
+	params.Shadow = config.DefaultShadowParams.Clone()
+	candidate := *config.DefaultFaceIDParams
+	candidate.ModelVersion = "2"
+	params.Shadow.FaceID = &candidate
+
+	pipeline, err := NewEKYCPipelineWithParams(server, params)
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline.Shadow.FaceID)
+	assert.Nil(t, pipeline.Shadow.FaceASCrop)
+	assert.Nil(t, pipeline.Shadow.FaceASFull)
+	assert.Equal(t, "2", server.requested[config.DefaultFaceIDParams.ModelName])
+
+	// Shadow evaluation is off by default.
+	pipeline, err = NewEKYCPipeline(server)
+	assert.NoError(t, err)
+	assert.Nil(t, pipeline.Shadow)
+
+	// A candidate failing to initialize fails the pipeline.
+	params.Shadow.FaceID.ModelVersion = "3"
+	_, err = NewEKYCPipelineWithParams(server, params)
+	assert.Error(t, err)
+
+	_, err = NewShadowEvaluator(server, config.NewShadowParams(nil, nil, nil, 1.5, 1))
+	assert.EqualError(t, err, "shadow sample rate must be between 0 and 1, got 1.5")
+	_, err = NewShadowEvaluator(server, config.NewShadowParams(nil, nil, nil, 1, 0))
+	assert.EqualError(t, err, "shadow max pending evaluations must be positive, got 0")
+}
+
+func TestShadowEvaluator_dispatch(t *testing.T) {
+	evaluator, err := NewShadowEvaluator(nil, config.NewShadowParams(nil, nil, nil, 1, 1))
+	assert.NoError(t, err)
+	reports := make(chan config.ShadowComparison, 3)
+	evaluator.Report = func(comparison config.ShadowComparison) {
+		reports <- comparison
+	}
+
+	// Checks are skipped while MaxPending evaluations are running.
+	release := make(chan struct{})
+	evaluator.dispatch(shadowComparison{Check: config.ShadowCheckSamePerson}, nil, 0, func([]gocv.Mat) config.ShadowComparison {
+		<-release
+		return config.ShadowComparison{Check: config.ShadowCheckSamePerson, IsDisagreement: true}
+	})
+	evaluator.dispatch(shadowComparison{Check: config.ShadowCheckLivenessCrop}, nil, 0, func([]gocv.Mat) config.ShadowComparison {
+		t.Error("evaluation should be skipped")
+		return config.ShadowComparison{}
+	})
+	assert.Equal(t, int64(1), evaluator.Skipped())
+	close(release)
+	evaluator.Wait()
+	assert.True(t, (<-reports).IsDisagreement)
+
+	// A panicking candidate is reported as failed.
+	evaluator.dispatch(shadowComparison{Check: config.ShadowCheckLivenessFull, PrimaryModel: "face_as"}, nil, 0, func([]gocv.Mat) config.ShadowComparison {
+		panic("out of memory")
+	})
+	evaluator.Wait()
+	comparison := <-reports
+	assert.Equal(t, "face_as", comparison.PrimaryModel)
+	assert.Equal(t, "candidate panicked: out of memory", comparison.Error)
+
+	// Full frames are handed over resized to the candidate input size, aligned faces as clones.
+	frame := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+	defer frame.Close()
+	evaluator.dispatch(shadowComparison{Check: config.ShadowCheckLivenessFull}, []gocv.Mat{frame}, 224, func(images []gocv.Mat) config.ShadowComparison {
+		assert.Equal(t, []int{224, 224}, images[0].Size())
+		return config.ShadowComparison{}
+	})
+	evaluator.Wait()
+	<-reports
+	evaluator.dispatch(shadowComparison{Check: config.ShadowCheckSamePerson}, []gocv.Mat{frame}, 0, func(images []gocv.Mat) config.ShadowComparison {
+		assert.Equal(t, []int{480, 640}, images[0].Size())
+		assert.NotEqual(t, frame.Ptr(), images[0].Ptr())
+		return config.ShadowComparison{}
+	})
+	evaluator.Wait()
+	<-reports
+
+	// Pipelines without shadow evaluation have a nil evaluator.
+	var disabled *ShadowEvaluator
+	disabled.samePerson(nil, nil, 0, 0, false)
+	disabled.liveness(nil, nil, nil, nil, 0, 0)
+}
+
+func TestShadowComparison_withCandidate(t *testing.T) {
+	server := &configServer{versions: map[string]bool{"2": true}, requested: map[string]string{}}
+	evaluator, err := NewShadowEvaluator(server, config.NewShadowParams(config.DefaultFaceIDParams, nil, nil, 1, 1))
+	assert.NoError(t, err)
+
+	comparison := shadowComparison{Check: config.ShadowCheckSamePerson, PrimaryScores: []float32{0.8, 0.7}, PrimaryDecision: true}
+	result := comparison.withCandidate(evaluator.FaceID, time.Millisecond, nil, []float32{0.6, 0.4}, false)
+	assert.Equal(t, config.DefaultFaceIDParams.ModelName, result.CandidateModel)
+	assert.Equal(t, []float32{0.6, 0.4}, result.CandidateScores)
+	assert.True(t, result.IsDisagreement)
+
+	result = comparison.withCandidate(evaluator.FaceID, time.Millisecond, errors.New("deadline exceeded"), []float32{0, 0}, false)
+	assert.Nil(t, result.CandidateScores)
+	assert.False(t, result.IsDisagreement)
+	assert.Equal(t, "deadline exceeded", result.Error)
+}
@@ -1,7 +1,9 @@
 package go_ekyc_pipeline
 
 import (
+	"context"
 	"fmt"
+	"github.com/okieraised/go-ekyc-pipeline/config"
 	"github.com/okieraised/go-ekyc-pipeline/utils"
 	gotritonclient "github.com/okieraised/go-triton-client"
 	"github.com/stretchr/testify/assert"
@@ -19,6 +21,11 @@ const (
 	tritonTestURL = "127.0.0.1:8301"
 )
 
+// rgbImage wraps a test Mat decoded by utils.ConvertImageToMat.
+func rgbImage(mat *gocv.Mat) utils.Image {
+	return utils.Image{Mat: *mat, Order: utils.ColorOrderRGB}
+}
+
 func genTestNearData() (*gocv.Mat, error) {
 	f, err := os.Open("./test_data/near")
 	if err != nil {
@@ -184,7 +191,7 @@ func TestEKYCPipeline_LivenessActiveCheck(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, pipeline)
 
-	livenessScoreCrop, livenessScoreFull, isLiveness, err := pipeline.livenessActiveCheck(*far, *mid, *near, lmkFar, lmkMid, lmkNear)
+	livenessScoreCrop, livenessScoreFull, isLiveness, err := pipeline.livenessActiveCheck(context.Background(), *far, *mid, *near, lmkFar, lmkMid, lmkNear)
 	fmt.Println("livenessScoreCrop", livenessScoreCrop)
 	fmt.Println("livenessScoreFull", livenessScoreFull)
 	fmt.Println("isLiveness", isLiveness)
@@ -248,7 +255,7 @@ func TestEKYCPipeline_GetFaceQuality(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, pipeline)
 
-	score, isFaceMask, err := pipeline.getFaceQuality(*far, *mid, *near, lmkFar, lmkMid, lmkNear)
+	score, isFaceMask, err := pipeline.getFaceQuality(context.Background(), *far, *mid, *near, lmkFar, lmkMid, lmkNear)
 	assert.NoError(t, err)
 	fmt.Println(score, isFaceMask)
 }
@@ -310,7 +317,7 @@ func TestEKYCPipeline_SamePersonCheck(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, pipeline)
 
-	scoreFM, scoreMN, isSamePerson, err := pipeline.samePersonCheck(*far, *mid, *near, lmkFar, lmkMid, lmkNear)
+	scoreFM, scoreMN, isSamePerson, err := pipeline.samePersonCheck(context.Background(), *far, *mid, *near, lmkFar, lmkMid, lmkNear)
 	assert.NoError(t, err)
 	fmt.Println(scoreFM, scoreMN, isSamePerson)
 }
@@ -372,7 +379,7 @@ func TestEKYCPipeline_FaceAntiSpoofingActiveVerify(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, pipeline)
 
-	res, err := pipeline.FaceAntiSpoofingActiveVerify(*far, *mid, *near, lmkFar, lmkMid, lmkNear)
+	res, err := pipeline.FaceAntiSpoofingActiveVerify(rgbImage(far), rgbImage(mid), rgbImage(near), lmkFar, lmkMid, lmkNear)
 	assert.NoError(t, err)
 	fmt.Println("res", res)
 }
@@ -407,11 +414,43 @@ func TestEKYCPipeline_PersonIDCardVerify(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, pipeline)
 
-	score, isSamePerson, err := pipeline.PersonIDCardVerify(*idCard, *far, lmkFar)
+	score, isSamePerson, err := pipeline.PersonIDCardVerify(rgbImage(idCard), rgbImage(far), lmkFar)
 	assert.NoError(t, err)
 	fmt.Println(score, isSamePerson)
 }
 
+func TestEKYCPipeline_PersonIDCardVerify_Rotated(t *testing.T) {
+	tritonClient, err := gotritonclient.NewTritonGRPCClient(
+		tritonTestURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	)
+	assert.NoError(t, err)
+
+	far, err := genTestFarData()
+	assert.NoError(t, err)
+	rotated, err := utils.RotateImage(*far, 90)
+	assert.NoError(t, err)
+	defer rotated.Close()
+
+	idCard, err := genTestIDCardData()
+	assert.NoError(t, err)
+
+	params := config.DefaultEKYCPipelineParams.Clone()
+	params.FaceDetection.TryRotation = true
+	pipeline, err := NewEKYCPipelineWithParams(tritonClient, params)
+	assert.NoError(t, err)
+
+	score, isSamePerson, err := pipeline.PersonIDCardVerify(rgbImage(idCard), rgbImage(far), nil)
+	assert.NoError(t, err)
+
+	// The face of the rotated image is checked and aligned upright.
+	rotatedScore, rotatedIsSamePerson, err := pipeline.PersonIDCardVerify(rgbImage(idCard), rgbImage(&rotated), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, isSamePerson, rotatedIsSamePerson)
+	assert.InDelta(t, score, rotatedScore, 0.05)
+}
+
 func TestEKYCPipeline_CropSelfie(t *testing.T) {
 	tritonClient, err := gotritonclient.NewTritonGRPCClient(
 		tritonTestURL,
@@ -427,7 +466,7 @@ func TestEKYCPipeline_CropSelfie(t *testing.T) {
 	pipeline, err := NewEKYCPipeline(tritonClient)
 	assert.NoError(t, err)
 	assert.NotNil(t, pipeline)
-	_, err = pipeline.CropSelfie(*far)
+	_, err = pipeline.CropSelfie(rgbImage(far))
 	assert.NoError(t, err)
 }
 
@@ -446,7 +485,7 @@ func TestEKYCPipeline_CropFaceIDCard(t *testing.T) {
 	pipeline, err := NewEKYCPipeline(tritonClient)
 	assert.NoError(t, err)
 	assert.NotNil(t, pipeline)
-	_, err = pipeline.CropFaceIDCard(*idCard)
+	_, err = pipeline.CropFaceIDCard(rgbImage(idCard))
 	assert.NoError(t, err)
 }
 
@@ -464,6 +503,116 @@ func TestEKYCPipeline_CropSelfie_Multiple(t *testing.T) {
 	pipeline, err := NewEKYCPipeline(tritonClient)
 	assert.NoError(t, err)
 	assert.NotNil(t, pipeline)
-	_, err = pipeline.CropSelfie(*far)
+	_, err = pipeline.CropSelfie(rgbImage(far))
+	assert.NoError(t, err)
+}
+
+func TestEKYCPipeline_FaceAntiSpoofingVideoVerify(t *testing.T) {
+	tritonClient, err := gotritonclient.NewTritonGRPCClient(
+		tritonTestURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	)
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile("./test_data/video.mp4")
+	assert.NoError(t, err)
+
+	pipeline, err := NewEKYCPipeline(tritonClient)
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline)
+
+	res, selection, err := pipeline.FaceAntiSpoofingVideoVerify(content, nil)
+	assert.NoError(t, err)
+	fmt.Println("res", res)
+	fmt.Println("selection", selection)
+}
+
+func TestEKYCPipeline_FaceActiveLivenessChallengeVerify(t *testing.T) {
+	tritonClient, err := gotritonclient.NewTritonGRPCClient(
+		tritonTestURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	)
+	assert.NoError(t, err)
+
+	far, err := genTestFarData()
+	assert.NoError(t, err)
+
+	mid, err := genTestMidData()
+	assert.NoError(t, err)
+
+	near, err := genTestNearData()
+	assert.NoError(t, err)
+
+	pipeline, err := NewEKYCPipeline(tritonClient)
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline)
+
+	issue, err := pipeline.IssueLivenessChallenges()
+	assert.NoError(t, err)
+	res, err := pipeline.FaceActiveLivenessChallengeVerify([]utils.Image{rgbImage(far), rgbImage(mid), rgbImage(near)}, nil, issue.ID)
+	assert.NoError(t, err)
+	fmt.Println("challenges", issue.Challenges)
+	fmt.Println("res", res)
+}
+
+func TestEKYCPipeline_EstimateHeadPose(t *testing.T) {
+	tritonClient, err := gotritonclient.NewTritonGRPCClient(
+		tritonTestURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	)
+	assert.NoError(t, err)
+
+	far, err := genTestFarData()
+	assert.NoError(t, err)
+
+	pipeline, err := NewEKYCPipeline(tritonClient)
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline)
+
+	pose, err := pipeline.EstimateHeadPose(rgbImage(far), nil)
+	assert.NoError(t, err)
+	fmt.Println("pose", pose)
+}
+
+func TestEKYCPipeline_ImageQualityVerify(t *testing.T) {
+	tritonClient, err := gotritonclient.NewTritonGRPCClient(
+		tritonTestURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	)
+	assert.NoError(t, err)
+
+	far, err := genTestFarData()
+	assert.NoError(t, err)
+
+	pipeline, err := NewEKYCPipeline(tritonClient)
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline)
+
+	quality, err := pipeline.ImageQualityVerify(rgbImage(far), nil)
+	assert.NoError(t, err)
+	fmt.Println("quality", quality)
+}
+
+func TestEKYCPipeline_FaceImageQuality(t *testing.T) {
+	tritonClient, err := gotritonclient.NewTritonGRPCClient(
+		tritonTestURL,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	)
+	assert.NoError(t, err)
+
+	far, err := genTestFarData()
+	assert.NoError(t, err)
+
+	pipeline, err := NewEKYCPipeline(tritonClient)
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline)
+
+	quality, err := pipeline.FaceImageQuality(rgbImage(far), nil)
 	assert.NoError(t, err)
+	fmt.Println("quality", quality)
 }
@@ -0,0 +1,100 @@
+package go_ekyc_pipeline
+
+import (
+	"context"
+	"github.com/okieraised/go-ekyc-pipeline/config"
+	"github.com/okieraised/go-ekyc-pipeline/modules"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"time"
+)
+
+// faceAligner aligns the faces of images on their landmarks, e.g. modules.FaceHelperClient.AlignWarpFaces.
+type faceAligner func(inputImgs []gocv.Mat, landmarks []*tensor.Dense, borderMode *gocv.BorderType) ([]gocv.Mat, []gocv.Mat, []config.AlignmentQuality, error)
+
+// startOperation starts the span of a pipeline operation. The returned function ends it with the error of the
+// operation and records its duration and outcome.
+func (c *EKYCPipeline) startOperation(ctx context.Context, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, end := modules.StartSpan(ctx, "ekyc."+operation)
+	return ctx, func(err error) {
+		end(err)
+		c.metrics.Load().ObserveOperation(operation, start, err)
+	}
+}
+
+// detectLandmarks detects the faces of images within a detection span, see modules.FaceHelperClient.GetFaceLandmarks5.
+func (c *EKYCPipeline) detectLandmarks(ctx context.Context, images []gocv.Mat, keepLargest, keepCenter, tryPadding *bool) ([]*tensor.Dense, []*tensor.Dense, error) {
+	ctx, end := modules.StartSpan(ctx, "ekyc.detection", attribute.Int("ekyc.images", len(images)))
+	tryRotation := c.Params != nil && c.Params.FaceDetection != nil && c.Params.FaceDetection.TryRotation
+	bboxes, landmarks, err := c.FaceHelper.GetFaceLandmarks5Context(ctx, images, keepLargest, keepCenter, nil, nil, tryPadding, &tryRotation)
+	faces := 0
+	for _, landmark := range landmarks {
+		if landmark != nil {
+			faces++
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("ekyc.faces", faces))
+	end(err)
+	return bboxes, landmarks, err
+}
+
+// detectLargestFace detects the largest face of img within a detection span.
+func (c *EKYCPipeline) detectLargestFace(ctx context.Context, img gocv.Mat) (*config.FaceDetectionOutput, error) {
+	ctx, end := modules.StartSpan(ctx, "ekyc.detection", attribute.Int("ekyc.images", 1))
+	detection, err := c.FaceHelper.DetectLargestFaceContext(ctx, img)
+	faces := 0
+	if detection != nil {
+		faces = 1
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("ekyc.faces", faces))
+	end(err)
+	return detection, err
+}
+
+// detectMatchingFace detects the face of img matching the landmark within a detection span.
+func (c *EKYCPipeline) detectMatchingFace(ctx context.Context, img gocv.Mat, lmk *tensor.Dense) (*config.FaceDetectionOutput, error) {
+	ctx, end := modules.StartSpan(ctx, "ekyc.detection", attribute.Int("ekyc.images", 1))
+	detection, err := c.FaceHelper.DetectMatchingFaceContext(ctx, img, lmk)
+	faces := 0
+	if detection != nil {
+		faces = 1
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("ekyc.faces", faces))
+	end(err)
+	return detection, err
+}
+
+// alignFaces aligns the faces of images with align within an alignment span. The rotation of the qualities is relative
+// to the upright orientation of the faces if the detection params set TryRotation.
+func (c *EKYCPipeline) alignFaces(ctx context.Context, align faceAligner, images []gocv.Mat, landmarks []*tensor.Dense) ([]gocv.Mat, []gocv.Mat, []config.AlignmentQuality, error) {
+	_, end := modules.StartSpan(ctx, "ekyc.alignment", attribute.Int("ekyc.images", len(images)))
+	faces, affineMatrices, qualities, err := align(images, landmarks, nil)
+	end(err)
+	for idx := range qualities {
+		// Turning the image upright turns the face by the same angle.
+		qualities[idx].Rotation += float32(c.uprightRotation(images[idx], landmarks[idx]))
+		if qualities[idx].Rotation > 180 {
+			qualities[idx].Rotation -= 360
+		}
+	}
+	return faces, affineMatrices, qualities, err
+}
+
+// observeDecision records the decision of a check and the scores it was made on to the metrics, and as attributes of
+// the span of ctx.
+func (c *EKYCPipeline) observeDecision(ctx context.Context, check string, decision bool, scores ...float32) {
+	c.metrics.Load().ObserveDecision(check, decision, scores...)
+
+	values := make([]float64, 0, len(scores))
+	for _, score := range scores {
+		values = append(values, float64(score))
+	}
+	attrs := []attribute.KeyValue{attribute.Bool("ekyc."+check+".decision", decision)}
+	if len(values) > 0 {
+		attrs = append(attrs, attribute.Float64Slice("ekyc."+check+".scores", values))
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+}
@@ -23,3 +23,11 @@ func BytesToT64[T int64 | float64](arr []byte) []T {
 	ptr := unsafe.Pointer(&arr[0])
 	return (*[1 << 26]T)(ptr)[:l:l]
 }
+
+// T32ToBytes returns the bytes of arr in memory order without copy, the inverse of BytesToT32.
+func T32ToBytes[T int32 | float32](arr []T) []byte {
+	if len(arr) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&arr[0])), 4*len(arr))
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"slices"
+)
+
+// IoU returns the intersection over union of two [x1, y1, x2, y2] boxes.
+func IoU(a, b [4]float32) float32 {
+	interW := min(a[2], b[2]) - max(a[0], b[0])
+	interH := min(a[3], b[3]) - max(a[1], b[1])
+	if interW <= 0 || interH <= 0 {
+		return 0
+	}
+	inter := interW * interH
+	union := (a[2]-a[0])*(a[3]-a[1]) + (b[2]-b[0])*(b[3]-b[1]) - inter
+	if union <= 0 {
+		return 0
+	}
+	return inter / union
+}
+
+// NMS performs greedy non-maximum suppression on [x1, y1, x2, y2] boxes and returns the indices
+// of the kept boxes sorted by descending score.
+func NMS(boxes [][4]float32, scores []float32, iouThreshold float32) []int {
+	order := make([]int, len(boxes))
+	for idx := range order {
+		order[idx] = idx
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		switch {
+		case scores[i] > scores[j]:
+			return -1
+		case scores[i] < scores[j]:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	keep := make([]int, 0)
+	suppressed := make([]bool, len(boxes))
+	for pos, i := range order {
+		if suppressed[i] {
+			continue
+		}
+		keep = append(keep, i)
+		for _, j := range order[pos+1:] {
+			if !suppressed[j] && IoU(boxes[i], boxes[j]) > iouThreshold {
+				suppressed[j] = true
+			}
+		}
+	}
+	return keep
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIoU(t *testing.T) {
+	assert.InDelta(t, 1, IoU([4]float32{0, 0, 10, 10}, [4]float32{0, 0, 10, 10}), 1e-6)
+	assert.InDelta(t, 25.0/175.0, IoU([4]float32{0, 0, 10, 10}, [4]float32{5, 5, 15, 15}), 1e-6)
+	assert.Equal(t, float32(0), IoU([4]float32{0, 0, 10, 10}, [4]float32{20, 20, 30, 30}))
+}
+
+func TestNMS(t *testing.T) {
+	boxes := [][4]float32{
+		{0, 0, 10, 10},
+		{1, 1, 11, 11},
+		{50, 50, 60, 60},
+		{0, 0, 9, 9},
+	}
+	scores := []float32{0.8, 0.9, 0.7, 0.6}
+
+	keep := NMS(boxes, scores, 0.4)
+	assert.Equal(t, []int{1, 2}, keep)
+}
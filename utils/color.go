@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"gocv.io/x/gocv"
+)
+
+// ColorOrder is the channel order of an 8-bit image.
+type ColorOrder string
+
+const (
+	ColorOrderBGR  ColorOrder = "bgr"
+	ColorOrderRGB  ColorOrder = "rgb"
+	ColorOrderGray ColorOrder = "gray"
+	ColorOrderBGRA ColorOrder = "bgra"
+)
+
+// Channels returns the number of channels of an image in the color order, 0 if the order is unknown.
+func (o ColorOrder) Channels() int {
+	switch o {
+	case ColorOrderGray:
+		return 1
+	case ColorOrderBGR, ColorOrderRGB:
+		return 3
+	case ColorOrderBGRA:
+		return 4
+	default:
+		return 0
+	}
+}
+
+var colorConversions = map[[2]ColorOrder]gocv.ColorConversionCode{
+	{ColorOrderBGR, ColorOrderRGB}:   gocv.ColorBGRToRGB,
+	{ColorOrderBGR, ColorOrderGray}:  gocv.ColorBGRToGray,
+	{ColorOrderBGR, ColorOrderBGRA}:  gocv.ColorBGRToBGRA,
+	{ColorOrderRGB, ColorOrderBGR}:   gocv.ColorRGBToBGR,
+	{ColorOrderRGB, ColorOrderGray}:  gocv.ColorRGBToGray,
+	{ColorOrderRGB, ColorOrderBGRA}:  gocv.ColorRGBToBGRA,
+	{ColorOrderGray, ColorOrderBGR}:  gocv.ColorGrayToBGR,
+	{ColorOrderGray, ColorOrderRGB}:  gocv.ColorGrayToRGB,
+	{ColorOrderGray, ColorOrderBGRA}: gocv.ColorGrayToBGRA,
+	{ColorOrderBGRA, ColorOrderBGR}:  gocv.ColorBGRAToBGR,
+	{ColorOrderBGRA, ColorOrderRGB}:  gocv.ColorBGRAToRGB,
+	{ColorOrderBGRA, ColorOrderGray}: gocv.ColorBGRAToGray,
+}
+
+// ColorConversion returns the OpenCV conversion code from one color order to another.
+func ColorConversion(from, to ColorOrder) (gocv.ColorConversionCode, error) {
+	code, ok := colorConversions[[2]ColorOrder{from, to}]
+	if !ok {
+		return 0, fmt.Errorf("unsupported color conversion from %q to %q", from, to)
+	}
+	return code, nil
+}
+
+// Image is an 8-bit Mat tagged with its color order. The Image owns the Mat.
+type Image struct {
+	Mat   gocv.Mat
+	Order ColorOrder
+}
+
+// NewImage wraps a Mat in the given color order, checking that the Mat has the matching number of channels.
+func NewImage(mat gocv.Mat, order ColorOrder) (Image, error) {
+	if order.Channels() == 0 {
+		return Image{}, fmt.Errorf("unknown color order %q", order)
+	}
+	if mat.Empty() {
+		return Image{}, errors.New("image must not be empty")
+	}
+	if mat.Channels() != order.Channels() {
+		return Image{}, fmt.Errorf("%s image must have %d channels, got %d", order, order.Channels(), mat.Channels())
+	}
+	return Image{Mat: mat, Order: order}, nil
+}
+
+// NewImages wraps Mats in the same color order, see NewImage.
+func NewImages(mats []gocv.Mat, order ColorOrder) ([]Image, error) {
+	images := make([]Image, len(mats))
+	for idx := range mats {
+		img, err := NewImage(mats[idx], order)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", idx, err)
+		}
+		images[idx] = img
+	}
+	return images, nil
+}
+
+// ConvertTo returns a new Image in the given color order. The caller must close it.
+func (img Image) ConvertTo(order ColorOrder) (Image, error) {
+	if img.Order == order {
+		return Image{Mat: img.Mat.Clone(), Order: order}, nil
+	}
+	code, err := ColorConversion(img.Order, order)
+	if err != nil {
+		return Image{}, err
+	}
+	dstMat := gocv.NewMat()
+	gocv.CvtColor(img.Mat, &dstMat, code)
+	return Image{Mat: dstMat, Order: order}, nil
+}
+
+// As returns the Mat of the image in the given color order. The image Mat itself is returned if it is already in
+// that order, otherwise the converted Mat is owned by scope.
+func (img Image) As(order ColorOrder, scope *MatScope) (gocv.Mat, error) {
+	if img.Order == order {
+		return img.Mat, nil
+	}
+	converted, err := img.ConvertTo(order)
+	if err != nil {
+		return gocv.Mat{}, err
+	}
+	scope.Track(converted.Mat)
+	return converted.Mat, nil
+}
+
+// Close closes the Mat of the image.
+func (img Image) Close() error {
+	return img.Mat.Close()
+}
+
+// DecodeImage decodes the image into a BGR Image, rotated upright according to its EXIF orientation.
+func DecodeImage(bImage []byte) (Image, error) {
+	srcMat, err := gocv.IMDecode(bImage, gocv.IMReadColor|gocv.IMReadIgnoreOrientation)
+	if err != nil {
+		return Image{}, err
+	}
+	defer srcMat.Close()
+	if srcMat.Empty() {
+		return Image{}, errors.New("cannot decode input image")
+	}
+
+	orientedMat := ApplyExifOrientation(srcMat, ExifOrientation(bImage))
+	return Image{Mat: orientedMat, Order: ColorOrderBGR}, nil
+}
+
+// EncodeImage encodes the image in the format of the file extension, e.g. ".jpg", converting it to BGR first.
+func EncodeImage(img Image, ext gocv.FileExt) ([]byte, error) {
+	scope := NewMatScope()
+	defer scope.Close()
+
+	order := ColorOrderBGR
+	if img.Order == ColorOrderGray || img.Order == ColorOrderBGRA {
+		order = img.Order
+	}
+	mat, err := img.As(order, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := gocv.IMEncode(ext, mat)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Close()
+
+	return append([]byte(nil), buf.GetBytes()...), nil
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"testing"
+)
+
+// genTestRedPNG returns a PNG of a pure red image, stored in BGR by OpenCV.
+func genTestRedPNG(t *testing.T) []byte {
+	bgr := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(0, 0, 255, 0), 4, 4, gocv.MatTypeCV8UC3)
+	defer bgr.Close()
+
+	buf, err := gocv.IMEncode(gocv.PNGFileExt, bgr)
+	assert.NoError(t, err)
+	defer buf.Close()
+	return append([]byte(nil), buf.GetBytes()...)
+}
+
+func TestColorOrder_Channels(t *testing.T) {
+	assert.Equal(t, 1, ColorOrderGray.Channels())
+	assert.Equal(t, 3, ColorOrderBGR.Channels())
+	assert.Equal(t, 3, ColorOrderRGB.Channels())
+	assert.Equal(t, 4, ColorOrderBGRA.Channels())
+	assert.Equal(t, 0, ColorOrder("yuv").Channels())
+}
+
+func TestColorConversion(t *testing.T) {
+	code, err := ColorConversion(ColorOrderBGR, ColorOrderRGB)
+	assert.NoError(t, err)
+	assert.Equal(t, gocv.ColorBGRToRGB, code)
+
+	code, err = ColorConversion(ColorOrderRGB, ColorOrderGray)
+	assert.NoError(t, err)
+	assert.Equal(t, gocv.ColorRGBToGray, code)
+
+	_, err = ColorConversion(ColorOrderRGB, ColorOrderRGB)
+	assert.Error(t, err)
+	_, err = ColorConversion(ColorOrderRGB, ColorOrder("yuv"))
+	assert.Error(t, err)
+}
+
+func TestNewImage(t *testing.T) {
+	mat := gocv.NewMatWithSize(4, 4, gocv.MatTypeCV8UC3)
+	defer mat.Close()
+
+	img, err := NewImage(mat, ColorOrderRGB)
+	assert.NoError(t, err)
+	assert.Equal(t, ColorOrderRGB, img.Order)
+
+	_, err = NewImage(mat, ColorOrderGray)
+	assert.Error(t, err)
+	_, err = NewImage(mat, ColorOrderBGRA)
+	assert.Error(t, err)
+	_, err = NewImage(gocv.NewMat(), ColorOrderRGB)
+	assert.Error(t, err)
+}
+
+func TestDecodeImage(t *testing.T) {
+	img, err := DecodeImage(genTestRedPNG(t))
+	assert.NoError(t, err)
+	defer img.Close()
+
+	assert.Equal(t, ColorOrderBGR, img.Order)
+	assert.Equal(t, uint8(255), img.Mat.GetVecbAt(0, 0)[2])
+
+	_, err = DecodeImage([]byte("not an image"))
+	assert.Error(t, err)
+}
+
+func TestConvertImageToMat(t *testing.T) {
+	// Red must be in the first channel of the decoded RGB Mat, a second swap would move it back to the last.
+	mat, err := ConvertImageToMat(genTestRedPNG(t))
+	assert.NoError(t, err)
+	defer mat.Close()
+
+	pixel := mat.GetVecbAt(0, 0)
+	assert.Equal(t, []uint8{255, 0, 0}, []uint8{pixel[0], pixel[1], pixel[2]})
+}
+
+func TestImage_As(t *testing.T) {
+	rgb := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(10, 20, 30, 0), 2, 2, gocv.MatTypeCV8UC3)
+	defer rgb.Close()
+	img := Image{Mat: rgb, Order: ColorOrderRGB}
+
+	scope := NewMatScope()
+	defer scope.Close()
+
+	same, err := img.As(ColorOrderRGB, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, rgb.Ptr(), same.Ptr())
+
+	bgr, err := img.As(ColorOrderBGR, scope)
+	assert.NoError(t, err)
+	assert.NotEqual(t, rgb.Ptr(), bgr.Ptr())
+	pixel := bgr.GetVecbAt(0, 0)
+	assert.Equal(t, []uint8{30, 20, 10}, []uint8{pixel[0], pixel[1], pixel[2]})
+
+	// A round trip restores the original channels.
+	back, err := Image{Mat: bgr, Order: ColorOrderBGR}.As(ColorOrderRGB, scope)
+	assert.NoError(t, err)
+	pixel = back.GetVecbAt(0, 0)
+	assert.Equal(t, []uint8{10, 20, 30}, []uint8{pixel[0], pixel[1], pixel[2]})
+
+	gray, err := img.As(ColorOrderGray, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, gray.Channels())
+}
+
+func TestEncodeImage(t *testing.T) {
+	rgb := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(255, 0, 0, 0), 4, 4, gocv.MatTypeCV8UC3)
+	defer rgb.Close()
+
+	bImage, err := EncodeImage(Image{Mat: rgb, Order: ColorOrderRGB}, gocv.PNGFileExt)
+	assert.NoError(t, err)
+
+	bgr, err := gocv.IMDecode(bImage, gocv.IMReadColor)
+	assert.NoError(t, err)
+	defer bgr.Close()
+	assert.Equal(t, uint8(255), bgr.GetVecbAt(0, 0)[2])
+}
@@ -8,15 +8,19 @@ import (
 	"os"
 )
 
+// ConvertImageToMat decodes the image into an RGB Mat, rotated upright according to its EXIF orientation.
 func ConvertImageToMat(bImage []byte) (*gocv.Mat, error) {
-	dstMat := gocv.NewMat()
-	srcMat, err := gocv.IMDecode(bImage, gocv.IMReadColor)
+	img, err := DecodeImage(bImage)
 	if err != nil {
-		return &dstMat, err
+		return nil, err
 	}
+	defer img.Close()
 
-	gocv.CvtColor(srcMat, &dstMat, gocv.ColorBGRToRGB)
-	return &dstMat, nil
+	rgbImage, err := img.ConvertTo(ColorOrderRGB)
+	if err != nil {
+		return nil, err
+	}
+	return &rgbImage.Mat, nil
 }
 
 func TensorToPoints(t *tensor.Dense) ([]gocv.Point2f, error) {
@@ -71,6 +75,7 @@ func AffineMatrixToTensor(mat gocv.Mat) (*tensor.Dense, error) {
 	return t, nil
 }
 
+// OpenCVImageToJPEG writes a BGR Mat to a JPEG file, see EncodeImage for images in other color orders.
 func OpenCVImageToJPEG(fPath string, jpegQuality int, img gocv.Mat) error {
 	outImg, err := img.ToImage()
 	if err != nil {
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"errors"
+	"gocv.io/x/gocv"
+	"image"
+	"math"
+)
+
+// Letterbox defines how an image is resized, keeping its aspect ratio, and padded into a model input.
+// The same geometry is used to build the model input and to map model outputs back to the image.
+type Letterbox struct {
+	ImageW, ImageH     int     // ImageW, ImageH is the original image size.
+	InputW, InputH     int     // InputW, InputH is the model input size.
+	ResizedW, ResizedH int     // ResizedW, ResizedH is the size of the resized image inside the model input.
+	ScaleX, ScaleY     float32 // ScaleX, ScaleY is the input pixels per image pixel along each axis.
+	OffsetX, OffsetY   int     // OffsetX, OffsetY is the padding before the resized image along each axis.
+}
+
+// NewLetterbox computes the letterbox of an image of size imgW x imgH into a model input of size inputW x inputH.
+// The resized image is placed in the top-left corner, or centered if centered is true.
+func NewLetterbox(imgW, imgH, inputW, inputH int, centered bool) Letterbox {
+	scale := math.Min(float64(inputW)/float64(imgW), float64(inputH)/float64(imgH))
+	resizedW := Clip(int(math.Round(float64(imgW)*scale)), 1, inputW)
+	resizedH := Clip(int(math.Round(float64(imgH)*scale)), 1, inputH)
+
+	lb := Letterbox{
+		ImageW:   imgW,
+		ImageH:   imgH,
+		InputW:   inputW,
+		InputH:   inputH,
+		ResizedW: resizedW,
+		ResizedH: resizedH,
+		ScaleX:   float32(resizedW) / float32(imgW),
+		ScaleY:   float32(resizedH) / float32(imgH),
+	}
+	if centered {
+		lb.OffsetX = (inputW - resizedW) / 2
+		lb.OffsetY = (inputH - resizedH) / 2
+	}
+	return lb
+}
+
+// ToInput maps a point from image to model input pixel coordinates.
+func (l Letterbox) ToInput(x, y float32) (float32, float32) {
+	return x*l.ScaleX + float32(l.OffsetX), y*l.ScaleY + float32(l.OffsetY)
+}
+
+// ToImage maps a point from model input to image pixel coordinates.
+func (l Letterbox) ToImage(x, y float32) (float32, float32) {
+	return (x - float32(l.OffsetX)) / l.ScaleX, (y - float32(l.OffsetY)) / l.ScaleY
+}
+
+// BoxToImage maps an [x1, y1, x2, y2] box from model input to image pixel coordinates.
+func (l Letterbox) BoxToImage(box [4]float32) [4]float32 {
+	box[0], box[1] = l.ToImage(box[0], box[1])
+	box[2], box[3] = l.ToImage(box[2], box[3])
+	return box
+}
+
+// PointsToImage maps interleaved (x, y) points from model input to image pixel coordinates in place.
+func (l Letterbox) PointsToImage(points []float32) {
+	for i := 0; i+1 < len(points); i += 2 {
+		points[i], points[i+1] = l.ToImage(points[i], points[i+1])
+	}
+}
+
+// Apply resizes the image into a new model input sized Mat padded with padValue. The caller must close the returned Mat.
+func (l Letterbox) Apply(img gocv.Mat, padValue float64) (gocv.Mat, error) {
+	if img.Empty() || img.Cols() != l.ImageW || img.Rows() != l.ImageH {
+		return gocv.NewMat(), errors.New("image does not match the letterbox size")
+	}
+	padded := gocv.NewMatWithSizesWithScalar([]int{l.InputH, l.InputW}, img.Type(), gocv.NewScalar(padValue, padValue, padValue, 0))
+	roi := padded.Region(image.Rect(l.OffsetX, l.OffsetY, l.OffsetX+l.ResizedW, l.OffsetY+l.ResizedH))
+	defer roi.Close()
+	gocv.Resize(img, &roi, image.Point{X: l.ResizedW, Y: l.ResizedH}, 0, 0, gocv.InterpolationLinear)
+	return padded, nil
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestNewLetterbox(t *testing.T) {
+	lb := NewLetterbox(1280, 720, 640, 640, false)
+	assert.Equal(t, 640, lb.ResizedW)
+	assert.Equal(t, 360, lb.ResizedH)
+	assert.Equal(t, 0, lb.OffsetY)
+
+	lb = NewLetterbox(1280, 720, 640, 640, true)
+	assert.Equal(t, 140, lb.OffsetY)
+	x, y := lb.ToImage(320, 320)
+	assert.InDelta(t, 640, x, 1e-3)
+	assert.InDelta(t, 360, y, 1e-3)
+
+	box := lb.BoxToImage([4]float32{0, 140, 640, 500})
+	for i, v := range []float32{0, 0, 1280, 720} {
+		assert.InDelta(t, v, box[i], 1e-3)
+	}
+}
+
+func TestLetterboxProperties(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	inputSizes := []int{96, 128, 160, 320, 480, 640}
+
+	for range 2000 {
+		imgW, imgH := 1+rng.IntN(4000), 1+rng.IntN(4000)
+		inputW, inputH := inputSizes[rng.IntN(len(inputSizes))], inputSizes[rng.IntN(len(inputSizes))]
+		centered := rng.IntN(2) == 0
+		lb := NewLetterbox(imgW, imgH, inputW, inputH, centered)
+
+		// The resized image fits inside the model input and fills at least one axis up to rounding.
+		assert.GreaterOrEqual(t, lb.OffsetX, 0)
+		assert.GreaterOrEqual(t, lb.OffsetY, 0)
+		assert.LessOrEqual(t, lb.OffsetX+lb.ResizedW, inputW)
+		assert.LessOrEqual(t, lb.OffsetY+lb.ResizedH, inputH)
+		assert.True(t, lb.ResizedW == inputW || lb.ResizedH == inputH, "%+v", lb)
+
+		// Padding is split evenly when centered and only after the image otherwise.
+		if centered {
+			assert.LessOrEqual(t, (inputW-lb.ResizedW)-2*lb.OffsetX, 1)
+			assert.LessOrEqual(t, (inputH-lb.ResizedH)-2*lb.OffsetY, 1)
+		} else {
+			assert.Equal(t, 0, lb.OffsetX)
+			assert.Equal(t, 0, lb.OffsetY)
+		}
+
+		// Image corners map to the corners of the resized region.
+		x0, y0 := lb.ToInput(0, 0)
+		x1, y1 := lb.ToInput(float32(imgW), float32(imgH))
+		assert.InDelta(t, lb.OffsetX, x0, 1e-3)
+		assert.InDelta(t, lb.OffsetY, y0, 1e-3)
+		assert.InDelta(t, lb.OffsetX+lb.ResizedW, x1, 1e-2)
+		assert.InDelta(t, lb.OffsetY+lb.ResizedH, y1, 1e-2)
+
+		// Mapping a point to the input and back is the identity.
+		px, py := rng.Float32()*float32(imgW), rng.Float32()*float32(imgH)
+		ix, iy := lb.ToInput(px, py)
+		rx, ry := lb.ToImage(ix, iy)
+		assert.InDelta(t, px, rx, 1e-3*math.Max(1, float64(imgW)))
+		assert.InDelta(t, py, ry, 1e-3*math.Max(1, float64(imgH)))
+	}
+}
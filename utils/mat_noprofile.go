@@ -0,0 +1,13 @@
+//go:build !matprofile
+
+package utils
+
+// IsMatProfileEnabled determines if Mat allocations are tracked, which requires the matprofile build tag.
+const IsMatProfileEnabled = false
+
+// CountMatLeaks returns the number of Mats allocated by fn that are still open when it returns.
+// Run with `go test -tags matprofile` to track allocations, it always returns 0 otherwise.
+func CountMatLeaks(fn func()) int {
+	fn()
+	return 0
+}
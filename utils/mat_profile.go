@@ -0,0 +1,16 @@
+//go:build matprofile
+
+package utils
+
+import "gocv.io/x/gocv"
+
+// IsMatProfileEnabled determines if Mat allocations are tracked, which requires the matprofile build tag.
+const IsMatProfileEnabled = true
+
+// CountMatLeaks returns the number of Mats allocated by fn that are still open when it returns.
+// Run with `go test -tags matprofile` to track allocations, it always returns 0 otherwise.
+func CountMatLeaks(fn func()) int {
+	before := gocv.MatProfile.Count()
+	fn()
+	return gocv.MatProfile.Count() - before
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"gocv.io/x/gocv"
+)
+
+// MatScope owns the Mats allocated while serving a call and closes them together, typically with a deferred Close.
+// Mats returned to the caller are handed over with Detach.
+type MatScope struct {
+	mats []gocv.Mat
+}
+
+// NewMatScope initializes an empty MatScope.
+func NewMatScope() *MatScope {
+	return &MatScope{}
+}
+
+// Track adds Mats to the scope.
+func (s *MatScope) Track(mats ...gocv.Mat) {
+	s.mats = append(s.mats, mats...)
+}
+
+// NewMat returns a new empty Mat owned by the scope.
+func (s *MatScope) NewMat() gocv.Mat {
+	mat := gocv.NewMat()
+	s.Track(mat)
+	return mat
+}
+
+// Detach hands a Mat over to the caller, it is not closed by the scope anymore.
+func (s *MatScope) Detach(mat gocv.Mat) gocv.Mat {
+	for idx := range s.mats {
+		if s.mats[idx].Ptr() == mat.Ptr() {
+			s.mats = append(s.mats[:idx], s.mats[idx+1:]...)
+			break
+		}
+	}
+	return mat
+}
+
+// Close closes every Mat of the scope and empties it.
+func (s *MatScope) Close() error {
+	var errs []error
+	for idx := range s.mats {
+		if err := s.mats[idx].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.mats = nil
+	return errors.Join(errs...)
+}
+
+// CloseMats closes a slice of Mats owned by the caller.
+func CloseMats(mats []gocv.Mat) error {
+	var errs []error
+	for idx := range mats {
+		if err := mats[idx].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"testing"
+)
+
+func TestMatScope(t *testing.T) {
+	if !IsMatProfileEnabled {
+		t.Skip("run with -tags matprofile to track Mat allocations")
+	}
+
+	var kept gocv.Mat
+	leaked := CountMatLeaks(func() {
+		scope := NewMatScope()
+		defer scope.Close()
+
+		_ = scope.NewMat()
+		scope.Track(gocv.NewMatWithSize(4, 4, gocv.MatTypeCV8U))
+		kept = scope.Detach(scope.NewMat())
+	})
+	// Only the detached Mat is open.
+	assert.Equal(t, 1, leaked)
+	assert.NoError(t, kept.Close())
+
+	leaked = CountMatLeaks(func() {
+		mats := []gocv.Mat{gocv.NewMat(), gocv.NewMat()}
+		assert.NoError(t, CloseMats(mats))
+	})
+	assert.Zero(t, leaked)
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"gorgonia.org/tensor"
+	"math"
 )
 
 func ComputeLinearNorm(t1, t2 *tensor.Dense) (float32, error) {
@@ -40,3 +41,19 @@ func Cross2D(a, b *tensor.Dense) (float32, error) {
 	}
 	return dataA[0]*dataB[1] - dataA[1]*dataB[0], nil
 }
+
+func EuclideanDistance(x1, y1, x2, y2 float32) float32 {
+	dx := float64(x2 - x1)
+	dy := float64(y2 - y1)
+	return float32(math.Sqrt(dx*dx + dy*dy))
+}
+
+func Clip[T int | int32 | int64 | float32 | float64](val, lower, upper T) T {
+	if val < lower {
+		return lower
+	}
+	if val > upper {
+		return upper
+	}
+	return val
+}
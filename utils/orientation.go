@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gocv.io/x/gocv"
+)
+
+// exifOrientationTag is the TIFF tag of the EXIF orientation.
+const exifOrientationTag = 0x0112
+
+// ExifOrientation returns the EXIF orientation (1 to 8) of a JPEG image, or 1 if it is absent or invalid.
+func ExifOrientation(bImage []byte) int {
+	if len(bImage) < 4 || bImage[0] != 0xFF || bImage[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(bImage) {
+		if bImage[pos] != 0xFF {
+			return 1
+		}
+		marker := bImage[pos+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte.
+			pos++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Standalone markers.
+			pos += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// Image data starts, the EXIF segment always comes before.
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(bImage[pos+2:]))
+		if length < 2 || pos+2+length > len(bImage) {
+			return 1
+		}
+		segment := bImage[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of a TIFF header.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	numEntries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < numEntries; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// The orientation is a single SHORT stored in the first bytes of the value field.
+		if order.Uint16(tiff[entry:]) == exifOrientationTag && order.Uint16(tiff[entry+2:]) == 3 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// ApplyExifOrientation returns a new Mat of the image transformed to be displayed upright given its EXIF orientation.
+// The caller must close the returned Mat.
+func ApplyExifOrientation(img gocv.Mat, orientation int) gocv.Mat {
+	dst := gocv.NewMat()
+	switch orientation {
+	case 2:
+		gocv.Flip(img, &dst, 1)
+	case 3:
+		gocv.Rotate(img, &dst, gocv.Rotate180Clockwise)
+	case 4:
+		gocv.Flip(img, &dst, 0)
+	case 5:
+		gocv.Transpose(img, &dst)
+	case 6:
+		gocv.Rotate(img, &dst, gocv.Rotate90Clockwise)
+	case 7:
+		transposed := gocv.NewMat()
+		defer transposed.Close()
+		gocv.Transpose(img, &transposed)
+		gocv.Flip(transposed, &dst, -1)
+	case 8:
+		gocv.Rotate(img, &dst, gocv.Rotate90CounterClockwise)
+	default:
+		img.CopyTo(&dst)
+	}
+	return dst
+}
+
+// RotateImage returns a new Mat of the image rotated clockwise by 90, 180 or 270 degrees.
+// The caller must close the returned Mat.
+func RotateImage(img gocv.Mat, rotation int) (gocv.Mat, error) {
+	dst := gocv.NewMat()
+	switch rotation {
+	case 90:
+		gocv.Rotate(img, &dst, gocv.Rotate90Clockwise)
+	case 180:
+		gocv.Rotate(img, &dst, gocv.Rotate180Clockwise)
+	case 270:
+		gocv.Rotate(img, &dst, gocv.Rotate90CounterClockwise)
+	default:
+		_ = dst.Close()
+		return gocv.NewMat(), fmt.Errorf("unsupported rotation %d, expected 90, 180 or 270", rotation)
+	}
+	return dst, nil
+}
+
+// RotatePoint maps a point of an image of size w x h into the image rotated clockwise by RotateImage.
+func RotatePoint(x, y float32, rotation, w, h int) (float32, float32) {
+	switch rotation {
+	case 90:
+		return float32(h) - y, x
+	case 180:
+		return float32(w) - x, float32(h) - y
+	case 270:
+		return y, float32(w) - x
+	default:
+		return x, y
+	}
+}
+
+// UnrotatePoint maps a point of an image rotated clockwise by RotateImage back to the original image of size w x h.
+func UnrotatePoint(x, y float32, rotation, w, h int) (float32, float32) {
+	switch rotation {
+	case 90:
+		return y, float32(h) - x
+	case 180:
+		return float32(w) - x, float32(h) - y
+	case 270:
+		return float32(w) - y, x
+	default:
+		return x, y
+	}
+}
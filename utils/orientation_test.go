@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"testing"
+)
+
+// genTestExifJPEG returns the header of a JPEG file with an APP0 segment and an EXIF segment holding the orientation.
+func genTestExifJPEG(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(app1)+2))
+	data = append(data, app1...)
+	return append(data, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+func TestExifOrientation(t *testing.T) {
+	assert.Equal(t, 6, ExifOrientation(genTestExifJPEG(binary.LittleEndian, 6)))
+	assert.Equal(t, 8, ExifOrientation(genTestExifJPEG(binary.BigEndian, 8)))
+	assert.Equal(t, 1, ExifOrientation(genTestExifJPEG(binary.BigEndian, 9)))
+	assert.Equal(t, 1, ExifOrientation([]byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}))
+	assert.Equal(t, 1, ExifOrientation([]byte("not a jpeg")))
+}
+
+func TestApplyExifOrientation(t *testing.T) {
+	img := gocv.NewMatWithSize(30, 40, gocv.MatTypeCV8UC1)
+	defer img.Close()
+	img.SetUCharAt(0, 0, 255)
+
+	for orientation, expected := range map[int][2]int{1: {0, 0}, 3: {29, 39}, 6: {0, 29}, 8: {39, 0}, 5: {0, 0}, 7: {39, 29}} {
+		oriented := ApplyExifOrientation(img, orientation)
+		_, _, _, maxLoc := gocv.MinMaxLoc(oriented)
+		assert.Equal(t, expected, [2]int{maxLoc.Y, maxLoc.X}, "orientation %d", orientation)
+		_ = oriented.Close()
+	}
+}
+
+func TestUnrotatePoint(t *testing.T) {
+	w, h := 40, 30
+	img := gocv.NewMatWithSize(h, w, gocv.MatTypeCV8UC1)
+	defer img.Close()
+	img.SetUCharAt(7, 5, 255)
+
+	for _, rotation := range []int{90, 180, 270} {
+		rotated, err := RotateImage(img, rotation)
+		assert.NoError(t, err)
+		_, _, _, maxLoc := gocv.MinMaxLoc(rotated)
+		x, y := UnrotatePoint(float32(maxLoc.X)+0.5, float32(maxLoc.Y)+0.5, rotation, w, h)
+		assert.Equal(t, float32(5.5), x)
+		assert.Equal(t, float32(7.5), y)
+		x, y = RotatePoint(5.5, 7.5, rotation, w, h)
+		assert.Equal(t, float32(maxLoc.X)+0.5, x)
+		assert.Equal(t, float32(maxLoc.Y)+0.5, y)
+		_ = rotated.Close()
+	}
+
+	_, err := RotateImage(img, 45)
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"errors"
+	"gocv.io/x/gocv"
+	"sync"
+)
+
+// ChannelNormalization defines the per-channel normalization (pixel - Mean) * Scale of a model input. Mean and Scale
+// are indexed by output channel, after the optional swap of the first and last channels.
+type ChannelNormalization struct {
+	Mean   [3]float64
+	Scale  [3]float64
+	SwapRB bool
+}
+
+// NewMeanStdNormalization returns the normalization (pixel / 255 - mean) / std of models trained on [0, 1] inputs.
+func NewMeanStdNormalization(mean, std [3]float64, swapRB bool) ChannelNormalization {
+	norm := ChannelNormalization{SwapRB: swapRB}
+	for z := range 3 {
+		norm.Mean[z] = 255 * mean[z]
+		norm.Scale[z] = 1 / (255 * std[z])
+	}
+	return norm
+}
+
+// lookupTable returns the normalized value of every 8-bit pixel value of each output channel.
+func (n ChannelNormalization) lookupTable() [3][256]float32 {
+	var table [3][256]float32
+	for z := range 3 {
+		m, s := float32(n.Mean[z]), float32(n.Scale[z])
+		for v := range 256 {
+			table[z][v] = (float32(v) - m) * s
+		}
+	}
+	return table
+}
+
+// HWCToCHW converts interleaved 3-channel 8-bit pixels to normalized planar channels in a single pass over the pixels.
+// The result is written to dst if it is large enough, a new slice is allocated otherwise.
+func HWCToCHW(pixels []uint8, norm ChannelNormalization, dst []float32) []float32 {
+	plane := len(pixels) / 3
+	if cap(dst) < 3*plane {
+		dst = make([]float32, 3*plane)
+	}
+	dst = dst[:3*plane]
+
+	table := norm.lookupTable()
+	lut0, lut1, lut2 := &table[0], &table[1], &table[2]
+	c0, c1, c2 := dst[:plane], dst[plane:2*plane], dst[2*plane:]
+	if norm.SwapRB {
+		c0, c2 = c2, c0
+		lut0, lut2 = lut2, lut0
+	}
+	pixels = pixels[:3*plane]
+	for i := range c0 {
+		p := pixels[3*i : 3*i+3 : 3*i+3]
+		c0[i] = lut0[p[0]]
+		c1[i] = lut1[p[1]]
+		c2[i] = lut2[p[2]]
+	}
+	return dst
+}
+
+// MatToCHW converts a 3-channel 8-bit Mat to normalized planar channels, see HWCToCHW.
+func MatToCHW(img gocv.Mat, norm ChannelNormalization, dst []float32) ([]float32, error) {
+	if img.Empty() || img.Type() != gocv.MatTypeCV8UC3 {
+		return nil, errors.New("image must be a non-empty 3-channel 8-bit Mat")
+	}
+	if !img.IsContinuous() {
+		continuous := img.Clone()
+		defer continuous.Close()
+		img = continuous
+	}
+	pixels, err := img.DataPtrUint8()
+	if err != nil {
+		return nil, err
+	}
+	return HWCToCHW(pixels, norm, dst), nil
+}
+
+// Float32Pool pools float32 buffers sized for one model input, so that the inputs of models of different sizes do
+// not evict each other's buffers.
+type Float32Pool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewFloat32Pool initializes a Float32Pool allocating buffers of at least size elements.
+func NewFloat32Pool(size int) *Float32Pool {
+	return &Float32Pool{size: max(size, 0)}
+}
+
+// Get returns a float32 slice of length n from the pool. Its content is undefined.
+func (p *Float32Pool) Get(n int) []float32 {
+	if buf, ok := p.pool.Get().(*[]float32); ok && cap(*buf) >= n {
+		return (*buf)[:n]
+	}
+	return make([]float32, n, max(n, p.size))
+}
+
+// Put returns a slice obtained from Get to the pool. It must not be used afterwards.
+func (p *Float32Pool) Put(buf []float32) {
+	if cap(buf) == 0 {
+		return
+	}
+	p.pool.Put(&buf)
+}
+
+var float32Pool = NewFloat32Pool(0)
+
+// GetFloat32s returns a float32 slice of length n from the shared buffer pool. Its content is undefined.
+func GetFloat32s(n int) []float32 {
+	return float32Pool.Get(n)
+}
+
+// PutFloat32s returns a slice obtained from GetFloat32s to the shared buffer pool. It must not be used afterwards.
+func PutFloat32s(buf []float32) {
+	float32Pool.Put(buf)
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gocv.io/x/gocv"
+	"gorgonia.org/tensor"
+	"image"
+	"testing"
+)
+
+func TestHWCToCHW(t *testing.T) {
+	pixels := []uint8{
+		10, 20, 30, 40, 50, 60,
+		70, 80, 90, 100, 110, 120,
+	}
+	norm := ChannelNormalization{Mean: [3]float64{10, 20, 30}, Scale: [3]float64{1, 0.5, 2}}
+
+	chw := HWCToCHW(pixels, norm, nil)
+	assert.Equal(t, []float32{
+		0, 30, 60, 90,
+		0, 15, 30, 45,
+		0, 60, 120, 180,
+	}, chw)
+
+	// Output channels are swapped before normalization and the destination buffer is reused.
+	norm.SwapRB = true
+	dst := make([]float32, 0, 16)
+	chw = HWCToCHW(pixels, norm, dst)
+	assert.Equal(t, []float32{
+		20, 50, 80, 110,
+		0, 15, 30, 45,
+		-40, 20, 80, 140,
+	}, chw)
+	assert.Equal(t, &dst[:1][0], &chw[0])
+
+	norm = NewMeanStdNormalization([3]float64{0.5, 0.5, 0.5}, [3]float64{0.5, 0.5, 0.5}, false)
+	assert.InDelta(t, -1, HWCToCHW([]uint8{0, 0, 0}, norm, nil)[0], 1e-6)
+	assert.InDelta(t, 1, HWCToCHW([]uint8{255, 255, 255}, norm, nil)[2], 1e-6)
+}
+
+func TestMatToCHW(t *testing.T) {
+	img := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(1, 2, 3, 0), 4, 6, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	img.SetUCharAt(1, 3*2, 9)
+
+	// Regions are not continuous.
+	roi := img.Region(image.Rect(2, 1, 4, 3))
+	defer roi.Close()
+	chw, err := MatToCHW(roi, ChannelNormalization{Scale: [3]float64{1, 1, 1}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{9, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3}, chw)
+
+	gray := gocv.NewMatWithSize(4, 4, gocv.MatTypeCV8U)
+	defer gray.Close()
+	_, err = MatToCHW(gray, ChannelNormalization{}, nil)
+	assert.Error(t, err)
+}
+
+// setAtHWCToCHW is the per-pixel GetVecbAt and SetAt conversion the model clients used before MatToCHW.
+func setAtHWCToCHW(img gocv.Mat, norm ChannelNormalization) (*tensor.Dense, error) {
+	imgTensors := tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(img.Rows(), img.Cols(), 3))
+	for z := range 3 {
+		for y := range img.Rows() {
+			for x := range img.Cols() {
+				err := imgTensors.SetAt((float32(img.GetVecbAt(y, x)[z])-float32(norm.Mean[z]))*float32(norm.Scale[z]), y, x, z)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := imgTensors.T(2, 0, 1); err != nil {
+		return nil, err
+	}
+	return imgTensors, imgTensors.Transpose()
+}
+
+// BenchmarkHWCToCHW compares the per-pixel conversion with MatToCHW at the input size of each model client.
+func BenchmarkHWCToCHW(b *testing.B) {
+	norm := ChannelNormalization{Mean: [3]float64{127.5, 127.5, 127.5}, Scale: [3]float64{1 / 127.5, 1 / 127.5, 1 / 127.5}}
+	clients := []struct {
+		name string
+		size int
+	}{
+		{"face_detection", 640},
+		{"face_landmark", 192},
+		{"face_antispoofing", 224},
+		{"face_id", 112},
+		{"face_quality", 112},
+	}
+
+	for _, client := range clients {
+		img := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(30, 120, 210, 0), client.size, client.size, gocv.MatTypeCV8UC3)
+		b.Run(fmt.Sprintf("%s/%dx%d/SetAt", client.name, client.size, client.size), func(b *testing.B) {
+			for range b.N {
+				if _, err := setAtHWCToCHW(img, norm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("%s/%dx%d/MatToCHW", client.name, client.size, client.size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				chw, err := MatToCHW(img, norm, GetFloat32s(3*client.size*client.size))
+				if err != nil {
+					b.Fatal(err)
+				}
+				PutFloat32s(chw)
+			}
+		})
+		_ = img.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"gocv.io/x/gocv"
+	"os"
+)
+
+// ReadVideoFrames decodes the video at fPath and returns every step-th frame converted to RGB,
+// up to maxFrames frames, along with the index of each returned frame in the video.
+func ReadVideoFrames(fPath string, step, maxFrames int) ([]gocv.Mat, []int, error) {
+	if step <= 0 {
+		step = 1
+	}
+
+	capture, err := gocv.VideoCaptureFile(fPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer capture.Close()
+
+	frames := make([]gocv.Mat, 0)
+	indices := make([]int, 0)
+
+	srcMat := gocv.NewMat()
+	defer srcMat.Close()
+
+	for frameIdx := 0; capture.Read(&srcMat); frameIdx++ {
+		if srcMat.Empty() || frameIdx%step != 0 {
+			continue
+		}
+		dstMat := gocv.NewMat()
+		gocv.CvtColor(srcMat, &dstMat, gocv.ColorBGRToRGB)
+		frames = append(frames, dstMat)
+		indices = append(indices, frameIdx)
+
+		if maxFrames > 0 && len(frames) >= maxFrames {
+			break
+		}
+	}
+
+	if len(frames) == 0 {
+		return nil, nil, errors.New("cannot decode any frame from input video")
+	}
+	return frames, indices, nil
+}
+
+// ReadVideoFramesFromBytes decodes the input video buffer and returns every step-th frame converted to RGB,
+// up to maxFrames frames, along with the index of each returned frame in the video.
+func ReadVideoFramesFromBytes(bVideo []byte, step, maxFrames int) ([]gocv.Mat, []int, error) {
+	if len(bVideo) == 0 {
+		return nil, nil, errors.New("input video is empty")
+	}
+
+	// OpenCV can only open video containers from a path, so the buffer is spilled to a temporary file.
+	f, err := os.CreateTemp("", "ekyc-video-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(bVideo)
+	if cErr := f.Close(); cErr != nil && err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ReadVideoFrames(f.Name(), step, maxFrames)
+}